@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
@@ -10,9 +14,16 @@ import (
 	"usdk-backend/internal/handler"
 	"usdk-backend/internal/repository"
 	"usdk-backend/internal/service"
+	"usdk-backend/pkg/archive"
 	"usdk-backend/pkg/database"
+	"usdk-backend/pkg/keyvault"
+	"usdk-backend/pkg/kyc"
 	"usdk-backend/pkg/middleware"
+	"usdk-backend/pkg/pricefeed"
 	"usdk-backend/pkg/riskcontrol"
+	"usdk-backend/pkg/signer"
+	"usdk-backend/pkg/utils"
+	"usdk-backend/pkg/wallet"
 )
 
 func main() {
@@ -32,6 +43,7 @@ func main() {
 	// Initialize repositories
 	db := database.GetDB()
 	userRepo := repository.NewUserRepository(db)
+	adminUserRepo := repository.NewAdminUserRepository(db)
 	chainRepo := repository.NewChainRepository(db)
 	assetRepo := repository.NewAssetRepository(db)
 	chainAssetRepo := repository.NewChainAssetRepository(db)
@@ -42,25 +54,218 @@ func main() {
 	proofBatchRepo := repository.NewProofBatchRepository(db)
 	riskConfigRepo := repository.NewRiskConfigRepository(db)
 	blacklistRepo := repository.NewBlacklistRepository(db)
+	whitelistRepo := repository.NewWithdrawalWhitelistRepository(db)
+	userKycRepo := repository.NewUserKycRepository(db)
+	onchainTxRepo := repository.NewOnchainTxRepository(db)
+	chainSyncStateRepo := repository.NewChainSyncStateRepository(db)
+	chainBlockRepo := repository.NewChainBlockRepository(db)
+	outgoingTxRepo := repository.NewOutgoingTxRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
 
 	// Initialize logger
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 
 	// Initialize services
-	riskService := riskcontrol.NewRiskService(userRepo, withdrawRequestRepo, ledgerRepo, riskConfigRepo, blacklistRepo, logger)
+	priceFeedService := pricefeed.NewPriceFeedService(cfg.PriceFeed.CoingeckoAPIKey, logger)
+	riskService := riskcontrol.NewRiskService(userRepo, withdrawRequestRepo, ledgerRepo, riskConfigRepo, blacklistRepo, whitelistRepo, userKycRepo, priceFeedService, logger)
 	metaService := service.NewMetaService(chainRepo, assetRepo, chainAssetRepo)
 	userService := service.NewUserService(userRepo)
-	walletService := service.NewWalletService(userRepo, chainRepo, assetRepo, depositAddressRepo, withdrawRequestRepo, riskService)
+	keyVault, err := loadKeyVault(cfg.Wallet)
+	if err != nil {
+		log.Fatalf("Invalid wallet encryption keys: %v", err)
+	}
+	var depositKeyService *service.DepositKeyService
+	if keyVault != nil {
+		depositKeyService = service.NewDepositKeyService(depositAddressRepo, auditLogRepo, keyVault, logger)
+		go func() {
+			rotated, err := depositKeyService.RotateKeys()
+			if err != nil {
+				logger.WithError(err).Error("Failed to re-wrap deposit keys")
+			}
+			if rotated > 0 {
+				logger.WithField("count", rotated).Info("Deposit keys re-wrapped with the current KEK")
+			}
+		}()
+	} else {
+		log.Println("Warning: WALLET_ENCRYPTION_KEY not set, random deposit addresses are disabled")
+	}
 	portfolioService := service.NewPortfolioService(ledgerRepo, platformMetricsRepo, chainRepo, assetRepo)
-	recordsService := service.NewRecordsService(ledgerRepo)
-	proofsService := service.NewProofsService(proofBatchRepo)
-	
+	var archiveStore archive.BlobStore
+	localStore, err := archive.NewLocalStore(cfg.Archive.Dir)
+	if err != nil {
+		log.Printf("Warning: Batch archive not available: %v", err)
+	} else {
+		archiveStore = localStore
+	}
+	proofsService := service.NewProofsService(proofBatchRepo, archiveStore)
+	kycProvider, err := kyc.NewProvider(cfg.KYC.Provider, cfg.KYC.WebhookSecret)
+	if err != nil {
+		log.Printf("Warning: KYC provider not available: %v", err)
+		kycProvider = nil
+	}
+	kycService := service.NewKycService(userKycRepo, kycProvider, logger)
+	whitelistService := service.NewWhitelistService(whitelistRepo, chainRepo, userRepo, cfg.Platform.WhitelistCoolingOffHours)
+	adminAuthService := service.NewAdminAuthService(adminUserRepo)
+	adminWithdrawService := service.NewAdminWithdrawService(withdrawRequestRepo, chainRepo)
+
+	// Seed the first admin account if configured
+	if cfg.Admin.BootstrapUsername != "" && cfg.Admin.BootstrapPassword != "" {
+		created, err := adminAuthService.EnsureAdmin(cfg.Admin.BootstrapUsername, cfg.Admin.BootstrapPassword, cfg.Admin.BootstrapRole)
+		if err != nil {
+			log.Fatalf("Failed to bootstrap admin account: %v", err)
+		}
+		if created {
+			log.Printf("Created admin account %s with role %s", cfg.Admin.BootstrapUsername, cfg.Admin.BootstrapRole)
+		}
+	}
+
+	// Load transaction signers, one account per role
+	var hdWallet *wallet.HDWalletService
+	if cfg.Wallet.HDMnemonic != "" {
+		hdWallet, err = wallet.NewHDWalletService(cfg.Wallet.HDMnemonic)
+		if err != nil {
+			log.Printf("Warning: HD wallet not available for signers: %v", err)
+		}
+	}
+	signers := make(map[string]signer.Signer)
+	for _, role := range signer.Roles() {
+		roleSigner, err := signer.New(context.Background(), cfg.Blockchain.Signers[role], hdWallet)
+		if err != nil {
+			log.Fatalf("Failed to load %s signer: %v", role, err)
+		}
+		if roleSigner == nil {
+			log.Printf("Warning: No %s signer configured", role)
+			continue
+		}
+		signers[role] = roleSigner
+	}
+	if err := signer.CheckDistinct(signers); err != nil {
+		log.Fatalf("Invalid signer configuration: %v", err)
+	}
+
 	// Initialize blockchain service
-	blockchainService, err := service.NewBlockchainService()
+	chainRegistry, err := service.NewChainRegistry(chainRepo, cfg.Blockchain, logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize chain registry: %v", err)
+	}
+	chainClients := chainRegistry.Clients()
+	walletService, err := service.NewWalletService(
+		userRepo, chainRepo, assetRepo, depositAddressRepo, withdrawRequestRepo, riskService, keyVault,
+		service.NewWithdrawAddressValidator(depositAddressRepo, chainAssetRepo, chainClients), priceFeedService,
+	)
 	if err != nil {
-		log.Printf("Warning: Failed to initialize blockchain service: %v", err)
-		log.Println("Blockchain endpoints will not be available")
+		log.Fatalf("Failed to initialize wallet: %v", err)
+	}
+	go func() {
+		checked, err := walletService.VerifyDepositAddresses()
+		if err != nil {
+			logger.WithError(err).Error("HD deposit addresses do not match the configured wallet")
+			return
+		}
+		if checked > 0 {
+			logger.WithField("count", checked).Info("HD deposit addresses verified")
+		}
+	}()
+	txManager := service.NewTxManagerService(outgoingTxRepo, chainClients, cfg.Tx, cfg.Platform, logger)
+	var blockchainService *service.BlockchainService
+	if len(chainRegistry.Chains()) > 0 {
+		blockchainService = service.NewBlockchainService(chainRegistry, txManager, cfg.Blockchain.ProofChain)
+		for _, role := range []string{signer.RoleMinter, signer.RoleTreasury} {
+			if roleSigner, ok := signers[role]; ok {
+				blockchainService.SetSigner(role, roleSigner)
+			}
+		}
+	} else {
+		log.Println("Warning: No chain RPC available, blockchain endpoints will not be available")
+	}
+	recordsService := service.NewRecordsService(ledgerRepo, proofBatchRepo, blockchainService)
+
+	// Start background workers
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+
+	depositScanner := service.NewDepositScannerService(
+		chainRepo, chainAssetRepo, depositAddressRepo, onchainTxRepo, chainSyncStateRepo, chainBlockRepo,
+		chainClients, priceFeedService, cfg.Platform, logger,
+	)
+	var gasStation common.Address
+	if gasStationSigner, ok := signers[signer.RoleGasStation]; ok {
+		gasStation = txManager.AddSigner(gasStationSigner)
+		// Gas top-ups of deposit addresses are not user deposits
+		depositScanner.IgnoreSender(gasStation)
+	}
+	go depositScanner.Run(workerCtx)
+
+	treasury := parseTreasuryAddresses(cfg.Blockchain.TreasuryAddresses)
+	if treasurySigner, ok := signers[signer.RoleTreasury]; ok {
+		hotWallet := txManager.AddSigner(treasurySigner)
+		treasury = append(treasury, hotWallet)
+		withdrawalExecutor := service.NewWithdrawalExecutorService(
+			withdrawRequestRepo, chainAssetRepo, onchainTxRepo,
+			chainClients, txManager, hotWallet, priceFeedService, cfg.Platform, logger,
+		)
+		go withdrawalExecutor.Run(workerCtx)
+	} else {
+		log.Println("Warning: No treasury signer, withdrawals will not be executed")
+	}
+
+	if oracleSigner, ok := signers[signer.RoleOracle]; ok {
+		var archiveWriter *archive.Writer
+		if archiveStore != nil {
+			archiveWriter, err = archive.NewWriter(archiveStore, cfg.Archive.Salt)
+			if err != nil {
+				log.Printf("Warning: Proof batches will be published without an archive: %v", err)
+			}
+		}
+		proofPublisher := service.NewProofPublisherService(
+			proofBatchRepo, ledgerRepo, chainRepo,
+			chainClients, txManager, txManager.AddSigner(oracleSigner), archiveWriter, cfg.Blockchain, cfg.Platform, logger,
+		)
+		go proofPublisher.Run(workerCtx)
+	} else {
+		log.Println("Warning: No oracle signer, proof batches will not be published")
+	}
+
+	// Deposit addresses sign their own sweeps, so the sweeper needs their key
+	// material. A watch-only server holds none and must not load it from
+	// HD_MNEMONIC: sweeping is then left to the separate signing component.
+	if cfg.Wallet.WalletType == "watch" {
+		if cfg.Sweep.Enabled {
+			log.Println("Warning: SWEEP_ENABLED is ignored with WALLET_TYPE=watch, run the sweeper where the deposit keys are held")
+		}
+	} else {
+		txManager.SetSignerSource(service.NewDepositSignerSource(depositAddressRepo, hdWallet, keyVault))
+		if cfg.Sweep.Enabled {
+			sweeper := service.NewDepositSweeperService(
+				chainRepo, chainAssetRepo, depositAddressRepo, onchainTxRepo, outgoingTxRepo,
+				chainClients, txManager, gasStation, priceFeedService, cfg.Sweep, logger,
+			)
+			// Swept funds still back USDK
+			for _, address := range sweeper.Treasuries() {
+				treasury = append(treasury, address)
+			}
+			if cfg.Sweep.DryRun {
+				log.Println("Sweeper running in dry-run mode, no transactions will be sent")
+			}
+			go sweeper.Run(workerCtx)
+		}
+	}
+
+	if len(chainClients) > 0 {
+		go txManager.Run(workerCtx)
+
+		reservesService := service.NewReservesService(
+			chainRepo, chainAssetRepo, onchainTxRepo, ledgerRepo, proofBatchRepo,
+			chainClients, treasury, priceFeedService, cfg.Platform, logger,
+		)
+		go reservesService.Run(workerCtx)
+
+		proofIndexer := service.NewProofIndexerService(
+			proofBatchRepo, chainRepo, chainSyncStateRepo,
+			chainClients, cfg.Blockchain, cfg.Platform, logger,
+		)
+		go proofIndexer.Run(workerCtx)
 	}
 
 	// Initialize handlers
@@ -71,6 +276,13 @@ func main() {
 	portfolioHandler := handler.NewPortfolioHandler(portfolioService)
 	recordsHandler := handler.NewRecordsHandler(recordsService)
 	proofsHandler := handler.NewProofsHandler(proofsService)
+	whitelistHandler := handler.NewWhitelistHandler(whitelistService)
+	kycHandler := handler.NewKycHandler(kycService)
+	adminHandler := handler.NewAdminHandler(adminAuthService, adminWithdrawService)
+	var depositKeyHandler *handler.DepositKeyHandler
+	if depositKeyService != nil {
+		depositKeyHandler = handler.NewDepositKeyHandler(depositKeyService)
+	}
 	
 	// Initialize blockchain handler (only if service is available)
 	var blockchainHandler *handler.BlockchainHandler
@@ -93,6 +305,10 @@ func main() {
 	api.GET("/auth/nonce", nonceHandler.GetNonce)
 	api.POST("/user/login-siwe", userHandler.LoginSIWE)
 	api.GET("/proofs/latest", proofsHandler.GetLatestProofs)
+	api.GET("/proofs/reserves", proofsHandler.GetLatestReserves)
+	api.GET("/proofs/batches/:id/leaves", proofsHandler.GetBatchLeaves)
+	api.GET("/proofs/archive/:cid", proofsHandler.GetArchiveDocument)
+	api.POST("/kyc/webhook", kycHandler.Webhook)
 
 	// Protected routes (require authentication)
 	protected := api.Group("/")
@@ -105,11 +321,41 @@ func main() {
 		protected.GET("/wallet/deposit-address", walletHandler.GetDepositAddress)
 		protected.POST("/withdraw", walletHandler.Withdraw)
 
+		// Withdrawal whitelist routes
+		protected.GET("/wallet/whitelist", whitelistHandler.GetWhitelist)
+		protected.POST("/wallet/whitelist", whitelistHandler.AddAddress)
+		protected.PUT("/wallet/whitelist/mode", whitelistHandler.SetMode)
+		protected.PUT("/wallet/whitelist/:id", whitelistHandler.UpdateLabel)
+		protected.DELETE("/wallet/whitelist/:id", whitelistHandler.RemoveAddress)
+
+		// KYC routes
+		protected.GET("/kyc/status", kycHandler.GetStatus)
+		protected.POST("/kyc/start", kycHandler.StartVerification)
+
 		// Portfolio routes
 		protected.GET("/portfolio/overview", portfolioHandler.GetOverview)
 
 		// Records routes
 		protected.GET("/records", recordsHandler.GetRecords)
+		protected.GET("/records/:id/proof", recordsHandler.GetRecordProof)
+	}
+
+	// Admin routes (require an admin token, each route checks its permission)
+	api.POST("/admin/auth/login", adminHandler.Login)
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminJWTAuthMiddleware(adminAuthService.CurrentRole))
+	{
+		// Withdrawal review queue
+		admin.GET("/withdrawals", middleware.RequirePermission(utils.PermWithdrawRead), adminHandler.ListWithdrawals)
+		admin.POST("/withdrawals/:id/approve", middleware.RequirePermission(utils.PermWithdrawReview), adminHandler.ApproveWithdrawal)
+		admin.POST("/withdrawals/:id/reject", middleware.RequirePermission(utils.PermWithdrawReview), adminHandler.RejectWithdrawal)
+		admin.POST("/withdrawals/:id/notes", middleware.RequirePermission(utils.PermWithdrawNotes), adminHandler.AddWithdrawalNotes)
+
+		// Stored deposit keys (audited)
+		if depositKeyHandler != nil {
+			admin.POST("/deposit-addresses/:id/private-key", middleware.RequirePermission(utils.PermKeyDecrypt), depositKeyHandler.DecryptDepositKey)
+		}
 	}
 
 	// Blockchain routes (only if blockchain service is available)
@@ -122,10 +368,12 @@ func main() {
 			blockchain.GET("/token/blacklisted/:address", blockchainHandler.IsBlacklisted)
 			blockchain.GET("/token/paused", blockchainHandler.IsPaused)
 			
-			// Transaction endpoints (require private key configuration)
-			blockchain.POST("/token/transfer", blockchainHandler.Transfer)
-			blockchain.POST("/token/mint", blockchainHandler.Mint)
-			blockchain.POST("/token/burn", blockchainHandler.Burn)
+			// Transaction endpoints (require the minter/treasury signer and a treasury admin)
+			tokenWrite := blockchain.Group("/token")
+			tokenWrite.Use(middleware.AdminJWTAuthMiddleware(adminAuthService.CurrentRole), middleware.RequirePermission(utils.PermTokenWrite))
+			tokenWrite.POST("/transfer", blockchainHandler.Transfer)
+			tokenWrite.POST("/mint", blockchainHandler.Mint)
+			tokenWrite.POST("/burn", blockchainHandler.Burn)
 			
 			// ProofRegistry endpoints
 			blockchain.GET("/proofs/batch/:batchId", blockchainHandler.GetProofBatch)
@@ -152,4 +400,39 @@ func main() {
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
-}
\ No newline at end of file
+}
+
+// parseTreasuryAddresses parses the TREASURY_ADDRESSES setting, skipping invalid entries
+func parseTreasuryAddresses(values []string) []common.Address {
+	var addresses []common.Address
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !common.IsHexAddress(value) {
+			log.Printf("Warning: Ignoring invalid treasury address %q", value)
+			continue
+		}
+		addresses = append(addresses, common.HexToAddress(value))
+	}
+	return addresses
+}
+
+// loadKeyVault builds the vault sealing deposit private keys from
+// WALLET_ENCRYPTION_KEYS, or from WALLET_ENCRYPTION_KEY as KEK version 1.
+// It returns nil when neither is set.
+func loadKeyVault(walletCfg config.WalletConfig) (*keyvault.Vault, error) {
+	keks, err := keyvault.ParseKeys(walletCfg.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keks) == 0 {
+		if walletCfg.EncryptionKey == "" {
+			return nil, nil
+		}
+		kek, err := keyvault.DecodeKey(walletCfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("WALLET_ENCRYPTION_KEY: %v", err)
+		}
+		keks[1] = kek
+	}
+	return keyvault.NewVault(keks, uint32(walletCfg.EncryptionKeyVersion))
+}
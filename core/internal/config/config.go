@@ -18,6 +18,12 @@ type Config struct {
 	Platform   PlatformConfig
 	Log        LogConfig
 	Wallet     WalletConfig
+	PriceFeed  PriceFeedConfig
+	Admin      AdminConfig
+	KYC        KYCConfig
+	Archive    ArchiveConfig
+	Tx         TxConfig
+	Sweep      SweepConfig
 }
 
 type DatabaseConfig struct {
@@ -34,8 +40,9 @@ type ServerConfig struct {
 }
 
 type JWTConfig struct {
-	Secret      string
-	ExpireHours int
+	Secret           string
+	ExpireHours      int
+	AdminExpireHours int
 }
 
 type RedisConfig struct {
@@ -52,7 +59,28 @@ type BlockchainConfig struct {
 	PolygonRPC  string
 	SepoliaRPC  string
 
+	ProofChain        string   // chain key proof batches are published on
+	TreasuryAddresses []string // platform addresses counted as reserves besides deposit addresses
+
 	Contracts map[string]ContractAddresses
+	Signers   map[string]SignerConfig // keyed by role: minter, oracle, treasury, gas_station
+}
+
+// RPCURL returns the <CHAIN>_RPC_URL setting of a chain key
+func (c BlockchainConfig) RPCURL(chainKey string) string {
+	switch chainKey {
+	case "ethereum":
+		return c.EthereumRPC
+	case "arbitrum":
+		return c.ArbitrumRPC
+	case "optimism":
+		return c.OptimismRPC
+	case "polygon":
+		return c.PolygonRPC
+	case "sepolia":
+		return c.SepoliaRPC
+	}
+	return ""
 }
 
 type ContractAddresses struct {
@@ -60,13 +88,32 @@ type ContractAddresses struct {
 	ProofRegistry string
 }
 
+// SignerConfig selects how a role signs transactions
+type SignerConfig struct {
+	Type string // keystore, hd, remote or key; empty disables the role
+
+	KeystorePath     string
+	KeystorePassword string
+	HDPath           string
+	RemoteURL        string
+	RemoteAuthToken  string
+	Address          string // account the remote signer signs with
+	PrivateKey       string // hex key, for development only
+}
+
 type PlatformConfig struct {
-	TargetAPY             float64
-	MinDepositKUSD        float64
-	MaxDailyWithdrawal    float64
-	WithdrawalFeeRate     float64
-	ConfirmationBlocks    int
-	ProofBatchIntervalSec int
+	TargetAPY                    float64
+	MinDepositKUSD               float64
+	MaxDailyWithdrawal           float64
+	WithdrawalFeeRate            float64
+	ConfirmationBlocks           int
+	ProofBatchIntervalSec        int
+	DepositScanIntervalSec       int
+	WithdrawalProcessIntervalSec int
+	WhitelistCoolingOffHours     int
+	ReservesSnapshotIntervalSec  int
+	ProofIndexIntervalSec        int
+	ProofIndexStartBlock         uint64 // block the ProofRegistry was deployed at
 }
 
 type LogConfig struct {
@@ -75,8 +122,55 @@ type LogConfig struct {
 
 type WalletConfig struct {
 	HDMnemonic    string
-	WalletType    string // "hd" or "random"
-	EncryptionKey string // for encrypting private keys
+	WalletType    string // "hd", "watch" or "random"
+	EncryptionKey string // for encrypting private keys, KEK version 1 when EncryptionKeys is empty
+
+	EncryptionKeys       string // "<version>:<key>,..." key-encryption keys, 32 bytes hex or base64
+	EncryptionKeyVersion int    // KEK version new private keys are sealed with
+
+	AccountXpubs string // "<account>:<xpub>,..." account keys deriving deposit addresses in watch mode
+}
+
+type PriceFeedConfig struct {
+	CoingeckoAPIKey string
+}
+
+// AdminConfig seeds the first admin account when it does not exist yet
+type AdminConfig struct {
+	BootstrapUsername string
+	BootstrapPassword string
+	BootstrapRole     string
+}
+
+type KYCConfig struct {
+	Provider      string // "fake" for local development and tests
+	WebhookSecret string
+}
+
+// ArchiveConfig is where proof batch detail documents are stored
+type ArchiveConfig struct {
+	Dir  string
+	Salt string // secret used to anonymise user IDs in archived batches
+}
+
+// TxConfig drives fee estimation and replacement of outgoing transactions
+type TxConfig struct {
+	MaxFeeGwei         float64 // cap on maxFeePerGas
+	MaxPriorityFeeGwei float64 // cap on maxPriorityFeePerGas
+	FeeBumpPercent     int     // fee increase of a replacement, at least 10
+	StuckTimeoutSec    int     // time without a receipt before a tx is replaced
+	ProcessIntervalSec int
+}
+
+// SweepConfig controls moving deposit address balances to the treasury
+type SweepConfig struct {
+	Enabled         bool
+	DryRun          bool    // log the planned top-ups and sweeps without sending them
+	IntervalSec     int
+	MinUSD          float64 // smallest balance worth sweeping
+	MaxSweepsPerRun int
+	MaxTopUpsPerRun int
+	Treasuries      map[string]string // keyed by chain key, chains without one are not swept
 }
 
 var AppConfig *Config
@@ -100,8 +194,9 @@ func LoadConfig() *Config {
 			GinMode: getEnv("GIN_MODE", "debug"),
 		},
 		JWT: JWTConfig{
-			Secret:      getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
-			ExpireHours: getEnvAsInt("JWT_EXPIRE_HOURS", 24),
+			Secret:           getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
+			ExpireHours:      getEnvAsInt("JWT_EXPIRE_HOURS", 24),
+			AdminExpireHours: getEnvAsInt("ADMIN_JWT_EXPIRE_HOURS", 8),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -115,6 +210,10 @@ func LoadConfig() *Config {
 			OptimismRPC: getEnv("OPTIMISM_RPC_URL", ""),
 			PolygonRPC:  getEnv("POLYGON_RPC_URL", ""),
 			SepoliaRPC:  getEnv("SEPOLIA_RPC_URL", "https://sepolia.infura.io/v3/dMKelTD27GwK0QXzeqUUCnsGm4/SgZKpRx/V8yTVNNsO7lOSZQI9Xw"),
+
+			ProofChain:        getEnv("PROOF_CHAIN", "ethereum"),
+			TreasuryAddresses: getEnvAsSlice("TREASURY_ADDRESSES", nil, ","),
+
 			Contracts: map[string]ContractAddresses{
 				"ethereum": {
 					USDK:          getEnv("USDK_CONTRACT_ETHEREUM", ""),
@@ -133,14 +232,27 @@ func LoadConfig() *Config {
 					ProofRegistry: getEnv("PROOF_REGISTRY_SEPOLIA", ""),
 				},
 			},
+			Signers: map[string]SignerConfig{
+				"minter":   getSignerConfig("MINTER", ""),
+				"oracle":   getSignerConfig("ORACLE", getEnv("ORACLE_PRIVATE_KEY", "")),
+				"treasury": getSignerConfig("TREASURY", getEnv("HOT_WALLET_PRIVATE_KEY", "")),
+
+				"gas_station": getSignerConfig("GAS_STATION", ""),
+			},
 		},
 		Platform: PlatformConfig{
-			TargetAPY:             getEnvAsFloat("TARGET_APY", 0.20),
-			MinDepositKUSD:        getEnvAsFloat("MIN_DEPOSIT_KUSD", 10.0),
-			MaxDailyWithdrawal:    getEnvAsFloat("MAX_DAILY_WITHDRAWAL", 50000.0),
-			WithdrawalFeeRate:     getEnvAsFloat("WITHDRAWAL_FEE_RATE", 0.001),
-			ConfirmationBlocks:    getEnvAsInt("CONFIRMATION_BLOCKS", 12),
-			ProofBatchIntervalSec: getEnvAsInt("PROOF_BATCH_INTERVAL", 86400),
+			TargetAPY:                    getEnvAsFloat("TARGET_APY", 0.20),
+			MinDepositKUSD:               getEnvAsFloat("MIN_DEPOSIT_KUSD", 10.0),
+			MaxDailyWithdrawal:           getEnvAsFloat("MAX_DAILY_WITHDRAWAL", 50000.0),
+			WithdrawalFeeRate:            getEnvAsFloat("WITHDRAWAL_FEE_RATE", 0.001),
+			ConfirmationBlocks:           getEnvAsInt("CONFIRMATION_BLOCKS", 12),
+			ProofBatchIntervalSec:        getEnvAsInt("PROOF_BATCH_INTERVAL", 86400),
+			DepositScanIntervalSec:       getEnvAsInt("DEPOSIT_SCAN_INTERVAL", 15),
+			WithdrawalProcessIntervalSec: getEnvAsInt("WITHDRAWAL_PROCESS_INTERVAL", 30),
+			WhitelistCoolingOffHours:     getEnvAsInt("WHITELIST_COOLING_OFF_HOURS", 24),
+			ReservesSnapshotIntervalSec:  getEnvAsInt("RESERVES_SNAPSHOT_INTERVAL", 3600),
+			ProofIndexIntervalSec:        getEnvAsInt("PROOF_INDEX_INTERVAL", 60),
+			ProofIndexStartBlock:         uint64(getEnvAsInt("PROOF_INDEX_START_BLOCK", 0)),
 		},
 		Log: LogConfig{
 			Level: getEnv("LOG_LEVEL", "info"),
@@ -149,6 +261,49 @@ func LoadConfig() *Config {
 			HDMnemonic:    getEnv("HD_MNEMONIC", ""),
 			WalletType:    getEnv("WALLET_TYPE", "hd"),
 			EncryptionKey: getEnv("WALLET_ENCRYPTION_KEY", ""),
+
+			EncryptionKeys:       getEnv("WALLET_ENCRYPTION_KEYS", ""),
+			EncryptionKeyVersion: getEnvAsInt("WALLET_ENCRYPTION_KEY_VERSION", 1),
+
+			AccountXpubs: getEnv("HD_ACCOUNT_XPUBS", ""),
+		},
+		PriceFeed: PriceFeedConfig{
+			CoingeckoAPIKey: getEnv("COINGECKO_API_KEY", ""),
+		},
+		Admin: AdminConfig{
+			BootstrapUsername: getEnv("ADMIN_BOOTSTRAP_USERNAME", ""),
+			BootstrapPassword: getEnv("ADMIN_BOOTSTRAP_PASSWORD", ""),
+			BootstrapRole:     getEnv("ADMIN_BOOTSTRAP_ROLE", "operator"),
+		},
+		KYC: KYCConfig{
+			Provider:      getEnv("KYC_PROVIDER", "fake"),
+			WebhookSecret: getEnv("KYC_WEBHOOK_SECRET", ""),
+		},
+		Archive: ArchiveConfig{
+			Dir:  getEnv("ARCHIVE_DIR", "./data/archive"),
+			Salt: getEnv("ARCHIVE_SALT", ""),
+		},
+		Tx: TxConfig{
+			MaxFeeGwei:         getEnvAsFloat("TX_MAX_FEE_GWEI", 300),
+			MaxPriorityFeeGwei: getEnvAsFloat("TX_MAX_PRIORITY_FEE_GWEI", 3),
+			FeeBumpPercent:     getEnvAsInt("TX_FEE_BUMP_PERCENT", 20),
+			StuckTimeoutSec:    getEnvAsInt("TX_STUCK_TIMEOUT", 300),
+			ProcessIntervalSec: getEnvAsInt("TX_PROCESS_INTERVAL", 15),
+		},
+		Sweep: SweepConfig{
+			Enabled:         getEnvAsBool("SWEEP_ENABLED", false),
+			DryRun:          getEnvAsBool("SWEEP_DRY_RUN", true),
+			IntervalSec:     getEnvAsInt("SWEEP_INTERVAL", 3600),
+			MinUSD:          getEnvAsFloat("SWEEP_MIN_USD", 100),
+			MaxSweepsPerRun: getEnvAsInt("SWEEP_MAX_PER_RUN", 50),
+			MaxTopUpsPerRun: getEnvAsInt("SWEEP_MAX_TOP_UPS_PER_RUN", 20),
+			Treasuries: map[string]string{
+				"ethereum": getEnv("SWEEP_TREASURY_ETHEREUM", ""),
+				"arbitrum": getEnv("SWEEP_TREASURY_ARBITRUM", ""),
+				"optimism": getEnv("SWEEP_TREASURY_OPTIMISM", ""),
+				"polygon":  getEnv("SWEEP_TREASURY_POLYGON", ""),
+				"sepolia":  getEnv("SWEEP_TREASURY_SEPOLIA", ""),
+			},
 		},
 	}
 
@@ -156,6 +311,27 @@ func LoadConfig() *Config {
 	return config
 }
 
+// getSignerConfig reads the SIGNER_<ROLE>_* settings of a role. A role that
+// only has legacyKey set signs with that raw key.
+func getSignerConfig(role, legacyKey string) SignerConfig {
+	prefix := "SIGNER_" + role + "_"
+	defaultType := ""
+	if legacyKey != "" {
+		defaultType = "key"
+	}
+
+	return SignerConfig{
+		Type:             getEnv(prefix+"TYPE", defaultType),
+		KeystorePath:     getEnv(prefix+"KEYSTORE", ""),
+		KeystorePassword: getEnv(prefix+"KEYSTORE_PASSWORD", ""),
+		HDPath:           getEnv(prefix+"HD_PATH", ""),
+		RemoteURL:        getEnv(prefix+"REMOTE_URL", ""),
+		RemoteAuthToken:  getEnv(prefix+"REMOTE_TOKEN", ""),
+		Address:          getEnv(prefix+"ADDRESS", ""),
+		PrivateKey:       getEnv(prefix+"PRIVATE_KEY", legacyKey),
+	}
+}
+
 func getEnv(key, defaultVal string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
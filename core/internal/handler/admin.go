@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/service"
+	"usdk-backend/pkg/utils"
+)
+
+type AdminHandler struct {
+	adminAuthService     *service.AdminAuthService
+	adminWithdrawService *service.AdminWithdrawService
+}
+
+func NewAdminHandler(adminAuthService *service.AdminAuthService, adminWithdrawService *service.AdminWithdrawService) *AdminHandler {
+	return &AdminHandler{
+		adminAuthService:     adminAuthService,
+		adminWithdrawService: adminWithdrawService,
+	}
+}
+
+type AdminLoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type AdminLoginResponse struct {
+	Token string                     `json:"token"`
+	Admin *service.AdminInfoResponse `json:"admin"`
+}
+
+// Login godoc
+// @Summary Admin login
+// @Description Authenticate an admin with username and password and return an admin token
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param request body AdminLoginRequest true "Admin credentials"
+// @Success 200 {object} utils.Response{data=AdminLoginResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/admin/auth/login [post]
+func (h *AdminHandler) Login(c *gin.Context) {
+	var req AdminLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+		return
+	}
+
+	token, adminInfo, err := h.adminAuthService.Login(req.Username, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(AdminLoginResponse{
+		Token: token,
+		Admin: adminInfo,
+	}))
+}
+
+type ReviewWithdrawRequest struct {
+	Notes string `json:"notes"`
+}
+
+// ListWithdrawals godoc
+// @Summary List withdrawal requests
+// @Description List withdrawal requests for the admin review queue
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Status (pending, pending_review, approved, rejected, processing, completed, failed)"
+// @Param userId query int false "User ID"
+// @Param chain query string false "Chain key (e.g. ethereum, arbitrum)"
+// @Param minRiskScore query number false "Minimum risk score"
+// @Param maxRiskScore query number false "Maximum risk score"
+// @Param page query int false "Page number (default: 1)"
+// @Param pageSize query int false "Page size (default: 20, max: 100)"
+// @Success 200 {object} utils.PaginatedResponse{data=[]model.WithdrawRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/v1/admin/withdrawals [get]
+func (h *AdminHandler) ListWithdrawals(c *gin.Context) {
+	query := service.WithdrawListQuery{
+		Status:       c.Query("status"),
+		ChainKey:     c.Query("chain"),
+		MinRiskScore: c.Query("minRiskScore"),
+		MaxRiskScore: c.Query("maxRiskScore"),
+	}
+
+	if userIDStr := c.Query("userId"); userIDStr != "" {
+		userID, err := strconv.ParseUint(userIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid userId"))
+			return
+		}
+		query.UserID = userID
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+	query.Page = page
+	query.PageSize = pageSize
+
+	requests, total, err := h.adminWithdrawService.ListWithdrawRequests(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.PaginatedSuccessResponse(requests, total, page, pageSize))
+}
+
+// ApproveWithdrawal godoc
+// @Summary Approve withdrawal request
+// @Description Approve a withdrawal request held for manual review
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Withdrawal request ID"
+// @Param request body ReviewWithdrawRequest false "Admin notes"
+// @Success 200 {object} utils.Response{data=model.WithdrawRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/v1/admin/withdrawals/{id}/approve [post]
+func (h *AdminHandler) ApproveWithdrawal(c *gin.Context) {
+	h.reviewWithdrawal(c, h.adminWithdrawService.ApproveWithdrawRequest)
+}
+
+// RejectWithdrawal godoc
+// @Summary Reject withdrawal request
+// @Description Reject a withdrawal request that has not been broadcast yet
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Withdrawal request ID"
+// @Param request body ReviewWithdrawRequest false "Admin notes"
+// @Success 200 {object} utils.Response{data=model.WithdrawRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/v1/admin/withdrawals/{id}/reject [post]
+func (h *AdminHandler) RejectWithdrawal(c *gin.Context) {
+	h.reviewWithdrawal(c, h.adminWithdrawService.RejectWithdrawRequest)
+}
+
+// AddWithdrawalNotes godoc
+// @Summary Add admin notes to withdrawal request
+// @Description Append admin notes to a withdrawal request without changing its status
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Withdrawal request ID"
+// @Param request body ReviewWithdrawRequest true "Admin notes"
+// @Success 200 {object} utils.Response{data=model.WithdrawRequest}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/v1/admin/withdrawals/{id}/notes [post]
+func (h *AdminHandler) AddWithdrawalNotes(c *gin.Context) {
+	h.reviewWithdrawal(c, h.adminWithdrawService.AddWithdrawNotes)
+}
+
+func (h *AdminHandler) reviewWithdrawal(c *gin.Context, action func(uint64, string, service.AuditContext) (*model.WithdrawRequest, error)) {
+	adminID, exists := c.Get("admin_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: admin_id not found in context"))
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid withdrawal request ID"))
+		return
+	}
+
+	var req ReviewWithdrawRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+			return
+		}
+	}
+
+	audit := service.AuditContext{
+		AdminID:   adminID.(uint64),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	request, err := action(id, req.Notes, audit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(request))
+}
@@ -3,10 +3,10 @@ package handler
 import (
 	"math/big"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"usdk-backend/internal/service"
+	"usdk-backend/pkg/contracts"
 	"usdk-backend/pkg/utils"
 )
 
@@ -37,7 +37,12 @@ func NewBlockchainHandler(blockchainService *service.BlockchainService) *Blockch
 // USDK Token Endpoints
 
 func (h *BlockchainHandler) GetTokenInfo(c *gin.Context) {
-	tokenInfo, err := h.blockchainService.GetTokenInfo()
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
+	tokenInfo, err := h.blockchainService.GetTokenInfo(chain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -47,13 +52,18 @@ func (h *BlockchainHandler) GetTokenInfo(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) GetBalance(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	address := c.Param("address")
 	if address == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Address parameter is required"))
 		return
 	}
 
-	balanceInfo, err := h.blockchainService.GetBalance(address)
+	balanceInfo, err := h.blockchainService.GetBalance(chain, address)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -63,13 +73,18 @@ func (h *BlockchainHandler) GetBalance(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) IsBlacklisted(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	address := c.Param("address")
 	if address == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Address parameter is required"))
 		return
 	}
 
-	isBlacklisted, err := h.blockchainService.IsBlacklisted(address)
+	isBlacklisted, err := h.blockchainService.IsBlacklisted(chain, address)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -82,7 +97,12 @@ func (h *BlockchainHandler) IsBlacklisted(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) IsPaused(c *gin.Context) {
-	isPaused, err := h.blockchainService.IsPaused()
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
+	isPaused, err := h.blockchainService.IsPaused(chain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -94,6 +114,11 @@ func (h *BlockchainHandler) IsPaused(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) Transfer(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	var req TransferRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
@@ -106,7 +131,7 @@ func (h *BlockchainHandler) Transfer(c *gin.Context) {
 		return
 	}
 
-	result, err := h.blockchainService.Transfer(req.To, amount)
+	result, err := h.blockchainService.Transfer(chain, req.To, amount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -116,6 +141,11 @@ func (h *BlockchainHandler) Transfer(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) Mint(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	var req MintRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
@@ -128,7 +158,7 @@ func (h *BlockchainHandler) Mint(c *gin.Context) {
 		return
 	}
 
-	result, err := h.blockchainService.Mint(req.To, amount)
+	result, err := h.blockchainService.Mint(chain, req.To, amount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -138,6 +168,11 @@ func (h *BlockchainHandler) Mint(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) Burn(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	var req BurnRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
@@ -150,7 +185,7 @@ func (h *BlockchainHandler) Burn(c *gin.Context) {
 		return
 	}
 
-	result, err := h.blockchainService.Burn(amount)
+	result, err := h.blockchainService.Burn(chain, amount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -162,6 +197,11 @@ func (h *BlockchainHandler) Burn(c *gin.Context) {
 // ProofRegistry Endpoints
 
 func (h *BlockchainHandler) GetProofBatch(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	batchIdStr := c.Param("batchId")
 	if batchIdStr == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Batch ID parameter is required"))
@@ -174,7 +214,7 @@ func (h *BlockchainHandler) GetProofBatch(c *gin.Context) {
 		return
 	}
 
-	batch, err := h.blockchainService.GetProofBatch(batchId)
+	batch, err := h.blockchainService.GetProofBatch(chain, batchId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -184,7 +224,12 @@ func (h *BlockchainHandler) GetProofBatch(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) GetBatchCount(c *gin.Context) {
-	count, err := h.blockchainService.GetBatchCount()
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.blockchainService.GetBatchCount(chain)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -196,40 +241,50 @@ func (h *BlockchainHandler) GetBatchCount(c *gin.Context) {
 }
 
 func (h *BlockchainHandler) GetBatchCountByType(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	batchTypeStr := c.Param("batchType")
 	if batchTypeStr == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Batch type parameter is required"))
 		return
 	}
 
-	batchType, err := strconv.ParseUint(batchTypeStr, 10, 8)
+	batchType, err := contracts.ParseOnchainBatchType(batchTypeStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid batch type format"))
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
 		return
 	}
 
-	count, err := h.blockchainService.GetBatchCountByType(uint8(batchType))
+	count, err := h.blockchainService.GetBatchCountByType(chain, batchType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(map[string]interface{}{
-		"batchType":    batchType,
+		"batchType":    batchType.String(),
 		"totalBatches": count.String(),
 	}))
 }
 
 func (h *BlockchainHandler) GetBatchesByType(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	batchTypeStr := c.Param("batchType")
 	if batchTypeStr == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Batch type parameter is required"))
 		return
 	}
 
-	batchType, err := strconv.ParseUint(batchTypeStr, 10, 8)
+	batchType, err := contracts.ParseOnchainBatchType(batchTypeStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid batch type format"))
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
 		return
 	}
 
@@ -249,7 +304,7 @@ func (h *BlockchainHandler) GetBatchesByType(c *gin.Context) {
 		return
 	}
 
-	batches, err := h.blockchainService.GetBatchesByType(uint8(batchType), offset, limit)
+	batches, err := h.blockchainService.GetBatchesByType(chain, batchType, offset, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
 		return
@@ -262,7 +317,7 @@ func (h *BlockchainHandler) GetBatchesByType(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(map[string]interface{}{
-		"batchType": batchType,
+		"batchType": batchType.String(),
 		"offset":    offset.String(),
 		"limit":     limit.String(),
 		"batchIds":  batchIds,
@@ -272,13 +327,18 @@ func (h *BlockchainHandler) GetBatchesByType(c *gin.Context) {
 // Utility Endpoints
 
 func (h *BlockchainHandler) GetTransactionStatus(c *gin.Context) {
+	chain, ok := h.chainParam(c)
+	if !ok {
+		return
+	}
+
 	txHash := c.Param("hash")
 	if txHash == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Transaction hash parameter is required"))
 		return
 	}
 
-	status, err := h.blockchainService.GetTransactionStatus(txHash)
+	status, err := h.blockchainService.GetTransactionStatus(chain, txHash)
 	if err != nil {
 		c.JSON(http.StatusNotFound, utils.ErrorResponse(err.Error()))
 		return
@@ -287,16 +347,43 @@ func (h *BlockchainHandler) GetTransactionStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, utils.SuccessResponse(status))
 }
 
+// HealthCheck reports the RPC status of every chain, or of the chain given
+// by the chain parameter
 func (h *BlockchainHandler) HealthCheck(c *gin.Context) {
-	err := h.blockchainService.HealthCheck()
-	if err != nil {
-		c.JSON(http.StatusServiceUnavailable, utils.ErrorResponse("Blockchain connection failed"))
-		return
-	}
+	health := h.blockchainService.HealthCheck(c.Request.Context())
+	if chain := c.Query("chain"); chain != "" {
+		var selected []service.ChainHealth
+		for _, chainHealth := range health {
+			if chainHealth.Chain == chain {
+				selected = append(selected, chainHealth)
+			}
+		}
+		if len(selected) == 0 {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Unknown chain: "+chain))
+			return
+		}
+		health = selected
+	}
+
+	for _, chainHealth := range health {
+		if !chainHealth.Healthy {
+			response := utils.ErrorResponse("Blockchain connection failed")
+			response.Data = health
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(health))
+}
 
-	c.JSON(http.StatusOK, utils.SuccessResponse(map[string]interface{}{
-		"status":    "healthy",
-		"network":   "sepolia",
-		"timestamp": strconv.FormatInt(c.Request.Context().Value("timestamp").(int64), 10),
-	}))
-}
\ No newline at end of file
+// chainParam returns the chain query parameter, an empty value selecting the
+// default chain. It replies 400 when the chain is not available.
+func (h *BlockchainHandler) chainParam(c *gin.Context) (string, bool) {
+	chain := c.Query("chain")
+	if chain != "" && !h.blockchainService.HasChain(chain) {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Unknown chain: "+chain))
+		return "", false
+	}
+	return chain, true
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/internal/service"
+	"usdk-backend/pkg/database"
+	"usdk-backend/pkg/utils"
+)
+
+func TestBlockchainRoutesSelectChainByParameter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	previousDB := database.DB
+	database.DB = db
+	err = database.AutoMigrate()
+	database.DB = previousDB
+	if err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	for _, chain := range []*model.Chain{
+		{ChainKey: "ethereum", NetworkID: 1, Name: "Ethereum", Enabled: true},
+		{ChainKey: "sepolia", NetworkID: 11155111, Name: "Sepolia", Enabled: true},
+	} {
+		if err := db.Create(chain).Error; err != nil {
+			t.Fatalf("failed to seed chain %s: %v", chain.ChainKey, err)
+		}
+	}
+
+	// Nothing listens on these RPC URLs, so every chain is unhealthy
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+	registry, err := service.NewChainRegistry(repository.NewChainRepository(db), config.BlockchainConfig{
+		EthereumRPC: "http://127.0.0.1:1",
+		SepoliaRPC:  "http://127.0.0.1:1",
+	}, log)
+	if err != nil {
+		t.Fatalf("failed to create chain registry: %v", err)
+	}
+	handler := NewBlockchainHandler(service.NewBlockchainService(registry, nil, "sepolia"))
+
+	router := gin.New()
+	router.GET("/token/info", handler.GetTokenInfo)
+	router.GET("/proofs/batch-count", handler.GetBatchCount)
+	router.GET("/health", handler.HealthCheck)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		error  string
+		chains []string
+	}{
+		{name: "default chain", target: "/token/info", status: http.StatusInternalServerError, error: "no USDK contract configured for chain sepolia"},
+		{name: "selected chain", target: "/token/info?chain=ethereum", status: http.StatusInternalServerError, error: "no USDK contract configured for chain ethereum"},
+		{name: "unknown chain", target: "/proofs/batch-count?chain=solana", status: http.StatusBadRequest, error: "Unknown chain: solana"},
+		{name: "health of every chain", target: "/health", status: http.StatusServiceUnavailable, error: "Blockchain connection failed", chains: []string{"ethereum", "sepolia"}},
+		{name: "health of one chain", target: "/health?chain=ethereum", status: http.StatusServiceUnavailable, error: "Blockchain connection failed", chains: []string{"ethereum"}},
+		{name: "health of unknown chain", target: "/health?chain=solana", status: http.StatusBadRequest, error: "Unknown chain: solana"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			var response struct {
+				utils.Response
+				Data []service.ChainHealth `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid response %s: %v", rec.Body.String(), err)
+			}
+			if rec.Code != tt.status || response.Success || !strings.Contains(response.Error, tt.error) {
+				t.Fatalf("got %d %s, want %d with %q", rec.Code, rec.Body.String(), tt.status, tt.error)
+			}
+
+			var chains []string
+			for _, health := range response.Data {
+				if health.Healthy || health.Error == "" {
+					t.Fatalf("got health %+v, want the chain reported down", health)
+				}
+				chains = append(chains, health.Chain)
+			}
+			if strings.Join(chains, ",") != strings.Join(tt.chains, ",") {
+				t.Fatalf("got health of %v, want %v", chains, tt.chains)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"usdk-backend/internal/service"
+	"usdk-backend/pkg/utils"
+)
+
+type DepositKeyHandler struct {
+	depositKeyService *service.DepositKeyService
+}
+
+func NewDepositKeyHandler(depositKeyService *service.DepositKeyService) *DepositKeyHandler {
+	return &DepositKeyHandler{
+		depositKeyService: depositKeyService,
+	}
+}
+
+type DecryptDepositKeyRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// DecryptDepositKey godoc
+// @Summary Decrypt a deposit address private key
+// @Description Return the stored private key of a random deposit address, e.g. to recover funds. Every call is recorded in the audit log with its reason.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Deposit address ID"
+// @Param request body DecryptDepositKeyRequest true "Reason for the access"
+// @Success 200 {object} utils.Response{data=service.DecryptedKeyResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/v1/admin/deposit-addresses/{id}/private-key [post]
+func (h *DepositKeyHandler) DecryptDepositKey(c *gin.Context) {
+	adminID, exists := c.Get("admin_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: admin_id not found in context"))
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid deposit address ID"))
+		return
+	}
+
+	var req DecryptDepositKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+		return
+	}
+
+	audit := service.AuditContext{
+		AdminID:   adminID.(uint64),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	key, err := h.depositKeyService.DecryptPrivateKey(id, req.Reason, audit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, utils.SuccessResponse(key))
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"usdk-backend/internal/service"
+	"usdk-backend/pkg/utils"
+)
+
+type KycHandler struct {
+	kycService *service.KycService
+}
+
+func NewKycHandler(kycService *service.KycService) *KycHandler {
+	return &KycHandler{
+		kycService: kycService,
+	}
+}
+
+type StartKycRequest struct {
+	Level int `json:"level" binding:"required"`
+}
+
+// GetStatus godoc
+// @Summary Get KYC status
+// @Description Get the user's approved KYC level and the status of the latest verification
+// @Tags KYC
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=service.KycStatusResponse}
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/kyc/status [get]
+func (h *KycHandler) GetStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	status, err := h.kycService.GetStatus(userID.(uint64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(status))
+}
+
+// StartVerification godoc
+// @Summary Start KYC verification
+// @Description Start a verification with the KYC provider for the requested level
+// @Tags KYC
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body StartKycRequest true "Requested KYC level (1 basic, 2 full)"
+// @Success 200 {object} utils.Response{data=service.KycStartResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/kyc/start [post]
+func (h *KycHandler) StartVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	var req StartKycRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+		return
+	}
+
+	response, err := h.kycService.StartVerification(userID.(uint64), req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(response))
+}
+
+// Webhook godoc
+// @Summary KYC provider webhook
+// @Description Callback used by the KYC provider to report verification results
+// @Tags KYC
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/kyc/webhook [post]
+func (h *KycHandler) Webhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Failed to read request body"))
+		return
+	}
+
+	if err := h.kycService.HandleWebhook(body, c.Request.Header); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(nil))
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
@@ -37,4 +38,80 @@ func (h *ProofsHandler) GetLatestProofs(c *gin.Context) {
 	c.JSON(http.StatusOK, utils.SuccessResponse(gin.H{
 		"batches": proofs,
 	}))
-}
\ No newline at end of file
+}
+
+// GetLatestReserves godoc
+// @Summary Get latest proof of reserves
+// @Description Get the latest reserves snapshot: platform holdings per chain and asset, liabilities and the coverage ratio
+// @Tags Proofs
+// @Accept json
+// @Produce json
+// @Success 200 {object} utils.Response{data=service.ReservesResponse}
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/proofs/reserves [get]
+func (h *ProofsHandler) GetLatestReserves(c *gin.Context) {
+	reserves, err := h.proofsService.GetLatestReserves()
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(reserves))
+}
+
+// GetBatchLeaves godoc
+// @Summary Get archived batch leaves
+// @Description Get a page of the archived leaves of a proof batch, with user IDs anonymised
+// @Tags Proofs
+// @Accept json
+// @Produce json
+// @Param id path int true "Proof batch ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param pageSize query int false "Page size (default: 100, max: 1000)"
+// @Success 200 {object} utils.PaginatedResponse{data=service.BatchLeavesResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/proofs/batches/{id}/leaves [get]
+func (h *ProofsHandler) GetBatchLeaves(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid batch ID"))
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "100"))
+	if err != nil || pageSize <= 0 || pageSize > 1000 {
+		pageSize = 100
+	}
+
+	leaves, total, err := h.proofsService.GetBatchLeaves(id, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.PaginatedSuccessResponse(leaves, total, page, pageSize))
+}
+
+// GetArchiveDocument godoc
+// @Summary Get batch archive document
+// @Description Get the raw archive document of a proof batch by the CID in its on-chain URI
+// @Tags Proofs
+// @Produce json
+// @Param cid path string true "Document CID"
+// @Success 200 {object} archive.Document
+// @Failure 404 {object} utils.Response
+// @Router /api/v1/proofs/archive/{cid} [get]
+func (h *ProofsHandler) GetArchiveDocument(c *gin.Context) {
+	data, err := h.proofsService.GetArchiveDocument(c.Param("cid"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", data)
+}
@@ -56,4 +56,41 @@ func (h *RecordsHandler) GetRecords(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse(records))
-}
\ No newline at end of file
+}
+
+// GetRecordProof godoc
+// @Summary Get record inclusion proof
+// @Description Get the merkle proof that a record is included in its published proof batch, to check against ProofRegistry.verifyProof
+// @Tags Records
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Record ID"
+// @Param verify query bool false "Also verify the proof on-chain"
+// @Success 200 {object} utils.Response{data=service.RecordProofResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/records/{id}/proof [get]
+func (h *RecordsHandler) GetRecordProof(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid record ID"))
+		return
+	}
+
+	verify, _ := strconv.ParseBool(c.Query("verify"))
+
+	proof, err := h.recordsService.GetRecordProof(userID.(uint64), id, verify)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(proof))
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -65,7 +66,7 @@ type WithdrawRequest struct {
 
 // Withdraw godoc
 // @Summary Submit withdrawal request
-// @Description Submit a withdrawal request for review and processing
+// @Description Submit a withdrawal request for review and processing. A rejected destination address returns a code: invalid_address, invalid_address_checksum, deposit_address_destination or contract_address_destination.
 // @Tags Wallet
 // @Accept json
 // @Produce json
@@ -89,12 +90,18 @@ func (h *WalletHandler) Withdraw(c *gin.Context) {
 	}
 
 	response, err := h.walletService.SubmitWithdrawRequest(
+		c.Request.Context(),
 		userID.(uint64),
 		req.Chain,
 		req.Asset,
 		req.Amount,
 		req.ToAddress,
 	)
+	var addressErr *service.AddressError
+	if errors.As(err, &addressErr) {
+		c.JSON(http.StatusBadRequest, utils.ErrorCodeResponse(addressErr.Code, addressErr.Message))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
 		return
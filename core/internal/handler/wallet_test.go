@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/internal/service"
+	"usdk-backend/pkg/database"
+	"usdk-backend/pkg/utils"
+)
+
+func TestWithdrawReturnsAddressErrorCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	previousDB := database.DB
+	database.DB = db
+	err = database.AutoMigrate()
+	database.DB = previousDB
+	if err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	chain := &model.Chain{ChainKey: "ethereum", NetworkID: 1, Name: "Ethereum", Enabled: true}
+	asset := &model.Asset{Symbol: "ETH", Name: "Ether", Decimals: 18, AssetType: "eth", Enabled: true}
+	user := &model.User{}
+	for _, record := range []interface{}{chain, asset, user} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed %T: %v", record, err)
+		}
+	}
+	deposit := &model.DepositAddress{UserID: user.ID, ChainID: chain.ID, AssetID: asset.ID, Address: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}
+	if err := db.Create(deposit).Error; err != nil {
+		t.Fatalf("failed to seed deposit address: %v", err)
+	}
+
+	depositAddressRepo := repository.NewDepositAddressRepository(db)
+	walletService, err := service.NewWalletService(
+		repository.NewUserRepository(db), repository.NewChainRepository(db), repository.NewAssetRepository(db),
+		depositAddressRepo, repository.NewWithdrawRequestRepository(db), nil, nil,
+		service.NewWithdrawAddressValidator(depositAddressRepo, repository.NewChainAssetRepository(db), nil), nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create wallet service: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/withdraw", func(c *gin.Context) { c.Set("user_id", user.ID) }, NewWalletHandler(walletService).Withdraw)
+
+	tests := []struct {
+		name      string
+		toAddress string
+		code      string
+	}{
+		{name: "malformed", toAddress: "0x1234", code: service.AddressErrInvalid},
+		{name: "bad checksum", toAddress: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", code: service.AddressErrChecksum},
+		{name: "deposit address", toAddress: deposit.Address, code: service.AddressErrDepositAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"chain":"ethereum","asset":"ETH","amount":"1","toAddress":"` + tt.toAddress + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/withdraw", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			var response utils.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid response %s: %v", rec.Body.String(), err)
+			}
+			if rec.Code != http.StatusBadRequest || response.Success || response.Code != tt.code || response.Error == "" {
+				t.Fatalf("got %d %s, want 400 with code %s", rec.Code, rec.Body.String(), tt.code)
+			}
+		})
+	}
+}
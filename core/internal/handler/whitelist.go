@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"usdk-backend/internal/service"
+	"usdk-backend/pkg/utils"
+)
+
+type WhitelistHandler struct {
+	whitelistService *service.WhitelistService
+}
+
+func NewWhitelistHandler(whitelistService *service.WhitelistService) *WhitelistHandler {
+	return &WhitelistHandler{
+		whitelistService: whitelistService,
+	}
+}
+
+type AddWhitelistRequest struct {
+	Chain   string  `json:"chain" binding:"required"`
+	Address string  `json:"address" binding:"required"`
+	Label   *string `json:"label"`
+}
+
+type UpdateWhitelistLabelRequest struct {
+	Label *string `json:"label"`
+}
+
+type WhitelistModeRequest struct {
+	WhitelistOnly *bool `json:"whitelistOnly" binding:"required"`
+}
+
+// GetWhitelist godoc
+// @Summary Get withdrawal whitelist
+// @Description Get the user's whitelisted withdrawal addresses and whether whitelist-only mode is on
+// @Tags Whitelist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=service.WhitelistResponse}
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/wallet/whitelist [get]
+func (h *WhitelistHandler) GetWhitelist(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	whitelist, err := h.whitelistService.GetWhitelist(userID.(uint64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(whitelist))
+}
+
+// AddAddress godoc
+// @Summary Add whitelisted address
+// @Description Whitelist a withdrawal address; it becomes usable after the cooling-off period
+// @Tags Whitelist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body AddWhitelistRequest true "Address to whitelist"
+// @Success 200 {object} utils.Response{data=service.WhitelistEntryResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/wallet/whitelist [post]
+func (h *WhitelistHandler) AddAddress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	var req AddWhitelistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+		return
+	}
+
+	entry, err := h.whitelistService.AddAddress(userID.(uint64), req.Chain, req.Address, req.Label)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(entry))
+}
+
+// UpdateLabel godoc
+// @Summary Update whitelisted address label
+// @Description Change the label of a whitelisted withdrawal address
+// @Tags Whitelist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Whitelist entry ID"
+// @Param request body UpdateWhitelistLabelRequest true "New label"
+// @Success 200 {object} utils.Response{data=service.WhitelistEntryResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/wallet/whitelist/{id} [put]
+func (h *WhitelistHandler) UpdateLabel(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid whitelist entry ID"))
+		return
+	}
+
+	var req UpdateWhitelistLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+		return
+	}
+
+	entry, err := h.whitelistService.UpdateLabel(userID.(uint64), id, req.Label)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse(entry))
+}
+
+// RemoveAddress godoc
+// @Summary Remove whitelisted address
+// @Description Remove a withdrawal address from the whitelist
+// @Tags Whitelist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Whitelist entry ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/wallet/whitelist/{id} [delete]
+func (h *WhitelistHandler) RemoveAddress(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid whitelist entry ID"))
+		return
+	}
+
+	if err := h.whitelistService.RemoveAddress(userID.(uint64), id); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessWithMessageResponse("Address removed from whitelist", nil))
+}
+
+// SetMode godoc
+// @Summary Set whitelist-only mode
+// @Description Turn whitelist-only mode on or off; when on, withdrawals to other addresses are rejected. Turning it on applies at once, turning it off only after the cooling-off period (whitelistOffAt)
+// @Tags Whitelist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body WhitelistModeRequest true "Whitelist mode"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/v1/wallet/whitelist/mode [put]
+func (h *WhitelistHandler) SetMode(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authentication required: user_id not found in context"))
+		return
+	}
+
+	var req WhitelistModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request parameters"))
+		return
+	}
+
+	offAt, err := h.whitelistService.SetWhitelistOnly(userID.(uint64), *req.WhitelistOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	if offAt != nil {
+		c.JSON(http.StatusOK, utils.SuccessResponse(gin.H{"whitelistOnly": true, "whitelistOffAt": offAt}))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse(gin.H{"whitelistOnly": *req.WhitelistOnly}))
+}
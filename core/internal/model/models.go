@@ -5,23 +5,34 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
+
+	"usdk-backend/pkg/contracts"
 )
 
 // User 用户表
 type User struct {
-	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
-	WalletAddr *string   `json:"walletAddr" gorm:"uniqueIndex;size:128"`
-	Email      *string   `json:"email" gorm:"size:128"`
-	Nonce      *string   `json:"nonce" gorm:"size:64"` // for SIWE login
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID             uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	WalletAddr     *string    `json:"walletAddr" gorm:"uniqueIndex;size:128"`
+	Email          *string    `json:"email" gorm:"size:128"`
+	Nonce          *string    `json:"nonce" gorm:"size:64"`               // for SIWE login
+	WhitelistOnly  bool       `json:"whitelistOnly" gorm:"default:false"` // only allow withdrawals to whitelisted addresses
+	WhitelistOffAt *time.Time `json:"whitelistOffAt"`                     // turning whitelist-only off takes effect at this time
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// WhitelistOnlyAt reports whether whitelist-only mode applies at t, which it
+// still does while turning it off is cooling off
+func (u *User) WhitelistOnlyAt(t time.Time) bool {
+	return u.WhitelistOnly && (u.WhitelistOffAt == nil || t.Before(*u.WhitelistOffAt))
 }
 
 // Chain 支持的区块链
 type Chain struct {
 	ID            uint64  `json:"id" gorm:"primaryKey;autoIncrement"`
 	ChainKey      string  `json:"chainKey" gorm:"uniqueIndex;size:32;not null"` // ethereum, arbitrum
-	ChainID       uint64  `json:"chainId" gorm:"not null"`                      // 1, 42161
+	NetworkID     uint64  `json:"chainId" gorm:"column:chain_id;not null"`      // 1, 42161; a ChainID field would turn every Chain relation into has-one
+	Family        string  `json:"family" gorm:"size:16;not null;default:evm"`   // evm, bitcoin, bitcoin_testnet, tron
 	Name          string  `json:"name" gorm:"size:64;not null"`
 	RpcURL        *string `json:"rpcUrl" gorm:"size:256"`
 	ExplorerBase  *string `json:"explorerBase" gorm:"size:128"`
@@ -61,8 +72,8 @@ type DepositAddress struct {
 	ChainID        uint64    `json:"chainId" gorm:"not null;uniqueIndex:idx_user_chain_asset"`
 	AssetID        uint64    `json:"assetId" gorm:"not null;uniqueIndex:idx_user_chain_asset"`
 	Address        string    `json:"address" gorm:"uniqueIndex;size:128;not null"`
-	DerivationPath *string   `json:"derivationPath" gorm:"size:128"` // HD/MPC metadata
-	PrivateKeyRef  *string   `json:"privateKeyRef" gorm:"size:256"`  // encrypted or KMS reference
+	DerivationPath *string   `json:"derivationPath" gorm:"size:128"` // m/{purpose}'/{coin}'/{100+chain}'/{asset}/{index} by chain family, legacy m/44'/60'/0'/0/{user}
+	PrivateKeyRef  *string   `json:"-" gorm:"size:256"`              // sealed by keyvault (kek<version>:...) or KMS reference
 	IsActive       bool      `json:"isActive" gorm:"default:true"`
 	CreatedAt      time.Time `json:"createdAt"`
 	User           User      `json:"user" gorm:"foreignKey:UserID"`
@@ -70,18 +81,29 @@ type DepositAddress struct {
 	Asset          Asset     `json:"asset" gorm:"foreignKey:AssetID"`
 }
 
+// DepositAddressCounter HD充值地址索引分配
+type DepositAddressCounter struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainID   uint64    `json:"chainId" gorm:"not null;uniqueIndex:idx_counter_chain_asset"`
+	AssetID   uint64    `json:"assetId" gorm:"not null;uniqueIndex:idx_counter_chain_asset"`
+	NextIndex uint32    `json:"nextIndex" gorm:"not null;default:0"` // next unused address index
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
 // OnchainTx 链上交易记录
 type OnchainTx struct {
 	ID            uint64           `json:"id" gorm:"primaryKey;autoIncrement"`
-	Direction     string           `json:"direction" gorm:"size:8;not null"` // in, out
+	Direction     string           `json:"direction" gorm:"size:8;not null"` // in, out, sweep
 	UserID        *uint64          `json:"userId"`
 	ChainID       uint64           `json:"chainId" gorm:"not null"`
 	AssetID       uint64           `json:"assetId" gorm:"not null"`
 	FromAddr      *string          `json:"fromAddr" gorm:"size:128"`
 	ToAddr        string           `json:"toAddr" gorm:"size:128;not null"`
-	TxHash        string           `json:"txHash" gorm:"uniqueIndex;size:128;not null"`
+	TxHash        string           `json:"txHash" gorm:"uniqueIndex:idx_tx_log;size:128;not null"`
+	LogIndex      int              `json:"logIndex" gorm:"uniqueIndex:idx_tx_log;not null;default:-1"` // -1 for native value transfers
 	Amount        decimal.Decimal  `json:"amount" gorm:"type:decimal(38,18);not null"`
 	BlockNum      *uint64          `json:"blockNum"`
+	BlockHash     *string          `json:"blockHash" gorm:"size:66"`
 	GasUsed       *uint64          `json:"gasUsed"`
 	GasPrice      *decimal.Decimal `json:"gasPrice" gorm:"type:decimal(38,18)"`
 	Status        string           `json:"status" gorm:"size:16;default:'pending'"` // pending, confirmed, failed
@@ -93,6 +115,64 @@ type OnchainTx struct {
 	Asset         Asset            `json:"asset" gorm:"foreignKey:AssetID"`
 }
 
+// OutgoingTx 交易管理器发出的交易，按 (chain, from, nonce) 唯一
+type OutgoingTx struct {
+	ID                   uint64              `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainID              uint64              `json:"chainId" gorm:"not null;uniqueIndex:idx_chain_from_nonce"`
+	FromAddr             string              `json:"fromAddr" gorm:"size:42;not null;uniqueIndex:idx_chain_from_nonce"`
+	Nonce                uint64              `json:"nonce" gorm:"not null;uniqueIndex:idx_chain_from_nonce"`
+	ToAddr               string              `json:"toAddr" gorm:"size:42;not null"`
+	Value                decimal.Decimal     `json:"value" gorm:"type:decimal(65,0);default:0"` // wei
+	Data                 []byte              `json:"-" gorm:"type:blob"`
+	GasLimit             uint64              `json:"gasLimit" gorm:"not null"`
+	MaxFeePerGas         decimal.Decimal     `json:"maxFeePerGas" gorm:"type:decimal(65,0);not null"`         // wei
+	MaxPriorityFeePerGas decimal.Decimal     `json:"maxPriorityFeePerGas" gorm:"type:decimal(65,0);not null"` // wei
+	TxHash               string              `json:"txHash" gorm:"size:66;not null;index"`                    // latest attempt, or the mined one
+	Purpose              string              `json:"purpose" gorm:"size:32;not null;index:idx_purpose_ref"`   // withdrawal, proof_batch, mint, burn, transfer, sweep, sweep_gas
+	RefID                *uint64             `json:"refId" gorm:"index:idx_purpose_ref"`
+	Status               string              `json:"status" gorm:"size:16;default:'pending';index"` // pending, mined, confirmed, reverted, dropped
+	Attempts             int                 `json:"attempts" gorm:"default:0"`
+	LastError            *string             `json:"lastError" gorm:"size:512"`
+	BlockNum             *uint64             `json:"blockNum"`
+	GasUsed              *uint64             `json:"gasUsed"`
+	EffectiveGasPrice    *decimal.Decimal    `json:"effectiveGasPrice" gorm:"type:decimal(65,0)"`
+	BroadcastAt          *time.Time          `json:"broadcastAt"`
+	CreatedAt            time.Time           `json:"createdAt"`
+	UpdatedAt            time.Time           `json:"updatedAt"`
+	FinalizedAt          *time.Time          `json:"finalizedAt"`
+	TxAttempts           []OutgoingTxAttempt `json:"txAttempts,omitempty" gorm:"foreignKey:OutgoingTxID"`
+	Chain                Chain               `json:"chain" gorm:"foreignKey:ChainID"`
+}
+
+// OutgoingTxAttempt 同一 nonce 的每次签名（含加价替换）
+type OutgoingTxAttempt struct {
+	ID                   uint64          `json:"id" gorm:"primaryKey;autoIncrement"`
+	OutgoingTxID         uint64          `json:"outgoingTxId" gorm:"not null;index"`
+	TxHash               string          `json:"txHash" gorm:"uniqueIndex;size:66;not null"`
+	MaxFeePerGas         decimal.Decimal `json:"maxFeePerGas" gorm:"type:decimal(65,0);not null"`
+	MaxPriorityFeePerGas decimal.Decimal `json:"maxPriorityFeePerGas" gorm:"type:decimal(65,0);not null"`
+	CreatedAt            time.Time       `json:"createdAt"`
+}
+
+// ChainSyncState 链上扫描进度
+type ChainSyncState struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainID   uint64    `json:"chainId" gorm:"not null;uniqueIndex:idx_chain_worker"`
+	Worker    string    `json:"worker" gorm:"size:32;not null;uniqueIndex:idx_chain_worker"` // deposit, proof_indexer
+	LastBlock uint64    `json:"lastBlock" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ChainBlock 已扫描区块哈希，用于检测链重组
+type ChainBlock struct {
+	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainID    uint64    `json:"chainId" gorm:"not null;uniqueIndex:idx_chain_block_num"`
+	BlockNum   uint64    `json:"blockNum" gorm:"not null;uniqueIndex:idx_chain_block_num"`
+	BlockHash  string    `json:"blockHash" gorm:"size:66;not null"`
+	ParentHash string    `json:"parentHash" gorm:"size:66;not null"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
 // Valuation 资产估值快照
 type Valuation struct {
 	ID         uint64          `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -131,7 +211,7 @@ type PriceFeed struct {
 type LedgerEntry struct {
 	ID                uint64           `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID            uint64           `json:"userId" gorm:"not null"`
-	EntryType         string           `json:"entryType" gorm:"size:16;not null"` // deposit, withdraw, yield, trade, fee
+	EntryType         string           `json:"entryType" gorm:"size:16;not null"` // deposit, withdraw, yield, trade, fee, reversal
 	ChainID           *uint64          `json:"chainId"`
 	AssetID           *uint64          `json:"assetId"`
 	Amount            decimal.Decimal  `json:"amount" gorm:"type:decimal(38,18);not null"` // 正负值
@@ -141,6 +221,7 @@ type LedgerEntry struct {
 	RefOnchainTxID    *uint64          `json:"refOnchainTxId"`
 	ProofRoot         *string          `json:"proofRoot" gorm:"size:128"`
 	BatchID           *uint64          `json:"batchId"`
+	ReversalOfID      *uint64          `json:"reversalOfId"` // set on compensating entries
 	Metadata          json.RawMessage  `json:"metadata" gorm:"type:json"`
 	CreatedAt         time.Time        `json:"createdAt"`
 	User              User             `json:"user" gorm:"foreignKey:UserID"`
@@ -151,23 +232,30 @@ type LedgerEntry struct {
 
 // ProofBatch 批次证明
 type ProofBatch struct {
-	ID            uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
-	BatchType     string     `json:"batchType" gorm:"size:16;not null"` // deposit, yield, trade, withdraw
-	PeriodStart   time.Time  `json:"periodStart" gorm:"not null"`
-	PeriodEnd     time.Time  `json:"periodEnd" gorm:"not null"`
-	MerkleRoot    string     `json:"merkleRoot" gorm:"uniqueIndex;size:128;not null"`
-	OracleSig     *string    `json:"oracleSig" gorm:"size:512"`
-	OnchainTxHash *string    `json:"onchainTxHash" gorm:"size:128"`
-	ChainID       *uint64    `json:"chainId"`
-	ContractAddr  *string    `json:"contractAddr" gorm:"size:128"`
-	BlockNum      *uint64    `json:"blockNum"`
-	GasUsed       *uint64    `json:"gasUsed"`
-	Status        string     `json:"status" gorm:"size:16;default:'pending'"` // pending, confirmed, failed
-	IpfsHash      *string    `json:"ipfsHash" gorm:"size:64"`
-	EntryCount    int        `json:"entryCount" gorm:"default:0"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	PublishedAt   *time.Time `json:"publishedAt"`
-	Chain         *Chain     `json:"chain" gorm:"foreignKey:ChainID"`
+	ID              uint64              `json:"id" gorm:"primaryKey;autoIncrement"`
+	BatchType       contracts.BatchType `json:"batchType" gorm:"type:varchar(16);not null"` // deposit, yield, trade, withdraw, reserves
+	PeriodStart     time.Time           `json:"periodStart" gorm:"not null"`
+	PeriodEnd       time.Time           `json:"periodEnd" gorm:"not null"`
+	MerkleRoot      string              `json:"merkleRoot" gorm:"uniqueIndex;size:128;not null"`
+	OracleSig       *string             `json:"oracleSig" gorm:"size:512"`
+	OnchainTxHash   *string             `json:"onchainTxHash" gorm:"size:128"`
+	PublishAttempts int                 `json:"publishAttempts" gorm:"default:0"`                          // publishBatch transactions sent for this batch
+	OnchainBatchID  *uint64             `json:"onchainBatchId" gorm:"uniqueIndex:idx_chain_onchain_batch"` // ProofRegistry batchId from the ProofPublished event
+	OnchainRoot     *string             `json:"onchainRoot" gorm:"size:128"`                               // root the registry holds, set only when it diverges from MerkleRoot
+	Divergent       bool                `json:"divergent" gorm:"default:false"`
+	Verified        bool                `json:"verified" gorm:"default:false"`
+	RevokeReason    *string             `json:"revokeReason" gorm:"size:512"`
+	ChainID         *uint64             `json:"chainId" gorm:"uniqueIndex:idx_chain_onchain_batch"`
+	ContractAddr    *string             `json:"contractAddr" gorm:"size:128"`
+	BlockNum        *uint64             `json:"blockNum"`
+	GasUsed         *uint64             `json:"gasUsed"`
+	Status          string              `json:"status" gorm:"size:16;default:'pending'"` // pending, confirmed, failed, revoked, recorded (off-chain only)
+	IpfsHash        *string             `json:"ipfsHash" gorm:"size:64"`
+	EntryCount      int                 `json:"entryCount" gorm:"default:0"`
+	Metadata        json.RawMessage     `json:"metadata" gorm:"type:json"` // reserves snapshot for reserves batches, publisher and URI for batches of other oracles
+	CreatedAt       time.Time           `json:"createdAt"`
+	PublishedAt     *time.Time          `json:"publishedAt"`
+	Chain           *Chain              `json:"chain" gorm:"foreignKey:ChainID"`
 }
 
 // WithdrawRequest 提现申请
@@ -179,12 +267,14 @@ type WithdrawRequest struct {
 	Amount          decimal.Decimal  `json:"amount" gorm:"type:decimal(38,18);not null"`
 	ToAddress       string           `json:"toAddress" gorm:"size:128;not null"`
 	Fee             decimal.Decimal  `json:"fee" gorm:"type:decimal(38,18);default:0"`
-	Status          string           `json:"status" gorm:"size:16;default:'pending'"` // pending, approved, rejected, processing, completed, failed
+	KusdReserved    decimal.Decimal  `json:"kusdReserved" gorm:"type:decimal(38,18);default:0"` // KUSD held from the balance while processing
+	Status          string           `json:"status" gorm:"size:16;default:'pending'"` // pending, pending_review, approved, rejected, processing, completed, failed
 	RiskScore       *decimal.Decimal `json:"riskScore" gorm:"type:decimal(4,2)"`
 	AdminNotes      *string          `json:"adminNotes" gorm:"type:text"`
 	TxHash          *string          `json:"txHash" gorm:"size:128"`
 	LedgerEntryID   *uint64          `json:"ledgerEntryId"`
 	CreatedAt       time.Time        `json:"createdAt"`
+	ClaimedAt       *time.Time       `json:"claimedAt"` // when the executor moved it to processing
 	ProcessedAt     *time.Time       `json:"processedAt"`
 	User            User             `json:"user" gorm:"foreignKey:UserID"`
 	Chain           Chain            `json:"chain" gorm:"foreignKey:ChainID"`
@@ -192,17 +282,42 @@ type WithdrawRequest struct {
 	LedgerEntry     *LedgerEntry     `json:"ledgerEntry" gorm:"foreignKey:LedgerEntryID"`
 }
 
-// WithdrawalWhitelist 提现白名单
-type WithdrawalWhitelist struct {
+// UserKyc 用户KYC状态
+type UserKyc struct {
+	ID             uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         uint64     `json:"userId" gorm:"uniqueIndex;not null"`
+	Level          int        `json:"level" gorm:"not null;default:0"`               // approved level: 0 none, 1 basic, 2 full
+	Status         string     `json:"status" gorm:"size:16;not null;default:'none'"` // latest application: none, pending, approved, rejected
+	RequestedLevel int        `json:"requestedLevel" gorm:"not null;default:0"`
+	Provider       *string    `json:"provider" gorm:"size:32"`
+	ApplicantID    *string    `json:"applicantId" gorm:"size:128;index"`
+	RejectReason   *string    `json:"rejectReason" gorm:"type:text"`
+	ReviewedAt     *time.Time `json:"reviewedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	User           User       `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// KycWebhookEvent 已处理的KYC回调事件，用于拒绝重放
+type KycWebhookEvent struct {
 	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID    uint64    `json:"userId" gorm:"not null;uniqueIndex:idx_user_chain_address"`
-	ChainID   uint64    `json:"chainId" gorm:"not null;uniqueIndex:idx_user_chain_address"`
-	Address   string    `json:"address" gorm:"size:128;not null;uniqueIndex:idx_user_chain_address"`
-	Label     *string   `json:"label" gorm:"size:64"`
-	IsActive  bool      `json:"isActive" gorm:"default:true"`
+	Provider  string    `json:"provider" gorm:"size:32;not null;uniqueIndex:idx_kyc_event"`
+	EventID   string    `json:"eventId" gorm:"size:128;not null;uniqueIndex:idx_kyc_event"`
 	CreatedAt time.Time `json:"createdAt"`
-	User      User      `json:"user" gorm:"foreignKey:UserID"`
-	Chain     Chain     `json:"chain" gorm:"foreignKey:ChainID"`
+}
+
+// WithdrawalWhitelist 提现白名单
+type WithdrawalWhitelist struct {
+	ID          uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      uint64    `json:"userId" gorm:"not null;uniqueIndex:idx_user_chain_address"`
+	ChainID     uint64    `json:"chainId" gorm:"not null;uniqueIndex:idx_user_chain_address"`
+	Address     string    `json:"address" gorm:"size:128;not null;uniqueIndex:idx_user_chain_address"`
+	Label       *string   `json:"label" gorm:"size:64"`
+	IsActive    bool      `json:"isActive" gorm:"default:true"`
+	ActivatesAt time.Time `json:"activatesAt" gorm:"not null"` // end of the cooling-off period
+	CreatedAt   time.Time `json:"createdAt"`
+	User        User      `json:"user" gorm:"foreignKey:UserID"`
+	Chain       Chain     `json:"chain" gorm:"foreignKey:ChainID"`
 }
 
 // RiskConfig 风控配置
@@ -236,10 +351,23 @@ type SystemConfig struct {
 	UpdatedAt   time.Time `json:"updatedAt"`
 }
 
+// AdminUser 后台管理员
+type AdminUser struct {
+	ID           uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Username     string     `json:"username" gorm:"uniqueIndex;size:64;not null"`
+	PasswordHash string     `json:"-" gorm:"size:128;not null"`   // bcrypt
+	Role         string     `json:"role" gorm:"size:32;not null"` // operator, risk_officer, treasury, auditor, custodian
+	Enabled      bool       `json:"enabled" gorm:"default:true"`
+	LastLoginAt  *time.Time `json:"lastLoginAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
 // AuditLog 审计日志
 type AuditLog struct {
 	ID           uint64          `json:"id" gorm:"primaryKey;autoIncrement"`
 	UserID       *uint64         `json:"userId"`
+	AdminID      *uint64         `json:"adminId"` // set for actions taken by an admin
 	Action       string          `json:"action" gorm:"size:64;not null"`
 	ResourceType *string         `json:"resourceType" gorm:"size:32"`
 	ResourceID   *string         `json:"resourceId" gorm:"size:64"`
@@ -249,6 +377,7 @@ type AuditLog struct {
 	UserAgent    *string         `json:"userAgent" gorm:"type:text"`
 	CreatedAt    time.Time       `json:"createdAt"`
 	User         *User           `json:"user" gorm:"foreignKey:UserID"`
+	Admin        *AdminUser      `json:"admin" gorm:"foreignKey:AdminID"`
 }
 
 // TableName methods for custom table names if needed
@@ -258,14 +387,19 @@ func (Asset) TableName() string             { return "assets" }
 func (ChainAsset) TableName() string        { return "chain_assets" }
 func (DepositAddress) TableName() string    { return "deposit_addresses" }
 func (OnchainTx) TableName() string         { return "onchain_txs" }
+func (ChainSyncState) TableName() string    { return "chain_sync_states" }
+func (ChainBlock) TableName() string        { return "chain_blocks" }
 func (Valuation) TableName() string         { return "valuations" }
 func (PlatformMetrics) TableName() string   { return "platform_metrics" }
 func (PriceFeed) TableName() string         { return "price_feeds" }
 func (LedgerEntry) TableName() string       { return "ledger_entries" }
 func (ProofBatch) TableName() string        { return "proof_batches" }
 func (WithdrawRequest) TableName() string   { return "withdraw_requests" }
+func (UserKyc) TableName() string           { return "user_kyc" }
+func (KycWebhookEvent) TableName() string   { return "kyc_webhook_events" }
 func (WithdrawalWhitelist) TableName() string { return "withdrawal_whitelist" }
 func (RiskConfig) TableName() string        { return "risk_configs" }
 func (BlacklistAddress) TableName() string  { return "blacklist_addresses" }
 func (SystemConfig) TableName() string      { return "system_configs" }
+func (AdminUser) TableName() string         { return "admin_users" }
 func (AuditLog) TableName() string          { return "audit_logs" }
\ No newline at end of file
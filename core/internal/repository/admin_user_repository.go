@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+)
+
+type AdminUserRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminUserRepository(db *gorm.DB) *AdminUserRepository {
+	return &AdminUserRepository{
+		db: db,
+	}
+}
+
+func (r *AdminUserRepository) Create(admin *model.AdminUser) error {
+	return r.db.Create(admin).Error
+}
+
+func (r *AdminUserRepository) FindByID(id uint64) (*model.AdminUser, error) {
+	var admin model.AdminUser
+	err := r.db.Where("id = ?", id).First(&admin).Error
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+func (r *AdminUserRepository) FindByUsername(username string) (*model.AdminUser, error) {
+	var admin model.AdminUser
+	err := r.db.Where("username = ?", username).First(&admin).Error
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+func (r *AdminUserRepository) UpdateLastLogin(id uint64, at time.Time) error {
+	return r.db.Model(&model.AdminUser{}).
+		Where("id = ?", id).
+		Update("last_login_at", at).Error
+}
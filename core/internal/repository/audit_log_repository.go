@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+)
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{
+		db: db,
+	}
+}
+
+func (r *AuditLogRepository) Create(auditLog *model.AuditLog) error {
+	return r.db.Create(auditLog).Error
+}
@@ -31,4 +31,12 @@ func (r *ChainAssetRepository) FindByChainAndAsset(chainID, assetID uint64) (*mo
 		return nil, err
 	}
 	return &chainAsset, nil
+}
+
+func (r *ChainAssetRepository) FindEnabledByChainID(chainID uint64) ([]model.ChainAsset, error) {
+	var chainAssets []model.ChainAsset
+	err := r.db.Preload("Asset").
+		Where("chain_id = ? AND enabled = ?", chainID, true).
+		Find(&chainAssets).Error
+	return chainAssets, err
 }
\ No newline at end of file
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"usdk-backend/internal/model"
+)
+
+type ChainBlockRepository struct {
+	db *gorm.DB
+}
+
+func NewChainBlockRepository(db *gorm.DB) *ChainBlockRepository {
+	return &ChainBlockRepository{
+		db: db,
+	}
+}
+
+// Save records the hash seen at a block height, replacing any previous hash
+func (r *ChainBlockRepository) Save(block *model.ChainBlock) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "block_num"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_hash", "parent_hash"}),
+	}).Create(block).Error
+}
+
+func (r *ChainBlockRepository) FindByNumber(chainID, blockNum uint64) (*model.ChainBlock, error) {
+	var block model.ChainBlock
+	err := r.db.Where("chain_id = ? AND block_num = ?", chainID, blockNum).First(&block).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &block, nil
+}
+
+// FindRecent returns the most recent tracked blocks of a chain, highest first
+func (r *ChainBlockRepository) FindRecent(chainID uint64, limit int) ([]model.ChainBlock, error) {
+	var blocks []model.ChainBlock
+	err := r.db.Where("chain_id = ?", chainID).
+		Order("block_num DESC").
+		Limit(limit).
+		Find(&blocks).Error
+	return blocks, err
+}
+
+func (r *ChainBlockRepository) DeleteAfter(chainID, blockNum uint64) error {
+	return r.db.Where("chain_id = ? AND block_num > ?", chainID, blockNum).
+		Delete(&model.ChainBlock{}).Error
+}
+
+func (r *ChainBlockRepository) DeleteBefore(chainID, blockNum uint64) error {
+	return r.db.Where("chain_id = ? AND block_num < ?", chainID, blockNum).
+		Delete(&model.ChainBlock{}).Error
+}
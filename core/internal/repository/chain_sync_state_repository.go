@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"usdk-backend/internal/model"
+)
+
+type ChainSyncStateRepository struct {
+	db *gorm.DB
+}
+
+func NewChainSyncStateRepository(db *gorm.DB) *ChainSyncStateRepository {
+	return &ChainSyncStateRepository{
+		db: db,
+	}
+}
+
+// Find returns the sync cursor for a chain and worker, or nil if the worker
+// has never run on that chain.
+func (r *ChainSyncStateRepository) Find(chainID uint64, worker string) (*model.ChainSyncState, error) {
+	var state model.ChainSyncState
+	err := r.db.Where("chain_id = ? AND worker = ?", chainID, worker).First(&state).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *ChainSyncStateRepository) SaveLastBlock(chainID uint64, worker string, lastBlock uint64) error {
+	state := &model.ChainSyncState{
+		ChainID:   chainID,
+		Worker:    worker,
+		LastBlock: lastBlock,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "worker"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_block", "updated_at"}),
+	}).Create(state).Error
+}
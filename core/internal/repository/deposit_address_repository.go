@@ -2,6 +2,7 @@ package repository
 
 import (
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"usdk-backend/internal/model"
 )
@@ -20,6 +21,43 @@ func (r *DepositAddressRepository) Create(depositAddress *model.DepositAddress)
 	return r.db.Create(depositAddress).Error
 }
 
+// CreateDerived allocates the next address index of the chain and asset,
+// calls derive to get the address and derivation path at that index and
+// inserts the address. Both happen in one transaction holding the counter
+// row, so indices are handed out in order and a failed insert gives its
+// index back instead of leaving a gap.
+func (r *DepositAddressRepository) CreateDerived(depositAddress *model.DepositAddress, derive func(index uint32) (string, string, error)) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		counter := model.DepositAddressCounter{
+			ChainID: depositAddress.ChainID,
+			AssetID: depositAddress.AssetID,
+		}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("chain_id = ? AND asset_id = ?", depositAddress.ChainID, depositAddress.AssetID).
+			First(&counter).Error
+		if err != nil {
+			return err
+		}
+
+		address, derivationPath, err := derive(counter.NextIndex)
+		if err != nil {
+			return err
+		}
+		depositAddress.Address = address
+		depositAddress.DerivationPath = &derivationPath
+
+		if err := tx.Create(depositAddress).Error; err != nil {
+			return err
+		}
+		return tx.Model(&counter).Update("next_index", counter.NextIndex+1).Error
+	})
+}
+
 func (r *DepositAddressRepository) FindByUserChainAsset(userID, chainID, assetID uint64) (*model.DepositAddress, error) {
 	var depositAddress model.DepositAddress
 	err := r.db.Where("user_id = ? AND chain_id = ? AND asset_id = ? AND is_active = ?", 
@@ -45,4 +83,69 @@ func (r *DepositAddressRepository) FindByUserID(userID uint64) ([]model.DepositA
 	err := r.db.Preload("Chain").Preload("Asset").
 		Where("user_id = ? AND is_active = ?", userID, true).Find(&addresses).Error
 	return addresses, err
-}
\ No newline at end of file
+}
+
+func (r *DepositAddressRepository) FindByChainID(chainID uint64) ([]model.DepositAddress, error) {
+	var addresses []model.DepositAddress
+	err := r.db.Where("chain_id = ? AND is_active = ?", chainID, true).Find(&addresses).Error
+	return addresses, err
+}
+
+func (r *DepositAddressRepository) FindByID(id uint64) (*model.DepositAddress, error) {
+	var depositAddress model.DepositAddress
+	err := r.db.Preload("Chain").Preload("Asset").Where("id = ?", id).First(&depositAddress).Error
+	if err != nil {
+		return nil, err
+	}
+	return &depositAddress, nil
+}
+
+// FindKeyRefsNotSealedWith returns up to limit addresses after afterID whose
+// private key reference is sealed with a KEK other than the one of prefix
+func (r *DepositAddressRepository) FindKeyRefsNotSealedWith(prefix string, afterID uint64, limit int) ([]model.DepositAddress, error) {
+	var addresses []model.DepositAddress
+	err := r.db.Where("id > ? AND private_key_ref LIKE ? AND private_key_ref NOT LIKE ?", afterID, "kek%", prefix+"%").
+		Order("id ASC").Limit(limit).Find(&addresses).Error
+	return addresses, err
+}
+
+// ReplacePrivateKeyRef swaps the private key reference of an address if it
+// still holds oldRef, and reports whether it did
+func (r *DepositAddressRepository) ReplacePrivateKeyRef(id uint64, oldRef, newRef string) (bool, error) {
+	result := r.db.Model(&model.DepositAddress{}).
+		Where("id = ? AND private_key_ref = ?", id, oldRef).
+		Update("private_key_ref", newRef)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FindDerivedAfter returns up to limit addresses after afterID that were
+// derived from the HD wallet
+func (r *DepositAddressRepository) FindDerivedAfter(afterID uint64, limit int) ([]model.DepositAddress, error) {
+	var addresses []model.DepositAddress
+	err := r.db.Preload("Chain").Where("id > ? AND derivation_path LIKE ?", afterID, "m/%").
+		Order("id ASC").Limit(limit).Find(&addresses).Error
+	return addresses, err
+}
+
+// FindAnyByAddress returns the address row, active or not
+func (r *DepositAddressRepository) FindAnyByAddress(address string) (*model.DepositAddress, error) {
+	var depositAddress model.DepositAddress
+	err := r.db.Where("address = ?", address).First(&depositAddress).Error
+	if err != nil {
+		return nil, err
+	}
+	return &depositAddress, nil
+}
+
+// FindByChainAfter returns up to limit addresses of the chain after afterID,
+// including deactivated ones that may still hold funds
+func (r *DepositAddressRepository) FindByChainAfter(chainID, afterID uint64, limit int) ([]model.DepositAddress, error) {
+	var addresses []model.DepositAddress
+	err := r.db.Preload("Asset").
+		Where("chain_id = ? AND id > ?", chainID, afterID).
+		Order("id ASC").Limit(limit).Find(&addresses).Error
+	return addresses, err
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"usdk-backend/internal/model"
+)
+
+func TestCreateDerivedGivesBackIndexOfFailedInsert(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDepositAddressRepository(db)
+
+	chain := &model.Chain{ChainKey: "ethereum", NetworkID: 1, Name: "Ethereum"}
+	asset := &model.Asset{Symbol: "ETH", Name: "Ether", Decimals: 18, AssetType: "eth"}
+	users := []*model.User{{}, {}}
+	for _, record := range []interface{}{chain, asset, users[0], users[1]} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed %T: %v", record, err)
+		}
+	}
+
+	var indices []uint32
+	create := func(user *model.User, address string, deriveErr error) error {
+		return repo.CreateDerived(&model.DepositAddress{UserID: user.ID, ChainID: chain.ID, AssetID: asset.ID}, func(index uint32) (string, string, error) {
+			indices = append(indices, index)
+			if deriveErr != nil {
+				return "", "", deriveErr
+			}
+			if address == "" {
+				address = fmt.Sprintf("0x%040x", index+1)
+			}
+			return address, fmt.Sprintf("m/44'/60'/101'/%d/%d", asset.ID, index), nil
+		})
+	}
+
+	if err := create(users[0], "", nil); err != nil {
+		t.Fatalf("failed to create first address: %v", err)
+	}
+	// A failing derivation, a second address for the same user, chain and
+	// asset, and an address already in use all fail without using an index
+	if err := create(users[1], "", errors.New("signer unavailable")); err == nil {
+		t.Fatalf("failed derivation created an address")
+	}
+	if err := create(users[0], "", nil); err == nil {
+		t.Fatalf("second address created for the same user, chain and asset")
+	}
+	if err := create(users[1], fmt.Sprintf("0x%040x", 1), nil); err == nil {
+		t.Fatalf("address created twice")
+	}
+	if err := create(users[1], "", nil); err != nil {
+		t.Fatalf("failed to create second address: %v", err)
+	}
+
+	want := []uint32{0, 1, 1, 1, 1}
+	if fmt.Sprint(indices) != fmt.Sprint(want) {
+		t.Fatalf("derived at indices %v, want %v", indices, want)
+	}
+	var counter model.DepositAddressCounter
+	if err := db.Where("chain_id = ? AND asset_id = ?", chain.ID, asset.ID).First(&counter).Error; err != nil {
+		t.Fatalf("failed to load counter: %v", err)
+	}
+	if counter.NextIndex != 2 {
+		t.Fatalf("got next index %d, want 2", counter.NextIndex)
+	}
+}
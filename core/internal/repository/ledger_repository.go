@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"usdk-backend/internal/model"
 )
@@ -21,6 +24,38 @@ func (r *LedgerRepository) Create(entry *model.LedgerEntry) error {
 	return r.db.Create(entry).Error
 }
 
+// insertLedgerEntry stamps KusdBalanceAfter from the user's running balance and
+// inserts the entry. It must be called inside a transaction so the balance
+// read and the insert are serialised per user.
+func insertLedgerEntry(tx *gorm.DB, entry *model.LedgerEntry) error {
+	balance, err := lockKusdBalance(tx, entry.UserID)
+	if err != nil {
+		return err
+	}
+
+	balanceAfter := balance.Add(entry.KusdDelta)
+	entry.KusdBalanceAfter = &balanceAfter
+	return tx.Create(entry).Error
+}
+
+// lockKusdBalance returns the user's KUSD balance with their ledger rows
+// locked until the transaction ends
+func lockKusdBalance(tx *gorm.DB, userID uint64) (decimal.Decimal, error) {
+	var result struct {
+		Balance decimal.Decimal
+	}
+
+	err := tx.Table("ledger_entries").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("COALESCE(SUM(kusd_delta), 0) as balance").
+		Where("user_id = ?", userID).
+		Scan(&result).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return result.Balance, nil
+}
+
 func (r *LedgerRepository) GetUserKUSDBalance(userID uint64) (decimal.Decimal, error) {
 	var result struct {
 		Balance decimal.Decimal
@@ -38,6 +73,42 @@ func (r *LedgerRepository) GetUserKUSDBalance(userID uint64) (decimal.Decimal, e
 	return result.Balance, nil
 }
 
+// GetTotalKUSDLiabilities returns the KUSD owed to all users, the sum of
+// every ledger delta
+func (r *LedgerRepository) GetTotalKUSDLiabilities() (decimal.Decimal, error) {
+	var result struct {
+		Total decimal.Decimal
+	}
+
+	err := r.db.Table("ledger_entries").
+		Select("COALESCE(SUM(kusd_delta), 0) as total").
+		Scan(&result).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return result.Total, nil
+}
+
+// SumKusdDeltaSince totals the KUSD delta of the user's entries of entryType
+// created since the given time. Entries cancelled by a reversal are skipped.
+func (r *LedgerRepository) SumKusdDeltaSince(userID uint64, entryType string, since time.Time) (decimal.Decimal, error) {
+	var result struct {
+		Total decimal.Decimal
+	}
+
+	err := r.db.Table("ledger_entries").
+		Select("COALESCE(SUM(kusd_delta), 0) as total").
+		Where("user_id = ? AND entry_type = ? AND created_at >= ?", userID, entryType, since).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries r WHERE r.reversal_of_id = ledger_entries.id)").
+		Scan(&result).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return result.Total, nil
+}
+
 func (r *LedgerRepository) GetUserRecordsPaginated(userID uint64, entryType string, offset uint64, limit int) ([]model.LedgerEntry, error) {
 	query := r.db.Preload("Chain").Preload("Asset").
 		Where("user_id = ?", userID).
@@ -54,6 +125,15 @@ func (r *LedgerRepository) GetUserRecordsPaginated(userID uint64, entryType stri
 	return entries, err
 }
 
+func (r *LedgerRepository) FindByID(id uint64) (*model.LedgerEntry, error) {
+	var entry model.LedgerEntry
+	err := r.db.Where("id = ?", id).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
 func (r *LedgerRepository) FindByBatchID(batchID uint64) ([]model.LedgerEntry, error) {
 	var entries []model.LedgerEntry
 	err := r.db.Preload("User").Preload("Chain").Preload("Asset").
@@ -61,6 +141,17 @@ func (r *LedgerRepository) FindByBatchID(batchID uint64) ([]model.LedgerEntry, e
 	return entries, err
 }
 
+// FindUnbatched returns entries of the given types created before the given
+// time that are not part of a proof batch yet, oldest first
+func (r *LedgerRepository) FindUnbatched(entryTypes []string, before time.Time) ([]model.LedgerEntry, error) {
+	var entries []model.LedgerEntry
+	err := r.db.
+		Where("entry_type IN ? AND batch_id IS NULL AND created_at < ?", entryTypes, before).
+		Order("created_at ASC, id ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
 func (r *LedgerRepository) GetUserEntriesByType(userID uint64, entryType string) ([]model.LedgerEntry, error) {
 	var entries []model.LedgerEntry
 	err := r.db.Preload("Chain").Preload("Asset").
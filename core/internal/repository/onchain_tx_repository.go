@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"usdk-backend/internal/model"
+)
+
+type OnchainTxRepository struct {
+	db *gorm.DB
+}
+
+func NewOnchainTxRepository(db *gorm.DB) *OnchainTxRepository {
+	return &OnchainTxRepository{
+		db: db,
+	}
+}
+
+// UpsertObserved inserts the transaction or, if a row with the same
+// (tx_hash, log_index) already exists, refreshes the block it was seen in.
+// A tx rolled back by a reorg gets its new block this way when re-mined.
+func (r *OnchainTxRepository) UpsertObserved(tx *model.OnchainTx) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_hash"}, {Name: "log_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_num", "block_hash"}),
+	}).Create(tx).Error
+}
+
+func (r *OnchainTxRepository) Create(tx *model.OnchainTx) error {
+	return r.db.Create(tx).Error
+}
+
+func (r *OnchainTxRepository) FindByID(id uint64) (*model.OnchainTx, error) {
+	var tx model.OnchainTx
+	err := r.db.Preload("Chain").Preload("Asset").Where("id = ?", id).First(&tx).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *OnchainTxRepository) FindByTxHash(txHash string, direction string) (*model.OnchainTx, error) {
+	var tx model.OnchainTx
+	err := r.db.Where("tx_hash = ? AND direction = ?", txHash, direction).First(&tx).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// UpdateMined records the block, gas and confirmation depth of a mined tx
+func (r *OnchainTxRepository) UpdateMined(tx *model.OnchainTx) error {
+	return r.db.Model(&model.OnchainTx{}).
+		Where("id = ?", tx.ID).
+		Updates(map[string]interface{}{
+			"block_num":     tx.BlockNum,
+			"block_hash":    tx.BlockHash,
+			"gas_used":      tx.GasUsed,
+			"gas_price":     tx.GasPrice,
+			"confirmations": tx.Confirmations,
+		}).Error
+}
+
+func (r *OnchainTxRepository) FindPendingByChain(chainID uint64, direction string) ([]model.OnchainTx, error) {
+	var txs []model.OnchainTx
+	err := r.db.Preload("Asset").
+		Where("chain_id = ? AND direction = ? AND status = ? AND block_num IS NOT NULL", chainID, direction, "pending").
+		Order("block_num ASC").
+		Find(&txs).Error
+	return txs, err
+}
+
+func (r *OnchainTxRepository) UpdateConfirmations(id uint64, confirmations int) error {
+	return r.db.Model(&model.OnchainTx{}).
+		Where("id = ?", id).
+		Update("confirmations", confirmations).Error
+}
+
+// ConfirmDeposit marks an incoming transaction as confirmed and posts the
+// matching deposit ledger entry in a single database transaction. The status
+// guard makes it safe to call more than once for the same tx.
+func (r *OnchainTxRepository) ConfirmDeposit(onchainTx *model.OnchainTx, confirmations int, entry *model.LedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.OnchainTx{}).
+			Where("id = ? AND status = ?", onchainTx.ID, "pending").
+			Updates(map[string]interface{}{
+				"status":        "confirmed",
+				"confirmations": confirmations,
+				"confirmed_at":  now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := insertLedgerEntry(tx, entry); err != nil {
+			return err
+		}
+
+		onchainTx.Status = "confirmed"
+		onchainTx.Confirmations = confirmations
+		onchainTx.ConfirmedAt = &now
+		return nil
+	})
+}
+
+// DepositAddressBalances returns what the deposit addresses of a chain hold
+// per asset according to the recorded transactions: confirmed deposits and
+// gas top-ups, less every sweep that has not failed. Sweeps still in flight
+// are already deducted, so the result never counts funds the treasury may
+// hold too. The native fees the sweeps paid, reverted ones included, are
+// returned separately in wei.
+func (r *OnchainTxRepository) DepositAddressBalances(chainID uint64) (map[uint64]decimal.Decimal, decimal.Decimal, error) {
+	depositAddresses := func() *gorm.DB {
+		return r.db.Model(&model.DepositAddress{}).Select("address").Where("chain_id = ?", chainID)
+	}
+
+	var credits, debits []struct {
+		AssetID uint64
+		Total   decimal.Decimal
+	}
+	err := r.db.Model(&model.OnchainTx{}).
+		Select("asset_id, COALESCE(SUM(amount), 0) as total").
+		Where("chain_id = ? AND status = ?", chainID, "confirmed").
+		Where("direction = ? OR (direction = ? AND to_addr IN (?))", "in", "sweep", depositAddresses()).
+		Group("asset_id").
+		Scan(&credits).Error
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	err = r.db.Model(&model.OnchainTx{}).
+		Select("asset_id, COALESCE(SUM(amount), 0) as total").
+		Where("chain_id = ? AND direction = ? AND status <> ?", chainID, "sweep", "failed").
+		Where("from_addr IN (?)", depositAddresses()).
+		Group("asset_id").
+		Scan(&debits).Error
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	var fees struct {
+		Total decimal.Decimal
+	}
+	err = r.db.Model(&model.OnchainTx{}).
+		Select("COALESCE(SUM(gas_used * gas_price), 0) as total").
+		Where("chain_id = ? AND direction = ? AND gas_used IS NOT NULL", chainID, "sweep").
+		Where("from_addr IN (?)", depositAddresses()).
+		Scan(&fees).Error
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	balances := make(map[uint64]decimal.Decimal, len(credits))
+	for _, credit := range credits {
+		balances[credit.AssetID] = credit.Total
+	}
+	for _, debit := range debits {
+		balances[debit.AssetID] = balances[debit.AssetID].Sub(debit.Total)
+	}
+	return balances, fees.Total, nil
+}
+
+// RollbackAfterBlock returns every transaction of the chain mined above
+// blockNum to pending and posts a compensating reversal for each ledger entry
+// that references it. Entries are never deleted so balances stay auditable.
+// It reports how many transactions and ledger entries were affected.
+func (r *OnchainTxRepository) RollbackAfterBlock(chainID, blockNum uint64, ancestorHash string) (int, int, error) {
+	txCount, reversalCount := 0, 0
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var orphaned []model.OnchainTx
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("chain_id = ? AND block_num > ?", chainID, blockNum).
+			Find(&orphaned).Error
+		if err != nil {
+			return err
+		}
+
+		for _, ot := range orphaned {
+			var entries []model.LedgerEntry
+			err := tx.Where("ref_onchain_tx_id = ? AND reversal_of_id IS NULL", ot.ID).
+				Where("NOT EXISTS (SELECT 1 FROM ledger_entries r WHERE r.reversal_of_id = ledger_entries.id)").
+				Find(&entries).Error
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				metadata, err := json.Marshal(map[string]interface{}{
+					"reason":            "chain_reorg",
+					"reversedEntryType": entry.EntryType,
+					"orphanedBlockNum":  ot.BlockNum,
+					"orphanedBlockHash": ot.BlockHash,
+					"reorgAncestor":     blockNum,
+					"reorgAncestorHash": ancestorHash,
+				})
+				if err != nil {
+					return err
+				}
+
+				reversedID := entry.ID
+				reversal := &model.LedgerEntry{
+					UserID:         entry.UserID,
+					EntryType:      "reversal",
+					ChainID:        entry.ChainID,
+					AssetID:        entry.AssetID,
+					Amount:         entry.Amount.Neg(),
+					KusdDelta:      entry.KusdDelta.Neg(),
+					RefTxHash:      entry.RefTxHash,
+					RefOnchainTxID: entry.RefOnchainTxID,
+					ReversalOfID:   &reversedID,
+					Metadata:       metadata,
+				}
+				if err := insertLedgerEntry(tx, reversal); err != nil {
+					return err
+				}
+				reversalCount++
+			}
+
+			err = tx.Model(&model.OnchainTx{}).
+				Where("id = ?", ot.ID).
+				Updates(map[string]interface{}{
+					"status":        "pending",
+					"block_num":     nil,
+					"block_hash":    nil,
+					"confirmations": 0,
+					"confirmed_at":  nil,
+				}).Error
+			if err != nil {
+				return err
+			}
+
+			// A completed withdrawal goes back to processing so the executor
+			// re-confirms it and posts fresh ledger entries once re-mined
+			if ot.Direction == "out" {
+				err = tx.Model(&model.WithdrawRequest{}).
+					Where("tx_hash = ? AND status = ?", ot.TxHash, "completed").
+					Updates(map[string]interface{}{
+						"status":          "processing",
+						"processed_at":    nil,
+						"ledger_entry_id": nil,
+					}).Error
+				if err != nil {
+					return err
+				}
+			}
+			txCount++
+		}
+
+		return nil
+	})
+
+	return txCount, reversalCount, err
+}
+
+// FindUnsettledByChain returns every pending transaction of a direction,
+// mined or not
+func (r *OnchainTxRepository) FindUnsettledByChain(chainID uint64, direction string) ([]model.OnchainTx, error) {
+	var txs []model.OnchainTx
+	err := r.db.Where("chain_id = ? AND direction = ? AND status = ?", chainID, direction, "pending").
+		Order("id ASC").
+		Find(&txs).Error
+	return txs, err
+}
+
+// Settle records the final hash, block, gas and status of a transaction the
+// platform sent, while it is still pending
+func (r *OnchainTxRepository) Settle(tx *model.OnchainTx) error {
+	return r.db.Model(&model.OnchainTx{}).
+		Where("id = ? AND status = ?", tx.ID, "pending").
+		Updates(map[string]interface{}{
+			"tx_hash":       tx.TxHash,
+			"block_num":     tx.BlockNum,
+			"gas_used":      tx.GasUsed,
+			"gas_price":     tx.GasPrice,
+			"confirmations": tx.Confirmations,
+			"status":        tx.Status,
+			"confirmed_at":  tx.ConfirmedAt,
+		}).Error
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"usdk-backend/internal/model"
+)
+
+type OutgoingTxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutgoingTxRepository(db *gorm.DB) *OutgoingTxRepository {
+	return &OutgoingTxRepository{
+		db: db,
+	}
+}
+
+// MaxNonce returns the highest nonce recorded for a sender on a chain, or nil
+// when the sender has no outgoing transaction yet
+func (r *OutgoingTxRepository) MaxNonce(chainID uint64, from string) (*uint64, error) {
+	var nonce *uint64
+	err := r.db.Model(&model.OutgoingTx{}).
+		Where("chain_id = ? AND from_addr = ?", chainID, from).
+		Select("MAX(nonce)").
+		Scan(&nonce).Error
+	return nonce, err
+}
+
+// CreateWithAttempt records a new transaction and its first signed attempt.
+// The (chain, from, nonce) unique index rejects a nonce that is already taken.
+func (r *OutgoingTxRepository) CreateWithAttempt(tx *model.OutgoingTx, attempt *model.OutgoingTxAttempt) error {
+	return r.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Omit(clause.Associations).Create(tx).Error; err != nil {
+			return err
+		}
+		attempt.OutgoingTxID = tx.ID
+		return db.Create(attempt).Error
+	})
+}
+
+// AddAttempt saves a replacement of the transaction with new fees
+func (r *OutgoingTxRepository) AddAttempt(tx *model.OutgoingTx, attempt *model.OutgoingTxAttempt) error {
+	return r.db.Transaction(func(db *gorm.DB) error {
+		attempt.OutgoingTxID = tx.ID
+		if err := db.Create(attempt).Error; err != nil {
+			return err
+		}
+		return db.Omit(clause.Associations).Save(tx).Error
+	})
+}
+
+func (r *OutgoingTxRepository) Update(tx *model.OutgoingTx) error {
+	return r.db.Omit(clause.Associations).Save(tx).Error
+}
+
+// FindInFlight returns every transaction that is not final yet, with its
+// attempts, in nonce order per sender
+func (r *OutgoingTxRepository) FindInFlight() ([]model.OutgoingTx, error) {
+	var txs []model.OutgoingTx
+	err := r.db.Preload("TxAttempts").Preload("Chain").
+		Where("status IN ?", []string{"pending", "mined"}).
+		Order("chain_id ASC, from_addr ASC, nonce ASC").
+		Find(&txs).Error
+	return txs, err
+}
+
+// FindLatestByRef returns the latest transaction sent for a record, or nil
+// when none was sent through the manager
+func (r *OutgoingTxRepository) FindLatestByRef(purpose string, refID uint64) (*model.OutgoingTx, error) {
+	var tx model.OutgoingTx
+	err := r.db.Where("purpose = ? AND ref_id = ?", purpose, refID).Order("id DESC").First(&tx).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+func (r *OutgoingTxRepository) FindByID(id uint64) (*model.OutgoingTx, error) {
+	var tx model.OutgoingTx
+	err := r.db.Preload("TxAttempts").Preload("Chain").Where("id = ?", id).First(&tx).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// FindByAttemptHash returns the transaction one of whose attempts has txHash,
+// or nil when none has
+func (r *OutgoingTxRepository) FindByAttemptHash(chainID uint64, txHash string) (*model.OutgoingTx, error) {
+	var tx model.OutgoingTx
+	err := r.db.Where("chain_id = ? AND id IN (?)", chainID,
+		r.db.Model(&model.OutgoingTxAttempt{}).Select("outgoing_tx_id").Where("tx_hash = ?", txHash)).
+		First(&tx).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"fmt"
+
 	"gorm.io/gorm"
 
 	"usdk-backend/internal/model"
+	"usdk-backend/pkg/contracts"
 )
 
 type ProofBatchRepository struct {
@@ -50,4 +53,127 @@ func (r *ProofBatchRepository) FindPendingBatches() ([]model.ProofBatch, error)
 
 func (r *ProofBatchRepository) Update(batch *model.ProofBatch) error {
 	return r.db.Save(batch).Error
-}
\ No newline at end of file
+}
+
+// FindLatestByType returns the batch with the latest period of the given type,
+// or nil when none exists yet
+func (r *ProofBatchRepository) FindLatestByType(batchType contracts.BatchType) (*model.ProofBatch, error) {
+	var batch model.ProofBatch
+	err := r.db.Where("batch_type = ?", batchType).Order("period_end DESC").First(&batch).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// CreateWithEntries creates the batch and assigns the given ledger entries to
+// it. Entries already taken by another batch make the whole create fail.
+func (r *ProofBatchRepository) CreateWithEntries(batch *model.ProofBatch, entryIDs []uint64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.LedgerEntry{}).
+			Where("id IN ? AND batch_id IS NULL", entryIDs).
+			Update("batch_id", batch.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(entryIDs)) {
+			return fmt.Errorf("ledger entries already batched: assigned %d of %d", result.RowsAffected, len(entryIDs))
+		}
+		return nil
+	})
+}
+
+// Confirm records the publication of a batch and stamps its merkle root on every
+// ledger entry it includes
+func (r *ProofBatchRepository) Confirm(batch *model.ProofBatch) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Only the publication columns, so flags the indexer set meanwhile stay
+		err := tx.Model(batch).
+			Select("onchain_batch_id", "block_num", "gas_used", "status", "published_at").
+			Updates(batch).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.LedgerEntry{}).
+			Where("batch_id = ?", batch.ID).
+			Update("proof_root", batch.MerkleRoot).Error
+	})
+}
+
+// FindByOnchainBatchID returns the batch with the given registry batchId on a
+// chain, or nil when it is not known yet
+func (r *ProofBatchRepository) FindByOnchainBatchID(chainID, onchainBatchID uint64) (*model.ProofBatch, error) {
+	var batch model.ProofBatch
+	err := r.db.Where("chain_id = ? AND onchain_batch_id = ?", chainID, onchainBatchID).First(&batch).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// FindUnlinked returns the batch published in txHash, or else the batch with
+// the given root, that has no registry batchId yet. It returns nil when there
+// is none.
+func (r *ProofBatchRepository) FindUnlinked(chainID uint64, txHash, merkleRoot string) (*model.ProofBatch, error) {
+	for _, cond := range []struct {
+		column string
+		value  string
+	}{{"onchain_tx_hash", txHash}, {"merkle_root", merkleRoot}} {
+		var batch model.ProofBatch
+		err := r.db.Where("chain_id = ? AND onchain_batch_id IS NULL", chainID).
+			Where(cond.column+" = ?", cond.value).
+			First(&batch).Error
+		if err == gorm.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &batch, nil
+	}
+	return nil, nil
+}
+
+// LinkOnchain sets the registry batchId of a batch without touching its status
+func (r *ProofBatchRepository) LinkOnchain(id, onchainBatchID uint64) error {
+	return r.db.Model(&model.ProofBatch{}).Where("id = ?", id).
+		Update("onchain_batch_id", onchainBatchID).Error
+}
+
+// MarkDivergent flags a batch whose registry root differs from its merkle root
+func (r *ProofBatchRepository) MarkDivergent(id uint64, onchainRoot string) error {
+	return r.db.Model(&model.ProofBatch{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"divergent": true, "onchain_root": onchainRoot}).Error
+}
+
+func (r *ProofBatchRepository) MarkVerified(id uint64) error {
+	return r.db.Model(&model.ProofBatch{}).Where("id = ?", id).Update("verified", true).Error
+}
+
+func (r *ProofBatchRepository) MarkRevoked(id uint64, reason string) error {
+	return r.db.Model(&model.ProofBatch{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": "revoked", "revoke_reason": reason}).Error
+}
+
+// FindByMerkleRoot returns the batch with the given root, or nil when none exists
+func (r *ProofBatchRepository) FindByMerkleRoot(merkleRoot string) (*model.ProofBatch, error) {
+	var batch model.ProofBatch
+	err := r.db.Where("merkle_root = ?", merkleRoot).First(&batch).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
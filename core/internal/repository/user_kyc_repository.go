@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"usdk-backend/internal/model"
+)
+
+// ErrDuplicateKycEvent is returned for a webhook event that was already applied
+var ErrDuplicateKycEvent = errors.New("KYC webhook event already processed")
+
+type UserKycRepository struct {
+	db *gorm.DB
+}
+
+func NewUserKycRepository(db *gorm.DB) *UserKycRepository {
+	return &UserKycRepository{
+		db: db,
+	}
+}
+
+// FindByUserID returns the user's KYC record, or nil if the user never applied
+func (r *UserKycRepository) FindByUserID(userID uint64) (*model.UserKyc, error) {
+	var kyc model.UserKyc
+	err := r.db.Where("user_id = ?", userID).First(&kyc).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &kyc, nil
+}
+
+func (r *UserKycRepository) FindByApplicant(provider, applicantID string) (*model.UserKyc, error) {
+	var kyc model.UserKyc
+	err := r.db.Where("provider = ? AND applicant_id = ?", provider, applicantID).First(&kyc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &kyc, nil
+}
+
+func (r *UserKycRepository) Save(kyc *model.UserKyc) error {
+	return r.db.Save(kyc).Error
+}
+
+// SaveWebhookResult records the provider's webhook event and saves the KYC
+// record it updated in one transaction. An event recorded before is not
+// applied again and returns ErrDuplicateKycEvent.
+func (r *UserKycRepository) SaveWebhookResult(kyc *model.UserKyc, provider, eventID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.KycWebhookEvent{Provider: provider, EventID: eventID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDuplicateKycEvent
+		}
+		return tx.Save(kyc).Error
+	})
+}
+
+// GetApprovedLevel returns the user's approved KYC level, 0 if none
+func (r *UserKycRepository) GetApprovedLevel(userID uint64) (int, error) {
+	kyc, err := r.FindByUserID(userID)
+	if err != nil || kyc == nil {
+		return 0, err
+	}
+	return kyc.Level, nil
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"usdk-backend/internal/model"
@@ -40,4 +42,15 @@ func (r *UserRepository) FindByWalletAddr(walletAddr string) (*model.User, error
 
 func (r *UserRepository) Update(user *model.User) error {
 	return r.db.Save(user).Error
-}
\ No newline at end of file
+}
+
+// UpdateWhitelistMode stores whitelist-only mode and when turning it off
+// takes effect, nil unless a switch-off is pending
+func (r *UserRepository) UpdateWhitelistMode(userID uint64, whitelistOnly bool, offAt *time.Time) error {
+	return r.db.Model(&model.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"whitelist_only":   whitelistOnly,
+			"whitelist_off_at": offAt,
+		}).Error
+}
@@ -1,11 +1,20 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 
 	"usdk-backend/internal/model"
 )
 
+// ErrInsufficientBalance is returned when a user's available KUSD balance does
+// not cover a withdrawal
+var ErrInsufficientBalance = errors.New("insufficient KUSD balance")
+
 type WithdrawRequestRepository struct {
 	db *gorm.DB
 }
@@ -44,6 +53,335 @@ func (r *WithdrawRequestRepository) FindPendingRequests() ([]model.WithdrawReque
 	return requests, err
 }
 
+// AssetAmountSum is the total amount, in the asset's own units, and the number
+// of a user's requests in one asset
+type AssetAmountSum struct {
+	Symbol string
+	Total  decimal.Decimal
+	Count  int64
+}
+
+// SumAmountByAssetSince totals per asset the amount of the user's requests
+// created since the given time whose status is one of statuses. Amounts of
+// different assets are not comparable, callers value each sum separately.
+func (r *WithdrawRequestRepository) SumAmountByAssetSince(userID uint64, since time.Time, statuses []string) ([]AssetAmountSum, error) {
+	var sums []AssetAmountSum
+	err := r.db.Model(&model.WithdrawRequest{}).
+		Select("assets.symbol AS symbol, COALESCE(SUM(withdraw_requests.amount), 0) AS total, COUNT(*) AS count").
+		Joins("JOIN assets ON assets.id = withdraw_requests.asset_id").
+		Where("withdraw_requests.user_id = ? AND withdraw_requests.created_at >= ? AND withdraw_requests.status IN ?", userID, since, statuses).
+		Group("assets.symbol").
+		Order("assets.symbol ASC").
+		Scan(&sums).Error
+	return sums, err
+}
+
+func (r *WithdrawRequestRepository) FindByUserSince(userID uint64, since time.Time, statuses []string) ([]model.WithdrawRequest, error) {
+	var requests []model.WithdrawRequest
+	err := r.db.Where("user_id = ? AND created_at >= ? AND status IN ?", userID, since, statuses).
+		Order("created_at DESC").Find(&requests).Error
+	return requests, err
+}
+
+// WithdrawRequestFilter narrows the admin withdrawal listing, zero values are ignored
+type WithdrawRequestFilter struct {
+	Status       string
+	UserID       uint64
+	ChainID      uint64
+	MinRiskScore *decimal.Decimal
+	MaxRiskScore *decimal.Decimal
+}
+
+// FindByFilter returns one page of matching requests, newest first, with the total count
+func (r *WithdrawRequestRepository) FindByFilter(filter WithdrawRequestFilter, offset, limit int) ([]model.WithdrawRequest, int64, error) {
+	query := r.db.Model(&model.WithdrawRequest{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.ChainID != 0 {
+		query = query.Where("chain_id = ?", filter.ChainID)
+	}
+	if filter.MinRiskScore != nil {
+		query = query.Where("risk_score >= ?", *filter.MinRiskScore)
+	}
+	if filter.MaxRiskScore != nil {
+		query = query.Where("risk_score <= ?", *filter.MaxRiskScore)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var requests []model.WithdrawRequest
+	err := query.Preload("User").Preload("Chain").Preload("Asset").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&requests).Error
+	return requests, total, err
+}
+
+// Review applies an admin decision to a request still in one of fromStatuses
+// and writes its audit log row in the same transaction. It reports false if
+// the request was no longer in an allowed status.
+func (r *WithdrawRequestRepository) Review(id uint64, fromStatuses []string, updates map[string]interface{}, auditLog *model.AuditLog) (bool, error) {
+	applied := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.WithdrawRequest{}).
+			Where("id = ? AND status IN ?", id, fromStatuses).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Create(auditLog).Error; err != nil {
+			return err
+		}
+		applied = true
+		return nil
+	})
+	return applied, err
+}
+
 func (r *WithdrawRequestRepository) Update(request *model.WithdrawRequest) error {
 	return r.db.Save(request).Error
+}
+
+func (r *WithdrawRequestRepository) FindApprovedRequests() ([]model.WithdrawRequest, error) {
+	var requests []model.WithdrawRequest
+	err := r.db.Preload("User").Preload("Chain").Preload("Asset").
+		Where("status = ?", "approved").Order("created_at ASC").Find(&requests).Error
+	return requests, err
+}
+
+// FindBroadcastRequests returns processing requests whose tx has been sent
+func (r *WithdrawRequestRepository) FindBroadcastRequests() ([]model.WithdrawRequest, error) {
+	var requests []model.WithdrawRequest
+	err := r.db.Preload("Chain").Preload("Asset").
+		Where("status = ? AND tx_hash IS NOT NULL", "processing").Order("created_at ASC").Find(&requests).Error
+	return requests, err
+}
+
+// FindUnrecordedRequests returns processing requests without a tx hash that
+// were claimed before claimedBefore but never recorded as broadcast
+func (r *WithdrawRequestRepository) FindUnrecordedRequests(claimedBefore time.Time) ([]model.WithdrawRequest, error) {
+	var requests []model.WithdrawRequest
+	err := r.db.Preload("Chain").Preload("Asset").
+		Where("status = ? AND tx_hash IS NULL", "processing").
+		Where("claimed_at IS NULL OR claimed_at < ?", claimedBefore).
+		Order("created_at ASC").Find(&requests).Error
+	return requests, err
+}
+
+// AvailableKusdBalance returns the user's KUSD ledger balance less what their
+// processing withdrawals hold
+func (r *WithdrawRequestRepository) AvailableKusdBalance(userID uint64) (decimal.Decimal, error) {
+	var result struct {
+		Balance decimal.Decimal
+	}
+
+	err := r.db.Table("ledger_entries").
+		Select("COALESCE(SUM(kusd_delta), 0) as balance").
+		Where("user_id = ?", userID).
+		Scan(&result).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	reserved, err := sumKusdReserved(r.db, userID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return result.Balance.Sub(reserved), nil
+}
+
+func sumKusdReserved(tx *gorm.DB, userID uint64) (decimal.Decimal, error) {
+	var result struct {
+		Total decimal.Decimal
+	}
+
+	err := tx.Model(&model.WithdrawRequest{}).
+		Select("COALESCE(SUM(kusd_reserved), 0) as total").
+		Where("user_id = ? AND status = ?", userID, "processing").
+		Scan(&result).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return result.Total, nil
+}
+
+// ClaimForProcessing moves a request from fromStatus to processing and holds
+// kusdAmount of the user's balance for it until it completes or fails. The
+// user's ledger rows stay locked while the available balance is checked, so
+// concurrent claims cannot spend the same funds. It reports false if another
+// worker already claimed the request or its status changed meanwhile. A
+// request the available balance does not cover is failed instead and
+// ErrInsufficientBalance returned.
+func (r *WithdrawRequestRepository) ClaimForProcessing(id uint64, fromStatus string, kusdAmount decimal.Decimal) (bool, error) {
+	claimed, insufficient := false, false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var request model.WithdrawRequest
+		err := tx.Where("id = ? AND status = ?", id, fromStatus).First(&request).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		balance, err := lockKusdBalance(tx, request.UserID)
+		if err != nil {
+			return err
+		}
+		reserved, err := sumKusdReserved(tx, request.UserID)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":        "processing",
+			"kusd_reserved": kusdAmount,
+			"claimed_at":    time.Now(),
+		}
+		if available := balance.Sub(reserved); available.LessThan(kusdAmount) {
+			insufficient = true
+			updates = map[string]interface{}{
+				"status":       "failed",
+				"processed_at": time.Now(),
+				"admin_notes": gorm.Expr("CONCAT_WS('\\n', admin_notes, ?)",
+					fmt.Sprintf("insufficient balance: %s KUSD available, %s KUSD required", available.String(), kusdAmount.String())),
+			}
+		}
+
+		result := tx.Model(&model.WithdrawRequest{}).
+			Where("id = ? AND status = ?", id, fromStatus).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		claimed = result.RowsAffected == 1 && !insufficient
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if insufficient {
+		return false, ErrInsufficientBalance
+	}
+	return claimed, nil
+}
+
+// MarkBroadcast stores the outgoing tx hash and fee on the request and records
+// the matching outgoing onchain_txs row.
+func (r *WithdrawRequestRepository) MarkBroadcast(request *model.WithdrawRequest, onchainTx *model.OnchainTx) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(onchainTx).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.WithdrawRequest{}).
+			Where("id = ?", request.ID).
+			Updates(map[string]interface{}{
+				"tx_hash": request.TxHash,
+				"fee":     request.Fee,
+			}).Error
+	})
+}
+
+// ReplaceTxHash points a broadcast request and its onchain_txs row at the
+// transaction that replaced the original one
+func (r *WithdrawRequestRepository) ReplaceTxHash(request *model.WithdrawRequest, txHash string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.OnchainTx{}).
+			Where("tx_hash = ? AND direction = ?", *request.TxHash, "out").
+			Update("tx_hash", txHash).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&model.WithdrawRequest{}).
+			Where("id = ?", request.ID).
+			Update("tx_hash", txHash).Error
+	})
+	if err != nil {
+		return err
+	}
+	request.TxHash = &txHash
+	return nil
+}
+
+// MarkFailed fails a processing request and, if it was broadcast, its onchain tx
+func (r *WithdrawRequestRepository) MarkFailed(request *model.WithdrawRequest, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Model(&model.WithdrawRequest{}).
+			Where("id = ? AND status = ?", request.ID, "processing").
+			Updates(map[string]interface{}{
+				"status":       "failed",
+				"processed_at": now,
+				"admin_notes":  gorm.Expr("CONCAT_WS('\\n', admin_notes, ?)", reason),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		if request.TxHash == nil {
+			return nil
+		}
+		return tx.Model(&model.OnchainTx{}).
+			Where("tx_hash = ? AND direction = ?", *request.TxHash, "out").
+			Update("status", "failed").Error
+	})
+}
+
+// Complete confirms the outgoing tx, posts the withdraw and fee ledger entries
+// and marks the request completed, all in one transaction.
+func (r *WithdrawRequestRepository) Complete(request *model.WithdrawRequest, onchainTx *model.OnchainTx, withdrawEntry, feeEntry *model.LedgerEntry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.WithdrawRequest{}).
+			Where("id = ? AND status = ?", request.ID, "processing").
+			Updates(map[string]interface{}{
+				"status":       "completed",
+				"processed_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		err := tx.Model(&model.OnchainTx{}).
+			Where("id = ?", onchainTx.ID).
+			Updates(map[string]interface{}{
+				"status":        "confirmed",
+				"confirmations": onchainTx.Confirmations,
+				"confirmed_at":  now,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := insertLedgerEntry(tx, withdrawEntry); err != nil {
+			return err
+		}
+		if feeEntry != nil {
+			if err := insertLedgerEntry(tx, feeEntry); err != nil {
+				return err
+			}
+		}
+
+		request.Status = "completed"
+		request.ProcessedAt = &now
+		request.LedgerEntryID = &withdrawEntry.ID
+		return tx.Model(&model.WithdrawRequest{}).
+			Where("id = ?", request.ID).
+			Update("ledger_entry_id", withdrawEntry.ID).Error
+	})
 }
\ No newline at end of file
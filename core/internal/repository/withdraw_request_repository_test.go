@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/pkg/database"
+)
+
+// newTestDB returns a migrated SQLite database private to the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func TestSumAmountByAssetSince(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewWithdrawRequestRepository(db)
+
+	eth := &model.Asset{Symbol: "ETH", Name: "Ether", Decimals: 18, AssetType: "eth"}
+	usdt := &model.Asset{Symbol: "USDT", Name: "Tether", Decimals: 6, AssetType: "stable"}
+	for _, asset := range []*model.Asset{eth, usdt} {
+		if err := db.Create(asset).Error; err != nil {
+			t.Fatalf("failed to seed asset: %v", err)
+		}
+	}
+
+	now := time.Now()
+	seed := []struct {
+		userID  uint64
+		asset   *model.Asset
+		amount  string
+		status  string
+		created time.Time
+	}{
+		{1, eth, "0.5", "completed", now.Add(-time.Hour)},
+		{1, eth, "1.25", "processing", now.Add(-2 * time.Hour)},
+		{1, usdt, "300", "pending", now.Add(-3 * time.Hour)},
+		{1, usdt, "700", "completed", now.Add(-4 * time.Hour)},
+		{1, usdt, "5000", "failed", now.Add(-time.Hour)},     // status not counted
+		{1, eth, "9", "completed", now.Add(-48 * time.Hour)}, // before the window
+		{2, eth, "3", "completed", now.Add(-time.Hour)},      // another user
+	}
+	for _, s := range seed {
+		err := db.Create(&model.WithdrawRequest{
+			UserID:    s.userID,
+			ChainID:   1,
+			AssetID:   s.asset.ID,
+			Amount:    decimal.RequireFromString(s.amount),
+			ToAddress: "0x00000000000000000000000000000000000000aa",
+			Status:    s.status,
+			CreatedAt: s.created,
+		}).Error
+		if err != nil {
+			t.Fatalf("failed to seed request: %v", err)
+		}
+	}
+
+	since := now.Add(-24 * time.Hour)
+	active := []string{"pending", "processing", "completed"}
+
+	sums, err := repo.SumAmountByAssetSince(1, since, active)
+	if err != nil {
+		t.Fatalf("failed to sum: %v", err)
+	}
+	want := []AssetAmountSum{
+		{Symbol: "ETH", Total: decimal.RequireFromString("1.75"), Count: 2},
+		{Symbol: "USDT", Total: decimal.NewFromInt(1000), Count: 2},
+	}
+	if len(sums) != len(want) {
+		t.Fatalf("got %+v, want %+v", sums, want)
+	}
+	for i := range want {
+		if sums[i].Symbol != want[i].Symbol || !sums[i].Total.Equal(want[i].Total) || sums[i].Count != want[i].Count {
+			t.Fatalf("got %+v, want %+v", sums, want)
+		}
+	}
+
+	completed, err := repo.SumAmountByAssetSince(1, since, []string{"completed"})
+	if err != nil {
+		t.Fatalf("failed to sum completed: %v", err)
+	}
+	if len(completed) != 2 || !completed[0].Total.Equal(decimal.RequireFromString("0.5")) || !completed[1].Total.Equal(decimal.NewFromInt(700)) {
+		t.Fatalf("got %+v for completed requests, want 0.5 ETH and 700 USDT", completed)
+	}
+
+	none, err := repo.SumAmountByAssetSince(3, since, active)
+	if err != nil {
+		t.Fatalf("failed to sum without history: %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("got %+v for a user without withdrawals, want none", none)
+	}
+}
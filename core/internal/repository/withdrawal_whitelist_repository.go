@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+)
+
+type WithdrawalWhitelistRepository struct {
+	db *gorm.DB
+}
+
+func NewWithdrawalWhitelistRepository(db *gorm.DB) *WithdrawalWhitelistRepository {
+	return &WithdrawalWhitelistRepository{
+		db: db,
+	}
+}
+
+func (r *WithdrawalWhitelistRepository) Create(entry *model.WithdrawalWhitelist) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *WithdrawalWhitelistRepository) Update(entry *model.WithdrawalWhitelist) error {
+	return r.db.Save(entry).Error
+}
+
+func (r *WithdrawalWhitelistRepository) FindByID(id uint64) (*model.WithdrawalWhitelist, error) {
+	var entry model.WithdrawalWhitelist
+	err := r.db.Preload("Chain").Where("id = ?", id).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindByAddress returns the user's entry for the address, active or not
+func (r *WithdrawalWhitelistRepository) FindByAddress(userID, chainID uint64, address string) (*model.WithdrawalWhitelist, error) {
+	var entry model.WithdrawalWhitelist
+	err := r.db.Where("user_id = ? AND chain_id = ? AND address = ?", userID, chainID, address).
+		First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *WithdrawalWhitelistRepository) FindActiveByUserID(userID uint64) ([]model.WithdrawalWhitelist, error) {
+	var entries []model.WithdrawalWhitelist
+	err := r.db.Preload("Chain").
+		Where("user_id = ? AND is_active = ?", userID, true).
+		Order("created_at DESC").Find(&entries).Error
+	return entries, err
+}
+
+// IsWhitelisted reports whether the address is whitelisted for the user and
+// its cooling-off period is over.
+func (r *WithdrawalWhitelistRepository) IsWhitelisted(userID, chainID uint64, address string, at time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.WithdrawalWhitelist{}).
+		Where("user_id = ? AND chain_id = ? AND address = ? AND is_active = ? AND activates_at <= ?",
+			userID, chainID, address, true, at).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *WithdrawalWhitelistRepository) Deactivate(id uint64) error {
+	return r.db.Model(&model.WithdrawalWhitelist{}).
+		Where("id = ?", id).
+		Update("is_active", false).Error
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/utils"
+)
+
+type AdminAuthService struct {
+	adminUserRepo *repository.AdminUserRepository
+}
+
+func NewAdminAuthService(adminUserRepo *repository.AdminUserRepository) *AdminAuthService {
+	return &AdminAuthService{
+		adminUserRepo: adminUserRepo,
+	}
+}
+
+type AdminInfoResponse struct {
+	ID          uint64   `json:"id"`
+	Username    string   `json:"username"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+// Login checks the admin password and returns an admin JWT
+func (s *AdminAuthService) Login(username, password string) (string, *AdminInfoResponse, error) {
+	admin, err := s.adminUserRepo.FindByUsername(username)
+	if err != nil || !admin.Enabled {
+		return "", nil, fmt.Errorf("invalid username or password")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)); err != nil {
+		return "", nil, fmt.Errorf("invalid username or password")
+	}
+
+	token, err := utils.GenerateAdminJWT(admin.ID, admin.Role)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+
+	if err := s.adminUserRepo.UpdateLastLogin(admin.ID, time.Now()); err != nil {
+		return "", nil, fmt.Errorf("failed to update last login: %v", err)
+	}
+
+	return token, &AdminInfoResponse{
+		ID:          admin.ID,
+		Username:    admin.Username,
+		Role:        admin.Role,
+		Permissions: utils.RolePermissions(admin.Role),
+	}, nil
+}
+
+// CurrentRole returns the role of an enabled admin. Admin requests are
+// authorised with it, so a disabled or re-roled admin's tokens follow the
+// change at once.
+func (s *AdminAuthService) CurrentRole(adminID uint64) (string, error) {
+	admin, err := s.adminUserRepo.FindByID(adminID)
+	if err != nil {
+		return "", fmt.Errorf("admin not found: %v", err)
+	}
+	if !admin.Enabled {
+		return "", fmt.Errorf("admin %d is disabled", adminID)
+	}
+	return admin.Role, nil
+}
+
+// EnsureAdmin creates an admin account unless one with the username exists.
+// It is used to seed the first admin from configuration.
+func (s *AdminAuthService) EnsureAdmin(username, password, role string) (bool, error) {
+	if !utils.IsValidRole(role) {
+		return false, fmt.Errorf("unknown admin role: %s", role)
+	}
+
+	_, err := s.adminUserRepo.FindByUsername(username)
+	if err == nil {
+		return false, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return false, fmt.Errorf("failed to look up admin: %v", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	admin := &model.AdminUser{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		Enabled:      true,
+	}
+	if err := s.adminUserRepo.Create(admin); err != nil {
+		return false, fmt.Errorf("failed to create admin: %v", err)
+	}
+	return true, nil
+}
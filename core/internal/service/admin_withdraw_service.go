@@ -0,0 +1,195 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+type AdminWithdrawService struct {
+	withdrawRequestRepo *repository.WithdrawRequestRepository
+	chainRepo           *repository.ChainRepository
+}
+
+func NewAdminWithdrawService(withdrawRequestRepo *repository.WithdrawRequestRepository, chainRepo *repository.ChainRepository) *AdminWithdrawService {
+	return &AdminWithdrawService{
+		withdrawRequestRepo: withdrawRequestRepo,
+		chainRepo:           chainRepo,
+	}
+}
+
+// WithdrawListQuery holds the admin listing filters as received from the API
+type WithdrawListQuery struct {
+	Status       string
+	UserID       uint64
+	ChainKey     string
+	MinRiskScore string
+	MaxRiskScore string
+	Page         int
+	PageSize     int
+}
+
+// AuditContext identifies who made an admin decision and from where
+type AuditContext struct {
+	AdminID   uint64
+	IPAddress string
+	UserAgent string
+}
+
+// reviewableStatuses are the statuses an operator may still act on
+var reviewableStatuses = []string{"pending", "pending_review", "approved"}
+
+func (s *AdminWithdrawService) ListWithdrawRequests(query WithdrawListQuery) ([]model.WithdrawRequest, int64, error) {
+	filter := repository.WithdrawRequestFilter{
+		Status: query.Status,
+		UserID: query.UserID,
+	}
+
+	if query.ChainKey != "" {
+		chain, err := s.chainRepo.FindByChainKey(query.ChainKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("chain not found: %v", err)
+		}
+		filter.ChainID = chain.ID
+	}
+
+	if query.MinRiskScore != "" {
+		score, err := decimal.NewFromString(query.MinRiskScore)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid minRiskScore: %v", err)
+		}
+		filter.MinRiskScore = &score
+	}
+	if query.MaxRiskScore != "" {
+		score, err := decimal.NewFromString(query.MaxRiskScore)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid maxRiskScore: %v", err)
+		}
+		filter.MaxRiskScore = &score
+	}
+
+	offset := (query.Page - 1) * query.PageSize
+	return s.withdrawRequestRepo.FindByFilter(filter, offset, query.PageSize)
+}
+
+// ApproveWithdrawRequest releases a request held for review to the withdrawal executor
+func (s *AdminWithdrawService) ApproveWithdrawRequest(id uint64, notes string, audit AuditContext) (*model.WithdrawRequest, error) {
+	return s.review(id, "withdraw_approve", []string{"pending_review"}, "approved", notes, audit)
+}
+
+// RejectWithdrawRequest rejects a request that has not been broadcast yet
+func (s *AdminWithdrawService) RejectWithdrawRequest(id uint64, notes string, audit AuditContext) (*model.WithdrawRequest, error) {
+	return s.review(id, "withdraw_reject", reviewableStatuses, "rejected", notes, audit)
+}
+
+// AddWithdrawNotes appends an admin note without changing the request status
+func (s *AdminWithdrawService) AddWithdrawNotes(id uint64, notes string, audit AuditContext) (*model.WithdrawRequest, error) {
+	if notes == "" {
+		return nil, fmt.Errorf("notes must not be empty")
+	}
+	return s.review(id, "withdraw_notes", nil, "", notes, audit)
+}
+
+// review applies a status change and/or note and records it in audit_logs.
+// An empty newStatus keeps the current status; nil fromStatuses allows any.
+func (s *AdminWithdrawService) review(id uint64, action string, fromStatuses []string, newStatus, notes string, audit AuditContext) (*model.WithdrawRequest, error) {
+	request, err := s.withdrawRequestRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("withdrawal request not found: %v", err)
+	}
+
+	if fromStatuses == nil {
+		fromStatuses = []string{request.Status}
+	} else if !containsStatus(fromStatuses, request.Status) {
+		return nil, fmt.Errorf("withdrawal request in status %s cannot be %s", request.Status, newStatus)
+	}
+
+	oldValues := map[string]interface{}{
+		"status":     request.Status,
+		"adminNotes": request.AdminNotes,
+	}
+
+	updates := make(map[string]interface{})
+	newValues := make(map[string]interface{})
+
+	if newStatus != "" {
+		updates["status"] = newStatus
+		newValues["status"] = newStatus
+		request.Status = newStatus
+	}
+	if newStatus == "rejected" {
+		now := time.Now()
+		updates["processed_at"] = now
+		request.ProcessedAt = &now
+	}
+	if notes != "" {
+		merged := notes
+		if request.AdminNotes != nil && *request.AdminNotes != "" {
+			merged = *request.AdminNotes + "\n" + notes
+		}
+		updates["admin_notes"] = merged
+		newValues["adminNotes"] = merged
+		request.AdminNotes = &merged
+	}
+
+	auditLog, err := newAuditLog(action, "withdraw_request", id, &request.UserID, oldValues, newValues, audit)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.withdrawRequestRepo.Review(id, fromStatuses, updates, auditLog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update withdrawal request: %v", err)
+	}
+	if !applied {
+		return nil, fmt.Errorf("withdrawal request was modified concurrently, please retry")
+	}
+
+	return request, nil
+}
+
+// newAuditLog builds an audit row for an admin action on a resource owned by userID
+func newAuditLog(action, resourceType string, resourceID uint64, userID *uint64, oldValues, newValues interface{}, audit AuditContext) (*model.AuditLog, error) {
+	oldJSON, err := json.Marshal(oldValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode old values: %v", err)
+	}
+	newJSON, err := json.Marshal(newValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode new values: %v", err)
+	}
+
+	adminID := audit.AdminID
+	resourceIDStr := strconv.FormatUint(resourceID, 10)
+	auditLog := &model.AuditLog{
+		UserID:       userID,
+		AdminID:      &adminID,
+		Action:       action,
+		ResourceType: &resourceType,
+		ResourceID:   &resourceIDStr,
+		OldValues:    oldJSON,
+		NewValues:    newJSON,
+	}
+	if audit.IPAddress != "" {
+		auditLog.IPAddress = &audit.IPAddress
+	}
+	if audit.UserAgent != "" {
+		auditLog.UserAgent = &audit.UserAgent
+	}
+	return auditLog, nil
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+func TestAdminWithdrawReviewWritesAuditLog(t *testing.T) {
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+	user := &model.User{}
+	admin := &model.AdminUser{Username: "reviewer", PasswordHash: "x", Role: "risk_officer", Enabled: true}
+	for _, record := range []interface{}{user, admin} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed %T: %v", record, err)
+		}
+	}
+
+	withdrawRequestRepo := repository.NewWithdrawRequestRepository(db)
+	request := func(status string) *model.WithdrawRequest {
+		t.Helper()
+		notes := "held by risk check"
+		req := &model.WithdrawRequest{
+			UserID:     user.ID,
+			ChainID:    chain.ID,
+			AssetID:    asset.ID,
+			Amount:     decimal.NewFromInt(1),
+			ToAddress:  "0x00000000000000000000000000000000000000aa",
+			Status:     status,
+			AdminNotes: &notes,
+		}
+		if err := withdrawRequestRepo.Create(req); err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		return req
+	}
+	auditLogs := func(id uint64) []model.AuditLog {
+		t.Helper()
+		var logs []model.AuditLog
+		if err := db.Where("resource_type = ? AND resource_id = ?", "withdraw_request", strconv.FormatUint(id, 10)).Order("id ASC").Find(&logs).Error; err != nil {
+			t.Fatalf("failed to load audit logs: %v", err)
+		}
+		return logs
+	}
+
+	service := NewAdminWithdrawService(withdrawRequestRepo, repository.NewChainRepository(db))
+	audit := AuditContext{AdminID: admin.ID, IPAddress: "203.0.113.7", UserAgent: "review-console"}
+
+	held := request("pending_review")
+	approved, err := service.ApproveWithdrawRequest(held.ID, "source of funds verified", audit)
+	if err != nil {
+		t.Fatalf("failed to approve: %v", err)
+	}
+	stored, err := withdrawRequestRepo.FindByID(held.ID)
+	if err != nil {
+		t.Fatalf("failed to reload request: %v", err)
+	}
+	wantNotes := "held by risk check\nsource of funds verified"
+	if approved.Status != "approved" || stored.Status != "approved" || stored.AdminNotes == nil || *stored.AdminNotes != wantNotes {
+		t.Fatalf("got status %s with notes %v, want approved with %q", stored.Status, stored.AdminNotes, wantNotes)
+	}
+
+	logs := auditLogs(held.ID)
+	if len(logs) != 1 {
+		t.Fatalf("got %d audit logs, want 1", len(logs))
+	}
+	entry := logs[0]
+	if entry.Action != "withdraw_approve" || entry.AdminID == nil || *entry.AdminID != admin.ID ||
+		entry.UserID == nil || *entry.UserID != user.ID ||
+		entry.IPAddress == nil || *entry.IPAddress != audit.IPAddress || entry.UserAgent == nil || *entry.UserAgent != audit.UserAgent {
+		t.Fatalf("got audit log %+v, want the approval by admin %d of user %d's request", entry, admin.ID, user.ID)
+	}
+	var oldValues, newValues map[string]interface{}
+	if err := json.Unmarshal(entry.OldValues, &oldValues); err != nil {
+		t.Fatalf("invalid old values: %v", err)
+	}
+	if err := json.Unmarshal(entry.NewValues, &newValues); err != nil {
+		t.Fatalf("invalid new values: %v", err)
+	}
+	if oldValues["status"] != "pending_review" || oldValues["adminNotes"] != "held by risk check" ||
+		newValues["status"] != "approved" || newValues["adminNotes"] != wantNotes {
+		t.Fatalf("got old values %v and new values %v", oldValues, newValues)
+	}
+
+	// Only a held request can be approved, and a refused decision leaves no trace
+	if _, err := service.ApproveWithdrawRequest(held.ID, "", audit); err == nil {
+		t.Fatalf("approved a request twice")
+	}
+	if logs := auditLogs(held.ID); len(logs) != 1 {
+		t.Fatalf("got %d audit logs after a refused approval, want 1", len(logs))
+	}
+
+	// An approved request not yet sent can still be rejected
+	rejected, err := service.RejectWithdrawRequest(held.ID, "customer asked to cancel", audit)
+	if err != nil {
+		t.Fatalf("failed to reject: %v", err)
+	}
+	if rejected.Status != "rejected" || rejected.ProcessedAt == nil {
+		t.Fatalf("got status %s processed at %v, want rejected with a processing time", rejected.Status, rejected.ProcessedAt)
+	}
+	logs = auditLogs(held.ID)
+	if len(logs) != 2 || logs[1].Action != "withdraw_reject" {
+		t.Fatalf("got audit logs %+v, want the approval then the rejection", logs)
+	}
+	newValues = nil
+	if err := json.Unmarshal(logs[1].NewValues, &newValues); err != nil || newValues["status"] != "rejected" {
+		t.Fatalf("got rejection new values %s (%v)", logs[1].NewValues, err)
+	}
+
+	// A request already being sent cannot be rejected
+	processing := request("processing")
+	if _, err := service.RejectWithdrawRequest(processing.ID, "too late", audit); err == nil {
+		t.Fatalf("rejected a request in processing")
+	}
+	if stored, _ := withdrawRequestRepo.FindByID(processing.ID); stored.Status != "processing" || len(auditLogs(processing.ID)) != 0 {
+		t.Fatalf("got status %s after a refused rejection, want processing without an audit log", stored.Status)
+	}
+}
@@ -2,28 +2,31 @@ package service
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 
+	"usdk-backend/internal/model"
 	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/signer"
 )
 
+// BlockchainService reads and writes the USDK and ProofRegistry contracts of
+// any chain in the registry. An empty chain key selects the default chain.
+// Transactions are sent through the TxManagerService from the account of the
+// role allowed to make them: Mint from the minter, Transfer and Burn from the
+// treasury.
 type BlockchainService struct {
-	client               *ethclient.Client
-	contractConfig       *contracts.ContractConfig
-	usdkContract        *contracts.USDKContract
-	proofRegistryContract *contracts.ProofRegistryContract
-	privateKey          *ecdsa.PrivateKey
-	auth               *bind.TransactOpts
+	registry     *ChainRegistry
+	txManager    *TxManagerService
+	defaultChain string
+	senders      map[string]common.Address // keyed by signer role
 }
 
 type TokenInfo struct {
+	Chain        string `json:"chain"`
 	Name         string `json:"name"`
 	Symbol       string `json:"symbol"`
 	Decimals     uint8  `json:"decimals"`
@@ -32,20 +35,22 @@ type TokenInfo struct {
 }
 
 type BalanceInfo struct {
+	Chain   string `json:"chain"`
 	Address string `json:"address"`
 	Balance string `json:"balance"`
 }
 
 type ProofBatchInfo struct {
-	BatchId        string `json:"batchId"`
-	Root           string `json:"root"`
-	BatchType      uint8  `json:"batchType"`
-	StartTimestamp uint64 `json:"startTimestamp"`
-	EndTimestamp   uint64 `json:"endTimestamp"`
-	Uri            string `json:"uri"`
-	Publisher      string `json:"publisher"`
-	EntryCount     uint32 `json:"entryCount"`
-	Verified       bool   `json:"verified"`
+	Chain          string              `json:"chain"`
+	BatchId        string              `json:"batchId"`
+	Root           string              `json:"root"`
+	BatchType      contracts.BatchType `json:"batchType"`
+	StartTimestamp uint64              `json:"startTimestamp"`
+	EndTimestamp   uint64              `json:"endTimestamp"`
+	Uri            string              `json:"uri"`
+	Publisher      string              `json:"publisher"`
+	EntryCount     uint32              `json:"entryCount"`
+	Verified       bool                `json:"verified"`
 }
 
 type TransactionResult struct {
@@ -54,190 +59,245 @@ type TransactionResult struct {
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
-func NewBlockchainService() (*BlockchainService, error) {
-	contractConfig := contracts.NewContractConfig()
+func NewBlockchainService(registry *ChainRegistry, txManager *TxManagerService, defaultChain string) *BlockchainService {
+	return &BlockchainService{
+		registry:     registry,
+		txManager:    txManager,
+		defaultChain: defaultChain,
+		senders:      make(map[string]common.Address),
+	}
+}
 
-	// Connect to Ethereum client
-	client, err := ethclient.Dial(contractConfig.RPCEndpoint)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum client: %v", err)
+// SetSigner registers the signer of a role for the transaction methods
+func (bs *BlockchainService) SetSigner(role string, s signer.Signer) {
+	bs.senders[role] = bs.txManager.AddSigner(s)
+}
+
+// Chains returns the keys of every chain the service can reach
+func (bs *BlockchainService) Chains() []string {
+	bindings := bs.registry.Chains()
+	keys := make([]string, len(bindings))
+	for i, binding := range bindings {
+		keys[i] = binding.Chain.ChainKey
 	}
+	return keys
+}
 
-	// Initialize USDK contract
-	usdkContract, err := contracts.NewUSDKContract(contractConfig.USDKAddress, client)
-	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate USDK contract: %v", err)
+// HasChain reports whether a chain is available
+func (bs *BlockchainService) HasChain(chain string) bool {
+	_, err := bs.registry.Get(chain)
+	return err == nil
+}
+
+func (bs *BlockchainService) binding(chain string) (*ChainBinding, error) {
+	if chain == "" {
+		chain = bs.defaultChain
 	}
+	return bs.registry.Get(chain)
+}
 
-	// Initialize ProofRegistry contract
-	proofRegistryContract, err := contracts.NewProofRegistryContract(contractConfig.ProofRegistryAddress, client)
+func (bs *BlockchainService) usdk(chain string) (*ChainBinding, error) {
+	binding, err := bs.binding(chain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate ProofRegistry contract: %v", err)
+		return nil, err
 	}
-
-	service := &BlockchainService{
-		client:               client,
-		contractConfig:       contractConfig,
-		usdkContract:        usdkContract,
-		proofRegistryContract: proofRegistryContract,
+	if binding.USDK == nil {
+		return nil, fmt.Errorf("no USDK contract configured for chain %s", binding.Chain.ChainKey)
 	}
-
-	return service, nil
+	return binding, nil
 }
 
-// SetPrivateKey sets the private key for transactions (optional - for admin operations)
-func (bs *BlockchainService) SetPrivateKey(privateKeyHex string) error {
-	privateKey, err := crypto.HexToECDSA(privateKeyHex)
+func (bs *BlockchainService) proofRegistry(chain string) (*ChainBinding, error) {
+	binding, err := bs.binding(chain)
 	if err != nil {
-		return fmt.Errorf("failed to load private key: %v", err)
+		return nil, err
 	}
+	if binding.ProofRegistry == nil {
+		return nil, fmt.Errorf("no ProofRegistry configured for chain %s", binding.Chain.ChainKey)
+	}
+	return binding, nil
+}
 
-	// Get chain ID
-	chainID, err := bs.client.NetworkID(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to get network ID: %v", err)
+// sendUSDK calls a USDK method on the chain of a binding from the account of
+// role, through the tx manager
+func (bs *BlockchainService) sendUSDK(binding *ChainBinding, role, purpose, method string, args ...interface{}) (*model.OutgoingTx, error) {
+	sender, ok := bs.senders[role]
+	if !ok {
+		return nil, fmt.Errorf("no %s signer configured", role)
 	}
 
-	// Create transaction authorization
-	auth, err := bind.NewKeyedTransactorWithChainID(privateKey, chainID)
+	usdkABI, err := contracts.USDKContractMetaData.GetAbi()
 	if err != nil {
-		return fmt.Errorf("failed to create authorized transactor: %v", err)
+		return nil, err
+	}
+	data, err := usdkABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %v", method, err)
 	}
 
-	bs.privateKey = privateKey
-	bs.auth = auth
-
-	return nil
+	return bs.txManager.Send(context.Background(), binding.Chain, sender, TxRequest{
+		To:      binding.USDKAddress,
+		Data:    data,
+		Purpose: purpose,
+	})
 }
 
 // USDK Token Methods
 
-func (bs *BlockchainService) GetTokenInfo() (*TokenInfo, error) {
-	name, err := bs.usdkContract.Name(&bind.CallOpts{})
+func (bs *BlockchainService) GetTokenInfo(chain string) (*TokenInfo, error) {
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := binding.USDK.Name(&bind.CallOpts{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token name: %v", err)
 	}
 
-	symbol, err := bs.usdkContract.Symbol(&bind.CallOpts{})
+	symbol, err := binding.USDK.Symbol(&bind.CallOpts{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token symbol: %v", err)
 	}
 
-	decimals, err := bs.usdkContract.Decimals(&bind.CallOpts{})
+	decimals, err := binding.USDK.Decimals(&bind.CallOpts{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token decimals: %v", err)
 	}
 
-	totalSupply, err := bs.usdkContract.TotalSupply(&bind.CallOpts{})
+	totalSupply, err := binding.USDK.TotalSupply(&bind.CallOpts{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total supply: %v", err)
 	}
 
 	return &TokenInfo{
+		Chain:        binding.Chain.ChainKey,
 		Name:         name,
 		Symbol:       symbol,
 		Decimals:     decimals,
 		TotalSupply:  totalSupply.String(),
-		ContractAddr: bs.contractConfig.USDKAddress.Hex(),
+		ContractAddr: binding.USDKAddress.Hex(),
 	}, nil
 }
 
-func (bs *BlockchainService) GetBalance(address string) (*BalanceInfo, error) {
+func (bs *BlockchainService) GetBalance(chain, address string) (*BalanceInfo, error) {
 	if !common.IsHexAddress(address) {
 		return nil, fmt.Errorf("invalid Ethereum address")
 	}
 
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return nil, err
+	}
+
 	addr := common.HexToAddress(address)
-	balance, err := bs.usdkContract.BalanceOf(&bind.CallOpts{}, addr)
+	balance, err := binding.USDK.BalanceOf(&bind.CallOpts{}, addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get balance: %v", err)
 	}
 
 	return &BalanceInfo{
+		Chain:   binding.Chain.ChainKey,
 		Address: address,
 		Balance: balance.String(),
 	}, nil
 }
 
-func (bs *BlockchainService) IsBlacklisted(address string) (bool, error) {
+func (bs *BlockchainService) IsBlacklisted(chain, address string) (bool, error) {
 	if !common.IsHexAddress(address) {
 		return false, fmt.Errorf("invalid Ethereum address")
 	}
 
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return false, err
+	}
+
 	addr := common.HexToAddress(address)
-	return bs.usdkContract.IsBlacklisted(&bind.CallOpts{}, addr)
+	return binding.USDK.IsBlacklisted(&bind.CallOpts{}, addr)
 }
 
-func (bs *BlockchainService) IsPaused() (bool, error) {
-	return bs.usdkContract.Paused(&bind.CallOpts{})
-}
+func (bs *BlockchainService) IsPaused(chain string) (bool, error) {
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return false, err
+	}
 
-// Transaction methods (require private key)
+	return binding.USDK.Paused(&bind.CallOpts{})
+}
 
-func (bs *BlockchainService) Transfer(to string, amount *big.Int) (*TransactionResult, error) {
-	if bs.auth == nil {
-		return nil, fmt.Errorf("private key not set")
-	}
+// Transaction methods (require the signer of their role)
 
+func (bs *BlockchainService) Transfer(chain, to string, amount *big.Int) (*TransactionResult, error) {
 	if !common.IsHexAddress(to) {
 		return nil, fmt.Errorf("invalid recipient address")
 	}
 
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return nil, err
+	}
+
 	toAddr := common.HexToAddress(to)
-	tx, err := bs.usdkContract.Transfer(bs.auth, toAddr, amount)
+	tx, err := bs.sendUSDK(binding, signer.RoleTreasury, "transfer", "transfer", toAddr, amount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to transfer: %v", err)
 	}
 
 	return &TransactionResult{
-		TxHash:  tx.Hash().Hex(),
+		TxHash:  tx.TxHash,
 		Success: true,
 		Data: map[string]interface{}{
+			"chain":  binding.Chain.ChainKey,
 			"to":     to,
 			"amount": amount.String(),
 		},
 	}, nil
 }
 
-func (bs *BlockchainService) Mint(to string, amount *big.Int) (*TransactionResult, error) {
-	if bs.auth == nil {
-		return nil, fmt.Errorf("private key not set")
-	}
-
+func (bs *BlockchainService) Mint(chain, to string, amount *big.Int) (*TransactionResult, error) {
 	if !common.IsHexAddress(to) {
 		return nil, fmt.Errorf("invalid recipient address")
 	}
 
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return nil, err
+	}
+
 	toAddr := common.HexToAddress(to)
-	tx, err := bs.usdkContract.Mint(bs.auth, toAddr, amount)
+	tx, err := bs.sendUSDK(binding, signer.RoleMinter, "mint", "mint", toAddr, amount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to mint: %v", err)
 	}
 
 	return &TransactionResult{
-		TxHash:  tx.Hash().Hex(),
+		TxHash:  tx.TxHash,
 		Success: true,
 		Data: map[string]interface{}{
+			"chain":  binding.Chain.ChainKey,
 			"to":     to,
 			"amount": amount.String(),
 		},
 	}, nil
 }
 
-func (bs *BlockchainService) Burn(amount *big.Int) (*TransactionResult, error) {
-	if bs.auth == nil {
-		return nil, fmt.Errorf("private key not set")
+func (bs *BlockchainService) Burn(chain string, amount *big.Int) (*TransactionResult, error) {
+	binding, err := bs.usdk(chain)
+	if err != nil {
+		return nil, err
 	}
 
-	tx, err := bs.usdkContract.Burn(bs.auth, amount)
+	tx, err := bs.sendUSDK(binding, signer.RoleTreasury, "burn", "burn", amount)
 	if err != nil {
 		return nil, fmt.Errorf("failed to burn: %v", err)
 	}
 
 	return &TransactionResult{
-		TxHash:  tx.Hash().Hex(),
+		TxHash:  tx.TxHash,
 		Success: true,
 		Data: map[string]interface{}{
+			"chain":  binding.Chain.ChainKey,
 			"amount": amount.String(),
 		},
 	}, nil
@@ -245,16 +305,22 @@ func (bs *BlockchainService) Burn(amount *big.Int) (*TransactionResult, error) {
 
 // ProofRegistry Methods
 
-func (bs *BlockchainService) GetProofBatch(batchId *big.Int) (*ProofBatchInfo, error) {
-	batch, err := bs.proofRegistryContract.GetBatch(&bind.CallOpts{}, batchId)
+func (bs *BlockchainService) GetProofBatch(chain string, batchId *big.Int) (*ProofBatchInfo, error) {
+	binding, err := bs.proofRegistry(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := binding.ProofRegistry.GetBatch(&bind.CallOpts{}, batchId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get batch: %v", err)
 	}
 
 	return &ProofBatchInfo{
+		Chain:          binding.Chain.ChainKey,
 		BatchId:        batchId.String(),
 		Root:           common.Bytes2Hex(batch.Root[:]),
-		BatchType:      batch.BatchType,
+		BatchType:      contracts.BatchType(batch.BatchType),
 		StartTimestamp: batch.StartTimestamp,
 		EndTimestamp:   batch.EndTimestamp,
 		Uri:            batch.Uri,
@@ -264,28 +330,58 @@ func (bs *BlockchainService) GetProofBatch(batchId *big.Int) (*ProofBatchInfo, e
 	}, nil
 }
 
-func (bs *BlockchainService) GetBatchCount() (*big.Int, error) {
-	return bs.proofRegistryContract.GetBatchCount(&bind.CallOpts{})
+func (bs *BlockchainService) GetBatchCount(chain string) (*big.Int, error) {
+	binding, err := bs.proofRegistry(chain)
+	if err != nil {
+		return nil, err
+	}
+	return binding.ProofRegistry.GetBatchCount(&bind.CallOpts{})
+}
+
+func (bs *BlockchainService) GetBatchCountByType(chain string, batchType contracts.BatchType) (*big.Int, error) {
+	binding, err := bs.proofRegistry(chain)
+	if err != nil {
+		return nil, err
+	}
+	return binding.ProofRegistry.GetBatchCountByType(&bind.CallOpts{}, uint8(batchType))
 }
 
-func (bs *BlockchainService) GetBatchCountByType(batchType uint8) (*big.Int, error) {
-	return bs.proofRegistryContract.GetBatchCountByType(&bind.CallOpts{}, batchType)
+func (bs *BlockchainService) GetBatchesByType(chain string, batchType contracts.BatchType, offset, limit *big.Int) ([]*big.Int, error) {
+	binding, err := bs.proofRegistry(chain)
+	if err != nil {
+		return nil, err
+	}
+	return binding.ProofRegistry.GetBatchesByType(&bind.CallOpts{}, uint8(batchType), offset, limit)
 }
 
-func (bs *BlockchainService) GetBatchesByType(batchType uint8, offset, limit *big.Int) ([]*big.Int, error) {
-	return bs.proofRegistryContract.GetBatchesByType(&bind.CallOpts{}, batchType, offset, limit)
+func (bs *BlockchainService) VerifyProof(chain string, batchId *big.Int, leaf [32]byte, proof [][32]byte) (bool, error) {
+	binding, err := bs.proofRegistry(chain)
+	if err != nil {
+		return false, err
+	}
+	return binding.ProofRegistry.VerifyProof(&bind.CallOpts{}, batchId, leaf, proof)
 }
 
-func (bs *BlockchainService) VerifyProof(batchId *big.Int, leaf [32]byte, proof [][32]byte) (bool, error) {
-	return bs.proofRegistryContract.VerifyProof(&bind.CallOpts{}, batchId, leaf, proof)
+// ProofRegistryAddress returns the ProofRegistry contract VerifyProof checks against on a chain
+func (bs *BlockchainService) ProofRegistryAddress(chain string) (common.Address, error) {
+	binding, err := bs.proofRegistry(chain)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return binding.ProofRegistryAddress, nil
 }
 
 // Utility Methods
 
-func (bs *BlockchainService) GetTransactionStatus(txHash string) (map[string]interface{}, error) {
+func (bs *BlockchainService) GetTransactionStatus(chain, txHash string) (map[string]interface{}, error) {
+	binding, err := bs.binding(chain)
+	if err != nil {
+		return nil, err
+	}
+
 	hash := common.HexToHash(txHash)
 
-	receipt, err := bs.client.TransactionReceipt(context.Background(), hash)
+	receipt, err := binding.Client.TransactionReceipt(context.Background(), hash)
 	if err != nil {
 		return nil, fmt.Errorf("transaction not found: %v", err)
 	}
@@ -296,6 +392,7 @@ func (bs *BlockchainService) GetTransactionStatus(txHash string) (map[string]int
 	}
 
 	return map[string]interface{}{
+		"chain":       binding.Chain.ChainKey,
 		"txHash":      txHash,
 		"status":      status,
 		"blockNumber": receipt.BlockNumber.String(),
@@ -303,7 +400,7 @@ func (bs *BlockchainService) GetTransactionStatus(txHash string) (map[string]int
 	}, nil
 }
 
-func (bs *BlockchainService) HealthCheck() error {
-	_, err := bs.client.NetworkID(context.Background())
-	return err
-}
\ No newline at end of file
+// HealthCheck reports the RPC status of every chain
+func (bs *BlockchainService) HealthCheck(ctx context.Context) []ChainHealth {
+	return bs.registry.Health(ctx)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/pkg/contracts"
+)
+
+// unreachableClient is a chain whose RPC does not answer
+type unreachableClient struct {
+	*backends.SimulatedBackend
+}
+
+func (unreachableClient) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestBlockchainServiceRoutesByChain(t *testing.T) {
+	db := newTestDB(t)
+	adminKey, _ := crypto.GenerateKey()
+	admin := crypto.PubkeyToAddress(adminKey.PublicKey)
+
+	// Two chains with their own ProofRegistry, and one without a registry
+	// whose RPC is down
+	bindings := make(map[string]*ChainBinding)
+	sims := make(map[string]*backends.SimulatedBackend)
+	for i, key := range []string{"first", "second", "down"} {
+		chain := &model.Chain{ChainKey: key, NetworkID: uint64(i + 1), Name: key, Enabled: true}
+		if err := db.Create(chain).Error; err != nil {
+			t.Fatalf("failed to seed chain %s: %v", key, err)
+		}
+		sim := newSimulatedChain(t, admin)
+		var client ChainClient = sim
+		addresses := map[string]config.ContractAddresses{}
+		if key == "down" {
+			client = unreachableClient{sim}
+		} else {
+			addresses[key] = config.ContractAddresses{ProofRegistry: deployProofRegistry(t, sim, adminKey).Hex()}
+		}
+		binding, err := bindChain(chain, client, addresses)
+		if err != nil {
+			t.Fatalf("failed to bind chain %s: %v", key, err)
+		}
+		bindings[key] = binding
+		sims[key] = sim
+	}
+
+	// Publish one batch on the second chain only
+	auth, err := bind.NewKeyedTransactorWithChainID(adminKey, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	end := time.Now().Add(-2 * time.Hour)
+	root := common.HexToHash("0x5ec0")
+	_, err = bindings["second"].ProofRegistry.PublishBatch(auth, root, uint8(contracts.BatchTypeYield),
+		uint64(end.Add(-time.Hour).Unix()), uint64(end.Unix()), "", 1)
+	if err != nil {
+		t.Fatalf("failed to publish batch: %v", err)
+	}
+	sims["second"].Commit()
+
+	blockchain := NewBlockchainService(&ChainRegistry{chains: bindings}, nil, "first")
+	if got := blockchain.Chains(); strings.Join(got, ",") != "first,second,down" {
+		t.Fatalf("got chains %v", got)
+	}
+	if !blockchain.HasChain("second") || blockchain.HasChain("third") {
+		t.Fatalf("chain availability does not follow the registry")
+	}
+
+	// No chain selects the default
+	for chain, want := range map[string]int64{"": 0, "first": 0, "second": 1} {
+		count, err := blockchain.GetBatchCount(chain)
+		if err != nil || count.Int64() != want {
+			t.Fatalf("chain %q: got %v batches (%v), want %d", chain, count, err, want)
+		}
+	}
+	batch, err := blockchain.GetProofBatch("second", big.NewInt(1))
+	if err != nil || batch.Chain != "second" || common.HexToHash(batch.Root) != root || batch.BatchType != contracts.BatchTypeYield {
+		t.Fatalf("got batch %+v (%v), want the yield batch of the second chain", batch, err)
+	}
+
+	if _, err := blockchain.GetBatchCount("third"); err == nil || !strings.Contains(err.Error(), "chain third is not available") {
+		t.Fatalf("got %v for an unknown chain", err)
+	}
+	if _, err := blockchain.GetBatchCount("down"); err == nil || !strings.Contains(err.Error(), "no ProofRegistry configured for chain down") {
+		t.Fatalf("got %v for a chain without a registry", err)
+	}
+	if _, err := blockchain.GetTokenInfo("first"); err == nil || !strings.Contains(err.Error(), "no USDK contract configured for chain first") {
+		t.Fatalf("got %v for a chain without USDK", err)
+	}
+
+	// Health is reported per chain
+	health := blockchain.HealthCheck(context.Background())
+	if len(health) != 3 {
+		t.Fatalf("got health %+v, want one line per chain", health)
+	}
+	for _, chainHealth := range health {
+		wantHealthy := chainHealth.Chain != "down"
+		if chainHealth.Healthy != wantHealthy || (chainHealth.BlockNumber != nil) != wantHealthy || (chainHealth.Error != "") == wantHealthy {
+			t.Fatalf("got health %+v, want healthy %t", chainHealth, wantHealthy)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ChainClient is the subset of an Ethereum RPC client used by the background
+// workers. Both *ethclient.Client and the go-ethereum simulated backend
+// satisfy it, so workers can be exercised without a live node.
+type ChainClient interface {
+	bind.ContractBackend
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/wallet"
+)
+
+const chainDialTimeout = 10 * time.Second
+
+// ChainBinding is the RPC client and contract bindings of one chain. USDK and
+// ProofRegistry are nil when the chain has no address for them.
+type ChainBinding struct {
+	Chain                *model.Chain
+	Client               ChainClient
+	USDKAddress          common.Address
+	USDK                 *contracts.USDKContract
+	ProofRegistryAddress common.Address
+	ProofRegistry        *contracts.ProofRegistryContract
+}
+
+// ChainHealth is the RPC status of one chain
+type ChainHealth struct {
+	Chain       string  `json:"chain"`
+	ChainID     uint64  `json:"chainId"`
+	Healthy     bool    `json:"healthy"`
+	BlockNumber *uint64 `json:"blockNumber,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// ChainRegistry holds one client and one set of contract bindings per enabled
+// EVM chain of the chains table. Contract addresses come from the chain row and
+// fall back to the USDK_CONTRACT_<CHAIN> and PROOF_REGISTRY_<CHAIN> settings;
+// the RPC URL falls back to <CHAIN>_RPC_URL.
+type ChainRegistry struct {
+	chains map[string]*ChainBinding // keyed by chain key
+}
+
+func NewChainRegistry(chainRepo *repository.ChainRepository, blockchainCfg config.BlockchainConfig, logger *logrus.Logger) (*ChainRegistry, error) {
+	chains, err := chainRepo.FindEnabled()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chains: %v", err)
+	}
+
+	registry := &ChainRegistry{chains: make(map[string]*ChainBinding)}
+	for i := range chains {
+		chain := &chains[i]
+		logger := logger.WithField("chain", chain.ChainKey)
+
+		// Chains of other families have no JSON-RPC client; their deposits
+		// are not scanned or swept yet
+		if family, err := wallet.LookupFamily(chain.Family); err != nil || family != wallet.EVM {
+			logger.WithField("family", chain.Family).Info("Not an EVM chain, skipping RPC client")
+			continue
+		}
+
+		rpcURL := blockchainCfg.RPCURL(chain.ChainKey)
+		if chain.RpcURL != nil && *chain.RpcURL != "" {
+			rpcURL = *chain.RpcURL
+		}
+		if rpcURL == "" {
+			logger.Warn("No RPC URL configured, chain is not available")
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), chainDialTimeout)
+		client, err := ethclient.DialContext(ctx, rpcURL)
+		cancel()
+		if err != nil {
+			logger.WithError(err).Warn("Failed to connect to chain RPC")
+			continue
+		}
+
+		binding, err := bindChain(chain, client, blockchainCfg.Contracts)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to bind chain contracts")
+			continue
+		}
+		registry.chains[chain.ChainKey] = binding
+	}
+
+	return registry, nil
+}
+
+// bindChain binds the contracts of a chain whose addresses are known
+func bindChain(chain *model.Chain, client ChainClient, addresses map[string]config.ContractAddresses) (*ChainBinding, error) {
+	binding := &ChainBinding{Chain: chain, Client: client}
+
+	if address, err := usdkAddress(chain, addresses); err == nil {
+		binding.USDKAddress = address
+		binding.USDK, err = contracts.NewUSDKContract(address, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate USDK contract: %v", err)
+		}
+	}
+
+	if address, err := proofRegistryAddress(chain, addresses); err == nil {
+		binding.ProofRegistryAddress = address
+		binding.ProofRegistry, err = contracts.NewProofRegistryContract(address, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to instantiate ProofRegistry contract: %v", err)
+		}
+	}
+
+	return binding, nil
+}
+
+// Get returns the binding of an enabled chain
+func (r *ChainRegistry) Get(chainKey string) (*ChainBinding, error) {
+	binding, ok := r.chains[chainKey]
+	if !ok {
+		return nil, fmt.Errorf("chain %s is not available", chainKey)
+	}
+	return binding, nil
+}
+
+// Chains returns every available chain ordered by chains.id
+func (r *ChainRegistry) Chains() []*ChainBinding {
+	bindings := make([]*ChainBinding, 0, len(r.chains))
+	for _, binding := range r.chains {
+		bindings = append(bindings, binding)
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Chain.ID < bindings[j].Chain.ID })
+	return bindings
+}
+
+// Clients returns the RPC client of every available chain, keyed by chains.id
+func (r *ChainRegistry) Clients() map[uint64]ChainClient {
+	clients := make(map[uint64]ChainClient, len(r.chains))
+	for _, binding := range r.chains {
+		clients[binding.Chain.ID] = binding.Client
+	}
+	return clients
+}
+
+// Health reports the RPC status of every available chain
+func (r *ChainRegistry) Health(ctx context.Context) []ChainHealth {
+	bindings := r.Chains()
+	health := make([]ChainHealth, len(bindings))
+	for i, binding := range bindings {
+		health[i] = ChainHealth{
+			Chain:   binding.Chain.ChainKey,
+			ChainID: binding.Chain.NetworkID,
+		}
+
+		header, err := binding.Client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			health[i].Error = err.Error()
+			continue
+		}
+		blockNumber := header.Number.Uint64()
+		health[i].Healthy = true
+		health[i].BlockNumber = &blockNumber
+	}
+	return health
+}
+
+// usdkAddress returns the USDK contract of the chain, falling back to the
+// USDK_CONTRACT_<CHAIN> setting
+func usdkAddress(chain *model.Chain, addresses map[string]config.ContractAddresses) (common.Address, error) {
+	address := ""
+	if chain.UsdkContract != nil {
+		address = *chain.UsdkContract
+	}
+	if address == "" {
+		address = addresses[chain.ChainKey].USDK
+	}
+	if !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("no USDK contract configured for chain %s", chain.ChainKey)
+	}
+	return common.HexToAddress(address), nil
+}
+
+// proofRegistryAddress returns the ProofRegistry of the chain, falling back to
+// the PROOF_REGISTRY_<CHAIN> setting
+func proofRegistryAddress(chain *model.Chain, registries map[string]config.ContractAddresses) (common.Address, error) {
+	address := ""
+	if chain.ProofRegistry != nil {
+		address = *chain.ProofRegistry
+	}
+	if address == "" {
+		address = registries[chain.ChainKey].ProofRegistry
+	}
+	if !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("no ProofRegistry configured for chain %s", chain.ChainKey)
+	}
+	return common.HexToAddress(address), nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+func TestChainRegistryBindsEnabledChainsOfTable(t *testing.T) {
+	db := newTestDB(t)
+
+	// Dialing an HTTP endpoint does not connect, so nothing needs to listen
+	rowRPC := "http://127.0.0.1:1/arbitrum"
+	rowRegistry := "0x00000000000000000000000000000000000000a2"
+	chains := []*model.Chain{
+		{ChainKey: "ethereum", NetworkID: 1, Name: "Ethereum", Enabled: true},
+		{ChainKey: "arbitrum", NetworkID: 42161, Name: "Arbitrum", Enabled: true, RpcURL: &rowRPC, ProofRegistry: &rowRegistry},
+		{ChainKey: "optimism", NetworkID: 10, Name: "Optimism", Enabled: true},
+		{ChainKey: "polygon", NetworkID: 137, Name: "Polygon", Enabled: true},
+		{ChainKey: "bitcoin", NetworkID: 0, Family: "bitcoin", Name: "Bitcoin", Enabled: true},
+	}
+	for _, chain := range chains {
+		if err := db.Create(chain).Error; err != nil {
+			t.Fatalf("failed to seed chain %s: %v", chain.ChainKey, err)
+		}
+	}
+	if err := db.Model(chains[3]).Update("enabled", false).Error; err != nil {
+		t.Fatalf("failed to disable chain: %v", err)
+	}
+
+	registry, err := NewChainRegistry(repository.NewChainRepository(db), config.BlockchainConfig{
+		EthereumRPC: "http://127.0.0.1:1/ethereum",
+		PolygonRPC:  "http://127.0.0.1:1/polygon",
+		Contracts: map[string]config.ContractAddresses{
+			"ethereum": {USDK: "0x00000000000000000000000000000000000000e1", ProofRegistry: "0x00000000000000000000000000000000000000e2"},
+			"arbitrum": {USDK: "0x00000000000000000000000000000000000000a1", ProofRegistry: "0x00000000000000000000000000000000000000ff"},
+		},
+	}, newTestLogger())
+	if err != nil {
+		t.Fatalf("failed to create chain registry: %v", err)
+	}
+
+	// Optimism has no RPC URL, polygon is disabled and bitcoin is not EVM
+	var keys []string
+	for _, binding := range registry.Chains() {
+		keys = append(keys, binding.Chain.ChainKey)
+	}
+	if !reflect.DeepEqual(keys, []string{"ethereum", "arbitrum"}) {
+		t.Fatalf("got chains %v, want ethereum and arbitrum", keys)
+	}
+
+	ethereum, err := registry.Get("ethereum")
+	if err != nil {
+		t.Fatalf("ethereum is not available: %v", err)
+	}
+	if ethereum.USDK == nil || ethereum.USDKAddress != common.HexToAddress("0xe1") ||
+		ethereum.ProofRegistry == nil || ethereum.ProofRegistryAddress != common.HexToAddress("0xe2") {
+		t.Fatalf("got ethereum contracts %s and %s, want the configured ones", ethereum.USDKAddress, ethereum.ProofRegistryAddress)
+	}
+
+	// The chain row wins over the settings
+	arbitrum, err := registry.Get("arbitrum")
+	if err != nil {
+		t.Fatalf("arbitrum is not available: %v", err)
+	}
+	if arbitrum.USDKAddress != common.HexToAddress("0xa1") || arbitrum.ProofRegistryAddress != common.HexToAddress(rowRegistry) {
+		t.Fatalf("got arbitrum contracts %s and %s, want the configured USDK and the row's registry", arbitrum.USDKAddress, arbitrum.ProofRegistryAddress)
+	}
+
+	for _, key := range []string{"optimism", "polygon", "bitcoin", ""} {
+		if _, err := registry.Get(key); err == nil {
+			t.Fatalf("chain %q is available", key)
+		}
+	}
+	if clients := registry.Clients(); len(clients) != 2 || clients[chains[0].ID] == nil || clients[chains[1].ID] == nil {
+		t.Fatalf("got clients %v, want one per bound chain", clients)
+	}
+}
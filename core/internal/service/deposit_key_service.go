@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/keyvault"
+	"usdk-backend/pkg/wallet"
+)
+
+const depositKeyRotateBatch = 200
+
+// DepositKeyService looks after the private keys of deposit addresses that
+// cannot be re-derived from the HD wallet. Keys are sealed by the key vault
+// into DepositAddress.PrivateKeyRef, re-wrapped when the current KEK version
+// changes, and only readable by a custodian through an audited decrypt.
+type DepositKeyService struct {
+	depositAddressRepo *repository.DepositAddressRepository
+	auditLogRepo       *repository.AuditLogRepository
+	vault              *keyvault.Vault
+	logger             *logrus.Logger
+}
+
+type DecryptedKeyResponse struct {
+	DepositAddressID uint64 `json:"depositAddressId"`
+	Address          string `json:"address"`
+	Chain            string `json:"chain"`
+	PrivateKey       string `json:"privateKey"`
+	KekVersion       uint32 `json:"kekVersion"`
+}
+
+func NewDepositKeyService(
+	depositAddressRepo *repository.DepositAddressRepository,
+	auditLogRepo *repository.AuditLogRepository,
+	vault *keyvault.Vault,
+	logger *logrus.Logger,
+) *DepositKeyService {
+	return &DepositKeyService{
+		depositAddressRepo: depositAddressRepo,
+		auditLogRepo:       auditLogRepo,
+		vault:              vault,
+		logger:             logger,
+	}
+}
+
+// depositKeyAssociatedData binds a sealed key to the address it belongs to
+func depositKeyAssociatedData(address string) string {
+	return "deposit:" + strings.ToLower(address)
+}
+
+// sealDepositKey seals the raw hex private key of a deposit address
+func sealDepositKey(vault *keyvault.Vault, family wallet.ChainFamily, address, privateKeyHex string) (string, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+	if !keyMatchesAddress(family, key, address) {
+		return "", fmt.Errorf("private key does not belong to %s", address)
+	}
+	return vault.Seal(crypto.FromECDSA(key), depositKeyAssociatedData(address))
+}
+
+// keyMatchesAddress reports whether key is the key of a family address
+func keyMatchesAddress(family wallet.ChainFamily, key *ecdsa.PrivateKey, address string) bool {
+	derived, err := family.AddressFromPublicKey(crypto.CompressPubkey(&key.PublicKey))
+	if err != nil {
+		return false
+	}
+	canonical, err := family.FormatAddress(address)
+	return err == nil && derived == canonical
+}
+
+// RotateKeys re-wraps every stored key that is not sealed with the current
+// KEK version and returns how many were re-wrapped. It is safe to run while
+// addresses are being created and to run again after a failure.
+func (s *DepositKeyService) RotateKeys() (int, error) {
+	prefix := keyvault.RefPrefix(s.vault.CurrentVersion())
+	rotated := 0
+	afterID := uint64(0)
+
+	for {
+		addresses, err := s.depositAddressRepo.FindKeyRefsNotSealedWith(prefix, afterID, depositKeyRotateBatch)
+		if err != nil {
+			return rotated, fmt.Errorf("failed to load deposit keys: %v", err)
+		}
+		if len(addresses) == 0 {
+			return rotated, nil
+		}
+
+		for i := range addresses {
+			addr := &addresses[i]
+			afterID = addr.ID
+
+			newRef, changed, err := s.vault.Rewrap(*addr.PrivateKeyRef, depositKeyAssociatedData(addr.Address))
+			if err != nil {
+				s.logger.WithError(err).WithField("deposit_address_id", addr.ID).Error("Failed to re-wrap deposit key")
+				continue
+			}
+			if !changed {
+				continue
+			}
+
+			replaced, err := s.depositAddressRepo.ReplacePrivateKeyRef(addr.ID, *addr.PrivateKeyRef, newRef)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to store re-wrapped key of address %d: %v", addr.ID, err)
+			}
+			if replaced {
+				rotated++
+			}
+		}
+	}
+}
+
+// DecryptPrivateKey returns the private key of a deposit address to a
+// custodian. The access is written to audit_logs before the key is decrypted,
+// so a failed audit never releases a key.
+func (s *DepositKeyService) DecryptPrivateKey(id uint64, reason string, audit AuditContext) (*DecryptedKeyResponse, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("reason must not be empty")
+	}
+
+	addr, err := s.depositAddressRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("deposit address not found: %v", err)
+	}
+	if addr.PrivateKeyRef == nil || *addr.PrivateKeyRef == "" {
+		return nil, fmt.Errorf("deposit address has no stored private key")
+	}
+
+	kekVersion, err := keyvault.RefVersion(*addr.PrivateKeyRef)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog, err := newAuditLog("deposit_key_decrypt", "deposit_address", addr.ID, &addr.UserID, nil, map[string]interface{}{
+		"address":    addr.Address,
+		"chainId":    addr.ChainID,
+		"kekVersion": kekVersion,
+		"reason":     reason,
+	}, audit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditLogRepo.Create(auditLog); err != nil {
+		return nil, fmt.Errorf("failed to record audit log: %v", err)
+	}
+
+	raw, err := s.vault.Open(*addr.PrivateKeyRef, depositKeyAssociatedData(addr.Address))
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ToECDSA(raw)
+	if err != nil {
+		return nil, fmt.Errorf("stored private key is invalid: %v", err)
+	}
+	family, err := chainFamily(&addr.Chain)
+	if err != nil {
+		return nil, err
+	}
+	if !keyMatchesAddress(family, key, addr.Address) {
+		return nil, fmt.Errorf("stored private key does not match the address")
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"deposit_address_id": addr.ID,
+		"admin_id":           audit.AdminID,
+	}).Warn("Deposit private key decrypted")
+
+	return &DecryptedKeyResponse{
+		DepositAddressID: addr.ID,
+		Address:          addr.Address,
+		Chain:            addr.Chain.ChainKey,
+		PrivateKey:       fmt.Sprintf("0x%x", raw),
+		KekVersion:       kekVersion,
+	}, nil
+}
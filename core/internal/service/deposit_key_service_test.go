@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/keyvault"
+	"usdk-backend/pkg/wallet"
+)
+
+// newTestVault returns a vault sealing with the highest of versions
+func newTestVault(t *testing.T, versions ...uint32) *keyvault.Vault {
+	t.Helper()
+
+	keks := make(map[uint32][]byte, len(versions))
+	current := uint32(0)
+	for _, version := range versions {
+		keks[version] = bytes.Repeat([]byte{byte(version)}, 32)
+		if version > current {
+			current = version
+		}
+	}
+	vault, err := keyvault.NewVault(keks, current)
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	return vault
+}
+
+// seedSealedDepositKeys stores n random deposit addresses with keys sealed
+// by vault and returns the raw keys by address ID
+func seedSealedDepositKeys(t *testing.T, db *gorm.DB, vault *keyvault.Vault, n int) map[uint64][]byte {
+	t.Helper()
+
+	chain, asset := seedNativeChain(t, db)
+	keys := make(map[uint64][]byte, n)
+	for i := 0; i < n; i++ {
+		user := &model.User{}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		address, privateKeyHex, err := wallet.GenerateRandomAddress(wallet.EVM)
+		if err != nil {
+			t.Fatalf("failed to generate address: %v", err)
+		}
+		ref, err := sealDepositKey(vault, wallet.EVM, address, privateKeyHex)
+		if err != nil {
+			t.Fatalf("failed to seal key: %v", err)
+		}
+		path := fmt.Sprintf("random/user/%d/%s/%s", user.ID, chain.ChainKey, asset.Symbol)
+		addr := &model.DepositAddress{UserID: user.ID, ChainID: chain.ID, AssetID: asset.ID, Address: address, DerivationPath: &path, PrivateKeyRef: &ref}
+		if err := db.Create(addr).Error; err != nil {
+			t.Fatalf("failed to seed deposit address: %v", err)
+		}
+		key, _ := crypto.HexToECDSA(privateKeyHex[2:])
+		keys[addr.ID] = crypto.FromECDSA(key)
+	}
+	return keys
+}
+
+func TestDepositKeyRotationIsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+	keys := seedSealedDepositKeys(t, db, newTestVault(t, 1), 3)
+
+	depositAddressRepo := repository.NewDepositAddressRepository(db)
+	rotated := newTestVault(t, 1, 2)
+	service := NewDepositKeyService(depositAddressRepo, repository.NewAuditLogRepository(db), rotated, newTestLogger())
+
+	if n, err := service.RotateKeys(); err != nil || n != len(keys) {
+		t.Fatalf("first rotation re-wrapped %d keys (%v), want %d", n, err, len(keys))
+	}
+	if n, err := service.RotateKeys(); err != nil || n != 0 {
+		t.Fatalf("second rotation re-wrapped %d keys (%v), want 0", n, err)
+	}
+
+	for id, want := range keys {
+		addr, err := depositAddressRepo.FindByID(id)
+		if err != nil {
+			t.Fatalf("failed to load deposit address: %v", err)
+		}
+		if version, _ := keyvault.RefVersion(*addr.PrivateKeyRef); version != 2 {
+			t.Fatalf("address %d: got KEK version %d, want 2", id, version)
+		}
+		raw, err := rotated.Open(*addr.PrivateKeyRef, depositKeyAssociatedData(addr.Address))
+		if err != nil || !bytes.Equal(raw, want) {
+			t.Fatalf("address %d: re-wrapped key does not open to the original (%v)", id, err)
+		}
+	}
+}
+
+func TestDecryptPrivateKeyIsAuditedFirst(t *testing.T) {
+	db := newTestDB(t)
+	vault := newTestVault(t, 1)
+	keys := seedSealedDepositKeys(t, db, vault, 1)
+	var addr model.DepositAddress
+	if err := db.First(&addr).Error; err != nil {
+		t.Fatalf("failed to load deposit address: %v", err)
+	}
+	id := addr.ID
+
+	service := NewDepositKeyService(repository.NewDepositAddressRepository(db), repository.NewAuditLogRepository(db), vault, newTestLogger())
+	audit := AuditContext{AdminID: 9, IPAddress: "203.0.113.7"}
+
+	auditRows := func() []model.AuditLog {
+		t.Helper()
+		var logs []model.AuditLog
+		if err := db.Where("action = ?", "deposit_key_decrypt").Find(&logs).Error; err != nil {
+			t.Fatalf("failed to load audit logs: %v", err)
+		}
+		return logs
+	}
+
+	if response, err := service.DecryptPrivateKey(id, "  ", audit); err == nil || response != nil {
+		t.Fatalf("decrypted without a reason")
+	}
+	if logs := auditRows(); len(logs) != 0 {
+		t.Fatalf("got %d audit rows for a refused decrypt", len(logs))
+	}
+
+	response, err := service.DecryptPrivateKey(id, "customer recovery #42", audit)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if response.PrivateKey != fmt.Sprintf("0x%x", keys[id]) || response.KekVersion != 1 {
+		t.Fatalf("got key %s with KEK version %d", response.PrivateKey, response.KekVersion)
+	}
+	logs := auditRows()
+	if len(logs) != 1 || logs[0].AdminID == nil || *logs[0].AdminID != audit.AdminID {
+		t.Fatalf("got audit rows %+v, want one by admin %d", logs, audit.AdminID)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(logs[0].NewValues, &values); err != nil || values["reason"] != "customer recovery #42" {
+		t.Fatalf("got audit values %s, want the reason recorded", logs[0].NewValues)
+	}
+
+	// Without an audit trail no key is released
+	if err := db.Migrator().DropTable(&model.AuditLog{}); err != nil {
+		t.Fatalf("failed to drop audit logs: %v", err)
+	}
+	if response, err := service.DecryptPrivateKey(id, "customer recovery #42", audit); err == nil || response != nil {
+		t.Fatalf("key released without an audit row")
+	}
+}
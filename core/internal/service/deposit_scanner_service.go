@@ -0,0 +1,583 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+const (
+	depositScannerWorker  = "deposit"
+	depositScanBlockRange = 500
+	// chainBlockRetention is how many blocks of hash history are kept per
+	// chain to locate the common ancestor after a reorg
+	chainBlockRetention = 1024
+)
+
+// erc20TransferTopic is keccak256("Transfer(address,address,uint256)")
+var erc20TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// AssetValuer converts an asset amount into its USD (KUSD) value
+type AssetValuer interface {
+	GetUSDValue(symbol string, amount decimal.Decimal) (decimal.Decimal, error)
+}
+
+// DepositScannerService follows ERC-20 Transfer logs and native value
+// transfers into user deposit addresses, records them as onchain_txs and
+// credits the ledger once they reach the configured confirmation depth.
+//
+// The hash of the last scanned block is tracked per chain. When it is no
+// longer canonical the scanner walks back to the common ancestor, returns
+// orphaned transactions to pending and reverses their ledger entries.
+type DepositScannerService struct {
+	chainRepo          *repository.ChainRepository
+	chainAssetRepo     *repository.ChainAssetRepository
+	depositAddressRepo *repository.DepositAddressRepository
+	onchainTxRepo      *repository.OnchainTxRepository
+	syncStateRepo      *repository.ChainSyncStateRepository
+	chainBlockRepo     *repository.ChainBlockRepository
+	clients            map[uint64]ChainClient // keyed by chains.id
+	valuer             AssetValuer
+	confirmationBlocks int
+	interval           time.Duration
+	logger             *logrus.Logger
+	ignoredSenders     map[common.Address]bool // platform wallets whose transfers are not deposits
+}
+
+func NewDepositScannerService(
+	chainRepo *repository.ChainRepository,
+	chainAssetRepo *repository.ChainAssetRepository,
+	depositAddressRepo *repository.DepositAddressRepository,
+	onchainTxRepo *repository.OnchainTxRepository,
+	syncStateRepo *repository.ChainSyncStateRepository,
+	chainBlockRepo *repository.ChainBlockRepository,
+	clients map[uint64]ChainClient,
+	valuer AssetValuer,
+	platformCfg config.PlatformConfig,
+	logger *logrus.Logger,
+) *DepositScannerService {
+	return &DepositScannerService{
+		chainRepo:          chainRepo,
+		chainAssetRepo:     chainAssetRepo,
+		depositAddressRepo: depositAddressRepo,
+		onchainTxRepo:      onchainTxRepo,
+		syncStateRepo:      syncStateRepo,
+		chainBlockRepo:     chainBlockRepo,
+		clients:            clients,
+		valuer:             valuer,
+		confirmationBlocks: platformCfg.ConfirmationBlocks,
+		interval:           time.Duration(platformCfg.DepositScanIntervalSec) * time.Second,
+		logger:             logger,
+		ignoredSenders:     make(map[common.Address]bool),
+	}
+}
+
+// IgnoreSender stops transfers from a platform wallet, such as gas top-ups
+// before a sweep, from being credited as deposits. It must be called before
+// Run.
+func (s *DepositScannerService) IgnoreSender(address common.Address) {
+	s.ignoredSenders[address] = true
+}
+
+// Run scans all enabled chains every interval until ctx is cancelled
+func (s *DepositScannerService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.ScanOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScanOnce runs a single scan pass over every enabled chain that has a client
+func (s *DepositScannerService) ScanOnce(ctx context.Context) {
+	chains, err := s.chainRepo.FindEnabled()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load chains for deposit scan")
+		return
+	}
+
+	for i := range chains {
+		chain := &chains[i]
+		client, ok := s.clients[chain.ID]
+		if !ok {
+			continue
+		}
+
+		if err := s.ScanChain(ctx, chain, client); err != nil {
+			s.logger.WithError(err).WithField("chain", chain.ChainKey).Warn("Deposit scan failed")
+		}
+	}
+}
+
+// ScanChain advances the deposit cursor of one chain by at most
+// depositScanBlockRange blocks and refreshes confirmations of pending deposits.
+func (s *DepositScannerService) ScanChain(ctx context.Context, chain *model.Chain, client ChainClient) error {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %v", err)
+	}
+	headNum := head.Number.Uint64()
+
+	state, err := s.syncStateRepo.Find(chain.ID, depositScannerWorker)
+	if err != nil {
+		return fmt.Errorf("failed to load sync state: %v", err)
+	}
+
+	if state != nil {
+		reorged, err := s.checkReorg(ctx, chain, client, state.LastBlock)
+		if err != nil {
+			return err
+		}
+		if reorged {
+			// Cursor moved back; pick up from the ancestor on the next pass
+			return nil
+		}
+	}
+
+	var from uint64
+	if state != nil {
+		from = state.LastBlock + 1
+	} else if headNum > uint64(s.confirmationBlocks) {
+		// First run on this chain: start just behind the confirmation window
+		from = headNum - uint64(s.confirmationBlocks)
+	}
+
+	if from <= headNum {
+		to := from + depositScanBlockRange - 1
+		if to > headNum {
+			to = headNum
+		}
+
+		if err := s.scanRange(ctx, chain, client, from, to); err != nil {
+			return err
+		}
+
+		if err := s.saveCheckpoint(ctx, chain, client, to); err != nil {
+			return err
+		}
+	}
+
+	return s.updateConfirmations(ctx, chain, client, headNum)
+}
+
+// saveCheckpoint records the hash of the last scanned block and advances the cursor
+func (s *DepositScannerService) saveCheckpoint(ctx context.Context, chain *model.Chain, client ChainClient, blockNum uint64) error {
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNum))
+	if err != nil || header == nil {
+		return fmt.Errorf("failed to get header %d: %v", blockNum, err)
+	}
+
+	err = s.chainBlockRepo.Save(&model.ChainBlock{
+		ChainID:    chain.ID,
+		BlockNum:   blockNum,
+		BlockHash:  header.Hash().Hex(),
+		ParentHash: header.ParentHash.Hex(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save block hash: %v", err)
+	}
+
+	if err := s.syncStateRepo.SaveLastBlock(chain.ID, depositScannerWorker, blockNum); err != nil {
+		return fmt.Errorf("failed to save sync state: %v", err)
+	}
+
+	if blockNum > chainBlockRetention {
+		if err := s.chainBlockRepo.DeleteBefore(chain.ID, blockNum-chainBlockRetention); err != nil {
+			s.logger.WithError(err).WithField("chain", chain.ChainKey).Warn("Failed to prune block hashes")
+		}
+	}
+
+	return nil
+}
+
+// checkReorg compares the stored hash of the cursor block with the canonical
+// chain and rolls back to the common ancestor if they differ.
+func (s *DepositScannerService) checkReorg(ctx context.Context, chain *model.Chain, client ChainClient, lastBlock uint64) (bool, error) {
+	tracked, err := s.chainBlockRepo.FindByNumber(chain.ID, lastBlock)
+	if err != nil {
+		return false, fmt.Errorf("failed to load block hash: %v", err)
+	}
+	if tracked == nil {
+		return false, nil
+	}
+
+	hash, ok, err := canonicalHash(ctx, client, lastBlock)
+	if err != nil {
+		return false, err
+	}
+	if ok && hash.Hex() == tracked.BlockHash {
+		return false, nil
+	}
+
+	ancestor, ancestorHash, err := s.findCommonAncestor(ctx, chain, client, lastBlock)
+	if err != nil {
+		return false, err
+	}
+
+	return true, s.rollbackTo(chain, ancestor, ancestorHash)
+}
+
+// findCommonAncestor returns the highest tracked block below the given
+// height whose hash is still canonical
+func (s *DepositScannerService) findCommonAncestor(ctx context.Context, chain *model.Chain, client ChainClient, below uint64) (uint64, string, error) {
+	tracked, err := s.chainBlockRepo.FindRecent(chain.ID, chainBlockRetention)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to load block hashes: %v", err)
+	}
+
+	oldest := below
+	for _, block := range tracked {
+		if block.BlockNum >= below {
+			continue
+		}
+		hash, ok, err := canonicalHash(ctx, client, block.BlockNum)
+		if err != nil {
+			return 0, "", err
+		}
+		if ok && hash.Hex() == block.BlockHash {
+			return block.BlockNum, block.BlockHash, nil
+		}
+		oldest = block.BlockNum
+	}
+
+	if oldest == 0 {
+		return 0, "", nil
+	}
+	if oldest < below {
+		s.logger.WithFields(logrus.Fields{
+			"chain":        chain.ChainKey,
+			"oldest_block": oldest,
+		}).Error("Reorg is deeper than tracked block history, rolling back past it")
+	}
+	return oldest - 1, "", nil
+}
+
+// rollbackTo reverts every transaction above the ancestor block and rewinds the cursor
+func (s *DepositScannerService) rollbackTo(chain *model.Chain, ancestor uint64, ancestorHash string) error {
+	txCount, reversalCount, err := s.onchainTxRepo.RollbackAfterBlock(chain.ID, ancestor, ancestorHash)
+	if err != nil {
+		return fmt.Errorf("failed to roll back orphaned transactions: %v", err)
+	}
+
+	if err := s.chainBlockRepo.DeleteAfter(chain.ID, ancestor); err != nil {
+		return fmt.Errorf("failed to drop orphaned block hashes: %v", err)
+	}
+
+	if err := s.syncStateRepo.SaveLastBlock(chain.ID, depositScannerWorker, ancestor); err != nil {
+		return fmt.Errorf("failed to rewind sync state: %v", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"chain":            chain.ChainKey,
+		"ancestor_block":   ancestor,
+		"rolled_back_txs":  txCount,
+		"ledger_reversals": reversalCount,
+	}).Warn("Chain reorg detected, rolled back to common ancestor")
+
+	return nil
+}
+
+func (s *DepositScannerService) scanRange(ctx context.Context, chain *model.Chain, client ChainClient, from, to uint64) error {
+	depositAddresses, err := s.depositAddressRepo.FindByChainID(chain.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load deposit addresses: %v", err)
+	}
+	if len(depositAddresses) == 0 {
+		return nil
+	}
+
+	watched := make(map[common.Address]*model.DepositAddress, len(depositAddresses))
+	for i := range depositAddresses {
+		watched[common.HexToAddress(depositAddresses[i].Address)] = &depositAddresses[i]
+	}
+
+	chainAssets, err := s.chainAssetRepo.FindEnabledByChainID(chain.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load chain assets: %v", err)
+	}
+
+	tokens := make(map[common.Address]*model.Asset)
+	var native *model.Asset
+	for i := range chainAssets {
+		ca := &chainAssets[i]
+		if !ca.Asset.Enabled {
+			continue
+		}
+		if ca.ContractAddress == nil || common.HexToAddress(*ca.ContractAddress) == (common.Address{}) {
+			native = &ca.Asset
+			continue
+		}
+		tokens[common.HexToAddress(*ca.ContractAddress)] = &ca.Asset
+	}
+
+	if len(tokens) > 0 {
+		if err := s.scanTokenTransfers(ctx, chain, client, from, to, tokens, watched); err != nil {
+			return err
+		}
+	}
+
+	if native != nil {
+		if err := s.scanNativeTransfers(ctx, chain, client, from, to, native, watched); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *DepositScannerService) scanTokenTransfers(
+	ctx context.Context,
+	chain *model.Chain,
+	client ChainClient,
+	from, to uint64,
+	tokens map[common.Address]*model.Asset,
+	watched map[common.Address]*model.DepositAddress,
+) error {
+	contracts := make([]common.Address, 0, len(tokens))
+	for addr := range tokens {
+		contracts = append(contracts, addr)
+	}
+
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: contracts,
+		Topics:    [][]common.Hash{{erc20TransferTopic}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to filter transfer logs: %v", err)
+	}
+
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) != 3 {
+			continue
+		}
+
+		toAddr := common.BytesToAddress(l.Topics[2].Bytes())
+		depositAddr, ok := watched[toAddr]
+		if !ok {
+			continue
+		}
+
+		fromAddr := common.BytesToAddress(l.Topics[1].Bytes())
+		if s.ignoredSenders[fromAddr] {
+			continue
+		}
+		value := new(big.Int).SetBytes(l.Data)
+		if err := s.recordDeposit(chain, tokens[l.Address], depositAddr, l.TxHash, int(l.Index), &fromAddr, toAddr, value, l.BlockNumber, l.BlockHash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanNativeTransfers only sees top-level value transfers; ETH moved by
+// internal calls of a contract is not detected.
+func (s *DepositScannerService) scanNativeTransfers(
+	ctx context.Context,
+	chain *model.Chain,
+	client ChainClient,
+	from, to uint64,
+	native *model.Asset,
+	watched map[common.Address]*model.DepositAddress,
+) error {
+	signer := types.LatestSignerForChainID(new(big.Int).SetUint64(chain.NetworkID))
+
+	for num := from; num <= to; num++ {
+		block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(num))
+		if err != nil {
+			return fmt.Errorf("failed to get block %d: %v", num, err)
+		}
+
+		for _, tx := range block.Transactions() {
+			if tx.To() == nil || tx.Value().Sign() <= 0 {
+				continue
+			}
+
+			depositAddr, ok := watched[*tx.To()]
+			if !ok {
+				continue
+			}
+
+			receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+			if err != nil {
+				return fmt.Errorf("failed to get receipt for %s: %v", tx.Hash().Hex(), err)
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				continue
+			}
+
+			var fromAddr *common.Address
+			if sender, err := types.Sender(signer, tx); err == nil {
+				if s.ignoredSenders[sender] {
+					continue
+				}
+				fromAddr = &sender
+			}
+
+			if err := s.recordDeposit(chain, native, depositAddr, tx.Hash(), -1, fromAddr, *tx.To(), tx.Value(), num, block.Hash()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *DepositScannerService) recordDeposit(
+	chain *model.Chain,
+	asset *model.Asset,
+	depositAddr *model.DepositAddress,
+	txHash common.Hash,
+	logIndex int,
+	fromAddr *common.Address,
+	toAddr common.Address,
+	value *big.Int,
+	blockNum uint64,
+	blockHash common.Hash,
+) error {
+	userID := depositAddr.UserID
+	blockHashHex := blockHash.Hex()
+	onchainTx := &model.OnchainTx{
+		Direction: "in",
+		UserID:    &userID,
+		ChainID:   chain.ID,
+		AssetID:   asset.ID,
+		ToAddr:    toAddr.Hex(),
+		TxHash:    txHash.Hex(),
+		LogIndex:  logIndex,
+		Amount:    decimal.NewFromBigInt(value, -int32(asset.Decimals)),
+		BlockNum:  &blockNum,
+		BlockHash: &blockHashHex,
+		Status:    "pending",
+		SeenAt:    time.Now(),
+	}
+	if fromAddr != nil {
+		from := fromAddr.Hex()
+		onchainTx.FromAddr = &from
+	}
+
+	if err := s.onchainTxRepo.UpsertObserved(onchainTx); err != nil {
+		return fmt.Errorf("failed to record deposit %s: %v", onchainTx.TxHash, err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"chain":     chain.ChainKey,
+		"asset":     asset.Symbol,
+		"user_id":   userID,
+		"tx_hash":   onchainTx.TxHash,
+		"log_index": logIndex,
+		"amount":    onchainTx.Amount.String(),
+		"block":     blockNum,
+	}).Info("Deposit observed")
+
+	return nil
+}
+
+func (s *DepositScannerService) updateConfirmations(ctx context.Context, chain *model.Chain, client ChainClient, headNum uint64) error {
+	pending, err := s.onchainTxRepo.FindPendingByChain(chain.ID, "in")
+	if err != nil {
+		return fmt.Errorf("failed to load pending deposits: %v", err)
+	}
+
+	for i := range pending {
+		tx := &pending[i]
+		if tx.BlockNum == nil || *tx.BlockNum > headNum || tx.UserID == nil {
+			continue
+		}
+
+		confirmations := int(headNum - *tx.BlockNum + 1)
+		if confirmations < s.confirmationBlocks {
+			if confirmations != tx.Confirmations {
+				if err := s.onchainTxRepo.UpdateConfirmations(tx.ID, confirmations); err != nil {
+					return fmt.Errorf("failed to update confirmations: %v", err)
+				}
+			}
+			continue
+		}
+
+		// Never credit a deposit whose block has been replaced since it was seen
+		hash, ok, err := canonicalHash(ctx, client, *tx.BlockNum)
+		if err != nil {
+			return err
+		}
+		if !ok || (tx.BlockHash != nil && hash.Hex() != *tx.BlockHash) {
+			// Blocks below the deposit's may be gone too, and blocks above it
+			// may already be tracked on the new chain
+			ancestor, ancestorHash, err := s.findCommonAncestor(ctx, chain, client, *tx.BlockNum)
+			if err != nil {
+				return err
+			}
+			return s.rollbackTo(chain, ancestor, ancestorHash)
+		}
+
+		kusdDelta, err := s.valuer.GetUSDValue(tx.Asset.Symbol, tx.Amount)
+		if err != nil {
+			// Leave it pending and retry on the next pass
+			s.logger.WithError(err).WithField("tx_hash", tx.TxHash).Warn("Failed to value deposit")
+			continue
+		}
+
+		chainID := tx.ChainID
+		assetID := tx.AssetID
+		txHash := tx.TxHash
+		onchainTxID := tx.ID
+		entry := &model.LedgerEntry{
+			UserID:         *tx.UserID,
+			EntryType:      "deposit",
+			ChainID:        &chainID,
+			AssetID:        &assetID,
+			Amount:         tx.Amount,
+			KusdDelta:      kusdDelta,
+			RefTxHash:      &txHash,
+			RefOnchainTxID: &onchainTxID,
+		}
+
+		if err := s.onchainTxRepo.ConfirmDeposit(tx, confirmations, entry); err != nil {
+			return fmt.Errorf("failed to confirm deposit %s: %v", tx.TxHash, err)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"chain":      chain.ChainKey,
+			"user_id":    *tx.UserID,
+			"tx_hash":    tx.TxHash,
+			"kusd_delta": kusdDelta.String(),
+		}).Info("Deposit confirmed and credited")
+	}
+
+	return nil
+}
+
+// canonicalHash returns the canonical block hash at a height. ok is false when
+// the canonical chain is currently shorter than blockNum.
+func canonicalHash(ctx context.Context, client ChainClient, blockNum uint64) (common.Hash, bool, error) {
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(blockNum))
+	if errors.Is(err, ethereum.NotFound) || (err == nil && header == nil) {
+		return common.Hash{}, false, nil
+	}
+	if err != nil {
+		return common.Hash{}, false, fmt.Errorf("failed to get header %d: %v", blockNum, err)
+	}
+	return header.Hash(), true, nil
+}
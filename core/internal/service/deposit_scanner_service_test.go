@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+// simulatedChainID is the chain id every go-ethereum simulated backend uses
+const simulatedChainID = 1337
+
+// newSimulatedChain starts a simulated backend with funder holding 1000 ETH
+func newSimulatedChain(t *testing.T, funder common.Address) *backends.SimulatedBackend {
+	t.Helper()
+
+	balance := new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{funder: {Balance: balance}}, 10_000_000)
+	t.Cleanup(func() { sim.Close() })
+	return sim
+}
+
+// sendEther signs and submits a native transfer to the pending block
+func sendEther(t *testing.T, sim *backends.SimulatedBackend, key *ecdsa.PrivateKey, to common.Address, wei *big.Int) *types.Transaction {
+	t.Helper()
+	ctx := context.Background()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := sim.PendingNonceAt(ctx, from)
+	if err != nil {
+		t.Fatalf("failed to get nonce: %v", err)
+	}
+	gasPrice, err := sim.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatalf("failed to suggest gas price: %v", err)
+	}
+
+	tx, err := types.SignTx(
+		types.NewTransaction(nonce, to, wei, params.TxGas, gasPrice, nil),
+		types.LatestSignerForChainID(big.NewInt(simulatedChainID)),
+		key,
+	)
+	if err != nil {
+		t.Fatalf("failed to sign transfer: %v", err)
+	}
+	if err := sim.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transfer: %v", err)
+	}
+	return tx
+}
+
+// seedNativeChain stores a simulated chain with ETH enabled as its native asset
+func seedNativeChain(t *testing.T, db *gorm.DB) (*model.Chain, *model.Asset) {
+	t.Helper()
+
+	chain := &model.Chain{ChainKey: "simulated", NetworkID: simulatedChainID, Name: "Simulated", Enabled: true}
+	asset := &model.Asset{Symbol: "ETH", Name: "Ether", Decimals: 18, AssetType: "eth", Enabled: true}
+	for _, record := range []interface{}{chain, asset} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed %T: %v", record, err)
+		}
+	}
+	if err := db.Create(&model.ChainAsset{ChainID: chain.ID, AssetID: asset.ID, Enabled: true}).Error; err != nil {
+		t.Fatalf("failed to seed chain asset: %v", err)
+	}
+	return chain, asset
+}
+
+func TestDepositScannerCreditsAndRollsBackNativeDeposit(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	depositKey, _ := crypto.GenerateKey()
+	depositAddr := crypto.PubkeyToAddress(depositKey.PublicKey)
+	err := db.Create(&model.DepositAddress{
+		UserID:   user.ID,
+		ChainID:  chain.ID,
+		AssetID:  asset.ID,
+		Address:  depositAddr.Hex(),
+		IsActive: true,
+	}).Error
+	if err != nil {
+		t.Fatalf("failed to seed deposit address: %v", err)
+	}
+
+	senderKey, _ := crypto.GenerateKey()
+	sim := newSimulatedChain(t, crypto.PubkeyToAddress(senderKey.PublicKey))
+	genesis, err := sim.HeaderByNumber(ctx, big.NewInt(0))
+	if err != nil {
+		t.Fatalf("failed to get genesis: %v", err)
+	}
+
+	ledgerRepo := repository.NewLedgerRepository(db)
+	scanner := NewDepositScannerService(
+		repository.NewChainRepository(db),
+		repository.NewChainAssetRepository(db),
+		repository.NewDepositAddressRepository(db),
+		repository.NewOnchainTxRepository(db),
+		repository.NewChainSyncStateRepository(db),
+		repository.NewChainBlockRepository(db),
+		map[uint64]ChainClient{chain.ID: sim},
+		fixedPriceValuer{"ETH": decimal.NewFromInt(2000)},
+		config.PlatformConfig{ConfirmationBlocks: 3, DepositScanIntervalSec: 1},
+		newTestLogger(),
+	)
+
+	scan := func() {
+		t.Helper()
+		if err := scanner.ScanChain(ctx, chain, sim); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+	}
+	findDeposit := func(hash common.Hash) *model.OnchainTx {
+		t.Helper()
+		var tx model.OnchainTx
+		if err := db.Where("tx_hash = ? AND direction = ?", hash.Hex(), "in").First(&tx).Error; err != nil {
+			t.Fatalf("deposit %s not recorded: %v", hash.Hex(), err)
+		}
+		return &tx
+	}
+	balance := func() decimal.Decimal {
+		t.Helper()
+		balance, err := ledgerRepo.GetUserKUSDBalance(user.ID)
+		if err != nil {
+			t.Fatalf("failed to load balance: %v", err)
+		}
+		return balance
+	}
+
+	// Start the cursor at genesis, then deposit 1.5 ETH in block 1
+	scan()
+	deposit := sendEther(t, sim, senderKey, depositAddr, new(big.Int).Div(big.NewInt(3*params.Ether), big.NewInt(2)))
+	sim.Commit()
+	scan()
+
+	observed := findDeposit(deposit.Hash())
+	if observed.Status != "pending" || observed.Confirmations != 1 {
+		t.Fatalf("got status %s with %d confirmations, want pending with 1", observed.Status, observed.Confirmations)
+	}
+	if observed.UserID == nil || *observed.UserID != user.ID || !observed.Amount.Equal(decimal.RequireFromString("1.5")) {
+		t.Fatalf("recorded deposit %+v does not match the transfer", observed)
+	}
+	if got := balance(); !got.IsZero() {
+		t.Fatalf("credited %s before the confirmation threshold", got)
+	}
+
+	// Two more blocks reach the 3 confirmation threshold
+	sim.Commit()
+	scan()
+	if got := balance(); !got.IsZero() {
+		t.Fatalf("credited %s with 2 confirmations", got)
+	}
+	sim.Commit()
+	scan()
+
+	confirmed := findDeposit(deposit.Hash())
+	if confirmed.Status != "confirmed" || confirmed.Confirmations != 3 {
+		t.Fatalf("got status %s with %d confirmations, want confirmed with 3", confirmed.Status, confirmed.Confirmations)
+	}
+	if got := balance(); !got.Equal(decimal.NewFromInt(3000)) {
+		t.Fatalf("got balance %s after confirmation, want 3000", got)
+	}
+
+	// Replace blocks 1-3 with a longer fork that does not contain the deposit
+	if err := sim.Fork(ctx, genesis.Hash()); err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		sim.Commit()
+	}
+	scan()
+
+	orphaned := findDeposit(deposit.Hash())
+	if orphaned.Status != "pending" || orphaned.BlockNum != nil {
+		t.Fatalf("got status %s in block %v after the reorg, want pending without a block", orphaned.Status, orphaned.BlockNum)
+	}
+	if got := balance(); !got.IsZero() {
+		t.Fatalf("got balance %s after the reorg, want 0", got)
+	}
+
+	var reversals []model.LedgerEntry
+	if err := db.Where("user_id = ? AND entry_type = ?", user.ID, "reversal").Find(&reversals).Error; err != nil {
+		t.Fatalf("failed to load reversals: %v", err)
+	}
+	if len(reversals) != 1 || reversals[0].ReversalOfID == nil || !reversals[0].KusdDelta.Equal(decimal.NewFromInt(-3000)) {
+		t.Fatalf("got reversals %+v, want one -3000 reversal of the deposit", reversals)
+	}
+
+	// The orphaned transfer is picked up again once re-mined on the new chain
+	if err := sim.SendTransaction(ctx, deposit); err != nil {
+		t.Fatalf("failed to resend deposit: %v", err)
+	}
+	sim.Commit()
+	scan()
+
+	remined := findDeposit(deposit.Hash())
+	if remined.BlockNum == nil || *remined.BlockNum != 6 || remined.Status != "pending" {
+		t.Fatalf("got status %s in block %v, want pending in block 6", remined.Status, remined.BlockNum)
+	}
+}
+
+func TestDepositScannerRollsBackToCommonAncestorBelowReplacedDeposit(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	depositKey, _ := crypto.GenerateKey()
+	depositAddr := crypto.PubkeyToAddress(depositKey.PublicKey)
+	err := db.Create(&model.DepositAddress{
+		UserID:   user.ID,
+		ChainID:  chain.ID,
+		AssetID:  asset.ID,
+		Address:  depositAddr.Hex(),
+		IsActive: true,
+	}).Error
+	if err != nil {
+		t.Fatalf("failed to seed deposit address: %v", err)
+	}
+
+	senderKey, _ := crypto.GenerateKey()
+	sim := newSimulatedChain(t, crypto.PubkeyToAddress(senderKey.PublicKey))
+	ledgerRepo := repository.NewLedgerRepository(db)
+	syncStateRepo := repository.NewChainSyncStateRepository(db)
+	scanner := NewDepositScannerService(
+		repository.NewChainRepository(db),
+		repository.NewChainAssetRepository(db),
+		repository.NewDepositAddressRepository(db),
+		repository.NewOnchainTxRepository(db),
+		syncStateRepo,
+		repository.NewChainBlockRepository(db),
+		map[uint64]ChainClient{chain.ID: sim},
+		fixedPriceValuer{"ETH": decimal.NewFromInt(2000)},
+		config.PlatformConfig{ConfirmationBlocks: 2, DepositScanIntervalSec: 1},
+		newTestLogger(),
+	)
+
+	scan := func() {
+		t.Helper()
+		if err := scanner.ScanChain(ctx, chain, sim); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+	}
+	findDeposit := func(hash common.Hash) *model.OnchainTx {
+		t.Helper()
+		var tx model.OnchainTx
+		if err := db.Where("tx_hash = ? AND direction = ?", hash.Hex(), "in").First(&tx).Error; err != nil {
+			t.Fatalf("deposit %s not recorded: %v", hash.Hex(), err)
+		}
+		return &tx
+	}
+	balance := func() decimal.Decimal {
+		t.Helper()
+		balance, err := ledgerRepo.GetUserKUSDBalance(user.ID)
+		if err != nil {
+			t.Fatalf("failed to load balance: %v", err)
+		}
+		return balance
+	}
+
+	// Genesis and an empty block 1 are tracked, then block 2 holds a deposit
+	// and a withdrawal and block 3 a second deposit
+	scan()
+	sim.Commit()
+	scan()
+	forkPoint, err := sim.HeaderByNumber(ctx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to get block 1: %v", err)
+	}
+
+	first := sendEther(t, sim, senderKey, depositAddr, big.NewInt(params.Ether))
+	withdrawal := sendEther(t, sim, senderKey, common.HexToAddress("0x00000000000000000000000000000000000000aa"), big.NewInt(params.Ether/4))
+	sim.Commit()
+	scan()
+	second := sendEther(t, sim, senderKey, depositAddr, big.NewInt(params.Ether/2))
+	sim.Commit()
+	scan()
+
+	if credited := findDeposit(first.Hash()); credited.Status != "confirmed" || !balance().Equal(decimal.NewFromInt(2000)) {
+		t.Fatalf("got first deposit %s with balance %s, want it credited 2000", credited.Status, balance())
+	}
+	if pending := findDeposit(second.Hash()); pending.Status != "pending" || pending.BlockNum == nil || *pending.BlockNum != 3 {
+		t.Fatalf("got second deposit %s in block %v, want pending in block 3", pending.Status, pending.BlockNum)
+	}
+
+	// The executor completed a withdrawal mined in block 2
+	block2, err := sim.HeaderByNumber(ctx, big.NewInt(2))
+	if err != nil {
+		t.Fatalf("failed to get block 2: %v", err)
+	}
+	blockNum, blockHash, txHash := uint64(2), block2.Hash().Hex(), withdrawal.Hash().Hex()
+	out := &model.OnchainTx{
+		Direction: "out",
+		UserID:    &user.ID,
+		ChainID:   chain.ID,
+		AssetID:   asset.ID,
+		ToAddr:    "0x00000000000000000000000000000000000000aa",
+		TxHash:    txHash,
+		LogIndex:  -1,
+		Amount:    decimal.RequireFromString("0.25"),
+		BlockNum:  &blockNum,
+		BlockHash: &blockHash,
+		Status:    "confirmed",
+	}
+	if err := db.Create(out).Error; err != nil {
+		t.Fatalf("failed to seed withdrawal transaction: %v", err)
+	}
+	withdrawEntry := &model.LedgerEntry{
+		UserID:         user.ID,
+		EntryType:      "withdraw",
+		Amount:         decimal.RequireFromString("-0.25"),
+		KusdDelta:      decimal.NewFromInt(-500),
+		RefTxHash:      &txHash,
+		RefOnchainTxID: &out.ID,
+	}
+	if err := db.Create(withdrawEntry).Error; err != nil {
+		t.Fatalf("failed to seed withdrawal entry: %v", err)
+	}
+	processedAt := time.Now()
+	request := &model.WithdrawRequest{
+		UserID:        user.ID,
+		ChainID:       chain.ID,
+		AssetID:       asset.ID,
+		Amount:        decimal.RequireFromString("0.25"),
+		ToAddress:     out.ToAddr,
+		Status:        "completed",
+		TxHash:        &txHash,
+		LedgerEntryID: &withdrawEntry.ID,
+		ProcessedAt:   &processedAt,
+	}
+	if err := db.Create(request).Error; err != nil {
+		t.Fatalf("failed to seed withdraw request: %v", err)
+	}
+
+	// Replace blocks 2 and 3 with a longer fork. The pass that tracked the
+	// cursor already ran, so the reorg is only seen when the second deposit
+	// reaches its confirmation depth on the new chain.
+	if err := sim.Fork(ctx, forkPoint.Hash()); err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		sim.Commit()
+	}
+	if err := scanner.updateConfirmations(ctx, chain, sim, 4); err != nil {
+		t.Fatalf("failed to update confirmations: %v", err)
+	}
+
+	// Everything above block 1 is rolled back, not only the deposit's block
+	state, err := syncStateRepo.Find(chain.ID, depositScannerWorker)
+	if err != nil || state == nil || state.LastBlock != 1 {
+		t.Fatalf("got cursor %+v (%v), want it rewound to block 1", state, err)
+	}
+	for _, hash := range []common.Hash{first.Hash(), second.Hash()} {
+		if orphaned := findDeposit(hash); orphaned.Status != "pending" || orphaned.BlockNum != nil {
+			t.Fatalf("deposit %s: got status %s in block %v, want pending without a block", hash.Hex(), orphaned.Status, orphaned.BlockNum)
+		}
+	}
+	var orphanedOut model.OnchainTx
+	if err := db.First(&orphanedOut, out.ID).Error; err != nil {
+		t.Fatalf("failed to reload withdrawal transaction: %v", err)
+	}
+	if orphanedOut.Status != "pending" || orphanedOut.BlockNum != nil {
+		t.Fatalf("got withdrawal transaction %s in block %v, want pending without a block", orphanedOut.Status, orphanedOut.BlockNum)
+	}
+
+	var reopened model.WithdrawRequest
+	if err := db.First(&reopened, request.ID).Error; err != nil {
+		t.Fatalf("failed to reload withdraw request: %v", err)
+	}
+	if reopened.Status != "processing" || reopened.LedgerEntryID != nil || reopened.ProcessedAt != nil {
+		t.Fatalf("got withdraw request %s with ledger entry %v, want processing without one", reopened.Status, reopened.LedgerEntryID)
+	}
+
+	var reversals []model.LedgerEntry
+	if err := db.Where("user_id = ? AND entry_type = ?", user.ID, "reversal").Order("kusd_delta ASC").Find(&reversals).Error; err != nil {
+		t.Fatalf("failed to load reversals: %v", err)
+	}
+	if len(reversals) != 2 ||
+		!reversals[0].KusdDelta.Equal(decimal.NewFromInt(-2000)) ||
+		!reversals[1].KusdDelta.Equal(decimal.NewFromInt(500)) || *reversals[1].ReversalOfID != withdrawEntry.ID {
+		t.Fatalf("got reversals %+v, want -2000 for the deposit and +500 for the withdrawal", reversals)
+	}
+	if got := balance(); !got.IsZero() {
+		t.Fatalf("got balance %s after the reorg, want 0", got)
+	}
+}
@@ -0,0 +1,533 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/keyvault"
+	"usdk-backend/pkg/signer"
+	"usdk-backend/pkg/wallet"
+)
+
+const (
+	sweepBatchSize = 200
+	// sweepFeeHeadroom multiplies the estimated fee of a sweep, both for gas
+	// top-ups and for what a native sweep leaves behind, so the sweep still
+	// fits when fees rise before it is mined
+	sweepFeeHeadroom = 2
+)
+
+// DepositSweeperService moves deposit address balances to the treasury
+// address of each chain.
+//
+// A run first settles the sweeps sent by earlier runs, then walks the deposit
+// addresses of every chain with a treasury. A balance worth at least MinUSD
+// is swept: a native balance minus a fee reserve, an ERC-20 balance once the
+// address holds enough native gas, which the gas-station wallet tops up in an
+// earlier run. Every top-up and sweep is recorded as an onchain_txs row with
+// direction "sweep", and an address with either in flight is left alone.
+type DepositSweeperService struct {
+	chainRepo          *repository.ChainRepository
+	chainAssetRepo     *repository.ChainAssetRepository
+	depositAddressRepo *repository.DepositAddressRepository
+	onchainTxRepo      *repository.OnchainTxRepository
+	outgoingTxRepo     *repository.OutgoingTxRepository
+	clients            map[uint64]ChainClient // keyed by chains.id
+	txManager          *TxManagerService
+	gasStation         common.Address // zero when ERC-20 addresses cannot be topped up
+	treasuries         map[string]common.Address
+	valuer             AssetValuer
+	minUSD             decimal.Decimal
+	maxSweeps          int
+	maxTopUps          int
+	dryRun             bool
+	interval           time.Duration
+	logger             *logrus.Logger
+}
+
+// sweepBudget counts what a run may still send across all chains
+type sweepBudget struct {
+	sweeps int
+	topUps int
+}
+
+func NewDepositSweeperService(
+	chainRepo *repository.ChainRepository,
+	chainAssetRepo *repository.ChainAssetRepository,
+	depositAddressRepo *repository.DepositAddressRepository,
+	onchainTxRepo *repository.OnchainTxRepository,
+	outgoingTxRepo *repository.OutgoingTxRepository,
+	clients map[uint64]ChainClient,
+	txManager *TxManagerService,
+	gasStation common.Address,
+	valuer AssetValuer,
+	sweepCfg config.SweepConfig,
+	logger *logrus.Logger,
+) *DepositSweeperService {
+	treasuries := make(map[string]common.Address)
+	for chainKey, address := range sweepCfg.Treasuries {
+		if address == "" {
+			continue
+		}
+		if !common.IsHexAddress(address) {
+			logger.WithField("chain", chainKey).Warn("Invalid sweep treasury address, chain will not be swept")
+			continue
+		}
+		treasuries[chainKey] = common.HexToAddress(address)
+	}
+
+	return &DepositSweeperService{
+		chainRepo:          chainRepo,
+		chainAssetRepo:     chainAssetRepo,
+		depositAddressRepo: depositAddressRepo,
+		onchainTxRepo:      onchainTxRepo,
+		outgoingTxRepo:     outgoingTxRepo,
+		clients:            clients,
+		txManager:          txManager,
+		gasStation:         gasStation,
+		treasuries:         treasuries,
+		valuer:             valuer,
+		minUSD:             decimal.NewFromFloat(sweepCfg.MinUSD),
+		maxSweeps:          sweepCfg.MaxSweepsPerRun,
+		maxTopUps:          sweepCfg.MaxTopUpsPerRun,
+		dryRun:             sweepCfg.DryRun,
+		interval:           time.Duration(sweepCfg.IntervalSec) * time.Second,
+		logger:             logger,
+	}
+}
+
+// Treasuries returns the sweep destination of every chain key that has one
+func (s *DepositSweeperService) Treasuries() map[string]common.Address {
+	return s.treasuries
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (s *DepositSweeperService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.ProcessOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessOnce settles earlier sweeps and sends new ones, up to the per-run
+// limits shared by all chains
+func (s *DepositSweeperService) ProcessOnce(ctx context.Context) {
+	chains, err := s.chainRepo.FindEnabled()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load chains for sweeping")
+		return
+	}
+
+	budget := &sweepBudget{sweeps: s.maxSweeps, topUps: s.maxTopUps}
+	for i := range chains {
+		chain := &chains[i]
+		client, ok := s.clients[chain.ID]
+		if !ok {
+			continue
+		}
+		treasury, ok := s.treasuries[chain.ChainKey]
+		if !ok {
+			continue
+		}
+
+		if err := s.settle(ctx, chain, client); err != nil {
+			s.logger.WithError(err).WithField("chain", chain.ChainKey).Warn("Failed to settle sweeps")
+		}
+		if err := s.sweepChain(ctx, chain, client, treasury, budget); err != nil {
+			s.logger.WithError(err).WithField("chain", chain.ChainKey).Warn("Sweep failed")
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"sweeps":  s.maxSweeps - budget.sweeps,
+		"top_ups": s.maxTopUps - budget.topUps,
+		"dry_run": s.dryRun,
+	}).Info("Sweep run finished")
+}
+
+// settle records the outcome of sweep and top-up transactions once the tx
+// manager has finalised them
+func (s *DepositSweeperService) settle(ctx context.Context, chain *model.Chain, client ChainClient) error {
+	unsettled, err := s.onchainTxRepo.FindUnsettledByChain(chain.ID, "sweep")
+	if err != nil {
+		return fmt.Errorf("failed to load pending sweeps: %v", err)
+	}
+	if len(unsettled) == 0 {
+		return nil
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %v", err)
+	}
+
+	for i := range unsettled {
+		onchainTx := &unsettled[i]
+		sent, err := s.outgoingTxRepo.FindByAttemptHash(chain.ID, onchainTx.TxHash)
+		if err != nil {
+			return fmt.Errorf("failed to load outgoing transaction: %v", err)
+		}
+		if sent == nil {
+			s.logger.WithField("tx_hash", onchainTx.TxHash).Warn("Sweep transaction is unknown to the tx manager")
+			continue
+		}
+
+		switch sent.Status {
+		case "confirmed":
+			onchainTx.Status = "confirmed"
+			onchainTx.ConfirmedAt = sent.FinalizedAt
+		case "reverted", "dropped":
+			onchainTx.Status = "failed"
+		default:
+			continue
+		}
+
+		onchainTx.TxHash = sent.TxHash
+		onchainTx.BlockNum = sent.BlockNum
+		onchainTx.GasUsed = sent.GasUsed
+		onchainTx.GasPrice = sent.EffectiveGasPrice
+		if sent.BlockNum != nil && head.Number.Uint64() >= *sent.BlockNum {
+			onchainTx.Confirmations = int(head.Number.Uint64() - *sent.BlockNum + 1)
+		}
+
+		if err := s.onchainTxRepo.Settle(onchainTx); err != nil {
+			return fmt.Errorf("failed to settle sweep %s: %v", onchainTx.TxHash, err)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"chain":   chain.ChainKey,
+			"tx_hash": onchainTx.TxHash,
+			"to":      onchainTx.ToAddr,
+			"status":  onchainTx.Status,
+		}).Info("Sweep settled")
+	}
+
+	return nil
+}
+
+func (s *DepositSweeperService) sweepChain(ctx context.Context, chain *model.Chain, client ChainClient, treasury common.Address, budget *sweepBudget) error {
+	chainAssets, err := s.chainAssetRepo.FindEnabledByChainID(chain.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load chain assets: %v", err)
+	}
+
+	assets := make(map[uint64]*model.ChainAsset, len(chainAssets))
+	var native *model.Asset
+	for i := range chainAssets {
+		ca := &chainAssets[i]
+		assets[ca.AssetID] = ca
+		if isNativeChainAsset(ca) {
+			native = &ca.Asset
+		}
+	}
+
+	afterID := uint64(0)
+	for budget.sweeps > 0 || budget.topUps > 0 {
+		addresses, err := s.depositAddressRepo.FindByChainAfter(chain.ID, afterID, sweepBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to load deposit addresses: %v", err)
+		}
+		if len(addresses) == 0 {
+			return nil
+		}
+
+		for i := range addresses {
+			if budget.sweeps <= 0 && budget.topUps <= 0 {
+				return nil
+			}
+			addr := &addresses[i]
+			afterID = addr.ID
+
+			chainAsset, ok := assets[addr.AssetID]
+			if !ok {
+				continue
+			}
+
+			if err := s.sweepAddress(ctx, chain, client, treasury, addr, chainAsset, native, budget); err != nil {
+				s.logger.WithError(err).WithFields(logrus.Fields{
+					"chain":   chain.ChainKey,
+					"address": addr.Address,
+				}).Warn("Failed to sweep deposit address")
+			}
+		}
+	}
+
+	return nil
+}
+
+// sweepAddress sweeps the balance of the asset a deposit address was issued
+// for, or tops it up with gas first
+func (s *DepositSweeperService) sweepAddress(
+	ctx context.Context,
+	chain *model.Chain,
+	client ChainClient,
+	treasury common.Address,
+	addr *model.DepositAddress,
+	chainAsset *model.ChainAsset,
+	native *model.Asset,
+	budget *sweepBudget,
+) error {
+	address := common.HexToAddress(addr.Address)
+	asset := &chainAsset.Asset
+	isNative := isNativeChainAsset(chainAsset)
+
+	var balance *big.Int
+	var err error
+	if isNative {
+		balance, err = client.BalanceAt(ctx, address, nil)
+	} else {
+		var erc20 *contracts.ERC20Contract
+		erc20, err = contracts.NewERC20Contract(common.HexToAddress(*chainAsset.ContractAddress), client)
+		if err == nil {
+			balance, err = erc20.BalanceOf(&bind.CallOpts{Context: ctx}, address)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s balance: %v", asset.Symbol, err)
+	}
+	if balance.Sign() <= 0 {
+		return nil
+	}
+
+	amount := decimal.NewFromBigInt(balance, -int32(asset.Decimals))
+	usdValue, err := s.valuer.GetUSDValue(asset.Symbol, amount)
+	if err != nil {
+		return fmt.Errorf("failed to value %s balance: %v", asset.Symbol, err)
+	}
+	if usdValue.LessThan(s.minUSD) {
+		return nil
+	}
+
+	busy, err := s.inFlight(addr.ID)
+	if err != nil || busy {
+		return err
+	}
+
+	req := TxRequest{Purpose: "sweep", RefID: &addr.ID}
+	if isNative {
+		req.To = treasury
+		req.Value = balance
+	} else {
+		erc20ABI, err := contracts.ERC20ContractMetaData.GetAbi()
+		if err != nil {
+			return err
+		}
+		req.To = common.HexToAddress(*chainAsset.ContractAddress)
+		req.Data, err = erc20ABI.Pack("transfer", treasury, balance)
+		if err != nil {
+			return fmt.Errorf("failed to pack transfer: %v", err)
+		}
+	}
+
+	cost, err := s.txManager.EstimateCost(ctx, chain, address, req)
+	if err != nil {
+		return err
+	}
+	cost.Mul(cost, big.NewInt(sweepFeeHeadroom))
+
+	if isNative {
+		// The fees come out of the balance being swept
+		req.Value = new(big.Int).Sub(balance, cost)
+		if req.Value.Sign() <= 0 {
+			return nil
+		}
+		amount = decimal.NewFromBigInt(req.Value, -int32(asset.Decimals))
+	} else {
+		gasBalance, err := client.BalanceAt(ctx, address, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get gas balance: %v", err)
+		}
+		if gasBalance.Cmp(cost) < 0 {
+			return s.topUp(ctx, chain, addr, native, new(big.Int).Sub(cost, gasBalance), budget)
+		}
+	}
+
+	if budget.sweeps <= 0 {
+		return nil
+	}
+	budget.sweeps--
+
+	logger := s.logger.WithFields(logrus.Fields{
+		"chain":     chain.ChainKey,
+		"asset":     asset.Symbol,
+		"address":   addr.Address,
+		"amount":    amount.String(),
+		"usd_value": usdValue.StringFixed(2),
+		"treasury":  treasury.Hex(),
+	})
+	if s.dryRun {
+		logger.Info("Dry run: would sweep deposit address")
+		return nil
+	}
+
+	sent, err := s.txManager.Send(ctx, chain, address, req)
+	if err != nil {
+		return fmt.Errorf("failed to send sweep: %v", err)
+	}
+	s.record(logger, chain, asset, address, treasury, sent, amount)
+	logger.WithField("tx_hash", sent.TxHash).Info("Deposit address swept")
+	return nil
+}
+
+// topUp sends native gas from the gas station to a deposit address holding
+// an ERC-20 balance; the sweep follows in a later run once it is confirmed
+func (s *DepositSweeperService) topUp(ctx context.Context, chain *model.Chain, addr *model.DepositAddress, native *model.Asset, value *big.Int, budget *sweepBudget) error {
+	if s.gasStation == (common.Address{}) {
+		return fmt.Errorf("address needs gas but no gas station signer is configured")
+	}
+	if native == nil {
+		return fmt.Errorf("address needs gas but chain %s has no native asset to record it", chain.ChainKey)
+	}
+	if budget.topUps <= 0 {
+		return nil
+	}
+	budget.topUps--
+
+	address := common.HexToAddress(addr.Address)
+	amount := decimal.NewFromBigInt(value, -int32(native.Decimals))
+	logger := s.logger.WithFields(logrus.Fields{
+		"chain":       chain.ChainKey,
+		"address":     addr.Address,
+		"amount":      amount.String(),
+		"gas_station": s.gasStation.Hex(),
+	})
+	if s.dryRun {
+		logger.Info("Dry run: would top up deposit address with gas")
+		return nil
+	}
+
+	sent, err := s.txManager.Send(ctx, chain, s.gasStation, TxRequest{
+		To:      address,
+		Value:   value,
+		Purpose: "sweep_gas",
+		RefID:   &addr.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send gas top-up: %v", err)
+	}
+	s.record(logger, chain, native, s.gasStation, address, sent, amount)
+	logger.WithField("tx_hash", sent.TxHash).Info("Deposit address topped up with gas")
+	return nil
+}
+
+// inFlight reports whether a sweep or top-up of the address is not final yet
+func (s *DepositSweeperService) inFlight(depositAddressID uint64) (bool, error) {
+	for _, purpose := range []string{"sweep", "sweep_gas"} {
+		tx, err := s.outgoingTxRepo.FindLatestByRef(purpose, depositAddressID)
+		if err != nil {
+			return false, fmt.Errorf("failed to load %s transaction: %v", purpose, err)
+		}
+		if tx != nil && (tx.Status == "pending" || tx.Status == "mined") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// record stores a sent top-up or sweep as an onchain_txs row
+func (s *DepositSweeperService) record(logger *logrus.Entry, chain *model.Chain, asset *model.Asset, from, to common.Address, sent *model.OutgoingTx, amount decimal.Decimal) {
+	fromAddr := from.Hex()
+	onchainTx := &model.OnchainTx{
+		Direction: "sweep",
+		ChainID:   chain.ID,
+		AssetID:   asset.ID,
+		FromAddr:  &fromAddr,
+		ToAddr:    to.Hex(),
+		TxHash:    sent.TxHash,
+		LogIndex:  -1,
+		Amount:    amount,
+		Status:    "pending",
+		SeenAt:    time.Now(),
+	}
+	if err := s.onchainTxRepo.Create(onchainTx); err != nil {
+		// The transaction is owned by the tx manager and inFlight still sees it
+		logger.WithError(err).WithField("tx_hash", sent.TxHash).Error("Sweep sent but not recorded")
+	}
+}
+
+func isNativeChainAsset(chainAsset *model.ChainAsset) bool {
+	return chainAsset.ContractAddress == nil || common.HexToAddress(*chainAsset.ContractAddress) == (common.Address{})
+}
+
+// DepositSignerSource provides the signers of deposit addresses to the tx
+// manager: derived from the mnemonic for HD addresses, or opened from the
+// key vault for random ones. Keys are loaded per use and never kept. It
+// needs offline key material and is not wired in a watch-only server.
+type DepositSignerSource struct {
+	depositAddressRepo *repository.DepositAddressRepository
+	hdWallet           *wallet.HDWalletService // nil without a mnemonic
+	vault              *keyvault.Vault         // nil when no KEK is configured
+}
+
+func NewDepositSignerSource(
+	depositAddressRepo *repository.DepositAddressRepository,
+	hdWallet *wallet.HDWalletService,
+	vault *keyvault.Vault,
+) *DepositSignerSource {
+	return &DepositSignerSource{
+		depositAddressRepo: depositAddressRepo,
+		hdWallet:           hdWallet,
+		vault:              vault,
+	}
+}
+
+// SignerFor returns the signer of a deposit address, or nil when from is not one
+func (s *DepositSignerSource) SignerFor(ctx context.Context, from common.Address) (signer.Signer, error) {
+	addr, err := s.depositAddressRepo.FindAnyByAddress(from.Hex())
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deposit address: %v", err)
+	}
+
+	if addr.PrivateKeyRef != nil && *addr.PrivateKeyRef != "" {
+		if s.vault == nil {
+			return nil, fmt.Errorf("deposit key of %s is sealed but no KEK is configured", addr.Address)
+		}
+		raw, err := s.vault.Open(*addr.PrivateKeyRef, depositKeyAssociatedData(addr.Address))
+		if err != nil {
+			return nil, err
+		}
+		key, err := crypto.ToECDSA(raw)
+		if err != nil {
+			return nil, fmt.Errorf("stored private key is invalid: %v", err)
+		}
+		return signer.NewKeySigner(key), nil
+	}
+
+	if addr.DerivationPath != nil && strings.HasPrefix(*addr.DerivationPath, "m/") {
+		if s.hdWallet == nil {
+			return nil, fmt.Errorf("deposit key of %s needs HD_MNEMONIC to be derived", addr.Address)
+		}
+		key, err := s.hdWallet.DeriveECDSAKey(*addr.DerivationPath)
+		if err != nil {
+			return nil, err
+		}
+		return signer.NewKeySigner(key), nil
+	}
+
+	return nil, fmt.Errorf("no key available for deposit address %s", addr.Address)
+}
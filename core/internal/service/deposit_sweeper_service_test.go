@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/signer"
+)
+
+// usdkArtifact is the compiled token from the hardhat project
+var usdkArtifact = filepath.Join("..", "..", "..", "contracts", "USDK.json")
+
+// depositKeys signs for the deposit addresses a test created
+type depositKeys map[common.Address]*ecdsa.PrivateKey
+
+func (k depositKeys) SignerFor(_ context.Context, from common.Address) (signer.Signer, error) {
+	key, ok := k[from]
+	if !ok {
+		return nil, nil
+	}
+	return signer.NewKeySigner(key), nil
+}
+
+// sweeperFixture is a sweeper on a simulated chain whose genesis account is
+// also the gas station
+type sweeperFixture struct {
+	db         *gorm.DB
+	chain      *model.Chain
+	eth        *model.Asset
+	sim        *backends.SimulatedBackend
+	funderKey  *ecdsa.PrivateKey
+	gasStation common.Address
+	treasury   common.Address
+	keys       depositKeys
+	txManager  *TxManagerService
+	sweeper    *DepositSweeperService
+	logs       *logtest.Hook
+}
+
+func newSweeperFixture(t *testing.T, sweepCfg config.SweepConfig) *sweeperFixture {
+	t.Helper()
+
+	db := newTestDB(t)
+	chain, eth := seedNativeChain(t, db)
+	funderKey, _ := crypto.GenerateKey()
+	sim := newSimulatedChain(t, crypto.PubkeyToAddress(funderKey.PublicKey))
+	clients := map[uint64]ChainClient{chain.ID: sim}
+	logger := newTestLogger()
+
+	outgoingTxRepo := repository.NewOutgoingTxRepository(db)
+	txManager := NewTxManagerService(
+		outgoingTxRepo, clients,
+		config.TxConfig{MaxFeeGwei: 100, MaxPriorityFeeGwei: 2, StuckTimeoutSec: 600},
+		config.PlatformConfig{ConfirmationBlocks: 1}, logger,
+	)
+	gasStation := txManager.AddSigner(signer.NewKeySigner(funderKey))
+	keys := make(depositKeys)
+	txManager.SetSignerSource(keys)
+
+	treasury := common.HexToAddress("0x0000000000000000000000000000000000007ea5")
+	sweepCfg.Treasuries = map[string]string{chain.ChainKey: treasury.Hex()}
+	sweeper := NewDepositSweeperService(
+		repository.NewChainRepository(db),
+		repository.NewChainAssetRepository(db),
+		repository.NewDepositAddressRepository(db),
+		repository.NewOnchainTxRepository(db),
+		outgoingTxRepo,
+		clients, txManager, gasStation,
+		fixedPriceValuer{"ETH": decimal.NewFromInt(2000), "USDK": decimal.NewFromInt(1)},
+		sweepCfg, logger,
+	)
+
+	return &sweeperFixture{
+		db:         db,
+		chain:      chain,
+		eth:        eth,
+		sim:        sim,
+		funderKey:  funderKey,
+		gasStation: gasStation,
+		treasury:   treasury,
+		keys:       keys,
+		txManager:  txManager,
+		sweeper:    sweeper,
+		logs:       logtest.NewLocal(logger),
+	}
+}
+
+// depositAddress issues a new user an address for asset and funds it with
+// wei of native balance
+func (f *sweeperFixture) depositAddress(t *testing.T, asset *model.Asset, wei *big.Int) common.Address {
+	t.Helper()
+
+	user := &model.User{}
+	if err := f.db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	f.keys[address] = key
+	err := f.db.Create(&model.DepositAddress{
+		UserID:   user.ID,
+		ChainID:  f.chain.ID,
+		AssetID:  asset.ID,
+		Address:  address.Hex(),
+		IsActive: true,
+	}).Error
+	if err != nil {
+		t.Fatalf("failed to seed deposit address: %v", err)
+	}
+
+	if wei != nil {
+		sendEther(t, f.sim, f.funderKey, address, wei)
+		f.sim.Commit()
+	}
+	return address
+}
+
+// mine includes the sent transactions and lets the tx manager confirm them
+func (f *sweeperFixture) mine(t *testing.T) {
+	t.Helper()
+
+	f.sim.Commit()
+	f.txManager.ProcessOnce(context.Background())
+}
+
+func (f *sweeperFixture) outgoing(t *testing.T, purpose string) []model.OutgoingTx {
+	t.Helper()
+
+	var txs []model.OutgoingTx
+	if err := f.db.Where("purpose = ?", purpose).Order("id ASC").Find(&txs).Error; err != nil {
+		t.Fatalf("failed to load %s transactions: %v", purpose, err)
+	}
+	return txs
+}
+
+func (f *sweeperFixture) recorded(t *testing.T) []model.OnchainTx {
+	t.Helper()
+
+	var txs []model.OnchainTx
+	if err := f.db.Where("direction = ?", "sweep").Order("id ASC").Find(&txs).Error; err != nil {
+		t.Fatalf("failed to load sweep records: %v", err)
+	}
+	return txs
+}
+
+// deployUSDK deploys the token with the key's account holding every role
+func deployUSDK(t *testing.T, sim *backends.SimulatedBackend, key *ecdsa.PrivateKey) (common.Address, *bind.BoundContract) {
+	t.Helper()
+
+	raw, err := os.ReadFile(usdkArtifact)
+	if err != nil {
+		t.Fatalf("failed to read USDK artifact: %v", err)
+	}
+	var artifact struct {
+		Bytecode string `json:"bytecode"`
+	}
+	if err := json.Unmarshal(raw, &artifact); err != nil {
+		t.Fatalf("invalid USDK artifact: %v", err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(contracts.USDKContractMetaData.ABI))
+	if err != nil {
+		t.Fatalf("invalid USDK ABI: %v", err)
+	}
+
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	address, _, token, err := bind.DeployContract(auth, parsed, hexutil.MustDecode(artifact.Bytecode), sim, "USDK", "USDK", owner, owner, owner, owner)
+	if err != nil {
+		t.Fatalf("failed to deploy USDK: %v", err)
+	}
+	sim.Commit()
+	return address, token
+}
+
+func TestSweeperSweepsNativeBalanceLeavingFeeHeadroom(t *testing.T) {
+	ctx := context.Background()
+	f := newSweeperFixture(t, config.SweepConfig{MinUSD: 10, MaxSweepsPerRun: 10, MaxTopUpsPerRun: 10})
+	balance := big.NewInt(params.Ether)
+	deposit := f.depositAddress(t, f.eth, balance)
+
+	cost, err := f.txManager.EstimateCost(ctx, f.chain, deposit, TxRequest{To: f.treasury, Value: balance})
+	if err != nil {
+		t.Fatalf("failed to estimate sweep cost: %v", err)
+	}
+	reserve := new(big.Int).Mul(cost, big.NewInt(sweepFeeHeadroom))
+	want := new(big.Int).Sub(balance, reserve)
+
+	f.sweeper.ProcessOnce(ctx)
+	sweeps := f.outgoing(t, "sweep")
+	if len(sweeps) != 1 {
+		t.Fatalf("got %d sweeps, want 1", len(sweeps))
+	}
+	sweep := sweeps[0]
+	if sweep.FromAddr != deposit.Hex() || sweep.ToAddr != f.treasury.Hex() || sweep.Value.BigInt().Cmp(want) != 0 {
+		t.Fatalf("got sweep of %s wei from %s to %s, want %s wei from %s to the treasury", sweep.Value, sweep.FromAddr, sweep.ToAddr, want, deposit.Hex())
+	}
+
+	// The balance is still there until the sweep is mined, but the address
+	// is left alone while it is in flight
+	f.sweeper.ProcessOnce(ctx)
+	if sweeps := f.outgoing(t, "sweep"); len(sweeps) != 1 {
+		t.Fatalf("got %d sweeps with one in flight, want 1", len(sweeps))
+	}
+
+	f.mine(t)
+	f.sweeper.ProcessOnce(ctx)
+	records := f.recorded(t)
+	if len(records) != 1 || records[0].Status != "confirmed" || records[0].AssetID != f.eth.ID || records[0].ToAddr != f.treasury.Hex() {
+		t.Fatalf("got sweep records %+v, want one confirmed ETH sweep to the treasury", records)
+	}
+	if sweeps := f.outgoing(t, "sweep"); len(sweeps) != 1 {
+		t.Fatalf("got %d sweeps after the balance was swept, want 1", len(sweeps))
+	}
+
+	treasuryBalance, err := f.sim.BalanceAt(ctx, f.treasury, nil)
+	if err != nil {
+		t.Fatalf("failed to get treasury balance: %v", err)
+	}
+	if treasuryBalance.Cmp(want) != 0 {
+		t.Fatalf("treasury holds %s wei, want %s", treasuryBalance, want)
+	}
+	// The fee paid is at most the estimate, so the headroom stays behind
+	left, err := f.sim.BalanceAt(ctx, deposit, nil)
+	if err != nil {
+		t.Fatalf("failed to get deposit balance: %v", err)
+	}
+	if left.Cmp(cost) < 0 || left.Cmp(reserve) >= 0 {
+		t.Fatalf("deposit address kept %s wei, want between %s and %s", left, cost, reserve)
+	}
+}
+
+func TestSweeperTopsUpERC20AddressBeforeSweeping(t *testing.T) {
+	ctx := context.Background()
+	f := newSweeperFixture(t, config.SweepConfig{MinUSD: 10, MaxSweepsPerRun: 10, MaxTopUpsPerRun: 10})
+
+	tokenAddress, token := deployUSDK(t, f.sim, f.funderKey)
+	contract := tokenAddress.Hex()
+	usdk := &model.Asset{Symbol: "USDK", Name: "USDK", Decimals: 18, AssetType: "stable", Enabled: true}
+	if err := f.db.Create(usdk).Error; err != nil {
+		t.Fatalf("failed to seed asset: %v", err)
+	}
+	if err := f.db.Create(&model.ChainAsset{ChainID: f.chain.ID, AssetID: usdk.ID, ContractAddress: &contract, Enabled: true}).Error; err != nil {
+		t.Fatalf("failed to seed chain asset: %v", err)
+	}
+
+	deposit := f.depositAddress(t, usdk, nil)
+	amount := new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))
+	auth, err := bind.NewKeyedTransactorWithChainID(f.funderKey, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	if _, err := token.Transact(auth, "mint", deposit, amount); err != nil {
+		t.Fatalf("failed to mint USDK: %v", err)
+	}
+	f.sim.Commit()
+
+	// Without gas the address is topped up instead of swept
+	f.sweeper.ProcessOnce(ctx)
+	if sweeps := f.outgoing(t, "sweep"); len(sweeps) != 0 {
+		t.Fatalf("got %d sweeps from an address without gas, want none", len(sweeps))
+	}
+	topUps := f.outgoing(t, "sweep_gas")
+	if len(topUps) != 1 || topUps[0].FromAddr != f.gasStation.Hex() || topUps[0].ToAddr != deposit.Hex() || topUps[0].Value.Sign() <= 0 {
+		t.Fatalf("got top-ups %+v, want one from the gas station to %s", topUps, deposit.Hex())
+	}
+	records := f.recorded(t)
+	if len(records) != 1 || records[0].AssetID != f.eth.ID || records[0].ToAddr != deposit.Hex() {
+		t.Fatalf("got sweep records %+v, want the ETH top-up", records)
+	}
+
+	// No second top-up while the first is in flight
+	f.sweeper.ProcessOnce(ctx)
+	if topUps := f.outgoing(t, "sweep_gas"); len(topUps) != 1 {
+		t.Fatalf("got %d top-ups with one in flight, want 1", len(topUps))
+	}
+
+	// Once the gas arrived the token balance is swept
+	f.mine(t)
+	f.sweeper.ProcessOnce(ctx)
+	sweeps := f.outgoing(t, "sweep")
+	if len(sweeps) != 1 || sweeps[0].FromAddr != deposit.Hex() || sweeps[0].ToAddr != contract {
+		t.Fatalf("got sweeps %+v, want a token transfer from %s", sweeps, deposit.Hex())
+	}
+	if topUps := f.outgoing(t, "sweep_gas"); len(topUps) != 1 {
+		t.Fatalf("got %d top-ups, want 1", len(topUps))
+	}
+
+	f.mine(t)
+	f.sweeper.ProcessOnce(ctx)
+	erc20, err := contracts.NewERC20Contract(tokenAddress, f.sim)
+	if err != nil {
+		t.Fatalf("failed to bind token: %v", err)
+	}
+	swept, err := erc20.BalanceOf(&bind.CallOpts{Context: ctx}, f.treasury)
+	if err != nil {
+		t.Fatalf("failed to get treasury balance: %v", err)
+	}
+	if swept.Cmp(amount) != 0 {
+		t.Fatalf("treasury holds %s USDK wei, want %s", swept, amount)
+	}
+	for _, record := range f.recorded(t) {
+		if record.Status != "confirmed" {
+			t.Fatalf("sweep record %s is %s, want confirmed", record.TxHash, record.Status)
+		}
+	}
+}
+
+func TestSweeperDryRunSpendsBudgetWithoutSending(t *testing.T) {
+	ctx := context.Background()
+	f := newSweeperFixture(t, config.SweepConfig{DryRun: true, MinUSD: 10, MaxSweepsPerRun: 1, MaxTopUpsPerRun: 1})
+	f.depositAddress(t, f.eth, big.NewInt(params.Ether))
+	f.depositAddress(t, f.eth, big.NewInt(params.Ether))
+
+	f.sweeper.ProcessOnce(ctx)
+
+	if sweeps := f.outgoing(t, "sweep"); len(sweeps) != 0 {
+		t.Fatalf("dry run sent %d sweeps", len(sweeps))
+	}
+	if records := f.recorded(t); len(records) != 0 {
+		t.Fatalf("dry run recorded %d sweeps", len(records))
+	}
+	planned := 0
+	for _, entry := range f.logs.AllEntries() {
+		if entry.Message == "Dry run: would sweep deposit address" {
+			planned++
+		}
+	}
+	if planned != 1 {
+		t.Fatalf("dry run planned %d sweeps, want the 1 the budget allows", planned)
+	}
+	if finished := f.logs.LastEntry(); finished.Message != "Sweep run finished" || finished.Data["sweeps"] != 1 {
+		t.Fatalf("got last log %q %v, want the run to count 1 sweep", finished.Message, finished.Data)
+	}
+}
+
+func TestSweeperSettlesFinalTransactions(t *testing.T) {
+	ctx := context.Background()
+	f := newSweeperFixture(t, config.SweepConfig{MinUSD: 10, MaxSweepsPerRun: 10, MaxTopUpsPerRun: 10})
+	outgoingTxRepo := repository.NewOutgoingTxRepository(f.db)
+
+	// Each sweep was recorded under its first attempt; the confirmed one was
+	// replaced and mined under a second
+	statuses := []string{"confirmed", "reverted", "dropped", "pending", "mined"}
+	records := make([]*model.OnchainTx, len(statuses))
+	finalHashes := make([]string, len(statuses))
+	for i, status := range statuses {
+		firstHash := common.BigToHash(big.NewInt(int64(2*i + 1))).Hex()
+		finalHashes[i] = firstHash
+		sent := &model.OutgoingTx{
+			ChainID:              f.chain.ID,
+			FromAddr:             f.gasStation.Hex(),
+			Nonce:                uint64(i),
+			ToAddr:               f.treasury.Hex(),
+			MaxFeePerGas:         decimal.NewFromInt(params.GWei),
+			MaxPriorityFeePerGas: decimal.NewFromInt(params.GWei),
+			GasLimit:             params.TxGas,
+			TxHash:               firstHash,
+			Purpose:              "sweep",
+			Status:               status,
+			Attempts:             1,
+		}
+		if err := outgoingTxRepo.CreateWithAttempt(sent, newTxAttempt(sent)); err != nil {
+			t.Fatalf("failed to seed %s transaction: %v", status, err)
+		}
+		if status == "confirmed" {
+			finalHashes[i] = common.BigToHash(big.NewInt(int64(2*i + 2))).Hex()
+			sent.TxHash = finalHashes[i]
+			if err := f.db.Create(newTxAttempt(sent)).Error; err != nil {
+				t.Fatalf("failed to seed replacement: %v", err)
+			}
+			if err := f.db.Model(sent).Update("tx_hash", sent.TxHash).Error; err != nil {
+				t.Fatalf("failed to record replacement: %v", err)
+			}
+		}
+
+		records[i] = &model.OnchainTx{
+			Direction: "sweep",
+			ChainID:   f.chain.ID,
+			AssetID:   f.eth.ID,
+			ToAddr:    f.treasury.Hex(),
+			TxHash:    firstHash,
+			LogIndex:  -1,
+			Amount:    decimal.NewFromInt(1),
+			Status:    "pending",
+		}
+		if err := f.db.Create(records[i]).Error; err != nil {
+			t.Fatalf("failed to seed sweep record: %v", err)
+		}
+	}
+
+	if err := f.sweeper.settle(ctx, f.chain, f.sim); err != nil {
+		t.Fatalf("failed to settle sweeps: %v", err)
+	}
+
+	want := []string{"confirmed", "failed", "failed", "pending", "pending"}
+	for i, record := range records {
+		var settled model.OnchainTx
+		if err := f.db.First(&settled, record.ID).Error; err != nil {
+			t.Fatalf("failed to reload sweep record: %v", err)
+		}
+		if settled.Status != want[i] || settled.TxHash != finalHashes[i] {
+			t.Fatalf("%s transaction: got record %s under %s, want %s under %s", statuses[i], settled.Status, settled.TxHash, want[i], finalHashes[i])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"database/sql/driver"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"usdk-backend/pkg/database"
+)
+
+func init() {
+	// MySQL's CONCAT_WS, used to append admin notes
+	gosqlite.MustRegisterDeterministicScalarFunction("concat_ws", -1, func(_ *gosqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var parts []string
+		for _, arg := range args[1:] {
+			switch v := arg.(type) {
+			case nil:
+			case []byte:
+				parts = append(parts, string(v))
+			case string:
+				parts = append(parts, v)
+			}
+		}
+		separator, _ := args[0].(string)
+		return strings.Join(parts, separator), nil
+	})
+}
+
+// fixedPriceValuer values assets at a fixed USD price per symbol
+type fixedPriceValuer map[string]decimal.Decimal
+
+func (v fixedPriceValuer) GetUSDValue(symbol string, amount decimal.Decimal) (decimal.Decimal, error) {
+	return amount.Mul(v[symbol]), nil
+}
+
+// newTestDB returns a migrated SQLite database private to the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/kyc"
+)
+
+type KycService struct {
+	kycRepo  *repository.UserKycRepository
+	provider kyc.Provider // nil when no provider is configured
+	logger   *logrus.Logger
+}
+
+func NewKycService(kycRepo *repository.UserKycRepository, provider kyc.Provider, logger *logrus.Logger) *KycService {
+	return &KycService{
+		kycRepo:  kycRepo,
+		provider: provider,
+		logger:   logger,
+	}
+}
+
+type KycStatusResponse struct {
+	Level          int        `json:"level"`
+	Status         string     `json:"status"`
+	RequestedLevel int        `json:"requestedLevel"`
+	RejectReason   *string    `json:"rejectReason,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewedAt,omitempty"`
+}
+
+type KycStartResponse struct {
+	Status          string `json:"status"`
+	RequestedLevel  int    `json:"requestedLevel"`
+	VerificationURL string `json:"verificationUrl"`
+}
+
+func (s *KycService) GetStatus(userID uint64) (*KycStatusResponse, error) {
+	record, err := s.kycRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load KYC status: %v", err)
+	}
+	if record == nil {
+		return &KycStatusResponse{Level: kyc.LevelNone, Status: "none"}, nil
+	}
+
+	return &KycStatusResponse{
+		Level:          record.Level,
+		Status:         record.Status,
+		RequestedLevel: record.RequestedLevel,
+		RejectReason:   record.RejectReason,
+		ReviewedAt:     record.ReviewedAt,
+	}, nil
+}
+
+// StartVerification opens a verification with the provider for a level above
+// the user's current one. The approved level is kept while it is pending.
+func (s *KycService) StartVerification(userID uint64, level int) (*KycStartResponse, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("KYC provider not configured")
+	}
+	if !kyc.IsValidLevel(level) || level == kyc.LevelNone {
+		return nil, fmt.Errorf("invalid KYC level: %d", level)
+	}
+
+	record, err := s.kycRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load KYC status: %v", err)
+	}
+	if record == nil {
+		record = &model.UserKyc{UserID: userID, Level: kyc.LevelNone}
+	}
+	if record.Level >= level {
+		return nil, fmt.Errorf("KYC level %d already approved", record.Level)
+	}
+
+	session, err := s.provider.StartVerification(userID, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start verification: %v", err)
+	}
+
+	providerName := s.provider.Name()
+	record.Status = kyc.StatusPending
+	record.RequestedLevel = level
+	record.Provider = &providerName
+	record.ApplicantID = &session.ApplicantID
+	record.RejectReason = nil
+	if err := s.kycRepo.Save(record); err != nil {
+		return nil, fmt.Errorf("failed to save KYC status: %v", err)
+	}
+
+	return &KycStartResponse{
+		Status:          record.Status,
+		RequestedLevel:  level,
+		VerificationURL: session.VerificationURL,
+	}, nil
+}
+
+// HandleWebhook applies a provider callback to the matching KYC record. Each
+// event is applied once; a replayed event id is rejected.
+func (s *KycService) HandleWebhook(body []byte, header http.Header) error {
+	if s.provider == nil {
+		return fmt.Errorf("KYC provider not configured")
+	}
+
+	event, err := s.provider.ParseWebhook(body, header)
+	if err != nil {
+		return err
+	}
+
+	record, err := s.kycRepo.FindByApplicant(s.provider.Name(), event.ApplicantID)
+	if err != nil {
+		return fmt.Errorf("unknown applicant: %s", event.ApplicantID)
+	}
+
+	now := time.Now()
+	switch event.Status {
+	case kyc.StatusApproved:
+		level := event.Level
+		if !kyc.IsValidLevel(level) || level == kyc.LevelNone {
+			level = record.RequestedLevel
+		}
+		if level > record.Level {
+			record.Level = level
+		}
+		record.RejectReason = nil
+	case kyc.StatusRejected:
+		reason := event.RejectReason
+		record.RejectReason = &reason
+	case kyc.StatusPending:
+	default:
+		return fmt.Errorf("unknown KYC status: %s", event.Status)
+	}
+
+	record.Status = event.Status
+	if event.Status != kyc.StatusPending {
+		record.ReviewedAt = &now
+	}
+	if err := s.kycRepo.SaveWebhookResult(record, s.provider.Name(), event.EventID); err != nil {
+		if errors.Is(err, repository.ErrDuplicateKycEvent) {
+			return fmt.Errorf("duplicate webhook delivery: %s", event.EventID)
+		}
+		return fmt.Errorf("failed to save KYC status: %v", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"event_id":     event.EventID,
+		"user_id":      record.UserID,
+		"applicant_id": event.ApplicantID,
+		"status":       record.Status,
+		"level":        record.Level,
+	}).Info("KYC status updated")
+
+	return nil
+}
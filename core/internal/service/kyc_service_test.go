@@ -0,0 +1,76 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/kyc"
+)
+
+func TestKycWebhookAppliesEachEventOnce(t *testing.T) {
+	db := newTestDB(t)
+	provider := kyc.NewFakeProvider("webhook-secret")
+	kycRepo := repository.NewUserKycRepository(db)
+	kycService := NewKycService(kycRepo, provider, newTestLogger())
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	if _, err := kycService.StartVerification(user.ID, kyc.LevelBasic); err != nil {
+		t.Fatalf("failed to start verification: %v", err)
+	}
+	record, err := kycRepo.FindByUserID(user.ID)
+	if err != nil || record == nil {
+		t.Fatalf("verification not recorded: %v", err)
+	}
+
+	deliver := func(eventID, status string, level int) error {
+		body := []byte(fmt.Sprintf(`{"eventId":%q,"applicantId":%q,"status":%q,"level":%d}`, eventID, *record.ApplicantID, status, level))
+		return kycService.HandleWebhook(body, provider.SignedHeader(body, time.Now()))
+	}
+	level := func() int {
+		t.Helper()
+		approved, err := kycRepo.GetApprovedLevel(user.ID)
+		if err != nil {
+			t.Fatalf("failed to load level: %v", err)
+		}
+		return approved
+	}
+
+	if err := deliver("evt-approved", kyc.StatusApproved, kyc.LevelBasic); err != nil {
+		t.Fatalf("approval rejected: %v", err)
+	}
+	if got := level(); got != kyc.LevelBasic {
+		t.Fatalf("got level %d after approval, want %d", got, kyc.LevelBasic)
+	}
+
+	// A replayed delivery is refused and changes nothing
+	if err := deliver("evt-approved", kyc.StatusApproved, kyc.LevelBasic); err == nil || !strings.Contains(err.Error(), "duplicate webhook delivery") {
+		t.Fatalf("got %v for a replayed event, want a duplicate delivery error", err)
+	}
+
+	// A new event is applied as usual
+	if err := deliver("evt-rejected", kyc.StatusRejected, kyc.LevelBasic); err != nil {
+		t.Fatalf("rejection refused: %v", err)
+	}
+	status, err := kycService.GetStatus(user.ID)
+	if err != nil {
+		t.Fatalf("failed to load status: %v", err)
+	}
+	if status.Status != kyc.StatusRejected || status.Level != kyc.LevelBasic {
+		t.Fatalf("got %+v, want rejected keeping the approved basic level", status)
+	}
+
+	var events int64
+	if err := db.Model(&model.KycWebhookEvent{}).Count(&events).Error; err != nil {
+		t.Fatalf("failed to count events: %v", err)
+	}
+	if events != 2 {
+		t.Fatalf("got %d recorded events, want 2", events)
+	}
+}
@@ -27,6 +27,7 @@ type AssetInfo struct {
 type ChainWithAssets struct {
 	Chain   string      `json:"chain"`
 	ChainID uint64      `json:"chainId"`
+	Family  string      `json:"family"`
 	Assets  []AssetInfo `json:"assets"`
 }
 
@@ -56,7 +57,8 @@ func (s *MetaService) GetSupportedChains() ([]ChainWithAssets, error) {
 
 		result = append(result, ChainWithAssets{
 			Chain:   chain.ChainKey,
-			ChainID: chain.ChainID,
+			ChainID: chain.NetworkID,
+			Family:  chain.Family,
 			Assets:  assets,
 		})
 	}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/archive"
+	"usdk-backend/pkg/contracts"
+)
+
+const (
+	proofIndexerWorker   = "proof_indexer"
+	proofIndexBlockRange = 2000
+)
+
+// ProofIndexerService follows the ProofPublished, BatchVerified and
+// BatchRevoked logs of the proof chain's ProofRegistry and syncs them back
+// into proof_batches, including batches published by other oracles. A batch
+// whose on-chain root differs from the stored merkle root is flagged as
+// divergent.
+//
+// Logs are only read once they have the configured number of confirmations,
+// so indexed events never have to be rolled back after a reorg.
+type ProofIndexerService struct {
+	proofBatchRepo     *repository.ProofBatchRepository
+	chainRepo          *repository.ChainRepository
+	syncStateRepo      *repository.ChainSyncStateRepository
+	clients            map[uint64]ChainClient // keyed by chains.id
+	proofChain         string
+	registries         map[string]config.ContractAddresses
+	startBlock         uint64
+	confirmationBlocks int
+	interval           time.Duration
+	logger             *logrus.Logger
+}
+
+func NewProofIndexerService(
+	proofBatchRepo *repository.ProofBatchRepository,
+	chainRepo *repository.ChainRepository,
+	syncStateRepo *repository.ChainSyncStateRepository,
+	clients map[uint64]ChainClient,
+	blockchainCfg config.BlockchainConfig,
+	platformCfg config.PlatformConfig,
+	logger *logrus.Logger,
+) *ProofIndexerService {
+	return &ProofIndexerService{
+		proofBatchRepo:     proofBatchRepo,
+		chainRepo:          chainRepo,
+		syncStateRepo:      syncStateRepo,
+		clients:            clients,
+		proofChain:         blockchainCfg.ProofChain,
+		registries:         blockchainCfg.Contracts,
+		startBlock:         platformCfg.ProofIndexStartBlock,
+		confirmationBlocks: platformCfg.ConfirmationBlocks,
+		interval:           time.Duration(platformCfg.ProofIndexIntervalSec) * time.Second,
+		logger:             logger,
+	}
+}
+
+// Run indexes the proof chain every interval until ctx is cancelled
+func (s *ProofIndexerService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.IndexOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IndexOnce runs a single indexing pass over the proof chain
+func (s *ProofIndexerService) IndexOnce(ctx context.Context) {
+	chain, err := s.chainRepo.FindByChainKey(s.proofChain)
+	if err != nil {
+		s.logger.WithError(err).WithField("chain", s.proofChain).Error("Proof chain not found")
+		return
+	}
+	client, ok := s.clients[chain.ID]
+	if !ok {
+		s.logger.WithField("chain", chain.ChainKey).Warn("No RPC client for proof chain, skipping proof indexing")
+		return
+	}
+
+	if err := s.IndexChain(ctx, chain, client); err != nil {
+		s.logger.WithError(err).WithField("chain", chain.ChainKey).Warn("Proof indexing failed")
+	}
+}
+
+// IndexChain advances the indexer cursor of the chain by at most
+// proofIndexBlockRange confirmed blocks
+func (s *ProofIndexerService) IndexChain(ctx context.Context, chain *model.Chain, client ChainClient) error {
+	registry, err := proofRegistryAddress(chain, s.registries)
+	if err != nil {
+		return err
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %v", err)
+	}
+	headNum := head.Number.Uint64()
+	if s.confirmationBlocks > 0 && headNum+1 < uint64(s.confirmationBlocks) {
+		return nil
+	}
+	confirmed := headNum
+	if s.confirmationBlocks > 0 {
+		confirmed = headNum + 1 - uint64(s.confirmationBlocks)
+	}
+
+	state, err := s.syncStateRepo.Find(chain.ID, proofIndexerWorker)
+	if err != nil {
+		return fmt.Errorf("failed to load sync state: %v", err)
+	}
+	from := s.startBlock
+	if state != nil {
+		from = state.LastBlock + 1
+	}
+	if from > confirmed {
+		return nil
+	}
+	to := from + proofIndexBlockRange - 1
+	if to > confirmed {
+		to = confirmed
+	}
+
+	proofRegistry, err := contracts.NewProofRegistryContract(registry, client)
+	if err != nil {
+		return fmt.Errorf("failed to bind ProofRegistry: %v", err)
+	}
+	registryABI, err := contracts.ProofRegistryContractMetaData.GetAbi()
+	if err != nil {
+		return fmt.Errorf("failed to parse ProofRegistry ABI: %v", err)
+	}
+	publishedID := registryABI.Events["ProofPublished"].ID
+	verifiedID := registryABI.Events["BatchVerified"].ID
+	revokedID := registryABI.Events["BatchRevoked"].ID
+
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{registry},
+		Topics:    [][]common.Hash{{publishedID, verifiedID, revokedID}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to filter ProofRegistry logs: %v", err)
+	}
+
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) == 0 {
+			continue
+		}
+
+		switch l.Topics[0] {
+		case publishedID:
+			event, err := proofRegistry.ParseProofPublished(l)
+			if err != nil {
+				return fmt.Errorf("failed to parse ProofPublished in %s: %v", l.TxHash.Hex(), err)
+			}
+			if err := s.onPublished(ctx, chain, client, event); err != nil {
+				return err
+			}
+		case verifiedID:
+			event, err := proofRegistry.ParseBatchVerified(l)
+			if err != nil {
+				return fmt.Errorf("failed to parse BatchVerified in %s: %v", l.TxHash.Hex(), err)
+			}
+			if err := s.onVerified(chain, event); err != nil {
+				return err
+			}
+		case revokedID:
+			event, err := proofRegistry.ParseBatchRevoked(l)
+			if err != nil {
+				return fmt.Errorf("failed to parse BatchRevoked in %s: %v", l.TxHash.Hex(), err)
+			}
+			if err := s.onRevoked(chain, event); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.syncStateRepo.SaveLastBlock(chain.ID, proofIndexerWorker, to); err != nil {
+		return fmt.Errorf("failed to save sync state: %v", err)
+	}
+	return nil
+}
+
+// onPublished links a ProofPublished event to the batch it publishes, or
+// records it as a new batch when it was published by another oracle
+func (s *ProofIndexerService) onPublished(ctx context.Context, chain *model.Chain, client ChainClient, event *contracts.ProofRegistryContractProofPublished) error {
+	onchainBatchID := event.BatchId.Uint64()
+	root := common.BytesToHash(event.Root[:]).Hex()
+	txHash := event.Raw.TxHash.Hex()
+
+	batch, err := s.proofBatchRepo.FindByOnchainBatchID(chain.ID, onchainBatchID)
+	if err != nil {
+		return fmt.Errorf("failed to load proof batch: %v", err)
+	}
+	if batch == nil {
+		batch, err = s.proofBatchRepo.FindUnlinked(chain.ID, txHash, root)
+		if err != nil {
+			return fmt.Errorf("failed to load proof batch: %v", err)
+		}
+		if batch != nil {
+			// Status is left to the publisher, which also stamps the ledger
+			if err := s.proofBatchRepo.LinkOnchain(batch.ID, onchainBatchID); err != nil {
+				return fmt.Errorf("failed to link proof batch %d: %v", batch.ID, err)
+			}
+		}
+	}
+	if batch != nil {
+		return s.checkRoot(batch, onchainBatchID, root)
+	}
+
+	return s.recordForeign(ctx, chain, client, event)
+}
+
+// recordForeign stores a batch published by another oracle
+func (s *ProofIndexerService) recordForeign(ctx context.Context, chain *model.Chain, client ChainClient, event *contracts.ProofRegistryContractProofPublished) error {
+	onchainBatchID := event.BatchId.Uint64()
+	root := common.BytesToHash(event.Root[:]).Hex()
+	logger := s.logger.WithFields(logrus.Fields{
+		"chain":            chain.ChainKey,
+		"onchain_batch_id": onchainBatchID,
+		"root":             root,
+	})
+
+	batchType := contracts.BatchType(event.BatchType)
+	if !batchType.IsOnchain() {
+		logger.WithField("batch_type", event.BatchType).Warn("Unknown batch type in ProofPublished, skipping")
+		return nil
+	}
+
+	existing, err := s.proofBatchRepo.FindByMerkleRoot(root)
+	if err != nil {
+		return fmt.Errorf("failed to load proof batch: %v", err)
+	}
+	if existing != nil {
+		logger.WithField("batch_id", existing.ID).Warn("Merkle root published more than once, skipping")
+		return nil
+	}
+
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(event.Raw.BlockNumber))
+	if err != nil {
+		return fmt.Errorf("failed to get header %d: %v", event.Raw.BlockNumber, err)
+	}
+	publishedAt := time.Unix(int64(header.Time), 0)
+
+	metadata, err := json.Marshal(map[string]string{
+		"publisher": event.Publisher.Hex(),
+		"uri":       event.Uri,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode batch metadata: %v", err)
+	}
+
+	chainID := chain.ID
+	txHash := event.Raw.TxHash.Hex()
+	contractAddr := event.Raw.Address.Hex()
+	blockNum := event.Raw.BlockNumber
+	batch := &model.ProofBatch{
+		BatchType:      batchType,
+		PeriodStart:    time.Unix(int64(event.StartTimestamp), 0),
+		PeriodEnd:      time.Unix(int64(event.EndTimestamp), 0),
+		MerkleRoot:     root,
+		OnchainTxHash:  &txHash,
+		OnchainBatchID: &onchainBatchID,
+		ChainID:        &chainID,
+		ContractAddr:   &contractAddr,
+		BlockNum:       &blockNum,
+		Status:         "confirmed",
+		EntryCount:     int(event.EntryCount),
+		Metadata:       metadata,
+		PublishedAt:    &publishedAt,
+	}
+	if cid := strings.TrimPrefix(event.Uri, "ipfs://"); cid != event.Uri && archive.IsCID(cid) {
+		batch.IpfsHash = &cid
+	}
+
+	if err := s.proofBatchRepo.Create(batch); err != nil {
+		return fmt.Errorf("failed to record proof batch %d: %v", onchainBatchID, err)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"batch_id":  batch.ID,
+		"publisher": event.Publisher.Hex(),
+	}).Info("Indexed proof batch published by another oracle")
+
+	return nil
+}
+
+func (s *ProofIndexerService) onVerified(chain *model.Chain, event *contracts.ProofRegistryContractBatchVerified) error {
+	batch, err := s.linkedBatch(chain, event.BatchId.Uint64(), "BatchVerified")
+	if err != nil || batch == nil {
+		return err
+	}
+
+	if err := s.proofBatchRepo.MarkVerified(batch.ID); err != nil {
+		return fmt.Errorf("failed to mark proof batch %d verified: %v", batch.ID, err)
+	}
+	return s.checkRoot(batch, event.BatchId.Uint64(), common.BytesToHash(event.Root[:]).Hex())
+}
+
+func (s *ProofIndexerService) onRevoked(chain *model.Chain, event *contracts.ProofRegistryContractBatchRevoked) error {
+	batch, err := s.linkedBatch(chain, event.BatchId.Uint64(), "BatchRevoked")
+	if err != nil || batch == nil {
+		return err
+	}
+
+	if err := s.proofBatchRepo.MarkRevoked(batch.ID, event.Reason); err != nil {
+		return fmt.Errorf("failed to mark proof batch %d revoked: %v", batch.ID, err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id":         batch.ID,
+		"onchain_batch_id": event.BatchId.Uint64(),
+		"reason":           event.Reason,
+	}).Warn("Proof batch revoked on-chain")
+
+	return s.checkRoot(batch, event.BatchId.Uint64(), common.BytesToHash(event.Root[:]).Hex())
+}
+
+// linkedBatch returns the batch with a registry batchId, or nil when its
+// ProofPublished event was never indexed (e.g. it predates the start block)
+func (s *ProofIndexerService) linkedBatch(chain *model.Chain, onchainBatchID uint64, eventName string) (*model.ProofBatch, error) {
+	batch, err := s.proofBatchRepo.FindByOnchainBatchID(chain.ID, onchainBatchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load proof batch: %v", err)
+	}
+	if batch == nil {
+		s.logger.WithFields(logrus.Fields{
+			"chain":            chain.ChainKey,
+			"onchain_batch_id": onchainBatchID,
+		}).Warn(eventName + " for unknown proof batch, skipping")
+	}
+	return batch, nil
+}
+
+// checkRoot flags the batch as divergent when the registry holds a different root
+func (s *ProofIndexerService) checkRoot(batch *model.ProofBatch, onchainBatchID uint64, onchainRoot string) error {
+	if strings.EqualFold(onchainRoot, batch.MerkleRoot) {
+		return nil
+	}
+
+	if err := s.proofBatchRepo.MarkDivergent(batch.ID, onchainRoot); err != nil {
+		return fmt.Errorf("failed to flag proof batch %d divergent: %v", batch.ID, err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id":         batch.ID,
+		"onchain_batch_id": onchainBatchID,
+		"merkle_root":      batch.MerkleRoot,
+		"onchain_root":     onchainRoot,
+	}).Error("On-chain proof root diverges from stored merkle root")
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/archive"
+	"usdk-backend/pkg/contracts"
+)
+
+func TestProofIndexerSyncsRegistryEvents(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	chain, _ := seedNativeChain(t, db)
+
+	adminKey, _ := crypto.GenerateKey()
+	sim := newSimulatedChain(t, crypto.PubkeyToAddress(adminKey.PublicKey))
+	registryAddr := deployProofRegistry(t, sim, adminKey)
+	registry, err := contracts.NewProofRegistryContract(registryAddr, sim)
+	if err != nil {
+		t.Fatalf("failed to bind ProofRegistry: %v", err)
+	}
+	auth, err := bind.NewKeyedTransactorWithChainID(adminKey, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	send := func(tx *types.Transaction, err error) *types.Transaction {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("failed to send transaction: %v", err)
+		}
+		sim.Commit()
+		receipt, err := sim.TransactionReceipt(ctx, tx.Hash())
+		if err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("transaction %s was not mined successfully: %v", tx.Hash().Hex(), err)
+		}
+		return tx
+	}
+	periodEnd := time.Now().Add(-2 * time.Hour)
+	publish := func(root common.Hash, uri string) *types.Transaction {
+		t.Helper()
+		tx, err := registry.PublishBatch(auth, root, uint8(contracts.BatchTypeDeposit),
+			uint64(periodEnd.Add(-time.Hour).Unix()), uint64(periodEnd.Unix()), uri, 3)
+		return send(tx, err)
+	}
+
+	proofBatchRepo := repository.NewProofBatchRepository(db)
+	chainID := chain.ID
+	ours := func(root common.Hash, txHash common.Hash) *model.ProofBatch {
+		t.Helper()
+		hash := txHash.Hex()
+		batch := &model.ProofBatch{
+			BatchType:     contracts.BatchTypeDeposit,
+			PeriodStart:   periodEnd.Add(-time.Hour),
+			PeriodEnd:     periodEnd,
+			MerkleRoot:    root.Hex(),
+			OnchainTxHash: &hash,
+			ChainID:       &chainID,
+			Status:        "pending",
+			EntryCount:    3,
+		}
+		if err := proofBatchRepo.Create(batch); err != nil {
+			t.Fatalf("failed to seed proof batch: %v", err)
+		}
+		return batch
+	}
+	reload := func(id uint64) *model.ProofBatch {
+		t.Helper()
+		batch, err := proofBatchRepo.FindByID(id)
+		if err != nil {
+			t.Fatalf("failed to reload proof batch %d: %v", id, err)
+		}
+		return batch
+	}
+
+	// Our batch, our batch whose stored root differs from the one published
+	// in its transaction, and a batch of another oracle
+	matching := ours(common.HexToHash("0x01"), publish(common.HexToHash("0x01"), "").Hash())
+	divergedRoot := common.HexToHash("0x02")
+	diverged := ours(common.HexToHash("0x22"), publish(divergedRoot, "").Hash())
+	cid := archive.ComputeCID([]byte("foreign batch"))
+	foreignTx := publish(common.HexToHash("0x03"), "ipfs://"+cid)
+
+	send(registry.VerifyBatch(auth, big.NewInt(1)))
+	send(registry.RevokeBatch(auth, big.NewInt(2), "root mismatch"))
+
+	indexer := NewProofIndexerService(
+		proofBatchRepo,
+		repository.NewChainRepository(db),
+		repository.NewChainSyncStateRepository(db),
+		map[uint64]ChainClient{chain.ID: sim},
+		config.BlockchainConfig{
+			ProofChain: chain.ChainKey,
+			Contracts:  map[string]config.ContractAddresses{chain.ChainKey: {ProofRegistry: registryAddr.Hex()}},
+		},
+		config.PlatformConfig{ConfirmationBlocks: 1, ProofIndexIntervalSec: 60},
+		newTestLogger(),
+	)
+	indexer.IndexOnce(ctx)
+
+	batch := reload(matching.ID)
+	if batch.OnchainBatchID == nil || *batch.OnchainBatchID != 1 || batch.Divergent || batch.OnchainRoot != nil {
+		t.Fatalf("got batch linked to %v, divergent %t, want registry batch 1 matching its root", batch.OnchainBatchID, batch.Divergent)
+	}
+	// Confirmation and the ledger stamps are left to the publisher
+	if !batch.Verified || batch.Status != "pending" {
+		t.Fatalf("got batch %s, verified %t, want it verified and still pending", batch.Status, batch.Verified)
+	}
+
+	batch = reload(diverged.ID)
+	if batch.OnchainBatchID == nil || *batch.OnchainBatchID != 2 || !batch.Divergent || batch.OnchainRoot == nil || *batch.OnchainRoot != divergedRoot.Hex() {
+		t.Fatalf("got batch linked to %v, divergent %t with on-chain root %v, want registry batch 2 flagged with root %s",
+			batch.OnchainBatchID, batch.Divergent, batch.OnchainRoot, divergedRoot.Hex())
+	}
+	if batch.MerkleRoot != diverged.MerkleRoot {
+		t.Fatalf("stored root changed to %s", batch.MerkleRoot)
+	}
+	if batch.Status != "revoked" || batch.RevokeReason == nil || *batch.RevokeReason != "root mismatch" {
+		t.Fatalf("got batch %s with reason %v, want it revoked for the root mismatch", batch.Status, batch.RevokeReason)
+	}
+
+	foreign, err := proofBatchRepo.FindByOnchainBatchID(chain.ID, 3)
+	if err != nil || foreign == nil {
+		t.Fatalf("foreign batch was not recorded (%v)", err)
+	}
+	if foreign.MerkleRoot != common.HexToHash("0x03").Hex() || foreign.Status != "confirmed" || foreign.Divergent ||
+		foreign.OnchainTxHash == nil || *foreign.OnchainTxHash != foreignTx.Hash().Hex() ||
+		foreign.ContractAddr == nil || *foreign.ContractAddr != registryAddr.Hex() ||
+		foreign.BatchType != contracts.BatchTypeDeposit || foreign.EntryCount != 3 || foreign.PeriodEnd.Unix() != periodEnd.Unix() ||
+		foreign.IpfsHash == nil || *foreign.IpfsHash != cid {
+		t.Fatalf("got foreign batch %+v", foreign)
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(foreign.Metadata, &metadata); err != nil || metadata["publisher"] != auth.From.Hex() || metadata["uri"] != "ipfs://"+cid {
+		t.Fatalf("got foreign batch metadata %s (%v)", foreign.Metadata, err)
+	}
+
+	// Indexed blocks are not read again
+	indexer.IndexOnce(ctx)
+	var count int64
+	if err := db.Model(&model.ProofBatch{}).Count(&count).Error; err != nil || count != 3 {
+		t.Fatalf("got %d proof batches (%v), want 3", count, err)
+	}
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/archive"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/merkle"
+)
+
+const (
+	// proofPublisherPollInterval is how often broadcast batches are followed
+	// and failed ones retried, independent of the batch period
+	proofPublisherPollInterval = time.Minute
+	// proofPeriodGrace delays closing a period so late ledger writes land in
+	// it and the period end is not ahead of the latest block timestamp
+	proofPeriodGrace = 2 * time.Minute
+	// proofPublishMaxAttempts is how many publishBatch transactions are sent
+	// for a batch before it is marked failed
+	proofPublishMaxAttempts = 3
+)
+
+// proofBatchEntryTypes lists the ledger entry types each batch type covers
+var proofBatchEntryTypes = map[contracts.BatchType][]string{
+	contracts.BatchTypeDeposit:  {"deposit", "reversal"},
+	contracts.BatchTypeYield:    {"yield"},
+	contracts.BatchTypeTrade:    {"trade"},
+	contracts.BatchTypeWithdraw: {"withdraw", "fee"},
+}
+
+// ProofPublisherService closes a period per batch type every
+// PROOF_BATCH_INTERVAL, commits its ledger entries to a merkle root and
+// publishes the root to ProofRegistry from the oracle account through the
+// TxManagerService.
+//
+// A batch is saved pending with its entries assigned, gets its OnchainTxHash
+// once sent and becomes confirmed once the transaction has enough
+// confirmations; the merkle root is then stamped on every included entry.
+// A batch whose transaction was dropped is sent again, up to
+// proofPublishMaxAttempts times; a batch whose transaction reverted with
+// enough confirmations is marked failed, since sending the same call again
+// would revert the same way.
+type ProofPublisherService struct {
+	proofBatchRepo     *repository.ProofBatchRepository
+	ledgerRepo         *repository.LedgerRepository
+	chainRepo          *repository.ChainRepository
+	clients            map[uint64]ChainClient // keyed by chains.id
+	txManager          *TxManagerService
+	oracle             common.Address
+	archive            *archive.Writer // nil publishes batches without a detail URI
+	proofChain         string
+	registries         map[string]config.ContractAddresses
+	interval           time.Duration
+	confirmationBlocks int
+	logger             *logrus.Logger
+}
+
+func NewProofPublisherService(
+	proofBatchRepo *repository.ProofBatchRepository,
+	ledgerRepo *repository.LedgerRepository,
+	chainRepo *repository.ChainRepository,
+	clients map[uint64]ChainClient,
+	txManager *TxManagerService,
+	oracle common.Address,
+	archiveWriter *archive.Writer,
+	blockchainCfg config.BlockchainConfig,
+	platformCfg config.PlatformConfig,
+	logger *logrus.Logger,
+) *ProofPublisherService {
+	return &ProofPublisherService{
+		proofBatchRepo:     proofBatchRepo,
+		ledgerRepo:         ledgerRepo,
+		chainRepo:          chainRepo,
+		clients:            clients,
+		txManager:          txManager,
+		oracle:             oracle,
+		archive:            archiveWriter,
+		proofChain:         blockchainCfg.ProofChain,
+		registries:         blockchainCfg.Contracts,
+		interval:           time.Duration(platformCfg.ProofBatchIntervalSec) * time.Second,
+		confirmationBlocks: platformCfg.ConfirmationBlocks,
+		logger:             logger,
+	}
+}
+
+// Run closes periods and publishes batches until ctx is cancelled
+func (s *ProofPublisherService) Run(ctx context.Context) {
+	pollInterval := proofPublisherPollInterval
+	if s.interval < pollInterval {
+		pollInterval = s.interval
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.ProcessOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessOnce closes every finished period, then publishes or follows all
+// pending batches
+func (s *ProofPublisherService) ProcessOnce(ctx context.Context) {
+	chain, err := s.chainRepo.FindByChainKey(s.proofChain)
+	if err != nil {
+		s.logger.WithError(err).WithField("chain", s.proofChain).Error("Proof chain not found")
+		return
+	}
+
+	closedEnd := time.Now().Add(-proofPeriodGrace).Truncate(s.interval)
+	for _, batchType := range contracts.BatchTypes() {
+		if err := s.closePeriods(chain, batchType, proofBatchEntryTypes[batchType], closedEnd); err != nil {
+			s.logger.WithError(err).WithField("batch_type", batchType.String()).Error("Failed to close proof periods")
+		}
+	}
+
+	batches, err := s.proofBatchRepo.FindPendingBatches()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load pending proof batches")
+		return
+	}
+
+	for i := range batches {
+		batch := &batches[i]
+		logger := s.logger.WithFields(logrus.Fields{
+			"batch_id":   batch.ID,
+			"batch_type": batch.BatchType.String(),
+		})
+
+		if batch.ChainID == nil || batch.Chain == nil {
+			logger.Warn("Proof batch has no chain, skipping")
+			continue
+		}
+		client, ok := s.clients[*batch.ChainID]
+		if !ok {
+			logger.Warn("No RPC client for proof chain, leaving batch pending")
+			continue
+		}
+
+		if batch.OnchainTxHash == nil {
+			err = s.publish(ctx, batch)
+		} else {
+			err = s.track(ctx, client, batch)
+		}
+		if err != nil {
+			logger.WithError(err).Warn("Failed to publish proof batch")
+		}
+	}
+}
+
+// closePeriods groups the unbatched entries of a batch type into one batch
+// per period ending at or before closedEnd. Periods without entries are
+// skipped; entries written late into an already published period go into
+// the next one.
+func (s *ProofPublisherService) closePeriods(chain *model.Chain, batchType contracts.BatchType, entryTypes []string, closedEnd time.Time) error {
+	entries, err := s.ledgerRepo.FindUnbatched(entryTypes, closedEnd)
+	if err != nil {
+		return fmt.Errorf("failed to load unbatched entries: %v", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	latest, err := s.proofBatchRepo.FindLatestByType(batchType)
+	if err != nil {
+		return fmt.Errorf("failed to load latest batch: %v", err)
+	}
+
+	var start time.Time
+	if latest != nil {
+		start = latest.PeriodEnd
+	}
+
+	for len(entries) > 0 {
+		if periodStart := entries[0].CreatedAt.Truncate(s.interval); periodStart.After(start) {
+			start = periodStart
+		}
+		end := start.Add(s.interval)
+		if end.After(closedEnd) {
+			end = closedEnd
+		}
+		if !end.After(start) {
+			// Only late entries of the last published period are left; they
+			// wait for the next period to close
+			break
+		}
+
+		n := 0
+		for n < len(entries) && entries[n].CreatedAt.Before(end) {
+			n++
+		}
+
+		if err := s.createBatch(chain, batchType, start, end, entries[:n]); err != nil {
+			return err
+		}
+		entries = entries[n:]
+		start = end
+	}
+
+	return nil
+}
+
+func (s *ProofPublisherService) createBatch(chain *model.Chain, batchType contracts.BatchType, start, end time.Time, entries []model.LedgerEntry) error {
+	tree, err := merkle.BuildLedgerTree(entries)
+	if err != nil {
+		return fmt.Errorf("failed to build merkle tree: %v", err)
+	}
+
+	registry, err := s.registryAddress(chain)
+	if err != nil {
+		return err
+	}
+
+	entryIDs := make([]uint64, len(entries))
+	for i := range entries {
+		entryIDs[i] = entries[i].ID
+	}
+
+	chainID := chain.ID
+	contractAddr := registry.Hex()
+	root := tree.Root()
+	batch := &model.ProofBatch{
+		BatchType:    batchType,
+		PeriodStart:  start,
+		PeriodEnd:    end,
+		MerkleRoot:   root.Hex(),
+		ChainID:      &chainID,
+		ContractAddr: &contractAddr,
+		Status:       "pending",
+		EntryCount:   len(entries),
+	}
+
+	if err := s.proofBatchRepo.CreateWithEntries(batch, entryIDs); err != nil {
+		return fmt.Errorf("failed to create proof batch: %v", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id":    batch.ID,
+		"batch_type":  batchType.String(),
+		"period_end":  end,
+		"entry_count": batch.EntryCount,
+		"merkle_root": batch.MerkleRoot,
+	}).Info("Proof batch created")
+
+	return nil
+}
+
+// publish rebuilds the batch tree from its entries, archives the batch detail
+// and sends publishBatch with the archive URI
+func (s *ProofPublisherService) publish(ctx context.Context, batch *model.ProofBatch) error {
+	entries, err := s.ledgerRepo.FindByBatchID(batch.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load batch entries: %v", err)
+	}
+
+	tree, err := merkle.BuildLedgerTree(entries)
+	if err != nil {
+		return fmt.Errorf("failed to build merkle tree: %v", err)
+	}
+	root := tree.Root()
+	if root.Hex() != batch.MerkleRoot {
+		return fmt.Errorf("merkle root mismatch: stored %s, rebuilt %x", batch.MerkleRoot, root)
+	}
+
+	uri := ""
+	if s.archive != nil {
+		cid, err := s.archive.WriteLedgerBatch(batch, entries)
+		if err != nil {
+			return fmt.Errorf("failed to archive batch: %v", err)
+		}
+		batch.IpfsHash = &cid
+		uri = archive.URI(cid)
+	}
+
+	registry, err := s.registryAddress(batch.Chain)
+	if err != nil {
+		return err
+	}
+	registryABI, err := contracts.ProofRegistryContractMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+	data, err := registryABI.Pack("publishBatch", root, uint8(batch.BatchType),
+		uint64(batch.PeriodStart.Unix()), uint64(batch.PeriodEnd.Unix()), uri, uint32(len(entries)))
+	if err != nil {
+		return fmt.Errorf("failed to pack publishBatch: %v", err)
+	}
+
+	refID := batch.ID
+	tx, err := s.txManager.Send(ctx, batch.Chain, s.oracle, TxRequest{
+		To:      registry,
+		Data:    data,
+		Purpose: "proof_batch",
+		RefID:   &refID,
+	})
+	if err != nil {
+		return fmt.Errorf("publishBatch failed: %v", err)
+	}
+
+	txHash := tx.TxHash
+	contractAddr := registry.Hex()
+	batch.OnchainTxHash = &txHash
+	batch.PublishAttempts++
+	batch.ContractAddr = &contractAddr
+	if err := s.proofBatchRepo.Update(batch); err != nil {
+		// The transaction is already owned by the tx manager; never re-send it
+		return fmt.Errorf("proof batch broadcast as %s but not recorded: %v", txHash, err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id": batch.ID,
+		"tx_hash":  txHash,
+		"attempt":  batch.PublishAttempts,
+	}).Info("Proof batch broadcast")
+
+	return nil
+}
+
+// track follows the publishBatch receipt and confirms the batch once it has
+// enough confirmations. A dropped transaction is cleared so it is re-sent
+// until the batch runs out of attempts; a reverted one fails the batch once
+// the revert has enough confirmations.
+func (s *ProofPublisherService) track(ctx context.Context, client ChainClient, batch *model.ProofBatch) error {
+	receipt, err := s.txManager.Receipt(ctx, client, "proof_batch", batch.ID, *batch.OnchainTxHash)
+	if errors.Is(err, ErrTxDropped) {
+		logger := s.logger.WithFields(logrus.Fields{
+			"batch_id": batch.ID,
+			"tx_hash":  *batch.OnchainTxHash,
+			"attempts": batch.PublishAttempts,
+		})
+		if batch.PublishAttempts >= proofPublishMaxAttempts {
+			logger.Error("publishBatch dropped, giving up on proof batch")
+			batch.Status = "failed"
+			return s.proofBatchRepo.Update(batch)
+		}
+		logger.Warn("publishBatch dropped, retrying")
+		batch.OnchainTxHash = nil
+		return s.proofBatchRepo.Update(batch)
+	}
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		// Not mined yet
+		return nil
+	}
+
+	if minedHash := receipt.TxHash.Hex(); minedHash != *batch.OnchainTxHash {
+		// The tx manager replaced the transaction with higher fees
+		batch.OnchainTxHash = &minedHash
+		if err := s.proofBatchRepo.Update(batch); err != nil {
+			return fmt.Errorf("failed to record replacement tx hash: %v", err)
+		}
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %v", err)
+	}
+	blockNum := receipt.BlockNumber.Uint64()
+	if headNum := head.Number.Uint64(); headNum < blockNum || int(headNum-blockNum+1) < s.confirmationBlocks {
+		// A reverted receipt may still be reorged out and the transaction
+		// mined again, so it is only final with the same depth as a success
+		return nil
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		s.logger.WithFields(logrus.Fields{
+			"batch_id": batch.ID,
+			"tx_hash":  *batch.OnchainTxHash,
+			"block":    blockNum,
+		}).Error("publishBatch reverted, marking proof batch failed")
+		batch.Status = "failed"
+		return s.proofBatchRepo.Update(batch)
+	}
+
+	onchainBatchID, err := s.publishedBatchID(client, batch, receipt)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	gasUsed := receipt.GasUsed
+	batch.BlockNum = &blockNum
+	batch.GasUsed = &gasUsed
+	batch.OnchainBatchID = &onchainBatchID
+	batch.Status = "confirmed"
+	batch.PublishedAt = &now
+	if err := s.proofBatchRepo.Confirm(batch); err != nil {
+		return fmt.Errorf("failed to confirm proof batch: %v", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id":         batch.ID,
+		"onchain_batch_id": onchainBatchID,
+		"tx_hash":          *batch.OnchainTxHash,
+		"block":            blockNum,
+	}).Info("Proof batch published")
+
+	return nil
+}
+
+// publishedBatchID reads the registry batchId from the ProofPublished event
+func (s *ProofPublisherService) publishedBatchID(client ChainClient, batch *model.ProofBatch, receipt *types.Receipt) (uint64, error) {
+	registry := common.HexToAddress(*batch.ContractAddr)
+	proofRegistry, err := contracts.NewProofRegistryContract(registry, client)
+	if err != nil {
+		return 0, fmt.Errorf("failed to bind ProofRegistry: %v", err)
+	}
+
+	for _, vLog := range receipt.Logs {
+		if vLog.Address != registry {
+			continue
+		}
+		event, err := proofRegistry.ParseProofPublished(*vLog)
+		if err != nil {
+			continue
+		}
+		if common.BytesToHash(event.Root[:]).Hex() == batch.MerkleRoot {
+			return event.BatchId.Uint64(), nil
+		}
+	}
+
+	return 0, fmt.Errorf("no ProofPublished event in receipt %s", receipt.TxHash.Hex())
+}
+
+// registryAddress returns the ProofRegistry of the chain
+func (s *ProofPublisherService) registryAddress(chain *model.Chain) (common.Address, error) {
+	return proofRegistryAddress(chain, s.registries)
+}
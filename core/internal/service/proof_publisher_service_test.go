@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/signer"
+)
+
+// proofRegistryArtifact is the compiled contract from the hardhat project
+var proofRegistryArtifact = filepath.Join("..", "..", "..", "contracts", "ProofRegistry.json")
+
+// fixedGasClient skips gas estimation, so calls that revert are still mined
+type fixedGasClient struct {
+	*backends.SimulatedBackend
+}
+
+func (fixedGasClient) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 500_000, nil
+}
+
+// deployProofRegistry deploys ProofRegistry with the key's account as both
+// admin and oracle. It first moves the simulated clock to an hour ago, so
+// periods that ended before that are accepted.
+func deployProofRegistry(t *testing.T, sim *backends.SimulatedBackend, key *ecdsa.PrivateKey) common.Address {
+	t.Helper()
+
+	raw, err := os.ReadFile(proofRegistryArtifact)
+	if err != nil {
+		t.Fatalf("failed to read ProofRegistry artifact: %v", err)
+	}
+	var artifact struct {
+		Bytecode string `json:"bytecode"`
+	}
+	if err := json.Unmarshal(raw, &artifact); err != nil {
+		t.Fatalf("invalid ProofRegistry artifact: %v", err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(contracts.ProofRegistryContractMetaData.ABI))
+	if err != nil {
+		t.Fatalf("invalid ProofRegistry ABI: %v", err)
+	}
+
+	head, err := sim.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to get chain head: %v", err)
+	}
+	if err := sim.AdjustTime(time.Since(time.Unix(int64(head.Time), 0)) - time.Hour); err != nil {
+		t.Fatalf("failed to adjust clock: %v", err)
+	}
+	sim.Commit()
+
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	address, _, _, err := bind.DeployContract(auth, parsed, hexutil.MustDecode(artifact.Bytecode), sim, owner, owner)
+	if err != nil {
+		t.Fatalf("failed to deploy ProofRegistry: %v", err)
+	}
+	sim.Commit()
+	return address
+}
+
+// proofPublisherFixture is a publisher on a simulated chain with a deployed
+// registry and deposit entries of a closed period waiting to be batched
+type proofPublisherFixture struct {
+	db        *gorm.DB
+	sim       *backends.SimulatedBackend
+	txManager *TxManagerService
+	publisher *ProofPublisherService
+	entries   []model.LedgerEntry
+}
+
+// newProofPublisherFixture publishes from the registry's oracle, or from an
+// account without the oracle role so every publishBatch reverts
+func newProofPublisherFixture(t *testing.T, authorized bool) *proofPublisherFixture {
+	t.Helper()
+
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+
+	adminKey, _ := crypto.GenerateKey()
+	sim := newSimulatedChain(t, crypto.PubkeyToAddress(adminKey.PublicKey))
+	registry := deployProofRegistry(t, sim, adminKey)
+	oracleKey := adminKey
+	if !authorized {
+		oracleKey, _ = crypto.GenerateKey()
+		sendEther(t, sim, adminKey, crypto.PubkeyToAddress(oracleKey.PublicKey), big.NewInt(params.Ether))
+		sim.Commit()
+	}
+
+	clients := map[uint64]ChainClient{chain.ID: fixedGasClient{sim}}
+	logger := newTestLogger()
+	platformCfg := config.PlatformConfig{ProofBatchIntervalSec: 3600, ConfirmationBlocks: 2}
+	blockchainCfg := config.BlockchainConfig{
+		ProofChain: chain.ChainKey,
+		Contracts:  map[string]config.ContractAddresses{chain.ChainKey: {ProofRegistry: registry.Hex()}},
+	}
+
+	txManager := NewTxManagerService(
+		repository.NewOutgoingTxRepository(db), clients,
+		config.TxConfig{MaxFeeGwei: 100, MaxPriorityFeeGwei: 2, StuckTimeoutSec: 600},
+		platformCfg, logger,
+	)
+	oracle := txManager.AddSigner(signer.NewKeySigner(oracleKey))
+
+	publisher := NewProofPublisherService(
+		repository.NewProofBatchRepository(db),
+		repository.NewLedgerRepository(db),
+		repository.NewChainRepository(db),
+		clients, txManager, oracle, nil,
+		blockchainCfg, platformCfg, logger,
+	)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	chainID, assetID := chain.ID, asset.ID
+	entries := make([]model.LedgerEntry, 3)
+	for i := range entries {
+		entries[i] = model.LedgerEntry{
+			UserID:    user.ID,
+			EntryType: "deposit",
+			ChainID:   &chainID,
+			AssetID:   &assetID,
+			Amount:    decimal.NewFromInt(int64(i + 1)),
+			KusdDelta: decimal.NewFromInt(int64(2000 * (i + 1))),
+			CreatedAt: time.Now().Add(-3 * time.Hour),
+		}
+		if err := db.Create(&entries[i]).Error; err != nil {
+			t.Fatalf("failed to seed ledger entry: %v", err)
+		}
+	}
+
+	return &proofPublisherFixture{db: db, sim: sim, txManager: txManager, publisher: publisher, entries: entries}
+}
+
+// batch returns the only proof batch
+func (f *proofPublisherFixture) batch(t *testing.T) *model.ProofBatch {
+	t.Helper()
+
+	var batches []model.ProofBatch
+	if err := f.db.Find(&batches).Error; err != nil {
+		t.Fatalf("failed to load proof batches: %v", err)
+	}
+	if len(batches) != 1 {
+		t.Fatalf("got %d proof batches, want 1", len(batches))
+	}
+	return &batches[0]
+}
+
+// sent returns the number of publishBatch transactions sent
+func (f *proofPublisherFixture) sent(t *testing.T) int64 {
+	t.Helper()
+
+	var count int64
+	if err := f.db.Model(&model.OutgoingTx{}).Where("purpose = ?", "proof_batch").Count(&count).Error; err != nil {
+		t.Fatalf("failed to count outgoing transactions: %v", err)
+	}
+	return count
+}
+
+func TestProofPublisherPublishesAndStampsBatch(t *testing.T) {
+	ctx := context.Background()
+	f := newProofPublisherFixture(t, true)
+
+	f.publisher.ProcessOnce(ctx)
+	batch := f.batch(t)
+	if batch.Status != "pending" || batch.OnchainTxHash == nil || batch.PublishAttempts != 1 || batch.EntryCount != len(f.entries) {
+		t.Fatalf("got batch %s with tx %v after %d attempts and %d entries, want pending, sent once with %d entries",
+			batch.Status, batch.OnchainTxHash, batch.PublishAttempts, batch.EntryCount, len(f.entries))
+	}
+
+	f.sim.Commit()
+	f.txManager.ProcessOnce(ctx)
+	f.publisher.ProcessOnce(ctx)
+	if batch = f.batch(t); batch.Status != "pending" {
+		t.Fatalf("got batch %s with one confirmation, want pending", batch.Status)
+	}
+
+	f.sim.Commit()
+	f.publisher.ProcessOnce(ctx)
+	batch = f.batch(t)
+	if batch.Status != "confirmed" || batch.OnchainBatchID == nil || *batch.OnchainBatchID != 1 || batch.BlockNum == nil {
+		t.Fatalf("got batch %s with registry id %v, want confirmed as batch 1", batch.Status, batch.OnchainBatchID)
+	}
+
+	var entries []model.LedgerEntry
+	if err := f.db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatalf("failed to load ledger entries: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.BatchID == nil || *entry.BatchID != batch.ID || entry.ProofRoot == nil || *entry.ProofRoot != batch.MerkleRoot {
+			t.Fatalf("entry %d: got batch %v with root %v, want batch %d with root %s",
+				entry.ID, entry.BatchID, entry.ProofRoot, batch.ID, batch.MerkleRoot)
+		}
+	}
+	if sent := f.sent(t); sent != 1 {
+		t.Fatalf("got %d publishBatch transactions, want 1", sent)
+	}
+}
+
+func TestProofPublisherFailsBatchOnConfirmedRevert(t *testing.T) {
+	ctx := context.Background()
+	f := newProofPublisherFixture(t, false)
+
+	f.publisher.ProcessOnce(ctx)
+	sentHash := f.batch(t).OnchainTxHash
+	if sentHash == nil {
+		t.Fatalf("batch was not sent")
+	}
+
+	f.sim.Commit()
+	f.txManager.ProcessOnce(ctx)
+	f.publisher.ProcessOnce(ctx)
+	batch := f.batch(t)
+	if batch.Status != "pending" || batch.OnchainTxHash == nil || *batch.OnchainTxHash != *sentHash {
+		t.Fatalf("got batch %s with tx %v before the revert is confirmed, want pending with %s", batch.Status, batch.OnchainTxHash, *sentHash)
+	}
+
+	f.sim.Commit()
+	f.publisher.ProcessOnce(ctx)
+	f.publisher.ProcessOnce(ctx)
+	if batch = f.batch(t); batch.Status != "failed" {
+		t.Fatalf("got batch %s after a confirmed revert, want failed", batch.Status)
+	}
+	if sent := f.sent(t); sent != 1 {
+		t.Fatalf("got %d publishBatch transactions, want the reverted one only", sent)
+	}
+}
+
+func TestProofPublisherGivesUpAfterRepeatedDrops(t *testing.T) {
+	ctx := context.Background()
+	f := newProofPublisherFixture(t, true)
+
+	for attempt := 1; attempt <= proofPublishMaxAttempts; attempt++ {
+		f.publisher.ProcessOnce(ctx)
+		if batch := f.batch(t); batch.OnchainTxHash == nil || batch.PublishAttempts != attempt {
+			t.Fatalf("attempt %d: got tx %v after %d attempts", attempt, batch.OnchainTxHash, batch.PublishAttempts)
+		}
+
+		// The tx manager gives up on a transaction it can no longer mine
+		err := f.db.Model(&model.OutgoingTx{}).Where("purpose = ?", "proof_batch").Update("status", "dropped").Error
+		if err != nil {
+			t.Fatalf("failed to drop transaction: %v", err)
+		}
+		f.publisher.ProcessOnce(ctx)
+	}
+
+	batch := f.batch(t)
+	if batch.Status != "failed" {
+		t.Fatalf("got batch %s after %d drops, want failed", batch.Status, proofPublishMaxAttempts)
+	}
+	f.publisher.ProcessOnce(ctx)
+	if sent := f.sent(t); sent != proofPublishMaxAttempts {
+		t.Fatalf("got %d publishBatch transactions, want %d", sent, proofPublishMaxAttempts)
+	}
+}
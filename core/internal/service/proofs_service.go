@@ -1,16 +1,23 @@
 package service
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/archive"
+	"usdk-backend/pkg/contracts"
 )
 
 type ProofsService struct {
 	proofBatchRepo *repository.ProofBatchRepository
+	archiveStore   archive.BlobStore // nil when no archive is configured
 }
 
-func NewProofsService(proofBatchRepo *repository.ProofBatchRepository) *ProofsService {
+func NewProofsService(proofBatchRepo *repository.ProofBatchRepository, archiveStore archive.BlobStore) *ProofsService {
 	return &ProofsService{
 		proofBatchRepo: proofBatchRepo,
+		archiveStore:   archiveStore,
 	}
 }
 
@@ -34,6 +41,25 @@ type ProofResponse struct {
 
 type ProofsResponse []ProofResponse
 
+// BatchLeavesResponse is one page of an archived batch
+type BatchLeavesResponse struct {
+	BatchID     uint64               `json:"batchId"`
+	BatchType   string               `json:"batchType"`
+	MerkleRoot  string               `json:"merkleRoot"`
+	URI         string               `json:"uri"`
+	LeafScheme  string               `json:"leafScheme"`
+	PeriodStart int64                `json:"periodStart"`
+	PeriodEnd   int64                `json:"periodEnd"`
+	Leaves      []archive.LeafRecord `json:"leaves"`
+}
+
+// ReservesResponse is the latest proof-of-reserves snapshot
+type ReservesResponse struct {
+	BatchID    uint64           `json:"batchId"`
+	MerkleRoot string           `json:"merkleRoot"`
+	Snapshot   ReservesSnapshot `json:"snapshot"`
+}
+
 func (s *ProofsService) GetLatestProofs() (ProofsResponse, error) {
 	// Get latest proof batches that have been published on-chain
 	batches, err := s.proofBatchRepo.GetLatestPublished(10)
@@ -44,7 +70,7 @@ func (s *ProofsService) GetLatestProofs() (ProofsResponse, error) {
 	var proofs ProofsResponse
 	for _, batch := range batches {
 		proof := ProofResponse{
-			Type:       batch.BatchType,
+			Type:       batch.BatchType.String(),
 			MerkleRoot: batch.MerkleRoot,
 			Period: PeriodInfo{
 				Start: batch.PeriodStart.Unix(),
@@ -65,4 +91,71 @@ func (s *ProofsService) GetLatestProofs() (ProofsResponse, error) {
 	}
 
 	return proofs, nil
-}
\ No newline at end of file
+}
+
+func (s *ProofsService) GetLatestReserves() (*ReservesResponse, error) {
+	batch, err := s.proofBatchRepo.FindLatestByType(contracts.BatchTypeReserves)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, fmt.Errorf("no reserves snapshot recorded yet")
+	}
+
+	response := &ReservesResponse{
+		BatchID:    batch.ID,
+		MerkleRoot: batch.MerkleRoot,
+	}
+	if err := json.Unmarshal(batch.Metadata, &response.Snapshot); err != nil {
+		return nil, fmt.Errorf("invalid reserves snapshot: %v", err)
+	}
+
+	return response, nil
+}
+
+// GetBatchLeaves returns a page of the archived leaves of a proof batch,
+// and the total number of leaves
+func (s *ProofsService) GetBatchLeaves(batchID uint64, page, pageSize int) (*BatchLeavesResponse, int64, error) {
+	batch, err := s.proofBatchRepo.FindByID(batchID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("proof batch not found")
+	}
+	if s.archiveStore == nil || batch.IpfsHash == nil {
+		return nil, 0, fmt.Errorf("proof batch %d has no archive", batchID)
+	}
+
+	doc, err := archive.ReadDocument(s.archiveStore, *batch.IpfsHash)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read batch archive: %v", err)
+	}
+
+	total := len(doc.Leaves)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	return &BatchLeavesResponse{
+		BatchID:     batch.ID,
+		BatchType:   doc.BatchType,
+		MerkleRoot:  doc.MerkleRoot,
+		URI:         archive.URI(*batch.IpfsHash),
+		LeafScheme:  doc.LeafScheme,
+		PeriodStart: doc.PeriodStart,
+		PeriodEnd:   doc.PeriodEnd,
+		Leaves:      doc.Leaves[start:end],
+	}, int64(total), nil
+}
+
+// GetArchiveDocument returns the raw archive document with the given CID, so
+// clients can check it hashes to the URI published on-chain
+func (s *ProofsService) GetArchiveDocument(cid string) ([]byte, error) {
+	if s.archiveStore == nil {
+		return nil, fmt.Errorf("batch archive is not configured")
+	}
+	return s.archiveStore.Get(cid)
+}
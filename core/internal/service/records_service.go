@@ -2,19 +2,30 @@ package service
 
 import (
 	"encoding/base64"
+	"fmt"
+	"math/big"
 	"strconv"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"usdk-backend/internal/model"
 	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/merkle"
 )
 
 type RecordsService struct {
-	ledgerRepo *repository.LedgerRepository
+	ledgerRepo        *repository.LedgerRepository
+	proofBatchRepo    *repository.ProofBatchRepository
+	blockchainService *BlockchainService // nil when no RPC is available
 }
 
-func NewRecordsService(ledgerRepo *repository.LedgerRepository) *RecordsService {
+func NewRecordsService(ledgerRepo *repository.LedgerRepository, proofBatchRepo *repository.ProofBatchRepository, blockchainService *BlockchainService) *RecordsService {
 	return &RecordsService{
-		ledgerRepo: ledgerRepo,
+		ledgerRepo:        ledgerRepo,
+		proofBatchRepo:    proofBatchRepo,
+		blockchainService: blockchainService,
 	}
 }
 
@@ -30,6 +41,27 @@ type RecordItem struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// LeafEncoding describes how the leaf of a record is built:
+// keccak256(keccak256(encoded)), where encoded is abi.encode of the fields
+type LeafEncoding struct {
+	Fields  []merkle.LeafField `json:"fields"`
+	Encoded string             `json:"encoded"`
+}
+
+// RecordProofResponse is everything needed to check a record against
+// ProofRegistry.verifyProof(onchainBatchId, leaf, proof)
+type RecordProofResponse struct {
+	RecordID       uint64       `json:"recordId"`
+	Leaf           string       `json:"leaf"`
+	LeafEncoding   LeafEncoding `json:"leafEncoding"`
+	Proof          []string     `json:"proof"`
+	MerkleRoot     string       `json:"merkleRoot"`
+	BatchID        uint64       `json:"batchId"`
+	OnchainBatchID *uint64      `json:"onchainBatchId"`
+	Onchain        *OnchainInfo `json:"onchain"`
+	Verified       *bool        `json:"verified,omitempty"` // only set when verification was requested
+}
+
 type RecordsResponse struct {
 	Records    []RecordItem `json:"records"`
 	NextCursor *string      `json:"nextCursor"`
@@ -97,4 +129,100 @@ func (s *RecordsService) GetUserRecords(userID uint64, recordType, cursor string
 		Records:    records,
 		NextCursor: nextCursor,
 	}, nil
-}
\ No newline at end of file
+}
+
+// GetRecordProof returns the inclusion proof of one of the user's records in
+// its published proof batch. With verify set the proof is also checked
+// on-chain through ProofRegistry.verifyProof.
+func (s *RecordsService) GetRecordProof(userID, recordID uint64, verify bool) (*RecordProofResponse, error) {
+	entry, err := s.ledgerRepo.FindByID(recordID)
+	if err != nil || entry.UserID != userID {
+		return nil, fmt.Errorf("record not found")
+	}
+	if entry.BatchID == nil || entry.ProofRoot == nil {
+		return nil, fmt.Errorf("record is not included in a published proof batch yet")
+	}
+
+	batch, err := s.proofBatchRepo.FindByID(*entry.BatchID)
+	if err != nil {
+		return nil, fmt.Errorf("proof batch not found: %v", err)
+	}
+	if batch.Status != "confirmed" || batch.OnchainBatchID == nil {
+		return nil, fmt.Errorf("proof batch %d is not published yet", batch.ID)
+	}
+
+	entries, err := s.ledgerRepo.FindByBatchID(batch.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load proof batch entries: %v", err)
+	}
+	tree, err := merkle.BuildLedgerTree(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build merkle tree: %v", err)
+	}
+	if tree.Root().Hex() != batch.MerkleRoot {
+		return nil, fmt.Errorf("proof batch %d does not match its merkle root", batch.ID)
+	}
+
+	leaf, proof, err := tree.ProofForEntry(entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := merkle.EncodeLedgerEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &RecordProofResponse{
+		RecordID: entry.ID,
+		Leaf:     leaf.Hex(),
+		LeafEncoding: LeafEncoding{
+			Fields:  merkle.LedgerLeafFields(entry),
+			Encoded: hexutil.Encode(encoded),
+		},
+		Proof:          make([]string, len(proof)),
+		MerkleRoot:     batch.MerkleRoot,
+		BatchID:        batch.ID,
+		OnchainBatchID: batch.OnchainBatchID,
+	}
+	for i, node := range proof {
+		response.Proof[i] = node.Hex()
+	}
+	if batch.OnchainTxHash != nil && batch.Chain != nil && batch.ContractAddr != nil {
+		response.Onchain = &OnchainInfo{
+			Chain:    batch.Chain.ChainKey,
+			Contract: *batch.ContractAddr,
+			Tx:       *batch.OnchainTxHash,
+		}
+	}
+
+	if verify {
+		verified, err := s.verifyOnchain(batch, leaf, proof)
+		if err != nil {
+			return nil, err
+		}
+		response.Verified = &verified
+	}
+
+	return response, nil
+}
+
+// verifyOnchain checks the proof against the ProofRegistry of the chain the
+// batch was published on
+func (s *RecordsService) verifyOnchain(batch *model.ProofBatch, leaf common.Hash, proof []common.Hash) (bool, error) {
+	if s.blockchainService == nil || batch.Chain == nil {
+		return false, fmt.Errorf("on-chain verification is not available")
+	}
+	registry, err := s.blockchainService.ProofRegistryAddress(batch.Chain.ChainKey)
+	if err != nil {
+		return false, fmt.Errorf("on-chain verification is not available: %v", err)
+	}
+	if batch.ContractAddr == nil || common.HexToAddress(*batch.ContractAddr) != registry {
+		return false, fmt.Errorf("proof batch was published to a different ProofRegistry, verify it there")
+	}
+
+	verified, err := s.blockchainService.VerifyProof(batch.Chain.ChainKey, new(big.Int).SetUint64(*batch.OnchainBatchID), leaf, merkle.ToBytes32(proof))
+	if err != nil {
+		return false, fmt.Errorf("on-chain verification failed: %v", err)
+	}
+	return verified, nil
+}
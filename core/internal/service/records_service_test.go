@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/merkle"
+)
+
+// publish publishes the fixture's entries and returns the confirmed batch
+func (f *proofPublisherFixture) publish(t *testing.T) *model.ProofBatch {
+	t.Helper()
+
+	ctx := context.Background()
+	f.publisher.ProcessOnce(ctx)
+	f.sim.Commit()
+	f.txManager.ProcessOnce(ctx)
+	f.publisher.ProcessOnce(ctx)
+	f.sim.Commit()
+	f.publisher.ProcessOnce(ctx)
+
+	batch := f.batch(t)
+	if batch.Status != "confirmed" || batch.OnchainBatchID == nil || batch.ContractAddr == nil {
+		t.Fatalf("got batch %s with registry id %v, want it confirmed", batch.Status, batch.OnchainBatchID)
+	}
+	return batch
+}
+
+// recordsService returns a records service that verifies against the
+// ProofRegistry at registry
+func (f *proofPublisherFixture) recordsService(t *testing.T, registry common.Address) *RecordsService {
+	t.Helper()
+
+	chains, err := repository.NewChainRepository(f.db).FindEnabled()
+	if err != nil || len(chains) != 1 {
+		t.Fatalf("got chains %v (%v), want the simulated chain", chains, err)
+	}
+	chain := &chains[0]
+	binding, err := bindChain(chain, fixedGasClient{f.sim}, map[string]config.ContractAddresses{
+		chain.ChainKey: {ProofRegistry: registry.Hex()},
+	})
+	if err != nil {
+		t.Fatalf("failed to bind chain: %v", err)
+	}
+	registries := &ChainRegistry{chains: map[string]*ChainBinding{chain.ChainKey: binding}}
+
+	return NewRecordsService(
+		repository.NewLedgerRepository(f.db),
+		repository.NewProofBatchRepository(f.db),
+		NewBlockchainService(registries, f.txManager, chain.ChainKey),
+	)
+}
+
+func TestRecordProofVerifiesAgainstPublishedRoot(t *testing.T) {
+	f := newProofPublisherFixture(t, true)
+	batch := f.publish(t)
+	records := f.recordsService(t, common.HexToAddress(*batch.ContractAddr))
+
+	for _, entry := range f.entries {
+		proof, err := records.GetRecordProof(entry.UserID, entry.ID, true)
+		if err != nil {
+			t.Fatalf("record %d: failed to get proof: %v", entry.ID, err)
+		}
+		if proof.RecordID != entry.ID || proof.BatchID != batch.ID || proof.MerkleRoot != batch.MerkleRoot ||
+			proof.OnchainBatchID == nil || *proof.OnchainBatchID != *batch.OnchainBatchID {
+			t.Fatalf("record %d: got proof %+v, want one in batch %d", entry.ID, proof, batch.ID)
+		}
+		if proof.Verified == nil || !*proof.Verified {
+			t.Fatalf("record %d: got verified %v, want the registry to accept the proof", entry.ID, proof.Verified)
+		}
+		if proof.Onchain == nil || proof.Onchain.Tx != *batch.OnchainTxHash || proof.Onchain.Contract != *batch.ContractAddr {
+			t.Fatalf("record %d: got on-chain info %+v, want the publish transaction", entry.ID, proof.Onchain)
+		}
+
+		// The leaf and proof check against the root without the registry too
+		stored, err := repository.NewLedgerRepository(f.db).FindByID(entry.ID)
+		if err != nil {
+			t.Fatalf("failed to reload record %d: %v", entry.ID, err)
+		}
+		leaf, err := merkle.LedgerEntryLeaf(stored)
+		if err != nil {
+			t.Fatalf("failed to hash record %d: %v", entry.ID, err)
+		}
+		path := make([]common.Hash, len(proof.Proof))
+		for i, node := range proof.Proof {
+			path[i] = common.HexToHash(node)
+		}
+		if proof.Leaf != leaf.Hex() || !merkle.Verify(common.HexToHash(batch.MerkleRoot), leaf, path) {
+			t.Fatalf("record %d: leaf %s and proof %v do not verify against root %s", entry.ID, proof.Leaf, proof.Proof, batch.MerkleRoot)
+		}
+	}
+
+	// Without verify set the registry is not asked
+	proof, err := records.GetRecordProof(f.entries[0].UserID, f.entries[0].ID, false)
+	if err != nil || proof.Verified != nil {
+		t.Fatalf("got verified %v (%v), want it left out", proof, err)
+	}
+}
+
+func TestRecordProofRefusesRecordsWithoutPublishedProof(t *testing.T) {
+	f := newProofPublisherFixture(t, true)
+	owner := f.entries[0].UserID
+
+	// Batched but not confirmed on-chain yet, so not stamped on the records
+	f.publisher.ProcessOnce(context.Background())
+	batch := f.batch(t)
+	records := f.recordsService(t, common.HexToAddress(*batch.ContractAddr))
+	if _, err := records.GetRecordProof(owner, f.entries[0].ID, false); err == nil || !strings.Contains(err.Error(), "not included in a published proof batch") {
+		t.Fatalf("got %v for a pending batch, want it refused", err)
+	}
+
+	batch = f.publish(t)
+
+	// A revoked batch no longer serves proofs
+	if err := f.db.Model(batch).Update("status", "revoked").Error; err != nil {
+		t.Fatalf("failed to revoke batch: %v", err)
+	}
+	if _, err := records.GetRecordProof(owner, f.entries[0].ID, false); err == nil || !strings.Contains(err.Error(), "is not published yet") {
+		t.Fatalf("got %v for a revoked batch, want it refused as not published", err)
+	}
+	if err := f.db.Model(batch).Update("status", "confirmed").Error; err != nil {
+		t.Fatalf("failed to restore batch: %v", err)
+	}
+
+	// Posted after the batch was cut
+	late := &model.LedgerEntry{UserID: owner, EntryType: "deposit", Amount: decimal.NewFromInt(1), KusdDelta: decimal.NewFromInt(2000), CreatedAt: time.Now()}
+	if err := f.db.Create(late).Error; err != nil {
+		t.Fatalf("failed to seed ledger entry: %v", err)
+	}
+	if _, err := records.GetRecordProof(owner, late.ID, false); err == nil || !strings.Contains(err.Error(), "not included in a published proof batch") {
+		t.Fatalf("got %v for an unbatched record, want it refused", err)
+	}
+
+	// Another user's record is not found, as is a missing one
+	other := &model.User{}
+	if err := f.db.Create(other).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	for _, id := range []uint64{f.entries[0].ID, 9999} {
+		if _, err := records.GetRecordProof(other.ID, id, false); err == nil || err.Error() != "record not found" {
+			t.Fatalf("got %v for record %d of another user, want record not found", err, id)
+		}
+	}
+
+	// A batch whose entries no longer hash to its root serves no proof
+	if err := f.db.Model(&model.LedgerEntry{}).Where("id = ?", f.entries[1].ID).Update("amount", decimal.NewFromInt(100)).Error; err != nil {
+		t.Fatalf("failed to tamper with record: %v", err)
+	}
+	if _, err := records.GetRecordProof(owner, f.entries[0].ID, false); err == nil || !strings.Contains(err.Error(), "does not match its merkle root") {
+		t.Fatalf("got %v for a tampered batch, want a root mismatch", err)
+	}
+	if err := f.db.Model(&model.LedgerEntry{}).Where("id = ?", f.entries[1].ID).Update("amount", f.entries[1].Amount).Error; err != nil {
+		t.Fatalf("failed to restore record: %v", err)
+	}
+
+	// Verification is refused against a registry the batch was not published to
+	elsewhere := f.recordsService(t, common.HexToAddress("0x00000000000000000000000000000000000000ee"))
+	if _, err := elsewhere.GetRecordProof(owner, f.entries[0].ID, true); err == nil || !strings.Contains(err.Error(), "different ProofRegistry") {
+		t.Fatalf("got %v against another registry, want it refused", err)
+	}
+	if proof, err := elsewhere.GetRecordProof(owner, f.entries[0].ID, false); err != nil || proof.BatchID != batch.ID {
+		t.Fatalf("got %v (%v) without verification, want the proof", proof, err)
+	}
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/merkle"
+)
+
+// ReservesService takes periodic proof-of-reserves snapshots. It sums what
+// the deposit and treasury addresses hold on every chain, compares it with
+// the KUSD owed to users plus the circulating USDK supply, and records the
+// result as an off-chain reserves ProofBatch whose merkle root commits to
+// every holding line.
+//
+// Treasury balances are read from the chain. Deposit addresses are too many
+// to read one by one, so their balances come from the confirmed onchain_txs
+// that moved funds in and out of them.
+type ReservesService struct {
+	chainRepo      *repository.ChainRepository
+	chainAssetRepo *repository.ChainAssetRepository
+	onchainTxRepo  *repository.OnchainTxRepository
+	ledgerRepo     *repository.LedgerRepository
+	proofBatchRepo *repository.ProofBatchRepository
+	clients        map[uint64]ChainClient // keyed by chains.id
+	treasury       []common.Address
+	valuer         AssetValuer
+	interval       time.Duration
+	logger         *logrus.Logger
+}
+
+func NewReservesService(
+	chainRepo *repository.ChainRepository,
+	chainAssetRepo *repository.ChainAssetRepository,
+	onchainTxRepo *repository.OnchainTxRepository,
+	ledgerRepo *repository.LedgerRepository,
+	proofBatchRepo *repository.ProofBatchRepository,
+	clients map[uint64]ChainClient,
+	treasury []common.Address,
+	valuer AssetValuer,
+	platformCfg config.PlatformConfig,
+	logger *logrus.Logger,
+) *ReservesService {
+	return &ReservesService{
+		chainRepo:      chainRepo,
+		chainAssetRepo: chainAssetRepo,
+		onchainTxRepo:  onchainTxRepo,
+		ledgerRepo:     ledgerRepo,
+		proofBatchRepo: proofBatchRepo,
+		clients:        clients,
+		treasury:       treasury,
+		valuer:         valuer,
+		interval:       time.Duration(platformCfg.ReservesSnapshotIntervalSec) * time.Second,
+		logger:         logger,
+	}
+}
+
+// ReserveHolding is the total balance of one asset held on one chain
+type ReserveHolding struct {
+	Chain       string `json:"chain"`
+	ChainID     uint64 `json:"chainId"` // EVM chain ID, as hashed into the leaf
+	Asset       string `json:"asset"`
+	Token       string `json:"token"` // zero address for the native asset
+	BlockNumber uint64 `json:"blockNumber"`
+	Balance     string `json:"balance"`
+	RawBalance  string `json:"rawBalance"` // balance in the token's smallest unit, as hashed into the leaf
+	USDValue    string `json:"usdValue"`
+	Leaf        string `json:"leaf"`
+}
+
+// ReservesSnapshot is stored as the metadata of a reserves ProofBatch
+type ReservesSnapshot struct {
+	TakenAt           time.Time        `json:"takenAt"`
+	Holdings          []ReserveHolding `json:"holdings"` // merkle leaves in tree order
+	TotalReserves     string           `json:"totalReserves"`
+	LedgerLiabilities string           `json:"ledgerLiabilities"` // KUSD owed to users
+	UsdkTotalSupply   string           `json:"usdkTotalSupply"`
+	UsdkHeld          string           `json:"usdkHeld"`         // USDK held by platform addresses, not circulating
+	TotalLiabilities  string           `json:"totalLiabilities"` // ledger liabilities plus circulating USDK
+	CoverageRatio     *string          `json:"coverageRatio"`    // reserves / liabilities, null when nothing is owed
+	SkippedChains     []string         `json:"skippedChains,omitempty"`
+}
+
+// Run takes a snapshot every interval until ctx is cancelled
+func (s *ReservesService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.TakeSnapshot(ctx); err != nil {
+			s.logger.WithError(err).Error("Failed to take reserves snapshot")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// TakeSnapshot reads all reserve balances and liabilities and records them
+// as a reserves batch. Chains without an RPC client are listed as skipped;
+// any other read error aborts the snapshot so no partial one is recorded.
+func (s *ReservesService) TakeSnapshot(ctx context.Context) (*model.ProofBatch, error) {
+	takenAt := time.Now()
+	snapshot := &ReservesSnapshot{TakenAt: takenAt}
+
+	chains, err := s.chainRepo.FindEnabled()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chains: %v", err)
+	}
+
+	totalReserves := decimal.Zero
+	usdkSupply := decimal.Zero
+	usdkHeld := decimal.Zero
+	var leaves []common.Hash
+
+	for i := range chains {
+		chain := &chains[i]
+		client, ok := s.clients[chain.ID]
+		if !ok {
+			snapshot.SkippedChains = append(snapshot.SkippedChains, chain.ChainKey)
+			continue
+		}
+
+		holdings, supply, held, err := s.snapshotChain(ctx, client, chain, takenAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read reserves on %s: %v", chain.ChainKey, err)
+		}
+
+		for _, holding := range holdings {
+			usdValue, _ := decimal.NewFromString(holding.USDValue)
+			totalReserves = totalReserves.Add(usdValue)
+			leaves = append(leaves, common.HexToHash(holding.Leaf))
+		}
+		snapshot.Holdings = append(snapshot.Holdings, holdings...)
+		usdkSupply = usdkSupply.Add(supply)
+		usdkHeld = usdkHeld.Add(held)
+	}
+
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("no reserve holdings to attest")
+	}
+
+	ledgerLiabilities, err := s.ledgerRepo.GetTotalKUSDLiabilities()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum ledger liabilities: %v", err)
+	}
+
+	totalLiabilities := ledgerLiabilities.Add(usdkSupply.Sub(usdkHeld))
+	snapshot.TotalReserves = totalReserves.String()
+	snapshot.LedgerLiabilities = ledgerLiabilities.String()
+	snapshot.UsdkTotalSupply = usdkSupply.String()
+	snapshot.UsdkHeld = usdkHeld.String()
+	snapshot.TotalLiabilities = totalLiabilities.String()
+	if totalLiabilities.GreaterThan(decimal.Zero) {
+		ratio := totalReserves.DivRound(totalLiabilities, 6).String()
+		snapshot.CoverageRatio = &ratio
+	}
+
+	tree, err := merkle.NewTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reserves snapshot: %v", err)
+	}
+
+	batch := &model.ProofBatch{
+		BatchType:   contracts.BatchTypeReserves,
+		PeriodStart: takenAt,
+		PeriodEnd:   takenAt,
+		MerkleRoot:  tree.Root().Hex(),
+		Status:      "recorded",
+		EntryCount:  len(leaves),
+		Metadata:    metadata,
+		PublishedAt: &takenAt,
+	}
+	if err := s.proofBatchRepo.Create(batch); err != nil {
+		return nil, fmt.Errorf("failed to record reserves batch: %v", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"batch_id":          batch.ID,
+		"total_reserves":    snapshot.TotalReserves,
+		"total_liabilities": snapshot.TotalLiabilities,
+		"coverage_ratio":    snapshot.CoverageRatio,
+	}).Info("Reserves snapshot recorded")
+
+	return batch, nil
+}
+
+// snapshotChain returns the holding lines of a chain at its current head,
+// plus the chain's USDK total supply and the part of it the platform holds
+func (s *ReservesService) snapshotChain(ctx context.Context, client ChainClient, chain *model.Chain, takenAt time.Time) ([]ReserveHolding, decimal.Decimal, decimal.Decimal, error) {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to get chain head: %v", err)
+	}
+	blockNum := head.Number
+	callOpts := &bind.CallOpts{Context: ctx, BlockNumber: blockNum}
+
+	deposits, depositFees, err := s.onchainTxRepo.DepositAddressBalances(chain.ID)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to sum deposit address balances: %v", err)
+	}
+
+	chainAssets, err := s.chainAssetRepo.FindByChainIDWithAsset(chain.ID)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to load chain assets: %v", err)
+	}
+
+	var usdk common.Address
+	if chain.UsdkContract != nil && common.IsHexAddress(*chain.UsdkContract) {
+		usdk = common.HexToAddress(*chain.UsdkContract)
+	}
+	usdkDeposits := decimal.Zero
+
+	holdings := make([]ReserveHolding, 0, len(chainAssets))
+	for _, chainAsset := range chainAssets {
+		token := common.Address{}
+		if chainAsset.ContractAddress != nil {
+			token = common.HexToAddress(*chainAsset.ContractAddress)
+		}
+
+		raw := new(big.Int)
+		if token == (common.Address{}) {
+			for _, holder := range s.treasury {
+				balance, err := client.BalanceAt(ctx, holder, blockNum)
+				if err != nil {
+					return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to get %s balance: %v", chainAsset.Asset.Symbol, err)
+				}
+				raw.Add(raw, balance)
+			}
+		} else {
+			erc20, err := contracts.NewERC20Contract(token, client)
+			if err != nil {
+				return nil, decimal.Zero, decimal.Zero, err
+			}
+			for _, holder := range s.treasury {
+				balance, err := erc20.BalanceOf(callOpts, holder)
+				if err != nil {
+					return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to get %s balance: %v", chainAsset.Asset.Symbol, err)
+				}
+				raw.Add(raw, balance)
+			}
+		}
+
+		deposited := deposits[chainAsset.AssetID].Shift(int32(chainAsset.Asset.Decimals))
+		if token == (common.Address{}) {
+			// Sweeps from deposit addresses pay their gas in the native asset
+			deposited = deposited.Sub(depositFees)
+		}
+		if deposited.IsPositive() {
+			raw.Add(raw, deposited.BigInt())
+			if token == usdk && usdk != (common.Address{}) {
+				usdkDeposits = usdkDeposits.Add(deposits[chainAsset.AssetID])
+			}
+		}
+
+		balance := decimal.NewFromBigInt(raw, -int32(chainAsset.Asset.Decimals))
+		usdValue := decimal.Zero
+		if raw.Sign() > 0 {
+			usdValue, err = s.valuer.GetUSDValue(chainAsset.Asset.Symbol, balance)
+			if err != nil {
+				return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to value %s: %v", chainAsset.Asset.Symbol, err)
+			}
+		}
+
+		leaf, err := merkle.ReserveLeaf(chain.NetworkID, token, raw, blockNum.Uint64(), takenAt.Unix())
+		if err != nil {
+			return nil, decimal.Zero, decimal.Zero, err
+		}
+
+		holdings = append(holdings, ReserveHolding{
+			Chain:       chain.ChainKey,
+			ChainID:     chain.NetworkID,
+			Asset:       chainAsset.Asset.Symbol,
+			Token:       token.Hex(),
+			BlockNumber: blockNum.Uint64(),
+			Balance:     balance.String(),
+			RawBalance:  raw.String(),
+			USDValue:    usdValue.String(),
+			Leaf:        leaf.Hex(),
+		})
+	}
+
+	if usdk == (common.Address{}) {
+		return holdings, decimal.Zero, decimal.Zero, nil
+	}
+
+	supply, held, err := s.usdkSupply(callOpts, client, usdk)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, err
+	}
+	return holdings, supply, held.Add(usdkDeposits), nil
+}
+
+// usdkSupply returns the USDK total supply on a chain and how much of it the
+// treasury addresses hold
+func (s *ReservesService) usdkSupply(callOpts *bind.CallOpts, client ChainClient, address common.Address) (decimal.Decimal, decimal.Decimal, error) {
+	usdk, err := contracts.NewUSDKContract(address, client)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	decimals, err := usdk.Decimals(callOpts)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("failed to get USDK decimals: %v", err)
+	}
+	totalSupply, err := usdk.TotalSupply(callOpts)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("failed to get USDK total supply: %v", err)
+	}
+
+	held := new(big.Int)
+	for _, holder := range s.treasury {
+		balance, err := usdk.BalanceOf(callOpts, holder)
+		if err != nil {
+			return decimal.Zero, decimal.Zero, fmt.Errorf("failed to get USDK balance: %v", err)
+		}
+		held.Add(held, balance)
+	}
+
+	return decimal.NewFromBigInt(totalSupply, -int32(decimals)), decimal.NewFromBigInt(held, -int32(decimals)), nil
+}
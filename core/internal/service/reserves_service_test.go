@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+// balanceCountingClient counts native balance reads
+type balanceCountingClient struct {
+	*backends.SimulatedBackend
+	reads atomic.Int32
+}
+
+func (c *balanceCountingClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	c.reads.Add(1)
+	return c.SimulatedBackend.BalanceAt(ctx, account, blockNumber)
+}
+
+func TestReservesCountsDepositAddressesFromRecordedTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	chain, eth := seedNativeChain(t, db)
+
+	treasuryKey, _ := crypto.GenerateKey()
+	treasury := crypto.PubkeyToAddress(treasuryKey.PublicKey)
+	client := &balanceCountingClient{SimulatedBackend: newSimulatedChain(t, treasury)}
+	treasuryBalance, err := client.SimulatedBackend.BalanceAt(ctx, treasury, nil)
+	if err != nil {
+		t.Fatalf("failed to get treasury balance: %v", err)
+	}
+
+	deposits := make([]string, 3)
+	for i := range deposits {
+		user := &model.User{}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		key, _ := crypto.GenerateKey()
+		deposits[i] = crypto.PubkeyToAddress(key.PublicKey).Hex()
+		err := db.Create(&model.DepositAddress{UserID: user.ID, ChainID: chain.ID, AssetID: eth.ID, Address: deposits[i], IsActive: true}).Error
+		if err != nil {
+			t.Fatalf("failed to seed deposit address: %v", err)
+		}
+	}
+
+	gasStation := "0x0000000000000000000000000000000000006a50"
+	gasUsed, gasPrice := uint64(21_000), decimal.NewFromInt(params.GWei)
+	txs := []struct {
+		direction string
+		from, to  string
+		amount    string
+		status    string
+		paidGas   bool
+	}{
+		{direction: "in", to: deposits[0], amount: "2", status: "confirmed"},
+		{direction: "in", to: deposits[1], amount: "0.5", status: "confirmed"},
+		// Not credited yet, so not counted
+		{direction: "in", to: deposits[2], amount: "1", status: "pending"},
+		// A gas top-up, a sweep in flight, a swept and a reverted sweep
+		{direction: "sweep", from: gasStation, to: deposits[1], amount: "0.01", status: "confirmed"},
+		{direction: "sweep", from: deposits[0], to: treasury.Hex(), amount: "1.5", status: "pending"},
+		{direction: "sweep", from: deposits[1], to: treasury.Hex(), amount: "0.2", status: "confirmed", paidGas: true},
+		{direction: "sweep", from: deposits[1], to: treasury.Hex(), amount: "0.3", status: "failed", paidGas: true},
+	}
+	for i, tx := range txs {
+		record := &model.OnchainTx{
+			Direction: tx.direction,
+			ChainID:   chain.ID,
+			AssetID:   eth.ID,
+			ToAddr:    tx.to,
+			TxHash:    common.BigToHash(big.NewInt(int64(i + 1))).Hex(),
+			LogIndex:  -1,
+			Amount:    decimal.RequireFromString(tx.amount),
+			Status:    tx.status,
+		}
+		if tx.from != "" {
+			from := tx.from
+			record.FromAddr = &from
+		}
+		if tx.paidGas {
+			record.GasUsed = &gasUsed
+			record.GasPrice = &gasPrice
+		}
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed transaction %d: %v", i, err)
+		}
+	}
+
+	reserves := NewReservesService(
+		repository.NewChainRepository(db),
+		repository.NewChainAssetRepository(db),
+		repository.NewOnchainTxRepository(db),
+		repository.NewLedgerRepository(db),
+		repository.NewProofBatchRepository(db),
+		map[uint64]ChainClient{chain.ID: client},
+		[]common.Address{treasury},
+		fixedPriceValuer{"ETH": decimal.NewFromInt(2000)},
+		config.PlatformConfig{},
+		newTestLogger(),
+	)
+	batch, err := reserves.TakeSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("failed to take snapshot: %v", err)
+	}
+
+	// Only the treasury is read from the chain
+	if reads := client.reads.Load(); reads != 1 {
+		t.Fatalf("got %d balance reads, want 1 for the treasury", reads)
+	}
+
+	var snapshot ReservesSnapshot
+	if err := json.Unmarshal(batch.Metadata, &snapshot); err != nil {
+		t.Fatalf("invalid snapshot: %v", err)
+	}
+	if len(snapshot.Holdings) != 1 {
+		t.Fatalf("got holdings %+v, want the ETH line", snapshot.Holdings)
+	}
+	// 2 + 0.5 + 0.01 deposited and topped up, 1.5 + 0.2 swept, two sweeps'
+	// gas paid
+	fees := decimal.NewFromInt(int64(2 * gasUsed)).Mul(gasPrice)
+	deposited := decimal.RequireFromString("0.81").Shift(18).Sub(fees)
+	want := new(big.Int).Add(treasuryBalance, deposited.BigInt())
+	if got := snapshot.Holdings[0].RawBalance; got != want.String() {
+		t.Fatalf("got %s wei of ETH reserves, want %s", got, want)
+	}
+}
@@ -0,0 +1,678 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/signer"
+)
+
+const (
+	// txGasLimitMargin is added on top of the gas estimate, in percent
+	txGasLimitMargin = 20
+	// minFeeBumpPercent is the fee increase nodes require to accept a
+	// replacement for a pending transaction
+	minFeeBumpPercent = 10
+	txLastErrorMaxLen = 512
+)
+
+// ErrTxDropped is returned for a transaction whose nonce was used by another
+// transaction of the same sender, so it can never be mined
+var ErrTxDropped = errors.New("transaction dropped: nonce used by another transaction")
+
+// TxRequest is a transaction to send through the TxManagerService
+type TxRequest struct {
+	To      common.Address
+	Value   *big.Int // nil for no value
+	Data    []byte
+	Purpose string  // withdrawal, proof_batch, mint, burn, transfer, sweep, sweep_gas
+	RefID   *uint64 // record the transaction is sent for
+}
+
+// SignerSource provides signers for senders that are not registered with
+// AddSigner, such as deposit addresses. It returns nil when it has no signer
+// for from.
+type SignerSource interface {
+	SignerFor(ctx context.Context, from common.Address) (signer.Signer, error)
+}
+
+// TxManagerService sends EIP-1559 transactions for the platform signers and
+// follows them until they are final.
+//
+// Nonces are allocated per chain and sender under a lock and persisted with
+// the transaction before it is broadcast, so concurrent senders never share
+// a nonce. A transaction without a receipt after the stuck timeout is
+// re-signed with bumped fees, up to the configured caps, and every signed
+// attempt is kept so whichever one is mined is found.
+type TxManagerService struct {
+	outgoingTxRepo     *repository.OutgoingTxRepository
+	clients            map[uint64]ChainClient // keyed by chains.id
+	signers            map[common.Address]signer.Signer
+	signerSource       SignerSource // nil when every sender is registered
+	maxFee             *big.Int
+	maxPriorityFee     *big.Int
+	feeBumpPercent     int64
+	stuckTimeout       time.Duration
+	confirmationBlocks int
+	interval           time.Duration
+	logger             *logrus.Logger
+
+	mu      sync.Mutex
+	senders map[string]*sync.Mutex // keyed by chain and sender address
+}
+
+func NewTxManagerService(
+	outgoingTxRepo *repository.OutgoingTxRepository,
+	clients map[uint64]ChainClient,
+	txCfg config.TxConfig,
+	platformCfg config.PlatformConfig,
+	logger *logrus.Logger,
+) *TxManagerService {
+	feeBumpPercent := int64(txCfg.FeeBumpPercent)
+	if feeBumpPercent < minFeeBumpPercent {
+		feeBumpPercent = minFeeBumpPercent
+	}
+
+	return &TxManagerService{
+		outgoingTxRepo:     outgoingTxRepo,
+		clients:            clients,
+		signers:            make(map[common.Address]signer.Signer),
+		maxFee:             gweiToWei(txCfg.MaxFeeGwei),
+		maxPriorityFee:     gweiToWei(txCfg.MaxPriorityFeeGwei),
+		feeBumpPercent:     feeBumpPercent,
+		stuckTimeout:       time.Duration(txCfg.StuckTimeoutSec) * time.Second,
+		confirmationBlocks: platformCfg.ConfirmationBlocks,
+		interval:           time.Duration(txCfg.ProcessIntervalSec) * time.Second,
+		logger:             logger,
+		senders:            make(map[string]*sync.Mutex),
+	}
+}
+
+// AddSigner registers a signer the manager sends from and returns its address
+func (m *TxManagerService) AddSigner(s signer.Signer) common.Address {
+	address := s.Address()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signers[address] = s
+	return address
+}
+
+// SetSignerSource sets where signers of unregistered senders come from. They
+// are looked up on every use rather than kept in memory.
+func (m *TxManagerService) SetSignerSource(source SignerSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signerSource = source
+}
+
+func (m *TxManagerService) signer(ctx context.Context, from common.Address) (signer.Signer, error) {
+	m.mu.Lock()
+	s, ok := m.signers[from]
+	source := m.signerSource
+	m.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+
+	if source != nil {
+		s, err := source.SignerFor(ctx, from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signer for %s: %v", from.Hex(), err)
+		}
+		if s != nil && s.Address() == from {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no signer registered for %s", from.Hex())
+}
+
+// senderLock returns the lock serialising nonce allocation of a sender on a chain
+func (m *TxManagerService) senderLock(chainID uint64, from common.Address) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := fmt.Sprintf("%d:%s", chainID, from.Hex())
+	lock, ok := m.senders[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.senders[id] = lock
+	}
+	return lock
+}
+
+// Send estimates gas and fees, allocates a nonce, records and broadcasts the
+// transaction. Once recorded the transaction is owned by the manager: a
+// failed broadcast is retried on the next pass rather than returned, since
+// its nonce is already reserved.
+func (m *TxManagerService) Send(ctx context.Context, chain *model.Chain, from common.Address, req TxRequest) (*model.OutgoingTx, error) {
+	txSigner, err := m.signer(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	client, ok := m.clients[chain.ID]
+	if !ok {
+		return nil, fmt.Errorf("no RPC client for chain %s", chain.ChainKey)
+	}
+
+	value := req.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	gasLimit, err := estimateGasLimit(ctx, client, from, req.To, value, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	tip, feeCap, err := m.suggestFees(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := m.senderLock(chain.ID, from)
+	lock.Lock()
+	defer lock.Unlock()
+
+	nonce, err := m.nextNonce(ctx, client, chain.ID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &model.OutgoingTx{
+		ChainID:              chain.ID,
+		FromAddr:             from.Hex(),
+		Nonce:                nonce,
+		ToAddr:               req.To.Hex(),
+		Value:                decimal.NewFromBigInt(value, 0),
+		Data:                 req.Data,
+		GasLimit:             gasLimit,
+		MaxFeePerGas:         decimal.NewFromBigInt(feeCap, 0),
+		MaxPriorityFeePerGas: decimal.NewFromBigInt(tip, 0),
+		Purpose:              req.Purpose,
+		RefID:                req.RefID,
+		Status:               "pending",
+		Attempts:             1,
+	}
+
+	signed, err := signOutgoingTx(ctx, chain, txSigner, tx)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxHash = signed.Hash().Hex()
+
+	if err := m.outgoingTxRepo.CreateWithAttempt(tx, newTxAttempt(tx)); err != nil {
+		return nil, fmt.Errorf("failed to record transaction: %v", err)
+	}
+
+	if err := m.broadcast(ctx, client, tx, signed); err != nil {
+		m.logger.WithError(err).WithFields(logrus.Fields{
+			"chain":   chain.ChainKey,
+			"tx_hash": tx.TxHash,
+			"nonce":   tx.Nonce,
+		}).Warn("Broadcast failed, transaction will be retried")
+	}
+
+	return tx, nil
+}
+
+// EstimateCost returns the most a transaction sent now may pay in fees: the
+// gas limit Send would use times the suggested fee cap. Replacements of a
+// stuck transaction may pay more.
+func (m *TxManagerService) EstimateCost(ctx context.Context, chain *model.Chain, from common.Address, req TxRequest) (*big.Int, error) {
+	client, ok := m.clients[chain.ID]
+	if !ok {
+		return nil, fmt.Errorf("no RPC client for chain %s", chain.ChainKey)
+	}
+
+	value := req.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+
+	gasLimit, err := estimateGasLimit(ctx, client, from, req.To, value, req.Data)
+	if err != nil {
+		return nil, err
+	}
+	_, feeCap, err := m.suggestFees(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), feeCap), nil
+}
+
+// estimateGasLimit returns the gas estimate of a call plus txGasLimitMargin
+func estimateGasLimit(ctx context.Context, client ChainClient, from, to common.Address, value *big.Int, data []byte) (uint64, error) {
+	gasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{
+		From:  from,
+		To:    &to,
+		Value: value,
+		Data:  data,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate gas: %v", err)
+	}
+	return gasLimit + gasLimit*txGasLimitMargin/100, nil
+}
+
+// nextNonce returns the next nonce of a sender: the node's pending nonce,
+// unless transactions recorded here are ahead of it
+func (m *TxManagerService) nextNonce(ctx context.Context, client ChainClient, chainID uint64, from common.Address) (uint64, error) {
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending nonce: %v", err)
+	}
+
+	recorded, err := m.outgoingTxRepo.MaxNonce(chainID, from.Hex())
+	if err != nil {
+		return 0, fmt.Errorf("failed to load recorded nonce: %v", err)
+	}
+	if recorded != nil && *recorded+1 > nonce {
+		nonce = *recorded + 1
+	}
+	return nonce, nil
+}
+
+// suggestFees returns the priority fee and fee cap for a new transaction:
+// the node's suggested tip and twice the base fee on top, both capped
+func (m *TxManagerService) suggestFees(ctx context.Context, client ChainClient) (*big.Int, *big.Int, error) {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get chain head: %v", err)
+	}
+	if head.BaseFee == nil {
+		return nil, nil, fmt.Errorf("chain does not support EIP-1559 transactions")
+	}
+
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to suggest priority fee: %v", err)
+	}
+
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	tip, feeCap = m.capFees(tip, feeCap)
+	return tip, feeCap, nil
+}
+
+func (m *TxManagerService) capFees(tip, feeCap *big.Int) (*big.Int, *big.Int) {
+	if feeCap.Cmp(m.maxFee) > 0 {
+		feeCap = new(big.Int).Set(m.maxFee)
+	}
+	if tip.Cmp(m.maxPriorityFee) > 0 {
+		tip = new(big.Int).Set(m.maxPriorityFee)
+	}
+	if tip.Cmp(feeCap) > 0 {
+		tip = new(big.Int).Set(feeCap)
+	}
+	return tip, feeCap
+}
+
+// broadcast sends a signed attempt and records the outcome on the
+// transaction. A node that already knows the transaction counts as success.
+func (m *TxManagerService) broadcast(ctx context.Context, client ChainClient, tx *model.OutgoingTx, signed *types.Transaction) error {
+	sendErr := client.SendTransaction(ctx, signed)
+	if sendErr != nil && isKnownTxError(sendErr) {
+		sendErr = nil
+	}
+
+	if sendErr != nil {
+		message := sendErr.Error()
+		if len(message) > txLastErrorMaxLen {
+			message = message[:txLastErrorMaxLen]
+		}
+		tx.LastError = &message
+	} else {
+		now := time.Now()
+		tx.BroadcastAt = &now
+		tx.LastError = nil
+	}
+
+	if err := m.outgoingTxRepo.Update(tx); err != nil {
+		return fmt.Errorf("failed to record broadcast of %s: %v", tx.TxHash, err)
+	}
+	return sendErr
+}
+
+// Run follows in-flight transactions every interval until ctx is cancelled
+func (m *TxManagerService) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.ProcessOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessOnce records receipts of in-flight transactions, rebroadcasts the
+// ones whose broadcast failed and replaces the stuck ones
+func (m *TxManagerService) ProcessOnce(ctx context.Context) {
+	txs, err := m.outgoingTxRepo.FindInFlight()
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to load in-flight transactions")
+		return
+	}
+
+	for i := range txs {
+		tx := &txs[i]
+		client, ok := m.clients[tx.ChainID]
+		if !ok {
+			continue
+		}
+
+		if err := m.process(ctx, client, tx); err != nil {
+			m.logger.WithError(err).WithFields(logrus.Fields{
+				"outgoing_tx_id": tx.ID,
+				"tx_hash":        tx.TxHash,
+				"nonce":          tx.Nonce,
+			}).Warn("Failed to process transaction")
+		}
+	}
+}
+
+func (m *TxManagerService) process(ctx context.Context, client ChainClient, tx *model.OutgoingTx) error {
+	receipt, err := m.findReceipt(ctx, client, tx)
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		return m.recordReceipt(ctx, client, tx, receipt)
+	}
+
+	if tx.Status == "mined" {
+		m.logger.WithField("tx_hash", tx.TxHash).Warn("Mined transaction disappeared after a reorg")
+		tx.Status = "pending"
+		tx.BlockNum = nil
+		tx.GasUsed = nil
+		tx.EffectiveGasPrice = nil
+		if err := m.outgoingTxRepo.Update(tx); err != nil {
+			return fmt.Errorf("failed to reset transaction: %v", err)
+		}
+	}
+
+	txSigner, err := m.signer(ctx, common.HexToAddress(tx.FromAddr))
+	if err != nil {
+		return err
+	}
+
+	if tx.BroadcastAt == nil || tx.LastError != nil {
+		return m.rebroadcast(ctx, client, tx, txSigner)
+	}
+	if time.Since(*tx.BroadcastAt) < m.stuckTimeout {
+		return nil
+	}
+	return m.replace(ctx, client, tx, txSigner)
+}
+
+// findReceipt returns the receipt of whichever attempt was mined, or nil
+func (m *TxManagerService) findReceipt(ctx context.Context, client ChainClient, tx *model.OutgoingTx) (*types.Receipt, error) {
+	hashes := []string{tx.TxHash}
+	for i := len(tx.TxAttempts) - 1; i >= 0; i-- {
+		if tx.TxAttempts[i].TxHash != tx.TxHash {
+			hashes = append(hashes, tx.TxAttempts[i].TxHash)
+		}
+	}
+
+	for _, hash := range hashes {
+		receipt, err := client.TransactionReceipt(ctx, common.HexToHash(hash))
+		if errors.Is(err, ethereum.NotFound) || (err == nil && receipt == nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get receipt of %s: %v", hash, err)
+		}
+		return receipt, nil
+	}
+	return nil, nil
+}
+
+// recordReceipt stores the mined attempt and finalises the transaction once
+// it has enough confirmations
+func (m *TxManagerService) recordReceipt(ctx context.Context, client ChainClient, tx *model.OutgoingTx, receipt *types.Receipt) error {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %v", err)
+	}
+
+	blockNum := receipt.BlockNumber.Uint64()
+	gasUsed := receipt.GasUsed
+	tx.TxHash = receipt.TxHash.Hex()
+	tx.BlockNum = &blockNum
+	tx.GasUsed = &gasUsed
+	if receipt.EffectiveGasPrice != nil {
+		gasPrice := decimal.NewFromBigInt(receipt.EffectiveGasPrice, 0)
+		tx.EffectiveGasPrice = &gasPrice
+	}
+
+	tx.Status = "mined"
+	if headNum := head.Number.Uint64(); headNum >= blockNum && int(headNum-blockNum+1) >= m.confirmationBlocks {
+		tx.Status = "confirmed"
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			tx.Status = "reverted"
+		}
+		now := time.Now()
+		tx.FinalizedAt = &now
+	}
+
+	if err := m.outgoingTxRepo.Update(tx); err != nil {
+		return fmt.Errorf("failed to record receipt: %v", err)
+	}
+
+	if tx.FinalizedAt != nil {
+		m.logger.WithFields(logrus.Fields{
+			"outgoing_tx_id": tx.ID,
+			"purpose":        tx.Purpose,
+			"tx_hash":        tx.TxHash,
+			"status":         tx.Status,
+			"attempts":       tx.Attempts,
+		}).Info("Transaction final")
+	}
+	return nil
+}
+
+// rebroadcast re-sends the current attempt. Local keys sign
+// deterministically; a signer that does not yields a new hash, which is
+// recorded as another attempt.
+func (m *TxManagerService) rebroadcast(ctx context.Context, client ChainClient, tx *model.OutgoingTx, txSigner signer.Signer) error {
+	signed, err := signOutgoingTx(ctx, &tx.Chain, txSigner, tx)
+	if err != nil {
+		return err
+	}
+	if hash := signed.Hash().Hex(); hash != tx.TxHash {
+		tx.TxHash = hash
+		tx.Attempts++
+		if err := m.outgoingTxRepo.AddAttempt(tx, newTxAttempt(tx)); err != nil {
+			return fmt.Errorf("failed to record re-signed attempt: %v", err)
+		}
+	}
+
+	err = m.broadcast(ctx, client, tx, signed)
+	if err != nil && isNonceTooLowError(err) {
+		return m.dropIfUnmined(ctx, client, tx)
+	}
+	return err
+}
+
+// replace re-signs a stuck transaction with fees bumped by feeBumpPercent,
+// or to the current suggestion if that is higher
+func (m *TxManagerService) replace(ctx context.Context, client ChainClient, tx *model.OutgoingTx, txSigner signer.Signer) error {
+	suggestedTip, suggestedFeeCap, err := m.suggestFees(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	oldTip := tx.MaxPriorityFeePerGas.BigInt()
+	oldFeeCap := tx.MaxFeePerGas.BigInt()
+	tip, feeCap := m.capFees(
+		maxBig(bumpFee(oldTip, m.feeBumpPercent), suggestedTip),
+		maxBig(bumpFee(oldFeeCap, m.feeBumpPercent), suggestedFeeCap),
+	)
+	if tip.Cmp(bumpFee(oldTip, minFeeBumpPercent)) < 0 || feeCap.Cmp(bumpFee(oldFeeCap, minFeeBumpPercent)) < 0 {
+		m.logger.WithFields(logrus.Fields{
+			"tx_hash":         tx.TxHash,
+			"max_fee_per_gas": oldFeeCap.String(),
+		}).Warn("Stuck transaction is at the fee cap, waiting for it to be mined")
+		return nil
+	}
+
+	tx.MaxPriorityFeePerGas = decimal.NewFromBigInt(tip, 0)
+	tx.MaxFeePerGas = decimal.NewFromBigInt(feeCap, 0)
+	signed, err := signOutgoingTx(ctx, &tx.Chain, txSigner, tx)
+	if err != nil {
+		return err
+	}
+	previousHash := tx.TxHash
+	tx.TxHash = signed.Hash().Hex()
+	tx.Attempts++
+
+	if err := m.outgoingTxRepo.AddAttempt(tx, newTxAttempt(tx)); err != nil {
+		return fmt.Errorf("failed to record replacement: %v", err)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"outgoing_tx_id":  tx.ID,
+		"replaced_hash":   previousHash,
+		"tx_hash":         tx.TxHash,
+		"nonce":           tx.Nonce,
+		"max_fee_per_gas": feeCap.String(),
+	}).Info("Replacing stuck transaction")
+
+	err = m.broadcast(ctx, client, tx, signed)
+	if err != nil && isNonceTooLowError(err) {
+		return m.dropIfUnmined(ctx, client, tx)
+	}
+	return err
+}
+
+// dropIfUnmined marks a transaction dropped when the node reports its nonce
+// as used and none of its attempts was mined
+func (m *TxManagerService) dropIfUnmined(ctx context.Context, client ChainClient, tx *model.OutgoingTx) error {
+	receipt, err := m.findReceipt(ctx, client, tx)
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		return m.recordReceipt(ctx, client, tx, receipt)
+	}
+
+	now := time.Now()
+	tx.Status = "dropped"
+	tx.FinalizedAt = &now
+	if err := m.outgoingTxRepo.Update(tx); err != nil {
+		return fmt.Errorf("failed to mark transaction dropped: %v", err)
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"outgoing_tx_id": tx.ID,
+		"purpose":        tx.Purpose,
+		"nonce":          tx.Nonce,
+	}).Error("Transaction dropped, its nonce was used by another transaction")
+	return nil
+}
+
+// Receipt returns the receipt of the transaction last sent for a record, or
+// of txHash when the record was sent before the manager existed. It returns
+// nil while nothing is mined, and ErrTxDropped when the transaction can no
+// longer be mined.
+func (m *TxManagerService) Receipt(ctx context.Context, client ChainClient, purpose string, refID uint64, txHash string) (*types.Receipt, error) {
+	tx, err := m.outgoingTxRepo.FindLatestByRef(purpose, refID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outgoing transaction: %v", err)
+	}
+	if tx != nil {
+		switch tx.Status {
+		case "dropped":
+			return nil, ErrTxDropped
+		case "pending":
+			return nil, nil
+		}
+		txHash = tx.TxHash
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(txHash))
+	if errors.Is(err, ethereum.NotFound) || (err == nil && receipt == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipt: %v", err)
+	}
+	return receipt, nil
+}
+
+// LatestTx returns the transaction last sent for a record, or nil when none
+// was sent through the manager
+func (m *TxManagerService) LatestTx(purpose string, refID uint64) (*model.OutgoingTx, error) {
+	return m.outgoingTxRepo.FindLatestByRef(purpose, refID)
+}
+
+// signOutgoingTx signs the current fields of a transaction
+func signOutgoingTx(ctx context.Context, chain *model.Chain, txSigner signer.Signer, tx *model.OutgoingTx) (*types.Transaction, error) {
+	chainID := new(big.Int).SetUint64(chain.NetworkID)
+	to := common.HexToAddress(tx.ToAddr)
+
+	return txSigner.SignTx(ctx, types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     tx.Nonce,
+		GasTipCap: tx.MaxPriorityFeePerGas.BigInt(),
+		GasFeeCap: tx.MaxFeePerGas.BigInt(),
+		Gas:       tx.GasLimit,
+		To:        &to,
+		Value:     tx.Value.BigInt(),
+		Data:      tx.Data,
+	}), chainID)
+}
+
+func newTxAttempt(tx *model.OutgoingTx) *model.OutgoingTxAttempt {
+	return &model.OutgoingTxAttempt{
+		TxHash:               tx.TxHash,
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+	}
+}
+
+// bumpFee returns fee increased by percent, rounded up
+func bumpFee(fee *big.Int, percent int64) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+percent))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
+
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func gweiToWei(gwei float64) *big.Int {
+	return decimal.NewFromFloat(gwei).Mul(decimal.NewFromInt(params.GWei)).BigInt()
+}
+
+func isKnownTxError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "already known") || strings.Contains(message, "known transaction")
+}
+
+func isNonceTooLowError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/txpool"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/signer"
+)
+
+// mempoolClient stands in for a node's transaction pool on top of the
+// simulated backend, which only accepts the next nonce and mines on Commit.
+// It keeps sent transactions unmined until mine, accepts a replacement that
+// raises both fees by minFeeBumpPercent and rejects mined nonces as too low.
+type mempoolClient struct {
+	*backends.SimulatedBackend
+
+	mu   sync.Mutex
+	pool map[common.Address]map[uint64]*types.Transaction
+}
+
+func newMempoolClient(sim *backends.SimulatedBackend) *mempoolClient {
+	return &mempoolClient{SimulatedBackend: sim, pool: make(map[common.Address]map[uint64]*types.Transaction)}
+}
+
+func (c *mempoolClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(simulatedChainID)), tx)
+	if err != nil {
+		return err
+	}
+	mined, err := c.SimulatedBackend.NonceAt(ctx, from, nil)
+	if err != nil {
+		return err
+	}
+	if tx.Nonce() < mined {
+		return core.ErrNonceTooLow
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pool[from] == nil {
+		c.pool[from] = make(map[uint64]*types.Transaction)
+	}
+	if old := c.pool[from][tx.Nonce()]; old != nil {
+		if old.Hash() == tx.Hash() {
+			return txpool.ErrAlreadyKnown
+		}
+		if tx.GasFeeCap().Cmp(bumpFee(old.GasFeeCap(), minFeeBumpPercent)) < 0 ||
+			tx.GasTipCap().Cmp(bumpFee(old.GasTipCap(), minFeeBumpPercent)) < 0 {
+			return txpool.ErrReplaceUnderpriced
+		}
+	}
+	c.pool[from][tx.Nonce()] = tx
+	return nil
+}
+
+func (c *mempoolClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	nonce, err := c.SimulatedBackend.NonceAt(ctx, account, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.pool[account][nonce] != nil {
+		nonce++
+	}
+	return nonce, nil
+}
+
+// mine includes every pooled transaction that follows a sender's mined
+// nonce in a new block
+func (c *mempoolClient) mine(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for from, txs := range c.pool {
+		nonce, err := c.SimulatedBackend.NonceAt(ctx, from, nil)
+		if err != nil {
+			t.Fatalf("failed to get nonce: %v", err)
+		}
+		for ; txs[nonce] != nil; nonce++ {
+			if err := c.SimulatedBackend.SendTransaction(ctx, txs[nonce]); err != nil {
+				t.Fatalf("failed to mine pooled transaction: %v", err)
+			}
+		}
+		delete(c.pool, from)
+	}
+	c.Commit()
+}
+
+// txManagerFixture is a tx manager sending from a funded key through a
+// mempool on a simulated chain
+type txManagerFixture struct {
+	db        *gorm.DB
+	chain     *model.Chain
+	client    *mempoolClient
+	key       *ecdsa.PrivateKey
+	from      common.Address
+	txManager *TxManagerService
+}
+
+func newTxManagerFixture(t *testing.T, txCfg config.TxConfig) *txManagerFixture {
+	t.Helper()
+
+	db := newTestDB(t)
+	chain, _ := seedNativeChain(t, db)
+	key, _ := crypto.GenerateKey()
+	client := newMempoolClient(newSimulatedChain(t, crypto.PubkeyToAddress(key.PublicKey)))
+
+	txManager := NewTxManagerService(
+		repository.NewOutgoingTxRepository(db), map[uint64]ChainClient{chain.ID: client},
+		txCfg, config.PlatformConfig{ConfirmationBlocks: 1}, newTestLogger(),
+	)
+	from := txManager.AddSigner(signer.NewKeySigner(key))
+	return &txManagerFixture{db: db, chain: chain, client: client, key: key, from: from, txManager: txManager}
+}
+
+// transfer sends a native transfer for refID
+func (f *txManagerFixture) transfer(refID uint64) (*model.OutgoingTx, error) {
+	return f.txManager.Send(context.Background(), f.chain, f.from, TxRequest{
+		To:      common.BigToAddress(new(big.Int).SetUint64(0xa000 + refID)),
+		Value:   big.NewInt(params.GWei),
+		Purpose: "transfer",
+		RefID:   &refID,
+	})
+}
+
+func (f *txManagerFixture) send(t *testing.T, refID uint64) *model.OutgoingTx {
+	t.Helper()
+
+	tx, err := f.transfer(refID)
+	if err != nil {
+		t.Fatalf("failed to send transaction %d: %v", refID, err)
+	}
+	return tx
+}
+
+func (f *txManagerFixture) reload(t *testing.T, tx *model.OutgoingTx) *model.OutgoingTx {
+	t.Helper()
+
+	reloaded, err := repository.NewOutgoingTxRepository(f.db).FindByID(tx.ID)
+	if err != nil {
+		t.Fatalf("failed to reload transaction: %v", err)
+	}
+	return reloaded
+}
+
+func TestTxManagerAllocatesDistinctNoncesToConcurrentSends(t *testing.T) {
+	ctx := context.Background()
+	f := newTxManagerFixture(t, config.TxConfig{MaxFeeGwei: 100, MaxPriorityFeeGwei: 2, StuckTimeoutSec: 600})
+
+	const sends = 8
+	txs := make([]*model.OutgoingTx, sends)
+	errs := make([]error, sends)
+	var wg sync.WaitGroup
+	for i := range txs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			txs[i], errs[i] = f.transfer(uint64(i + 1))
+		}(i)
+	}
+	wg.Wait()
+
+	nonces := make([]int, sends)
+	for i, tx := range txs {
+		if errs[i] != nil {
+			t.Fatalf("send %d failed: %v", i, errs[i])
+		}
+		nonces[i] = int(tx.Nonce)
+	}
+	sort.Ints(nonces)
+	for i, nonce := range nonces {
+		if nonce != i {
+			t.Fatalf("got nonces %v, want 0 to %d each once", nonces, sends-1)
+		}
+	}
+
+	f.client.mine(t)
+	f.txManager.ProcessOnce(ctx)
+	for _, tx := range txs {
+		if got := f.reload(t, tx); got.Status != "confirmed" {
+			t.Fatalf("transaction with nonce %d: got status %s, want confirmed", tx.Nonce, got.Status)
+		}
+	}
+}
+
+func TestTxManagerReplacesStuckTransactionUpToFeeCap(t *testing.T) {
+	ctx := context.Background()
+	// Every pending transaction counts as stuck; the fee cap leaves room for
+	// a few replacements over the ~2 gwei first fee cap
+	f := newTxManagerFixture(t, config.TxConfig{MaxFeeGwei: 3, MaxPriorityFeeGwei: 2})
+	tx := f.send(t, 1)
+
+	for i := 0; i < 10; i++ {
+		f.txManager.ProcessOnce(ctx)
+	}
+
+	stuck := f.reload(t, tx)
+	attempts := stuck.TxAttempts
+	if len(attempts) < 3 || stuck.Attempts != len(attempts) {
+		t.Fatalf("got %d recorded attempts with counter %d, want a few replacements", len(attempts), stuck.Attempts)
+	}
+	for i := 1; i < len(attempts); i++ {
+		previous, current := attempts[i-1], attempts[i]
+		if current.MaxFeePerGas.LessThan(bumpedFee(previous.MaxFeePerGas)) ||
+			current.MaxPriorityFeePerGas.LessThan(bumpedFee(previous.MaxPriorityFeePerGas)) {
+			t.Fatalf("attempt %d: fees %s/%s are not %d%% over %s/%s", i, current.MaxFeePerGas, current.MaxPriorityFeePerGas,
+				minFeeBumpPercent, previous.MaxFeePerGas, previous.MaxPriorityFeePerGas)
+		}
+	}
+	maxFee := decimal.NewFromBigInt(gweiToWei(3), 0)
+	if stuck.MaxFeePerGas.GreaterThan(maxFee) {
+		t.Fatalf("got fee cap %s over the configured %s", stuck.MaxFeePerGas, maxFee)
+	}
+	if stuck.LastError != nil {
+		t.Fatalf("replacement rejected: %s", *stuck.LastError)
+	}
+
+	// Held at the cap: no further replacement
+	f.txManager.ProcessOnce(ctx)
+	if held := f.reload(t, tx); held.Attempts != stuck.Attempts || held.TxHash != stuck.TxHash {
+		t.Fatalf("got %d attempts after reaching the fee cap, want %d", held.Attempts, stuck.Attempts)
+	}
+
+	f.client.mine(t)
+	f.txManager.ProcessOnce(ctx)
+	if mined := f.reload(t, tx); mined.Status != "confirmed" || mined.TxHash != stuck.TxHash {
+		t.Fatalf("got status %s for %s, want the last replacement %s confirmed", mined.Status, mined.TxHash, stuck.TxHash)
+	}
+}
+
+func TestTxManagerDropsTransactionWhoseNonceWasUsed(t *testing.T) {
+	ctx := context.Background()
+	f := newTxManagerFixture(t, config.TxConfig{MaxFeeGwei: 100, MaxPriorityFeeGwei: 2})
+	tx := f.send(t, 1)
+
+	// Another wallet holding the same key spends the nonce first
+	f.client.mu.Lock()
+	delete(f.client.pool, f.from)
+	f.client.mu.Unlock()
+	sendEther(t, f.client.SimulatedBackend, f.key, common.HexToAddress("0xbb"), big.NewInt(1))
+	f.client.Commit()
+
+	f.txManager.ProcessOnce(ctx)
+	if dropped := f.reload(t, tx); dropped.Status != "dropped" || dropped.FinalizedAt == nil {
+		t.Fatalf("got status %s, want dropped", dropped.Status)
+	}
+	_, err := f.txManager.Receipt(ctx, f.client, "transfer", 1, tx.TxHash)
+	if !errors.Is(err, ErrTxDropped) {
+		t.Fatalf("got receipt error %v, want ErrTxDropped", err)
+	}
+}
+
+// bumpedFee returns fee raised by minFeeBumpPercent
+func bumpedFee(fee decimal.Decimal) decimal.Decimal {
+	return decimal.NewFromBigInt(bumpFee(fee.BigInt(), minFeeBumpPercent), 0)
+}
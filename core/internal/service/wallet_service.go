@@ -1,27 +1,40 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/shopspring/decimal"
 
 	"usdk-backend/internal/config"
 	"usdk-backend/internal/model"
 	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/keyvault"
 	"usdk-backend/pkg/riskcontrol"
 	"usdk-backend/pkg/wallet"
 )
 
+const depositAddressVerifyBatch = 500
+
 type WalletService struct {
-	userRepo           *repository.UserRepository
-	chainRepo          *repository.ChainRepository
-	assetRepo          *repository.AssetRepository
-	depositAddressRepo *repository.DepositAddressRepository
+	userRepo            *repository.UserRepository
+	chainRepo           *repository.ChainRepository
+	assetRepo           *repository.AssetRepository
+	depositAddressRepo  *repository.DepositAddressRepository
 	withdrawRequestRepo *repository.WithdrawRequestRepository
-	hdWallet           *wallet.HDWalletService
-	riskService        *riskcontrol.RiskService
+	hdWallet            wallet.AddressDeriver // mnemonic or watch-only, nil for random addresses
+	watchOnly           bool                  // WALLET_TYPE=watch: addresses are only ever derived from xpubs
+	keyVault            *keyvault.Vault       // seals keys of random addresses, nil when not configured
+	riskService         *riskcontrol.RiskService
+	addressValidator    *WithdrawAddressValidator
+	valuer              AssetValuer
 }
 
+// NewWalletService fails when WALLET_TYPE=watch and HD_ACCOUNT_XPUBS does not
+// give a usable watch-only wallet, since a watch deployment must never fall
+// back to generating deposit keys it holds itself.
 func NewWalletService(
 	userRepo *repository.UserRepository,
 	chainRepo *repository.ChainRepository,
@@ -29,27 +42,62 @@ func NewWalletService(
 	depositAddressRepo *repository.DepositAddressRepository,
 	withdrawRequestRepo *repository.WithdrawRequestRepository,
 	riskService *riskcontrol.RiskService,
-) *WalletService {
-	// Initialize HD wallet if mnemonic is provided
-	var hdWallet *wallet.HDWalletService
-	if config.AppConfig.Wallet.HDMnemonic != "" && config.AppConfig.Wallet.WalletType == "hd" {
-		var err error
-		hdWallet, err = wallet.NewHDWalletService(config.AppConfig.Wallet.HDMnemonic)
+	keyVault *keyvault.Vault,
+	addressValidator *WithdrawAddressValidator,
+	valuer AssetValuer,
+) (*WalletService, error) {
+	// Initialize the HD wallet from the mnemonic, or from account xpubs only
+	var hdWallet wallet.AddressDeriver
+	switch config.AppConfig.Wallet.WalletType {
+	case "hd":
+		if config.AppConfig.Wallet.HDMnemonic != "" {
+			hdService, err := wallet.NewHDWalletService(config.AppConfig.Wallet.HDMnemonic)
+			if err != nil {
+				// Log error but don't fail initialization
+				fmt.Printf("Warning: Failed to initialize HD wallet: %v\n", err)
+			} else {
+				hdWallet = hdService
+			}
+		}
+	case "watch":
+		watchWallet, err := newWatchWallet(config.AppConfig.Wallet.AccountXpubs)
 		if err != nil {
-			// Log error but don't fail initialization
-			fmt.Printf("Warning: Failed to initialize HD wallet: %v\n", err)
+			return nil, fmt.Errorf("failed to initialize watch-only wallet: %v", err)
 		}
+		hdWallet = watchWallet
 	}
 
 	return &WalletService{
-		userRepo:           userRepo,
-		chainRepo:          chainRepo,
-		assetRepo:          assetRepo,
-		depositAddressRepo: depositAddressRepo,
+		userRepo:            userRepo,
+		chainRepo:           chainRepo,
+		assetRepo:           assetRepo,
+		depositAddressRepo:  depositAddressRepo,
 		withdrawRequestRepo: withdrawRequestRepo,
-		hdWallet:           hdWallet,
-		riskService:        riskService,
+		hdWallet:            hdWallet,
+		watchOnly:           config.AppConfig.Wallet.WalletType == "watch",
+		keyVault:            keyVault,
+		riskService:         riskService,
+		addressValidator:    addressValidator,
+		valuer:              valuer,
+	}, nil
+}
+
+// newWatchWallet creates the watch-only wallet of WALLET_TYPE=watch
+func newWatchWallet(spec string) (*wallet.WatchWallet, error) {
+	xpubs, err := wallet.ParseAccountXpubs(spec)
+	if err != nil {
+		return nil, err
 	}
+	return wallet.NewWatchWallet(xpubs)
+}
+
+// chainFamily returns the family deriving and validating a chain's addresses
+func chainFamily(chain *model.Chain) (wallet.ChainFamily, error) {
+	family, err := wallet.LookupFamily(chain.Family)
+	if err != nil {
+		return nil, fmt.Errorf("chain %s: %v", chain.ChainKey, err)
+	}
+	return family, nil
 }
 
 type DepositAddressResponse struct {
@@ -62,10 +110,10 @@ type DepositAddressResponse struct {
 }
 
 type WithdrawResponse struct {
-	ID          uint64                          `json:"id"`
-	Status      string                          `json:"status"`
-	RiskCheck   *riskcontrol.RiskCheckResult    `json:"riskCheck,omitempty"`
-	WaitingTime int                             `json:"waitingTimeHours,omitempty"`
+	ID          uint64                       `json:"id"`
+	Status      string                       `json:"status"`
+	RiskCheck   *riskcontrol.RiskCheckResult `json:"riskCheck,omitempty"`
+	WaitingTime int                          `json:"waitingTimeHours,omitempty"`
 }
 
 func (s *WalletService) GetOrCreateDepositAddress(userID uint64, chainKey, assetSymbol string) (*DepositAddressResponse, error) {
@@ -93,36 +141,36 @@ func (s *WalletService) GetOrCreateDepositAddress(userID uint64, chainKey, asset
 		}, nil
 	}
 
-	// Create new deposit address
-	address, derivationPath, err := s.generateDepositAddress(userID, chainKey, assetSymbol)
+	family, err := chainFamily(chain)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate deposit address: %v", err)
+		return nil, err
 	}
 
+	// Create new deposit address
 	newDepositAddr := &model.DepositAddress{
-		UserID:         userID,
-		ChainID:        chain.ID,
-		AssetID:        asset.ID,
-		Address:        address,
-		DerivationPath: &derivationPath,
-		IsActive:       true,
+		UserID:   userID,
+		ChainID:  chain.ID,
+		AssetID:  asset.ID,
+		IsActive: true,
 	}
 
-	if err := s.depositAddressRepo.Create(newDepositAddr); err != nil {
-		return nil, fmt.Errorf("failed to create deposit address: %v", err)
+	if err := s.createDepositAddress(newDepositAddr, family, chainKey, assetSymbol); err != nil {
+		return nil, err
 	}
 
 	return &DepositAddressResponse{
 		Chain:     chainKey,
 		Asset:     assetSymbol,
-		Address:   address,
+		Address:   newDepositAddr.Address,
 		Memo:      nil,
 		MinAmount: asset.MinDeposit.String(),
 		Fresh:     true,
 	}, nil
 }
 
-func (s *WalletService) SubmitWithdrawRequest(userID uint64, chainKey, assetSymbol, amountStr, toAddress string) (*WithdrawResponse, error) {
+// SubmitWithdrawRequest validates the destination, runs the risk checks and
+// queues the withdrawal. A rejected destination returns an *AddressError.
+func (s *WalletService) SubmitWithdrawRequest(ctx context.Context, userID uint64, chainKey, assetSymbol, amountStr, toAddress string) (*WithdrawResponse, error) {
 	// Get chain and asset info
 	chain, err := s.chainRepo.FindByChainKey(chainKey)
 	if err != nil {
@@ -134,6 +182,13 @@ func (s *WalletService) SubmitWithdrawRequest(userID uint64, chainKey, assetSymb
 		return nil, fmt.Errorf("asset not found: %v", err)
 	}
 
+	// Validate the destination with the chain's address rules and use its
+	// canonical form from here on
+	toAddress, err = s.addressValidator.Validate(ctx, chain, toAddress)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse amount
 	amount, err := decimal.NewFromString(amountStr)
 	if err != nil {
@@ -144,8 +199,22 @@ func (s *WalletService) SubmitWithdrawRequest(userID uint64, chainKey, assetSymb
 		return nil, fmt.Errorf("amount must be greater than zero")
 	}
 
-	// Perform risk assessment
-	riskResult, err := s.riskService.CheckWithdrawRisk(userID, amount, toAddress, chain.ID)
+	// The executor holds the funds when it claims the request; reject early
+	// what the balance cannot cover now
+	kusdAmount, err := s.valuer.GetUSDValue(asset.Symbol, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to value withdrawal: %v", err)
+	}
+	available, err := s.withdrawRequestRepo.AvailableKusdBalance(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load balance: %v", err)
+	}
+	if available.LessThan(kusdAmount) {
+		return nil, fmt.Errorf("insufficient balance: %s KUSD available, %s KUSD required", available.String(), kusdAmount.String())
+	}
+
+	// Perform risk assessment, the risk limits are in KUSD
+	riskResult, err := s.riskService.CheckWithdrawRisk(userID, kusdAmount, toAddress, chain.ID)
 	if err != nil {
 		return nil, fmt.Errorf("risk assessment failed: %v", err)
 	}
@@ -189,23 +258,91 @@ func (s *WalletService) SubmitWithdrawRequest(userID uint64, chainKey, assetSymb
 	}, nil
 }
 
-func (s *WalletService) generateDepositAddress(userID uint64, chainKey, assetSymbol string) (string, string, error) {
+// createDepositAddress fills in a new address of the chain's family for
+// depositAddr and stores it. HD addresses take the next index of the chain and
+// asset; random addresses store their sealed private key.
+func (s *WalletService) createDepositAddress(depositAddr *model.DepositAddress, family wallet.ChainFamily, chainKey, assetSymbol string) error {
 	if s.hdWallet != nil {
 		// Use HD wallet to generate address
-		address, derivationPath, err := s.hdWallet.DeriveAddressForUser(userID, chainKey, assetSymbol)
+		err := s.depositAddressRepo.CreateDerived(depositAddr, func(index uint32) (string, string, error) {
+			return s.hdWallet.DeriveDepositAddress(family, depositAddr.ChainID, depositAddr.AssetID, index)
+		})
 		if err != nil {
-			return "", "", fmt.Errorf("HD wallet address generation failed: %v", err)
+			return fmt.Errorf("failed to create HD deposit address: %v", err)
 		}
-		return address, derivationPath, nil
+		return nil
+	}
+
+	if s.watchOnly {
+		return fmt.Errorf("failed to generate deposit address: watch-only wallet has no account xpubs")
+	}
+
+	// A random address cannot be re-derived, so its key must be stored
+	if s.keyVault == nil {
+		return fmt.Errorf("failed to generate deposit address: random deposit addresses require WALLET_ENCRYPTION_KEY")
 	}
 
 	// Fallback to random address generation
-	address, _, err := wallet.GenerateRandomAddress()
+	address, privateKeyHex, err := wallet.GenerateRandomAddress(family)
+	if err != nil {
+		return fmt.Errorf("failed to generate deposit address: %v", err)
+	}
+
+	privateKeyRef, err := sealDepositKey(s.keyVault, family, address, privateKeyHex)
 	if err != nil {
-		return "", "", fmt.Errorf("random address generation failed: %v", err)
+		return fmt.Errorf("failed to seal private key: %v", err)
 	}
 
 	// For random addresses, use a pseudo derivation path for consistency
-	derivationPath := fmt.Sprintf("random/user/%d/%s/%s", userID, chainKey, assetSymbol)
-	return address, derivationPath, nil
-}
\ No newline at end of file
+	derivationPath := fmt.Sprintf("random/user/%d/%s/%s", depositAddr.UserID, chainKey, assetSymbol)
+	depositAddr.Address = address
+	depositAddr.DerivationPath = &derivationPath
+	depositAddr.PrivateKeyRef = &privateKeyRef
+
+	if err := s.depositAddressRepo.Create(depositAddr); err != nil {
+		return fmt.Errorf("failed to create deposit address: %v", err)
+	}
+	return nil
+}
+
+// VerifyDepositAddresses re-derives every stored HD deposit address from its
+// derivation path, including legacy m/44'/60'/0'/0/{userID} addresses, which
+// keep their path and stay in use. It returns how many were checked and fails
+// on the first address the configured mnemonic or xpubs no longer derive.
+func (s *WalletService) VerifyDepositAddresses() (int, error) {
+	if s.hdWallet == nil {
+		return 0, nil
+	}
+
+	checked := 0
+	afterID := uint64(0)
+	for {
+		addresses, err := s.depositAddressRepo.FindDerivedAfter(afterID, depositAddressVerifyBatch)
+		if err != nil {
+			return checked, fmt.Errorf("failed to load deposit addresses: %v", err)
+		}
+		if len(addresses) == 0 {
+			return checked, nil
+		}
+
+		for _, addr := range addresses {
+			afterID = addr.ID
+			family, err := chainFamily(&addr.Chain)
+			if err != nil {
+				return checked, fmt.Errorf("deposit address %d: %v", addr.ID, err)
+			}
+			derived, err := s.hdWallet.DeriveAddress(family, *addr.DerivationPath)
+			if errors.Is(err, wallet.ErrAccountNotWatched) {
+				// Watch mode only covers the accounts it has an xpub for
+				continue
+			}
+			if err != nil {
+				return checked, fmt.Errorf("deposit address %d: %v", addr.ID, err)
+			}
+			if !strings.EqualFold(derived, addr.Address) {
+				return checked, fmt.Errorf("deposit address %d (%s) does not derive from %s with the configured wallet", addr.ID, addr.Address, *addr.DerivationPath)
+			}
+			checked++
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/keyvault"
+	"usdk-backend/pkg/wallet"
+)
+
+// setWalletConfig installs a wallet configuration for the rest of the test
+func setWalletConfig(t *testing.T, walletCfg config.WalletConfig) {
+	t.Helper()
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{Wallet: walletCfg}
+	t.Cleanup(func() { config.AppConfig = previous })
+}
+
+func TestWatchWalletRequiresXpubsAndNeverGeneratesKeys(t *testing.T) {
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+	depositAddressRepo := repository.NewDepositAddressRepository(db)
+	// A vault is configured, so only watch mode stops random addresses
+	keyVault, err := keyvault.NewVault(map[uint32][]byte{1: bytes.Repeat([]byte{7}, 32)}, 1)
+	if err != nil {
+		t.Fatalf("failed to create key vault: %v", err)
+	}
+
+	newWalletService := func() (*WalletService, error) {
+		return NewWalletService(
+			repository.NewUserRepository(db), repository.NewChainRepository(db), repository.NewAssetRepository(db),
+			depositAddressRepo, repository.NewWithdrawRequestRepository(db),
+			nil, keyVault, nil, nil,
+		)
+	}
+
+	for _, xpubs := range []string{"", "0:not-an-xpub", "xpub-without-account"} {
+		setWalletConfig(t, config.WalletConfig{WalletType: "watch", AccountXpubs: xpubs})
+		if _, err := newWalletService(); err == nil {
+			t.Fatalf("watch wallet started with HD_ACCOUNT_XPUBS %q", xpubs)
+		}
+	}
+
+	mnemonic, err := wallet.GenerateMnemonic()
+	if err != nil {
+		t.Fatalf("failed to generate mnemonic: %v", err)
+	}
+	hdWallet, err := wallet.NewHDWalletService(mnemonic)
+	if err != nil {
+		t.Fatalf("failed to create HD wallet: %v", err)
+	}
+	family, err := wallet.LookupFamily("evm")
+	if err != nil {
+		t.Fatalf("failed to look up family: %v", err)
+	}
+	account, err := wallet.DepositAccount(chain.ID)
+	if err != nil {
+		t.Fatalf("failed to get deposit account: %v", err)
+	}
+	xpub, err := hdWallet.AccountXpub(family, account)
+	if err != nil {
+		t.Fatalf("failed to get account xpub: %v", err)
+	}
+
+	setWalletConfig(t, config.WalletConfig{WalletType: "watch", AccountXpubs: fmt.Sprintf("%d:%s", account, xpub)})
+	walletService, err := newWalletService()
+	if err != nil {
+		t.Fatalf("failed to create watch wallet: %v", err)
+	}
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	deposit, err := walletService.GetOrCreateDepositAddress(user.ID, chain.ChainKey, asset.Symbol)
+	if err != nil {
+		t.Fatalf("failed to derive deposit address: %v", err)
+	}
+	want, _, err := hdWallet.DeriveDepositAddress(family, chain.ID, asset.ID, 0)
+	if err != nil {
+		t.Fatalf("failed to derive expected address: %v", err)
+	}
+	if deposit.Address != want {
+		t.Fatalf("got deposit address %s, want %s", deposit.Address, want)
+	}
+
+	// A chain whose account is not watched gets no address rather than a
+	// random one with a key this service would have to hold
+	unwatched := &model.Chain{ChainKey: "unwatched", NetworkID: 5, Name: "Unwatched", Enabled: true}
+	if err := db.Create(unwatched).Error; err != nil {
+		t.Fatalf("failed to seed chain: %v", err)
+	}
+	if _, err := walletService.GetOrCreateDepositAddress(user.ID, unwatched.ChainKey, asset.Symbol); err == nil {
+		t.Fatalf("got a deposit address on a chain without a watched account")
+	}
+	var keyed int64
+	if err := db.Model(&model.DepositAddress{}).Where("private_key_ref IS NOT NULL OR chain_id = ?", unwatched.ID).Count(&keyed).Error; err != nil {
+		t.Fatalf("failed to count deposit addresses: %v", err)
+	}
+	if keyed != 0 {
+		t.Fatalf("got %d generated deposit addresses in watch mode", keyed)
+	}
+
+	// Even without a deriver, watch mode never falls back to random keys
+	walletService.hdWallet = nil
+	if err := walletService.createDepositAddress(&model.DepositAddress{UserID: user.ID, ChainID: unwatched.ID, AssetID: asset.ID}, family, unwatched.ChainKey, asset.Symbol); err == nil {
+		t.Fatalf("watch wallet generated a random deposit address")
+	}
+}
+
+func TestHDDepositAddressesPerChainAndAssetAndLegacyVerification(t *testing.T) {
+	db := newTestDB(t)
+	chain, eth := seedNativeChain(t, db)
+	other := &model.Chain{ChainKey: "other", NetworkID: 10, Name: "Other", Enabled: true}
+	usdt := &model.Asset{Symbol: "USDT", Name: "Tether", Decimals: 6, AssetType: "stable", Enabled: true}
+	user := &model.User{}
+	for _, record := range []interface{}{other, usdt, user} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed %T: %v", record, err)
+		}
+	}
+
+	mnemonic, err := wallet.GenerateMnemonic()
+	if err != nil {
+		t.Fatalf("failed to generate mnemonic: %v", err)
+	}
+	setWalletConfig(t, config.WalletConfig{WalletType: "hd", HDMnemonic: mnemonic})
+	depositAddressRepo := repository.NewDepositAddressRepository(db)
+	walletService, err := NewWalletService(
+		repository.NewUserRepository(db), repository.NewChainRepository(db), repository.NewAssetRepository(db),
+		depositAddressRepo, repository.NewWithdrawRequestRepository(db),
+		nil, nil, nil, nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to create wallet service: %v", err)
+	}
+
+	// One user gets a distinct address for every chain and asset
+	seen := make(map[string]string)
+	for _, pair := range []struct{ chain, asset string }{
+		{chain.ChainKey, eth.Symbol},
+		{chain.ChainKey, usdt.Symbol},
+		{other.ChainKey, eth.Symbol},
+	} {
+		deposit, err := walletService.GetOrCreateDepositAddress(user.ID, pair.chain, pair.asset)
+		if err != nil {
+			t.Fatalf("failed to create %s %s address: %v", pair.chain, pair.asset, err)
+		}
+		key := pair.chain + "/" + pair.asset
+		if previous, ok := seen[deposit.Address]; ok {
+			t.Fatalf("%s got the address of %s: %s", key, previous, deposit.Address)
+		}
+		seen[deposit.Address] = key
+	}
+
+	// An address from before per-chain derivation keeps its path
+	hdWallet, err := wallet.NewHDWalletService(mnemonic)
+	if err != nil {
+		t.Fatalf("failed to create HD wallet: %v", err)
+	}
+	legacyUser := &model.User{}
+	if err := db.Create(legacyUser).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	legacyPath := fmt.Sprintf("m/44'/60'/0'/0/%d", legacyUser.ID)
+	legacyAddress, err := hdWallet.DeriveAddress(wallet.EVM, legacyPath)
+	if err != nil {
+		t.Fatalf("failed to derive legacy address: %v", err)
+	}
+	legacy := &model.DepositAddress{UserID: legacyUser.ID, ChainID: chain.ID, AssetID: eth.ID, Address: legacyAddress, DerivationPath: &legacyPath}
+	if err := db.Create(legacy).Error; err != nil {
+		t.Fatalf("failed to seed legacy address: %v", err)
+	}
+
+	if checked, err := walletService.VerifyDepositAddresses(); err != nil || checked != len(seen)+1 {
+		t.Fatalf("verified %d addresses (%v), want %d", checked, err, len(seen)+1)
+	}
+
+	// A stored address the mnemonic does not derive fails verification
+	wrongPath := fmt.Sprintf("m/44'/60'/0'/0/%d", legacyUser.ID+1)
+	if err := db.Model(legacy).Update("derivation_path", wrongPath).Error; err != nil {
+		t.Fatalf("failed to change derivation path: %v", err)
+	}
+	if _, err := walletService.VerifyDepositAddresses(); err == nil {
+		t.Fatalf("address verified against the wrong derivation path")
+	}
+}
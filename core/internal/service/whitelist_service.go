@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+type WhitelistService struct {
+	whitelistRepo *repository.WithdrawalWhitelistRepository
+	chainRepo     *repository.ChainRepository
+	userRepo      *repository.UserRepository
+	coolingOff    time.Duration
+}
+
+func NewWhitelistService(
+	whitelistRepo *repository.WithdrawalWhitelistRepository,
+	chainRepo *repository.ChainRepository,
+	userRepo *repository.UserRepository,
+	coolingOffHours int,
+) *WhitelistService {
+	return &WhitelistService{
+		whitelistRepo: whitelistRepo,
+		chainRepo:     chainRepo,
+		userRepo:      userRepo,
+		coolingOff:    time.Duration(coolingOffHours) * time.Hour,
+	}
+}
+
+type WhitelistEntryResponse struct {
+	ID          uint64    `json:"id"`
+	Chain       string    `json:"chain"`
+	Address     string    `json:"address"`
+	Label       *string   `json:"label"`
+	ActivatesAt time.Time `json:"activatesAt"`
+	Active      bool      `json:"active"` // false while still cooling off
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type WhitelistResponse struct {
+	WhitelistOnly  bool                     `json:"whitelistOnly"`
+	WhitelistOffAt *time.Time               `json:"whitelistOffAt,omitempty"` // set while turning it off is cooling off
+	Entries        []WhitelistEntryResponse `json:"entries"`
+}
+
+func (s *WhitelistService) GetWhitelist(userID uint64) (*WhitelistResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %v", err)
+	}
+
+	entries, err := s.whitelistRepo.FindActiveByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load whitelist: %v", err)
+	}
+
+	response := &WhitelistResponse{
+		WhitelistOnly: user.WhitelistOnlyAt(time.Now()),
+		Entries:       make([]WhitelistEntryResponse, 0, len(entries)),
+	}
+	if response.WhitelistOnly {
+		response.WhitelistOffAt = user.WhitelistOffAt
+	}
+	for i := range entries {
+		response.Entries = append(response.Entries, toWhitelistEntryResponse(&entries[i]))
+	}
+	return response, nil
+}
+
+// AddAddress whitelists a destination address. It only becomes usable once
+// the cooling-off period has passed, including when a removed address is re-added.
+func (s *WhitelistService) AddAddress(userID uint64, chainKey, address string, label *string) (*WhitelistEntryResponse, error) {
+	chain, err := s.chainRepo.FindByChainKey(chainKey)
+	if err != nil {
+		return nil, fmt.Errorf("chain not found: %v", err)
+	}
+
+	family, err := chainFamily(chain)
+	if err != nil {
+		return nil, err
+	}
+	address, err = family.FormatAddress(strings.TrimSpace(address))
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %v", err)
+	}
+
+	activatesAt := time.Now().Add(s.coolingOff)
+
+	entry, err := s.whitelistRepo.FindByAddress(userID, chain.ID, address)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to look up whitelist: %v", err)
+	}
+
+	if entry != nil {
+		if entry.IsActive {
+			return nil, fmt.Errorf("address is already whitelisted")
+		}
+		entry.IsActive = true
+		entry.Label = label
+		entry.ActivatesAt = activatesAt
+		if err := s.whitelistRepo.Update(entry); err != nil {
+			return nil, fmt.Errorf("failed to whitelist address: %v", err)
+		}
+	} else {
+		entry = &model.WithdrawalWhitelist{
+			UserID:      userID,
+			ChainID:     chain.ID,
+			Address:     address,
+			Label:       label,
+			IsActive:    true,
+			ActivatesAt: activatesAt,
+		}
+		if err := s.whitelistRepo.Create(entry); err != nil {
+			return nil, fmt.Errorf("failed to whitelist address: %v", err)
+		}
+	}
+
+	entry.Chain = *chain
+	response := toWhitelistEntryResponse(entry)
+	return &response, nil
+}
+
+func (s *WhitelistService) UpdateLabel(userID, id uint64, label *string) (*WhitelistEntryResponse, error) {
+	entry, err := s.findOwnedEntry(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Label = label
+	if err := s.whitelistRepo.Update(entry); err != nil {
+		return nil, fmt.Errorf("failed to update label: %v", err)
+	}
+
+	response := toWhitelistEntryResponse(entry)
+	return &response, nil
+}
+
+func (s *WhitelistService) RemoveAddress(userID, id uint64) error {
+	entry, err := s.findOwnedEntry(userID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.whitelistRepo.Deactivate(entry.ID); err != nil {
+		return fmt.Errorf("failed to remove address: %v", err)
+	}
+	return nil
+}
+
+// SetWhitelistOnly turns whitelist-only mode on or off for the user. Turning
+// it on applies at once and cancels a pending switch-off. Turning it off only
+// applies after the cooling-off period, like adding an address, so a stolen
+// session cannot lift the restriction and withdraw right away. It returns
+// when the mode goes off, nil if it stays on or is already off.
+func (s *WhitelistService) SetWhitelistOnly(userID uint64, enabled bool) (*time.Time, error) {
+	if enabled {
+		if err := s.userRepo.UpdateWhitelistMode(userID, true, nil); err != nil {
+			return nil, fmt.Errorf("failed to update whitelist mode: %v", err)
+		}
+		return nil, nil
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found: %v", err)
+	}
+	now := time.Now()
+	if !user.WhitelistOnlyAt(now) {
+		return nil, nil
+	}
+	if user.WhitelistOffAt != nil {
+		// Already switching off, asking again does not restart the clock
+		return user.WhitelistOffAt, nil
+	}
+
+	offAt := now.Add(s.coolingOff)
+	if err := s.userRepo.UpdateWhitelistMode(userID, true, &offAt); err != nil {
+		return nil, fmt.Errorf("failed to update whitelist mode: %v", err)
+	}
+	return &offAt, nil
+}
+
+func (s *WhitelistService) findOwnedEntry(userID, id uint64) (*model.WithdrawalWhitelist, error) {
+	entry, err := s.whitelistRepo.FindByID(id)
+	if err != nil || entry.UserID != userID || !entry.IsActive {
+		return nil, fmt.Errorf("whitelist entry not found")
+	}
+	return entry, nil
+}
+
+func toWhitelistEntryResponse(entry *model.WithdrawalWhitelist) WhitelistEntryResponse {
+	return WhitelistEntryResponse{
+		ID:          entry.ID,
+		Chain:       entry.Chain.ChainKey,
+		Address:     entry.Address,
+		Label:       entry.Label,
+		ActivatesAt: entry.ActivatesAt,
+		Active:      !time.Now().Before(entry.ActivatesAt),
+		CreatedAt:   entry.CreatedAt,
+	}
+}
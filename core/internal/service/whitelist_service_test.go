@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+func TestSetWhitelistOnlyCoolsOffBeforeTurningOff(t *testing.T) {
+	db := newTestDB(t)
+	userRepo := repository.NewUserRepository(db)
+	whitelistService := NewWhitelistService(
+		repository.NewWithdrawalWhitelistRepository(db),
+		repository.NewChainRepository(db),
+		userRepo,
+		24,
+	)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	mode := func() *WhitelistResponse {
+		t.Helper()
+		whitelist, err := whitelistService.GetWhitelist(user.ID)
+		if err != nil {
+			t.Fatalf("failed to load whitelist: %v", err)
+		}
+		return whitelist
+	}
+
+	// Turning the mode on applies at once
+	if offAt, err := whitelistService.SetWhitelistOnly(user.ID, true); err != nil || offAt != nil {
+		t.Fatalf("enable: got %v, %v", offAt, err)
+	}
+	if !mode().WhitelistOnly {
+		t.Fatalf("whitelist-only mode not on after enabling")
+	}
+
+	// Turning it off waits for the cooling-off period
+	before := time.Now()
+	offAt, err := whitelistService.SetWhitelistOnly(user.ID, false)
+	if err != nil {
+		t.Fatalf("disable failed: %v", err)
+	}
+	if offAt == nil || offAt.Before(before.Add(24*time.Hour)) {
+		t.Fatalf("got switch-off at %v, want 24h from now", offAt)
+	}
+	if got := mode(); !got.WhitelistOnly || got.WhitelistOffAt == nil {
+		t.Fatalf("got %+v right after disabling, want still on with a switch-off time", got)
+	}
+
+	// Asking again does not push the switch-off back
+	again, err := whitelistService.SetWhitelistOnly(user.ID, false)
+	if err != nil || again == nil || !again.Equal(*offAt) {
+		t.Fatalf("repeated disable: got %v, %v, want %v", again, err, offAt)
+	}
+
+	stored, err := userRepo.FindByID(user.ID)
+	if err != nil {
+		t.Fatalf("failed to reload user: %v", err)
+	}
+	if !stored.WhitelistOnlyAt(offAt.Add(-time.Minute)) || stored.WhitelistOnlyAt(offAt.Add(time.Minute)) {
+		t.Fatalf("whitelist-only mode does not end at %v", offAt)
+	}
+
+	// Re-enabling cancels the pending switch-off
+	if _, err := whitelistService.SetWhitelistOnly(user.ID, true); err != nil {
+		t.Fatalf("re-enable failed: %v", err)
+	}
+	if got := mode(); !got.WhitelistOnly || got.WhitelistOffAt != nil {
+		t.Fatalf("got %+v after re-enabling, want on without a switch-off time", got)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/wallet"
+)
+
+const destinationCodeTimeout = 5 * time.Second
+
+// delegationPrefix starts the code of an EOA delegating to a contract
+// (EIP-7702). Such an account keeps its key and can receive withdrawals.
+var delegationPrefix = []byte{0xef, 0x01, 0x00}
+
+// Codes of rejected withdrawal destinations
+const (
+	AddressErrInvalid         = "invalid_address"
+	AddressErrChecksum        = "invalid_address_checksum"
+	AddressErrDepositAddress  = "deposit_address_destination"
+	AddressErrContractAddress = "contract_address_destination"
+)
+
+// AddressError is a withdrawal destination the platform does not send to.
+// Code tells the client why, so it can point the user at the problem.
+type AddressError struct {
+	Code    string
+	Message string
+}
+
+func (e *AddressError) Error() string {
+	return e.Message
+}
+
+// WithdrawAddressValidator checks withdrawal destinations with the rules of
+// the chain's family: the address must be well formed with a valid checksum,
+// must not be one of our deposit addresses, and must not be a contract. Known
+// contracts (asset tokens, USDK, ProofRegistry) are rejected on every chain;
+// other EVM contracts are detected with CodeAt where the chain has a client.
+type WithdrawAddressValidator struct {
+	depositAddressRepo *repository.DepositAddressRepository
+	chainAssetRepo     *repository.ChainAssetRepository
+	clients            map[uint64]ChainClient // keyed by chains.id
+}
+
+func NewWithdrawAddressValidator(
+	depositAddressRepo *repository.DepositAddressRepository,
+	chainAssetRepo *repository.ChainAssetRepository,
+	clients map[uint64]ChainClient,
+) *WithdrawAddressValidator {
+	return &WithdrawAddressValidator{
+		depositAddressRepo: depositAddressRepo,
+		chainAssetRepo:     chainAssetRepo,
+		clients:            clients,
+	}
+}
+
+// Validate returns the canonical form of a destination on chain. A rejected
+// destination returns an *AddressError; any other error means the checks
+// could not be completed and the withdrawal must not go ahead.
+func (v *WithdrawAddressValidator) Validate(ctx context.Context, chain *model.Chain, address string) (string, error) {
+	family, err := chainFamily(chain)
+	if err != nil {
+		return "", err
+	}
+
+	canonical, err := family.FormatAddress(strings.TrimSpace(address))
+	if errors.Is(err, wallet.ErrInvalidChecksum) {
+		return "", &AddressError{
+			Code:    AddressErrChecksum,
+			Message: fmt.Sprintf("destination address checksum does not match, check the address for typos: %s", address),
+		}
+	}
+	if err != nil {
+		return "", &AddressError{
+			Code:    AddressErrInvalid,
+			Message: fmt.Sprintf("destination is not a valid %s address: %s", chain.Name, address),
+		}
+	}
+
+	// Deposit addresses of any chain belong to us; EVM ones are the same
+	// account on every EVM chain
+	_, err = v.depositAddressRepo.FindAnyByAddress(canonical)
+	if err == nil {
+		return "", &AddressError{
+			Code:    AddressErrDepositAddress,
+			Message: "destination is a platform deposit address, withdrawals to deposit addresses are not allowed",
+		}
+	}
+	if err != gorm.ErrRecordNotFound {
+		return "", fmt.Errorf("failed to look up deposit addresses: %v", err)
+	}
+
+	isContract, err := v.isKnownContract(chain, family, canonical)
+	if err != nil {
+		return "", err
+	}
+	if !isContract && family == wallet.EVM {
+		isContract, err = v.hasCode(ctx, chain, canonical)
+		if err != nil {
+			return "", err
+		}
+	}
+	if isContract {
+		return "", &AddressError{
+			Code:    AddressErrContractAddress,
+			Message: "destination is a contract address, withdrawals to contracts are not allowed",
+		}
+	}
+
+	return canonical, nil
+}
+
+// isKnownContract reports whether address is a contract configured for chain
+func (v *WithdrawAddressValidator) isKnownContract(chain *model.Chain, family wallet.ChainFamily, address string) (bool, error) {
+	contracts := []*string{chain.UsdkContract, chain.ProofRegistry}
+
+	chainAssets, err := v.chainAssetRepo.FindByChainIDWithAsset(chain.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load chain assets: %v", err)
+	}
+	for i := range chainAssets {
+		contracts = append(contracts, chainAssets[i].ContractAddress)
+	}
+
+	for _, contract := range contracts {
+		if contract == nil || *contract == "" {
+			continue
+		}
+		formatted, err := family.FormatAddress(*contract)
+		if err == nil && formatted == address {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasCode reports whether an EVM address has contract code. Chains without a
+// client cannot be checked and only get the known contract check.
+func (v *WithdrawAddressValidator) hasCode(ctx context.Context, chain *model.Chain, address string) (bool, error) {
+	client, ok := v.clients[chain.ID]
+	if !ok {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, destinationCodeTimeout)
+	defer cancel()
+	code, err := client.CodeAt(ctx, common.HexToAddress(address), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check destination on %s: %v", chain.ChainKey, err)
+	}
+	if len(code) == len(delegationPrefix)+common.AddressLength && bytes.HasPrefix(code, delegationPrefix) {
+		return false, nil
+	}
+	return len(code) > 0, nil
+}
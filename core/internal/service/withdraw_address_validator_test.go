@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+)
+
+func TestWithdrawAddressValidator(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+
+	// An EOA delegating to a contract (EIP-7702) carries 0xef0100 || target
+	// as its code but keeps its key
+	deployerKey, _ := crypto.GenerateKey()
+	delegated := common.HexToAddress("0x00000000000000000000000000000000000070a2")
+	delegationCode := append(append([]byte{}, delegationPrefix...), common.HexToAddress("0x7702").Bytes()...)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(deployerKey.PublicKey): {Balance: new(big.Int).Mul(big.NewInt(10), big.NewInt(params.Ether))},
+		delegated: {Balance: big.NewInt(1), Code: delegationCode},
+	}, 10_000_000)
+	t.Cleanup(func() { sim.Close() })
+	contract := deployProofRegistry(t, sim, deployerKey)
+
+	// A configured contract is rejected without asking the chain
+	usdk := "0x00000000000000000000000000000000000005d4"
+	chain.UsdkContract = &usdk
+	if err := db.Save(chain).Error; err != nil {
+		t.Fatalf("failed to configure USDK contract: %v", err)
+	}
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	deposit := &model.DepositAddress{UserID: user.ID, ChainID: chain.ID, AssetID: asset.ID, Address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}
+	if err := db.Create(deposit).Error; err != nil {
+		t.Fatalf("failed to seed deposit address: %v", err)
+	}
+
+	validator := NewWithdrawAddressValidator(
+		repository.NewDepositAddressRepository(db),
+		repository.NewChainAssetRepository(db),
+		map[uint64]ChainClient{chain.ID: sim},
+	)
+
+	tests := []struct {
+		name    string
+		address string
+		want    string // canonical form of an accepted address
+		code    string // AddressError code of a rejected address
+	}{
+		{name: "EOA in lower case", address: "0xfb6916095ca1df60bb79ce92ce3ea74c37c5d359", want: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"},
+		{name: "EIP-7702 delegated EOA", address: delegated.Hex(), want: delegated.Hex()},
+		{name: "not an address", address: "0x1234", code: AddressErrInvalid},
+		{name: "Bitcoin address on an EVM chain", address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", code: AddressErrInvalid},
+		{name: "bad EIP-55 checksum", address: "0xFb6916095ca1df60bB79Ce92cE3Ea74c37c5d359", code: AddressErrChecksum},
+		{name: "deposit address", address: strings.ToLower(deposit.Address), code: AddressErrDepositAddress},
+		{name: "deployed contract", address: contract.Hex(), code: AddressErrContractAddress},
+		{name: "configured contract", address: usdk, code: AddressErrContractAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.Validate(ctx, chain, tt.address)
+			if tt.code == "" {
+				if err != nil || got != tt.want {
+					t.Fatalf("got %s (%v), want %s", got, err, tt.want)
+				}
+				return
+			}
+
+			var addressErr *AddressError
+			if !errors.As(err, &addressErr) {
+				t.Fatalf("got %s (%v), want an AddressError", got, err)
+			}
+			if addressErr.Code != tt.code {
+				t.Fatalf("got code %s, want %s", addressErr.Code, tt.code)
+			}
+		})
+	}
+
+	// A contract on a chain without a client can only be caught when it is
+	// configured, so the check does not fail the withdrawal
+	unchecked := NewWithdrawAddressValidator(
+		repository.NewDepositAddressRepository(db),
+		repository.NewChainAssetRepository(db),
+		map[uint64]ChainClient{},
+	)
+	if got, err := unchecked.Validate(ctx, chain, contract.Hex()); err != nil || got != contract.Hex() {
+		t.Fatalf("got %s (%v) without a client, want the contract address accepted", got, err)
+	}
+}
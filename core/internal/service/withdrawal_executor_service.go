@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/contracts"
+)
+
+// unrecordedGrace is how long a claimed request may go without a tx hash
+// before recoverUnrecorded treats its worker as gone
+const unrecordedGrace = 5 * time.Minute
+
+// WithdrawalExecutorService broadcasts risk-approved withdrawal requests from
+// the hot wallet and settles them once the transfer is confirmed.
+//
+// A request moves pending/approved → processing when claimed, holding its
+// KUSD value from the user's balance, gets its TxHash once handed to the
+// TxManagerService, and ends completed (with withdraw and fee ledger entries
+// posted) or failed. A request the balance does not cover is failed when
+// claimed. TxHash follows the manager when it replaces a stuck transfer.
+type WithdrawalExecutorService struct {
+	withdrawRequestRepo *repository.WithdrawRequestRepository
+	chainAssetRepo      *repository.ChainAssetRepository
+	onchainTxRepo       *repository.OnchainTxRepository
+	clients             map[uint64]ChainClient // keyed by chains.id
+	txManager           *TxManagerService
+	hotWallet           common.Address
+	valuer              AssetValuer
+	feeRate             decimal.Decimal
+	confirmationBlocks  int
+	interval            time.Duration
+	logger              *logrus.Logger
+}
+
+func NewWithdrawalExecutorService(
+	withdrawRequestRepo *repository.WithdrawRequestRepository,
+	chainAssetRepo *repository.ChainAssetRepository,
+	onchainTxRepo *repository.OnchainTxRepository,
+	clients map[uint64]ChainClient,
+	txManager *TxManagerService,
+	hotWallet common.Address,
+	valuer AssetValuer,
+	platformCfg config.PlatformConfig,
+	logger *logrus.Logger,
+) *WithdrawalExecutorService {
+	return &WithdrawalExecutorService{
+		withdrawRequestRepo: withdrawRequestRepo,
+		chainAssetRepo:      chainAssetRepo,
+		onchainTxRepo:       onchainTxRepo,
+		clients:             clients,
+		txManager:           txManager,
+		hotWallet:           hotWallet,
+		valuer:              valuer,
+		feeRate:             decimal.NewFromFloat(platformCfg.WithdrawalFeeRate),
+		confirmationBlocks:  platformCfg.ConfirmationBlocks,
+		interval:            time.Duration(platformCfg.WithdrawalProcessIntervalSec) * time.Second,
+		logger:              logger,
+	}
+}
+
+// Run processes withdrawals every interval until ctx is cancelled
+func (s *WithdrawalExecutorService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.ProcessOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessOnce recovers withdrawals whose broadcast was not recorded, settles
+// already broadcast ones, then broadcasts new ones
+func (s *WithdrawalExecutorService) ProcessOnce(ctx context.Context) {
+	s.recoverUnrecorded()
+	s.trackBroadcast(ctx)
+
+	pending, err := s.withdrawRequestRepo.FindPendingRequests()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load pending withdrawals")
+		return
+	}
+	approved, err := s.withdrawRequestRepo.FindApprovedRequests()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load approved withdrawals")
+		return
+	}
+
+	for i := range pending {
+		s.execute(ctx, &pending[i], "pending")
+	}
+	for i := range approved {
+		s.execute(ctx, &approved[i], "approved")
+	}
+}
+
+func (s *WithdrawalExecutorService) execute(ctx context.Context, req *model.WithdrawRequest, fromStatus string) {
+	logger := s.logger.WithFields(logrus.Fields{
+		"withdraw_id": req.ID,
+		"user_id":     req.UserID,
+		"chain":       req.Chain.ChainKey,
+		"asset":       req.Asset.Symbol,
+	})
+
+	if _, ok := s.clients[req.ChainID]; !ok {
+		logger.Warn("No RPC client for withdrawal chain, leaving request queued")
+		return
+	}
+
+	// The gross amount, fee included, is held from the user's balance
+	kusdAmount, err := s.valuer.GetUSDValue(req.Asset.Symbol, req.Amount)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to value withdrawal, leaving request queued")
+		return
+	}
+
+	claimed, err := s.withdrawRequestRepo.ClaimForProcessing(req.ID, fromStatus, kusdAmount)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		logger.WithField("kusd_amount", kusdAmount.String()).Warn("Withdrawal failed: insufficient balance")
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to claim withdrawal")
+		return
+	}
+	if !claimed {
+		return
+	}
+	req.Status = "processing"
+	req.KusdReserved = kusdAmount
+
+	chainAsset, err := s.chainAssetRepo.FindByChainAndAsset(req.ChainID, req.AssetID)
+	if err != nil {
+		s.fail(logger, req, fmt.Sprintf("asset not enabled on chain: %v", err))
+		return
+	}
+
+	fee, netAmount := s.splitFee(req)
+	if netAmount.LessThanOrEqual(decimal.Zero) {
+		s.fail(logger, req, "amount does not cover the withdrawal fee")
+		return
+	}
+
+	rawAmount := netAmount.Shift(int32(req.Asset.Decimals)).BigInt()
+	to := common.HexToAddress(req.ToAddress)
+
+	tx, err := s.send(ctx, req, chainAsset, to, rawAmount)
+	if err != nil {
+		s.fail(logger, req, fmt.Sprintf("broadcast failed: %v", err))
+		return
+	}
+
+	if err := s.recordBroadcast(req, tx, fee, netAmount); err != nil {
+		// The transfer is already owned by the tx manager; never re-send it.
+		// recoverUnrecorded picks the request up again on the next pass.
+		logger.WithError(err).WithField("tx_hash", tx.TxHash).Error("Withdrawal broadcast but not recorded")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"tx_hash": tx.TxHash,
+		"amount":  netAmount.String(),
+		"fee":     fee.String(),
+	}).Info("Withdrawal broadcast")
+}
+
+// splitFee returns the withdrawal fee and the amount left to send
+func (s *WithdrawalExecutorService) splitFee(req *model.WithdrawRequest) (decimal.Decimal, decimal.Decimal) {
+	fee := req.Amount.Mul(s.feeRate).Round(int32(req.Asset.Decimals))
+	return fee, req.Amount.Sub(fee)
+}
+
+// recordBroadcast stores the hash and fee of the transaction sent for a
+// request along with its outgoing onchain_txs row
+func (s *WithdrawalExecutorService) recordBroadcast(req *model.WithdrawRequest, tx *model.OutgoingTx, fee, netAmount decimal.Decimal) error {
+	txHash := tx.TxHash
+	from := common.HexToAddress(tx.FromAddr).Hex()
+	req.TxHash = &txHash
+	req.Fee = fee
+
+	userID := req.UserID
+	onchainTx := &model.OnchainTx{
+		Direction: "out",
+		UserID:    &userID,
+		ChainID:   req.ChainID,
+		AssetID:   req.AssetID,
+		FromAddr:  &from,
+		ToAddr:    common.HexToAddress(req.ToAddress).Hex(),
+		TxHash:    txHash,
+		LogIndex:  -1,
+		Amount:    netAmount,
+		Status:    "pending",
+		SeenAt:    time.Now(),
+	}
+
+	if err := s.withdrawRequestRepo.MarkBroadcast(req, onchainTx); err != nil {
+		req.TxHash = nil
+		return err
+	}
+	return nil
+}
+
+// recoverUnrecorded settles requests left processing without a tx hash for
+// longer than unrecordedGrace, because recording the broadcast failed or the
+// executor stopped between claiming a request and recording it. A request the tx manager holds a
+// transaction for is recorded with that transaction. One the manager never
+// received was not sent, since Send records before it broadcasts, and is
+// failed to release its balance.
+func (s *WithdrawalExecutorService) recoverUnrecorded() {
+	requests, err := s.withdrawRequestRepo.FindUnrecordedRequests(time.Now().Add(-unrecordedGrace))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load unrecorded withdrawals")
+		return
+	}
+
+	for i := range requests {
+		req := &requests[i]
+		logger := s.logger.WithFields(logrus.Fields{
+			"withdraw_id": req.ID,
+			"user_id":     req.UserID,
+		})
+
+		tx, err := s.txManager.LatestTx("withdrawal", req.ID)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to look up withdrawal transaction")
+			continue
+		}
+		if tx == nil {
+			s.fail(logger, req, "interrupted before broadcast, nothing was sent")
+			continue
+		}
+
+		fee, netAmount := s.splitFee(req)
+		if err := s.recordBroadcast(req, tx, fee, netAmount); err != nil {
+			logger.WithError(err).WithField("tx_hash", tx.TxHash).Error("Failed to record recovered withdrawal broadcast")
+			continue
+		}
+		logger.WithField("tx_hash", tx.TxHash).Warn("Recovered unrecorded withdrawal broadcast")
+	}
+}
+
+// send hands either a native value transfer or an ERC-20 transfer to the
+// tx manager
+func (s *WithdrawalExecutorService) send(ctx context.Context, req *model.WithdrawRequest, chainAsset *model.ChainAsset, to common.Address, amount *big.Int) (*model.OutgoingTx, error) {
+	refID := req.ID
+	txReq := TxRequest{Purpose: "withdrawal", RefID: &refID}
+
+	if chainAsset.ContractAddress == nil || common.HexToAddress(*chainAsset.ContractAddress) == (common.Address{}) {
+		txReq.To = to
+		txReq.Value = amount
+	} else {
+		erc20ABI, err := contracts.ERC20ContractMetaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		txReq.To = common.HexToAddress(*chainAsset.ContractAddress)
+		txReq.Data, err = erc20ABI.Pack("transfer", to, amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack transfer: %v", err)
+		}
+	}
+
+	return s.txManager.Send(ctx, &req.Chain, s.hotWallet, txReq)
+}
+
+// trackBroadcast follows receipts of broadcast withdrawals and settles them
+// once they reach the confirmation depth.
+func (s *WithdrawalExecutorService) trackBroadcast(ctx context.Context) {
+	requests, err := s.withdrawRequestRepo.FindBroadcastRequests()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load broadcast withdrawals")
+		return
+	}
+
+	for i := range requests {
+		req := &requests[i]
+		logger := s.logger.WithFields(logrus.Fields{
+			"withdraw_id": req.ID,
+			"tx_hash":     *req.TxHash,
+		})
+
+		client, ok := s.clients[req.ChainID]
+		if !ok {
+			continue
+		}
+
+		if err := s.settle(ctx, client, req); err != nil {
+			logger.WithError(err).Warn("Failed to settle withdrawal")
+		}
+	}
+}
+
+func (s *WithdrawalExecutorService) settle(ctx context.Context, client ChainClient, req *model.WithdrawRequest) error {
+	receipt, err := s.txManager.Receipt(ctx, client, "withdrawal", req.ID, *req.TxHash)
+	if errors.Is(err, ErrTxDropped) {
+		s.fail(s.logger.WithField("withdraw_id", req.ID), req, "transaction dropped: nonce used by another transaction")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		// Not mined yet
+		return nil
+	}
+
+	if minedHash := receipt.TxHash.Hex(); minedHash != *req.TxHash {
+		// The tx manager replaced the transfer with higher fees
+		if err := s.withdrawRequestRepo.ReplaceTxHash(req, minedHash); err != nil {
+			return fmt.Errorf("failed to record replacement tx hash: %v", err)
+		}
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		s.fail(s.logger.WithField("withdraw_id", req.ID), req, "transaction reverted on-chain")
+		return nil
+	}
+
+	onchainTx, err := s.onchainTxRepo.FindByTxHash(*req.TxHash, "out")
+	if err != nil {
+		return fmt.Errorf("failed to load onchain tx: %v", err)
+	}
+
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %v", err)
+	}
+
+	blockNum := receipt.BlockNumber.Uint64()
+	blockHash := receipt.BlockHash.Hex()
+	gasUsed := receipt.GasUsed
+	onchainTx.BlockNum = &blockNum
+	onchainTx.BlockHash = &blockHash
+	onchainTx.GasUsed = &gasUsed
+	if receipt.EffectiveGasPrice != nil {
+		gasPrice := decimal.NewFromBigInt(receipt.EffectiveGasPrice, 0)
+		onchainTx.GasPrice = &gasPrice
+	}
+	if headNum := head.Number.Uint64(); headNum >= blockNum {
+		onchainTx.Confirmations = int(headNum - blockNum + 1)
+	}
+
+	if err := s.onchainTxRepo.UpdateMined(onchainTx); err != nil {
+		return fmt.Errorf("failed to update onchain tx: %v", err)
+	}
+
+	if onchainTx.Confirmations < s.confirmationBlocks {
+		return nil
+	}
+
+	withdrawEntry, feeEntry, err := s.buildLedgerEntries(req, onchainTx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.withdrawRequestRepo.Complete(req, onchainTx, withdrawEntry, feeEntry); err != nil {
+		return fmt.Errorf("failed to complete withdrawal: %v", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"withdraw_id": req.ID,
+		"user_id":     req.UserID,
+		"tx_hash":     *req.TxHash,
+	}).Info("Withdrawal completed")
+
+	return nil
+}
+
+// buildLedgerEntries returns the negative withdraw entry for the amount sent
+// and a negative fee entry for the withdrawal fee (nil when the fee is zero).
+// Both are posted at the KUSD held when the request was claimed, split in the
+// fee's share of the amount, so the debit always equals the hold it releases.
+func (s *WithdrawalExecutorService) buildLedgerEntries(req *model.WithdrawRequest, onchainTx *model.OnchainTx) (*model.LedgerEntry, *model.LedgerEntry, error) {
+	chainID := req.ChainID
+	assetID := req.AssetID
+	txHash := *req.TxHash
+	onchainTxID := onchainTx.ID
+
+	if !req.Amount.IsPositive() {
+		return nil, nil, fmt.Errorf("withdrawal %d has no amount", req.ID)
+	}
+	feeKusd := req.KusdReserved.Mul(req.Fee).Div(req.Amount).Round(18)
+	sentKusd := req.KusdReserved.Sub(feeKusd)
+
+	withdrawEntry := &model.LedgerEntry{
+		UserID:         req.UserID,
+		EntryType:      "withdraw",
+		ChainID:        &chainID,
+		AssetID:        &assetID,
+		Amount:         onchainTx.Amount.Neg(),
+		KusdDelta:      sentKusd.Neg(),
+		RefTxHash:      &txHash,
+		RefOnchainTxID: &onchainTxID,
+	}
+
+	if req.Fee.IsZero() {
+		return withdrawEntry, nil, nil
+	}
+
+	feeEntry := &model.LedgerEntry{
+		UserID:         req.UserID,
+		EntryType:      "fee",
+		ChainID:        &chainID,
+		AssetID:        &assetID,
+		Amount:         req.Fee.Neg(),
+		KusdDelta:      feeKusd.Neg(),
+		RefTxHash:      &txHash,
+		RefOnchainTxID: &onchainTxID,
+	}
+
+	return withdrawEntry, feeEntry, nil
+}
+
+func (s *WithdrawalExecutorService) fail(logger *logrus.Entry, req *model.WithdrawRequest, reason string) {
+	if err := s.withdrawRequestRepo.MarkFailed(req, reason); err != nil {
+		logger.WithError(err).Error("Failed to mark withdrawal as failed")
+		return
+	}
+	logger.WithField("reason", reason).Warn("Withdrawal failed")
+}
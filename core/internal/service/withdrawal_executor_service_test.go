@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/signer"
+)
+
+func TestWithdrawalExecutorHoldsBalanceAndRecoversUnrecordedBroadcast(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	chain, asset := seedNativeChain(t, db)
+
+	hotKey, _ := crypto.GenerateKey()
+	sim := newSimulatedChain(t, crypto.PubkeyToAddress(hotKey.PublicKey))
+	clients := map[uint64]ChainClient{chain.ID: sim}
+	logger := newTestLogger()
+	platformCfg := config.PlatformConfig{WithdrawalFeeRate: 0.01, ConfirmationBlocks: 1}
+
+	txManager := NewTxManagerService(
+		repository.NewOutgoingTxRepository(db), clients,
+		config.TxConfig{MaxFeeGwei: 100, MaxPriorityFeeGwei: 2, StuckTimeoutSec: 600},
+		platformCfg, logger,
+	)
+	hotWallet := txManager.AddSigner(signer.NewKeySigner(hotKey))
+
+	withdrawRequestRepo := repository.NewWithdrawRequestRepository(db)
+	prices := fixedPriceValuer{"ETH": decimal.NewFromInt(2000)}
+	executor := NewWithdrawalExecutorService(
+		withdrawRequestRepo,
+		repository.NewChainAssetRepository(db),
+		repository.NewOnchainTxRepository(db),
+		clients, txManager, hotWallet, prices,
+		platformCfg, logger,
+	)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	err := db.Create(&model.LedgerEntry{
+		UserID:    user.ID,
+		EntryType: "deposit",
+		Amount:    decimal.NewFromInt(100),
+		KusdDelta: decimal.NewFromInt(100),
+	}).Error
+	if err != nil {
+		t.Fatalf("failed to seed deposit: %v", err)
+	}
+
+	request := func(amount string) *model.WithdrawRequest {
+		t.Helper()
+		req := &model.WithdrawRequest{
+			UserID:    user.ID,
+			ChainID:   chain.ID,
+			AssetID:   asset.ID,
+			Amount:    decimal.RequireFromString(amount),
+			ToAddress: "0x00000000000000000000000000000000000000aa",
+			Status:    "approved",
+		}
+		if err := withdrawRequestRepo.Create(req); err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		return req
+	}
+	reload := func(req *model.WithdrawRequest) *model.WithdrawRequest {
+		t.Helper()
+		reloaded, err := withdrawRequestRepo.FindByID(req.ID)
+		if err != nil {
+			t.Fatalf("failed to reload request: %v", err)
+		}
+		return reloaded
+	}
+
+	// 80 KUSD of the 100 KUSD balance is held by the first request, so the
+	// 30 KUSD second one is not covered any more
+	covered := request("0.04")
+	uncovered := request("0.015")
+	executor.ProcessOnce(ctx)
+
+	sent := reload(covered)
+	if sent.Status != "processing" || sent.TxHash == nil || !sent.KusdReserved.Equal(decimal.NewFromInt(80)) {
+		t.Fatalf("covered request: got status %s, tx hash %v, %s KUSD held", sent.Status, sent.TxHash, sent.KusdReserved)
+	}
+	refused := reload(uncovered)
+	if refused.Status != "failed" || refused.AdminNotes == nil || !strings.Contains(*refused.AdminNotes, "insufficient balance") {
+		t.Fatalf("uncovered request: got status %s with notes %v, want failed for insufficient balance", refused.Status, refused.AdminNotes)
+	}
+	if outgoing, _ := txManager.LatestTx("withdrawal", uncovered.ID); outgoing != nil {
+		t.Fatalf("uncovered request was sent as %s", outgoing.TxHash)
+	}
+
+	available, err := withdrawRequestRepo.AvailableKusdBalance(user.ID)
+	if err != nil {
+		t.Fatalf("failed to load available balance: %v", err)
+	}
+	if !available.Equal(decimal.NewFromInt(20)) {
+		t.Fatalf("got %s KUSD available, want 20", available)
+	}
+
+	// Lose the broadcast record of the sent request, as if the executor had
+	// stopped right after handing it to the tx manager
+	sentHash := *sent.TxHash
+	claimedAt := time.Now().Add(-time.Hour)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tx_hash = ?", sentHash).Delete(&model.OnchainTx{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.WithdrawRequest{}).Where("id = ?", covered.ID).
+			Updates(map[string]interface{}{"tx_hash": nil, "claimed_at": claimedAt}).Error
+	})
+	if err != nil {
+		t.Fatalf("failed to drop broadcast record: %v", err)
+	}
+
+	// A request claimed but never handed to the tx manager, and one whose
+	// worker may still be sending it
+	interrupted := request("0.001")
+	inFlight := request("0.002")
+	err = db.Model(interrupted).Updates(map[string]interface{}{"status": "processing", "claimed_at": claimedAt}).Error
+	if err == nil {
+		err = db.Model(inFlight).Updates(map[string]interface{}{"status": "processing", "claimed_at": time.Now()}).Error
+	}
+	if err != nil {
+		t.Fatalf("failed to claim requests: %v", err)
+	}
+
+	executor.ProcessOnce(ctx)
+
+	recovered := reload(covered)
+	if recovered.TxHash == nil || *recovered.TxHash != sentHash || recovered.Status != "processing" {
+		t.Fatalf("got status %s with tx hash %v, want processing with %s", recovered.Status, recovered.TxHash, sentHash)
+	}
+	if _, err := repository.NewOnchainTxRepository(db).FindByTxHash(sentHash, "out"); err != nil {
+		t.Fatalf("outgoing onchain tx not recreated: %v", err)
+	}
+	if got := reload(interrupted); got.Status != "failed" {
+		t.Fatalf("got status %s for the interrupted request, want failed", got.Status)
+	}
+	if got := reload(inFlight); got.Status != "processing" {
+		t.Fatalf("got status %s for the request still in its grace period, want processing", got.Status)
+	}
+
+	// Once mined the recovered withdrawal settles at the KUSD it held, even
+	// though ETH has moved since it was claimed
+	prices["ETH"] = decimal.NewFromInt(3000)
+	sim.Commit()
+	txManager.ProcessOnce(ctx)
+	executor.ProcessOnce(ctx)
+
+	if got := reload(covered); got.Status != "completed" || got.LedgerEntryID == nil {
+		t.Fatalf("got status %s, want completed with a ledger entry", got.Status)
+	}
+	balance, err := repository.NewLedgerRepository(db).GetUserKUSDBalance(user.ID)
+	if err != nil {
+		t.Fatalf("failed to load balance: %v", err)
+	}
+	// SQLite sums decimal columns as floats
+	if !balance.Round(8).Equal(decimal.NewFromInt(20)) {
+		t.Fatalf("got balance %s after the 80 KUSD withdrawal, want 20", balance)
+	}
+	var fee model.LedgerEntry
+	if err := db.Where("user_id = ? AND entry_type = ?", user.ID, "fee").First(&fee).Error; err != nil {
+		t.Fatalf("failed to load fee entry: %v", err)
+	}
+	if !fee.KusdDelta.Round(8).Equal(decimal.RequireFromString("-0.8")) {
+		t.Fatalf("got fee entry of %s KUSD, want the 1%% share of the hold, -0.8", fee.KusdDelta)
+	}
+	available, err = withdrawRequestRepo.AvailableKusdBalance(user.ID)
+	if err != nil {
+		t.Fatalf("failed to load available balance: %v", err)
+	}
+	if available.Round(8).IsNegative() {
+		t.Fatalf("got %s KUSD available after settling, want it not negative", available)
+	}
+}
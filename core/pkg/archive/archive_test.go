@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/pkg/contracts"
+	"usdk-backend/pkg/merkle"
+)
+
+func TestComputeCIDMatchesIPFS(t *testing.T) {
+	// `ipfs add --cid-version 1 --raw-leaves` of an empty file
+	if got := ComputeCID(nil); got != "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku" {
+		t.Fatalf("got CID %s for empty data", got)
+	}
+	if !IsCID(ComputeCID([]byte("archive"))) {
+		t.Fatalf("computed CID not recognised")
+	}
+}
+
+func TestLocalStoreDocumentRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	writer, err := NewWriter(store, "archive-salt")
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+
+	chainID, assetID := uint64(1), uint64(2)
+	txHash := "0x9f2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c5d6e7f809"
+	created := time.Unix(1_700_000_000, 0)
+	entries := []model.LedgerEntry{
+		{ID: 12, UserID: 7, EntryType: "withdraw", ChainID: &chainID, AssetID: &assetID, Amount: decimal.NewFromInt(-5), KusdDelta: decimal.NewFromInt(-5), CreatedAt: created},
+		{ID: 10, UserID: 7, EntryType: "deposit", ChainID: &chainID, AssetID: &assetID, RefTxHash: &txHash, Amount: decimal.NewFromInt(20), KusdDelta: decimal.NewFromInt(20), CreatedAt: created},
+		{ID: 11, UserID: 8, EntryType: "deposit", Amount: decimal.RequireFromString("1.5"), KusdDelta: decimal.NewFromInt(3000), CreatedAt: created},
+	}
+	tree, err := merkle.BuildLedgerTree(entries)
+	if err != nil {
+		t.Fatalf("failed to build tree: %v", err)
+	}
+	batch := &model.ProofBatch{
+		BatchType:   contracts.BatchTypeDeposit,
+		PeriodStart: created.Add(-time.Hour),
+		PeriodEnd:   created,
+		MerkleRoot:  tree.Root().Hex(),
+	}
+
+	cid, err := writer.WriteLedgerBatch(batch, entries)
+	if err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	if !IsCID(cid) {
+		t.Fatalf("write returned invalid CID %s", cid)
+	}
+	// Writing the same batch again yields the same document
+	again, err := writer.WriteLedgerBatch(batch, entries)
+	if err != nil || again != cid {
+		t.Fatalf("rewrite got %s, %v, want %s", again, err, cid)
+	}
+
+	doc, err := ReadDocument(store, cid)
+	if err != nil {
+		t.Fatalf("failed to read document: %v", err)
+	}
+	if doc.MerkleRoot != batch.MerkleRoot || doc.EntryCount != 3 || len(doc.Leaves) != 3 {
+		t.Fatalf("got document %+v", doc)
+	}
+	for i, record := range doc.Leaves {
+		if record.Index != i || record.Leaf != tree.Leaves()[i].Hex() {
+			t.Fatalf("leaf %d is %+v, want leaf %s", i, record, tree.Leaves()[i].Hex())
+		}
+	}
+	// Nothing in the document links an entry to a transaction or to the
+	// user's entries in other batches
+	data, err := writer.BuildLedgerDocument(batch, entries)
+	if err != nil {
+		t.Fatalf("failed to build document: %v", err)
+	}
+	for _, linkable := range []string{txHash, `"entryId"`, `"refTxHash"`, `"userId"`} {
+		if strings.Contains(string(data), linkable) {
+			t.Fatalf("archive document contains %s", linkable)
+		}
+	}
+	if doc.Leaves[0].UserTag != doc.Leaves[2].UserTag || doc.Leaves[0].UserTag == doc.Leaves[1].UserTag {
+		t.Fatalf("user tags do not group entries by user: %+v", doc.Leaves)
+	}
+
+	// A document changed on disk no longer matches its CID
+	if err := os.WriteFile(filepath.Join(dir, cid), []byte(`{"version":1}`), 0o644); err != nil {
+		t.Fatalf("failed to tamper with document: %v", err)
+	}
+	if _, err := ReadDocument(store, cid); err == nil || !strings.Contains(err.Error(), "does not match CID") {
+		t.Fatalf("got %v for a tampered document, want a CID mismatch", err)
+	}
+
+	if _, err := ReadDocument(store, ComputeCID([]byte("missing"))); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v for a missing document, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStoreRejectsInvalidCIDs(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	valid := ComputeCID([]byte("archive"))
+	invalid := []string{
+		"",
+		"../../etc/passwd",
+		"QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",              // CIDv0
+		"bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", // dag-pb codec
+		valid[:len(valid)-1],
+		strings.ToUpper(valid),
+		valid + "/..",
+	}
+	for _, cid := range invalid {
+		if IsCID(cid) {
+			t.Errorf("IsCID(%q) = true", cid)
+		}
+		if err := store.Put(cid, []byte("data")); err == nil || !strings.Contains(err.Error(), "invalid CID") {
+			t.Errorf("Put(%q) got %v, want invalid CID", cid, err)
+		}
+		if _, err := store.Get(cid); err == nil || !strings.Contains(err.Error(), "invalid CID") {
+			t.Errorf("Get(%q) got %v, want invalid CID", cid, err)
+		}
+		if _, err := ReadDocument(store, cid); err == nil {
+			t.Errorf("ReadDocument(%q) succeeded", cid)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+)
+
+// CIDv1 prefix bytes: version 1, raw codec, sha2-256 multihash of 32 bytes
+var cidPrefix = []byte{0x01, 0x55, 0x12, 0x20}
+
+var cidEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// ComputeCID returns the CIDv1 (raw codec, sha2-256, base32 "b" multibase) of
+// data. It is the CID IPFS assigns to the same bytes added as a raw block, so
+// an archive document can be pinned there under the URI published on-chain.
+func ComputeCID(data []byte) string {
+	digest := sha256.Sum256(data)
+	return "b" + cidEncoding.EncodeToString(append(append([]byte{}, cidPrefix...), digest[:]...))
+}
+
+// IsCID reports whether s looks like a CID produced by ComputeCID
+func IsCID(s string) bool {
+	if !strings.HasPrefix(s, "b") {
+		return false
+	}
+	raw, err := cidEncoding.DecodeString(s[1:])
+	return err == nil && len(raw) == len(cidPrefix)+sha256.Size && string(raw[:len(cidPrefix)]) == string(cidPrefix)
+}
+
+// URI returns the ipfs:// URI of a CID, as passed to publishBatch
+func URI(cid string) string {
+	return "ipfs://" + cid
+}
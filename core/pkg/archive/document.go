@@ -0,0 +1,145 @@
+package archive
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/pkg/merkle"
+)
+
+// documentVersion is bumped whenever the document layout changes
+const documentVersion = 2
+
+// Document is the archived detail of a ledger proof batch. It is encoded as
+// JSON with a fixed field order and leaves in tree order, so the same batch
+// always yields the same bytes and CID.
+type Document struct {
+	Version     int          `json:"version"`
+	BatchType   string       `json:"batchType"`
+	PeriodStart int64        `json:"periodStart"`
+	PeriodEnd   int64        `json:"periodEnd"`
+	MerkleRoot  string       `json:"merkleRoot"`
+	LeafScheme  string       `json:"leafScheme"`
+	EntryCount  int          `json:"entryCount"`
+	Leaves      []LeafRecord `json:"leaves"`
+}
+
+// LeafRecord is one ledger entry of an archived batch. The user ID is
+// replaced by a tag salted per batch, so entries of one user cannot be linked
+// across batches; users find their own entries by leaf hash. The entry ID and
+// the referenced transaction hash are left out, as either would link an
+// entry to a user's deposit address or to their entries in other batches.
+type LeafRecord struct {
+	Index     int    `json:"index"`
+	Leaf      string `json:"leaf"`
+	UserTag   string `json:"userTag"`
+	EntryType string `json:"entryType"`
+	ChainID   uint64 `json:"chainId"`
+	AssetID   uint64 `json:"assetId"`
+	Amount    string `json:"amount"`
+	KusdDelta string `json:"kusdDelta"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// Writer builds archive documents for proof batches and stores them
+type Writer struct {
+	store BlobStore
+	salt  []byte // secret mixed into user tags
+}
+
+func NewWriter(store BlobStore, salt string) (*Writer, error) {
+	if salt == "" {
+		return nil, fmt.Errorf("archive salt is required")
+	}
+	return &Writer{store: store, salt: []byte(salt)}, nil
+}
+
+// WriteLedgerBatch archives the entries of a ledger batch and returns the
+// document CID
+func (w *Writer) WriteLedgerBatch(batch *model.ProofBatch, entries []model.LedgerEntry) (string, error) {
+	data, err := w.BuildLedgerDocument(batch, entries)
+	if err != nil {
+		return "", err
+	}
+
+	cid := ComputeCID(data)
+	if err := w.store.Put(cid, data); err != nil {
+		return "", fmt.Errorf("failed to store archive document: %v", err)
+	}
+	return cid, nil
+}
+
+// BuildLedgerDocument returns the encoded archive document of a ledger batch
+func (w *Writer) BuildLedgerDocument(batch *model.ProofBatch, entries []model.LedgerEntry) ([]byte, error) {
+	sorted := make([]model.LedgerEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	doc := Document{
+		Version:     documentVersion,
+		BatchType:   batch.BatchType.String(),
+		PeriodStart: batch.PeriodStart.Unix(),
+		PeriodEnd:   batch.PeriodEnd.Unix(),
+		MerkleRoot:  batch.MerkleRoot,
+		LeafScheme:  merkle.LedgerLeafScheme(),
+		EntryCount:  len(sorted),
+		Leaves:      make([]LeafRecord, len(sorted)),
+	}
+
+	for i := range sorted {
+		entry := &sorted[i]
+		leaf, err := merkle.LedgerEntryLeaf(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		record := LeafRecord{
+			Index:     i,
+			Leaf:      leaf.Hex(),
+			UserTag:   w.userTag(batch.MerkleRoot, entry.UserID),
+			EntryType: entry.EntryType,
+			Amount:    entry.Amount.String(),
+			KusdDelta: entry.KusdDelta.String(),
+			CreatedAt: entry.CreatedAt.Unix(),
+		}
+		if entry.ChainID != nil {
+			record.ChainID = *entry.ChainID
+		}
+		if entry.AssetID != nil {
+			record.AssetID = *entry.AssetID
+		}
+		doc.Leaves[i] = record
+	}
+
+	return json.Marshal(doc)
+}
+
+// userTag is HMAC-SHA256(salt, merkleRoot ":" userID), truncated to 16 bytes
+func (w *Writer) userTag(merkleRoot string, userID uint64) string {
+	mac := hmac.New(sha256.New, w.salt)
+	mac.Write([]byte(merkleRoot + ":" + strconv.FormatUint(userID, 10)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// ReadDocument loads an archive document and checks it matches its CID
+func ReadDocument(store BlobStore, cid string) (*Document, error) {
+	data, err := store.Get(cid)
+	if err != nil {
+		return nil, err
+	}
+	if ComputeCID(data) != cid {
+		return nil, fmt.Errorf("archive document does not match CID %s", cid)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid archive document: %v", err)
+	}
+	return &doc, nil
+}
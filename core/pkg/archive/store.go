@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned by a BlobStore when no blob has the given CID
+var ErrNotFound = errors.New("archive blob not found")
+
+// BlobStore keeps archive documents addressed by their CID. Implementations
+// may be backed by a local directory, object storage or an IPFS node.
+type BlobStore interface {
+	Put(cid string, data []byte) error
+	Get(cid string) ([]byte, error)
+}
+
+// LocalStore is a BlobStore writing one file per CID into a directory
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %v", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put writes the blob atomically; writing an existing CID is a no-op since
+// the content is the same
+func (s *LocalStore) Put(cid string, data []byte) error {
+	path, err := s.path(cid)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Get(cid string) ([]byte, error) {
+	path, err := s.path(cid)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *LocalStore) path(cid string) (string, error) {
+	if !IsCID(cid) {
+		return "", fmt.Errorf("invalid CID: %s", cid)
+	}
+	return filepath.Join(s.dir, cid), nil
+}
@@ -0,0 +1,127 @@
+package contracts
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BatchType mirrors the ProofRegistry.BatchType enum. Its value is the uint8
+// passed to publishBatch and getBatchesByType; it is stored in the database
+// and rendered in JSON by name.
+type BatchType uint8
+
+const (
+	BatchTypeDeposit BatchType = iota
+	BatchTypeYield
+	BatchTypeTrade
+	BatchTypeWithdraw
+
+	// BatchTypeReserves is a reserves attestation. ProofRegistry has no enum
+	// value for it, so these batches are only recorded off-chain.
+	BatchTypeReserves
+)
+
+var batchTypeNames = [...]string{
+	BatchTypeDeposit:  "deposit",
+	BatchTypeYield:    "yield",
+	BatchTypeTrade:    "trade",
+	BatchTypeWithdraw: "withdraw",
+	BatchTypeReserves: "reserves",
+}
+
+// BatchTypes returns the batch types of the on-chain enum in enum order
+func BatchTypes() []BatchType {
+	types := make([]BatchType, 0, len(batchTypeNames))
+	for i := range batchTypeNames {
+		if t := BatchType(i); t.IsOnchain() {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// ParseBatchType accepts a batch type name or the ProofRegistry enum value
+func ParseBatchType(s string) (BatchType, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range batchTypeNames {
+		if s == name {
+			return BatchType(i), nil
+		}
+	}
+
+	if v, err := strconv.ParseUint(s, 10, 8); err == nil && BatchType(v).IsOnchain() {
+		return BatchType(v), nil
+	}
+
+	return 0, fmt.Errorf("unknown batch type %q, expected one of %s or 0-%d",
+		s, strings.Join(batchTypeNames[:], ", "), uint8(BatchTypeReserves)-1)
+}
+
+// ParseOnchainBatchType is ParseBatchType limited to the types ProofRegistry knows
+func ParseOnchainBatchType(s string) (BatchType, error) {
+	t, err := ParseBatchType(s)
+	if err != nil {
+		return 0, err
+	}
+	if !t.IsOnchain() {
+		return 0, fmt.Errorf("batch type %s is not published on-chain", t)
+	}
+	return t, nil
+}
+
+// IsValid reports whether t is a known batch type
+func (t BatchType) IsValid() bool {
+	return int(t) < len(batchTypeNames)
+}
+
+// IsOnchain reports whether t is a value of the ProofRegistry enum
+func (t BatchType) IsOnchain() bool {
+	return t < BatchTypeReserves
+}
+
+func (t BatchType) String() string {
+	if !t.IsValid() {
+		return fmt.Sprintf("BatchType(%d)", uint8(t))
+	}
+	return batchTypeNames[t]
+}
+
+// MarshalText renders the batch type by name in JSON
+func (t BatchType) MarshalText() ([]byte, error) {
+	if !t.IsValid() {
+		return nil, fmt.Errorf("invalid batch type %d", uint8(t))
+	}
+	return []byte(batchTypeNames[t]), nil
+}
+
+// UnmarshalText parses a batch type name or enum value
+func (t *BatchType) UnmarshalText(text []byte) error {
+	parsed, err := ParseBatchType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Value stores the batch type by name
+func (t BatchType) Value() (driver.Value, error) {
+	if !t.IsValid() {
+		return nil, fmt.Errorf("invalid batch type %d", uint8(t))
+	}
+	return batchTypeNames[t], nil
+}
+
+// Scan reads a batch type stored by name
+func (t *BatchType) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return t.UnmarshalText([]byte(v))
+	case []byte:
+		return t.UnmarshalText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into BatchType", value)
+	}
+}
@@ -0,0 +1,93 @@
+package contracts
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// proofRegistrySource is the Solidity source the ProofRegistry ABI is built from
+var proofRegistrySource = filepath.Join("..", "..", "..", "contracts", "contracts", "ProofRegistry.sol")
+
+func TestBatchTypesMatchProofRegistryEnum(t *testing.T) {
+	source, err := os.ReadFile(proofRegistrySource)
+	if err != nil {
+		t.Fatalf("failed to read ProofRegistry source: %v", err)
+	}
+	match := regexp.MustCompile(`enum BatchType \{([^}]*)\}`).FindSubmatch(source)
+	if match == nil {
+		t.Fatalf("BatchType enum not found in ProofRegistry source")
+	}
+	var members []string
+	for _, line := range strings.Split(string(match[1]), "\n") {
+		if member := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.SplitN(line, "//", 2)[0]), ",")); member != "" {
+			members = append(members, member)
+		}
+	}
+
+	types := BatchTypes()
+	if len(types) != len(members) {
+		t.Fatalf("got on-chain batch types %v, want one per enum member %v", types, members)
+	}
+	for i, member := range members {
+		if uint8(types[i]) != uint8(i) || strings.ToUpper(types[i].String()) != member {
+			t.Fatalf("enum value %d is %s, got batch type %d %s", i, member, uint8(types[i]), types[i])
+		}
+	}
+}
+
+func TestParseOnchainBatchTypeRoundTrip(t *testing.T) {
+	for _, batchType := range BatchTypes() {
+		for _, input := range []string{batchType.String(), strings.ToUpper(batchType.String()), " " + batchType.String() + " ", strconv.Itoa(int(batchType))} {
+			parsed, err := ParseOnchainBatchType(input)
+			if err != nil || parsed != batchType {
+				t.Fatalf("ParseOnchainBatchType(%q) = %v, %v, want %s", input, parsed, err, batchType)
+			}
+		}
+
+		// Stored and rendered by name, read back to the same value
+		stored, err := batchType.Value()
+		if err != nil || stored != batchType.String() {
+			t.Fatalf("%s stored as %v (%v)", batchType, stored, err)
+		}
+		var scanned BatchType
+		if err := scanned.Scan([]byte(batchType.String())); err != nil || scanned != batchType {
+			t.Fatalf("scanned %s as %v (%v)", batchType, scanned, err)
+		}
+		encoded, err := json.Marshal(batchType)
+		if err != nil || string(encoded) != `"`+batchType.String()+`"` {
+			t.Fatalf("%s encoded as %s (%v)", batchType, encoded, err)
+		}
+		var decoded BatchType
+		if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != batchType {
+			t.Fatalf("decoded %s as %v (%v)", encoded, decoded, err)
+		}
+	}
+
+	// Reserves batches exist off-chain only
+	if parsed, err := ParseBatchType("reserves"); err != nil || parsed != BatchTypeReserves {
+		t.Fatalf("ParseBatchType(reserves) = %v, %v", parsed, err)
+	}
+	if _, err := ParseOnchainBatchType("reserves"); err == nil || !strings.Contains(err.Error(), "not published on-chain") {
+		t.Fatalf("got %v for reserves, want it refused as off-chain", err)
+	}
+
+	for _, input := range []string{"", "deposits", "transactions", "-1", "4", "256", "1.0"} {
+		if parsed, err := ParseOnchainBatchType(input); err == nil {
+			t.Fatalf("ParseOnchainBatchType(%q) = %s, want an error", input, parsed)
+		} else if !strings.Contains(err.Error(), "unknown batch type") {
+			t.Fatalf("ParseOnchainBatchType(%q) error %q does not name the accepted values", input, err)
+		}
+	}
+	if _, err := BatchType(9).Value(); err == nil {
+		t.Fatalf("stored an invalid batch type")
+	}
+	var scanned BatchType
+	if err := scanned.Scan(int64(0)); err == nil {
+		t.Fatalf("scanned a batch type stored as a number")
+	}
+}
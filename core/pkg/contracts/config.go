@@ -1,51 +1,9 @@
 package contracts
 
 import (
-	"os"
-
 	"github.com/ethereum/go-ethereum/common"
 )
 
-// Contract addresses - Sepolia testnet
-const (
-	DefaultUSDKAddress        = "0xAeE3625b0E6a4FfAc196d4DCB51dCe7568dD6353"
-	DefaultProofRegistryAddress = "0x4699ED32Ab75A7B7f8c74eAE88EF1EB02BFa55da"
-	DefaultRPCEndpoint        = "https://sepolia.infura.io/v3/5248acacf6244306b0cac215a002eb04"
-)
-
-// ContractConfig holds the configuration for blockchain contracts
-type ContractConfig struct {
-	USDKAddress        common.Address
-	ProofRegistryAddress common.Address
-	RPCEndpoint        string
-}
-
-// NewContractConfig creates a new contract configuration with default values or from environment
-func NewContractConfig() *ContractConfig {
-	config := &ContractConfig{
-		USDKAddress:        common.HexToAddress(getEnvOrDefault("USDK_CONTRACT_ADDRESS", DefaultUSDKAddress)),
-		ProofRegistryAddress: common.HexToAddress(getEnvOrDefault("PROOF_REGISTRY_ADDRESS", DefaultProofRegistryAddress)),
-		RPCEndpoint:        getEnvOrDefault("ETH_RPC_URL", DefaultRPCEndpoint),
-	}
-	
-	return config
-}
-
-// getEnvOrDefault returns the environment variable value or a default value if not set
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-// Batch types for ProofRegistry
-const (
-	BatchTypeReserves uint8 = iota
-	BatchTypeTransactions
-	BatchTypeLiabilities
-)
-
 // Role constants for contracts
 var (
 	// USDK roles
@@ -0,0 +1,67 @@
+package contracts
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ERC20ContractMetaData contains the minimal ERC-20 ABI used for third-party tokens (USDC, USDT, ...).
+var ERC20ContractMetaData = &bind.MetaData{
+	ABI: "[{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"internalType\":\"address\",\"name\":\"from\",\"type\":\"address\"},{\"indexed\":true,\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"Transfer\",\"type\":\"event\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"balanceOf\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"decimals\",\"outputs\":[{\"internalType\":\"uint8\",\"name\":\"\",\"type\":\"uint8\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"to\",\"type\":\"address\"},{\"internalType\":\"uint256\",\"name\":\"value\",\"type\":\"uint256\"}],\"name\":\"transfer\",\"outputs\":[{\"internalType\":\"bool\",\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]",
+}
+
+// ERC20Contract is a Go binding around a standard ERC-20 token contract.
+type ERC20Contract struct {
+	ERC20ContractCaller     // Read-only binding to the contract
+	ERC20ContractTransactor // Write-only binding to the contract
+}
+
+// ERC20ContractCaller is a read-only Go binding around an ERC-20 token contract.
+type ERC20ContractCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// ERC20ContractTransactor is a write-only Go binding around an ERC-20 token contract.
+type ERC20ContractTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// NewERC20Contract creates a new instance of ERC20Contract, bound to a specific deployed token.
+func NewERC20Contract(address common.Address, backend bind.ContractBackend) (*ERC20Contract, error) {
+	parsed, err := abi.JSON(strings.NewReader(ERC20ContractMetaData.ABI))
+	if err != nil {
+		return nil, err
+	}
+	contract := bind.NewBoundContract(address, parsed, backend, backend, backend)
+	return &ERC20Contract{ERC20ContractCaller: ERC20ContractCaller{contract: contract}, ERC20ContractTransactor: ERC20ContractTransactor{contract: contract}}, nil
+}
+
+// BalanceOf retrieves the token balance of an account.
+func (erc20 *ERC20ContractCaller) BalanceOf(opts *bind.CallOpts, account common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := erc20.contract.Call(opts, &out, "balanceOf", account)
+	if err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// Decimals retrieves the token decimals.
+func (erc20 *ERC20ContractCaller) Decimals(opts *bind.CallOpts) (uint8, error) {
+	var out []interface{}
+	err := erc20.contract.Call(opts, &out, "decimals")
+	if err != nil {
+		return 0, err
+	}
+	return *abi.ConvertType(out[0], new(uint8)).(*uint8), nil
+}
+
+// Transfer transfers tokens to a specified address.
+func (erc20 *ERC20ContractTransactor) Transfer(opts *bind.TransactOpts, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	return erc20.contract.Transact(opts, "transfer", to, amount)
+}
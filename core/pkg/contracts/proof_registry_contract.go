@@ -121,22 +121,14 @@ func bindProofRegistryContract(address common.Address, caller bind.ContractCalle
 func (pr *ProofRegistryContractCaller) GetBatch(opts *bind.CallOpts, batchId *big.Int) (ProofRegistryBatch, error) {
 	var out []interface{}
 	err := pr.contract.Call(opts, &out, "getBatch", batchId)
-	
-	outstruct := new(ProofRegistryBatch)
 	if err != nil {
-		return *outstruct, err
+		return *new(ProofRegistryBatch), err
 	}
 	
-	outstruct.Root = *abi.ConvertType(out[0], new([32]byte)).(*[32]byte)
-	outstruct.BatchType = *abi.ConvertType(out[1], new(uint8)).(*uint8)
-	outstruct.StartTimestamp = *abi.ConvertType(out[2], new(uint64)).(*uint64)
-	outstruct.EndTimestamp = *abi.ConvertType(out[3], new(uint64)).(*uint64)
-	outstruct.Uri = *abi.ConvertType(out[4], new(string)).(*string)
-	outstruct.Publisher = *abi.ConvertType(out[5], new(common.Address)).(*common.Address)
-	outstruct.EntryCount = *abi.ConvertType(out[6], new(uint32)).(*uint32)
-	outstruct.Verified = *abi.ConvertType(out[7], new(bool)).(*bool)
+	// getBatch returns the Batch struct as a single tuple
+	out0 := *abi.ConvertType(out[0], new(ProofRegistryBatch)).(*ProofRegistryBatch)
 	
-	return *outstruct, err
+	return out0, err
 }
 
 // GetBatchCount retrieves the total number of batches.
@@ -212,4 +204,62 @@ func (pr *ProofRegistryContractTransactor) Pause(opts *bind.TransactOpts) (*type
 // Unpause unpauses the contract (requires PAUSER_ROLE).
 func (pr *ProofRegistryContractTransactor) Unpause(opts *bind.TransactOpts) (*types.Transaction, error) {
 	return pr.contract.Transact(opts, "unpause")
-}
\ No newline at end of file
+}
+
+// ProofRegistryContractProofPublished represents a ProofPublished event raised by the ProofRegistryContract contract.
+type ProofRegistryContractProofPublished struct {
+	BatchId        *big.Int
+	Root           [32]byte
+	BatchType      uint8
+	StartTimestamp uint64
+	EndTimestamp   uint64
+	Uri            string
+	Publisher      common.Address
+	EntryCount     uint32
+	Raw            types.Log // Blockchain specific contextual infos
+}
+
+// ParseProofPublished is a log parse operation binding the contract event ProofPublished.
+func (pr *ProofRegistryContractFilterer) ParseProofPublished(log types.Log) (*ProofRegistryContractProofPublished, error) {
+	event := new(ProofRegistryContractProofPublished)
+	if err := pr.contract.UnpackLog(event, "ProofPublished", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ProofRegistryContractBatchVerified represents a BatchVerified event raised by the ProofRegistryContract contract.
+type ProofRegistryContractBatchVerified struct {
+	BatchId *big.Int
+	Root    [32]byte
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// ParseBatchVerified is a log parse operation binding the contract event BatchVerified.
+func (pr *ProofRegistryContractFilterer) ParseBatchVerified(log types.Log) (*ProofRegistryContractBatchVerified, error) {
+	event := new(ProofRegistryContractBatchVerified)
+	if err := pr.contract.UnpackLog(event, "BatchVerified", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ProofRegistryContractBatchRevoked represents a BatchRevoked event raised by the ProofRegistryContract contract.
+type ProofRegistryContractBatchRevoked struct {
+	BatchId *big.Int
+	Root    [32]byte
+	Reason  string
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// ParseBatchRevoked is a log parse operation binding the contract event BatchRevoked.
+func (pr *ProofRegistryContractFilterer) ParseBatchRevoked(log types.Log) (*ProofRegistryContractBatchRevoked, error) {
+	event := new(ProofRegistryContractBatchRevoked)
+	if err := pr.contract.UnpackLog(event, "BatchRevoked", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
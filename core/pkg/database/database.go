@@ -59,17 +59,25 @@ func AutoMigrate() error {
 		&model.Asset{},
 		&model.ChainAsset{},
 		&model.DepositAddress{},
+		&model.DepositAddressCounter{},
 		&model.OnchainTx{},
+		&model.OutgoingTx{},
+		&model.OutgoingTxAttempt{},
+		&model.ChainSyncState{},
+		&model.ChainBlock{},
 		&model.Valuation{},
 		&model.PlatformMetrics{},
 		&model.PriceFeed{},
 		&model.LedgerEntry{},
 		&model.ProofBatch{},
 		&model.WithdrawRequest{},
+		&model.UserKyc{},
+		&model.KycWebhookEvent{},
 		&model.WithdrawalWhitelist{},
 		&model.RiskConfig{},
 		&model.BlacklistAddress{},
 		&model.SystemConfig{},
+		&model.AdminUser{},
 		&model.AuditLog{},
 	)
 }
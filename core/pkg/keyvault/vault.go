@@ -0,0 +1,247 @@
+package keyvault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	keySize = 32 // AES-256
+	// refPrefix starts every sealed reference, followed by the KEK version
+	refPrefix = "kek"
+)
+
+// Vault seals secrets with envelope encryption: every secret is encrypted
+// with its own random data key (AES-256-GCM), and the data key is wrapped
+// with a versioned key-encryption key (KEK). Rotating to a new KEK only
+// re-wraps data keys; the secret ciphertext never changes.
+//
+// A sealed reference is "kek<version>:<wrapped data key>:<ciphertext>", both
+// parts base64url encoded with their GCM nonce in front. The caller's
+// associated data (such as the address a key belongs to) is authenticated by
+// both layers, so a reference cannot be moved to another record.
+type Vault struct {
+	keks    map[uint32][]byte
+	current uint32
+}
+
+// NewVault creates a vault sealing with keks[current] and opening references
+// of any version in keks
+func NewVault(keks map[uint32][]byte, current uint32) (*Vault, error) {
+	for version, kek := range keks {
+		if len(kek) != keySize {
+			return nil, fmt.Errorf("KEK version %d must be %d bytes", version, keySize)
+		}
+	}
+	if _, ok := keks[current]; !ok {
+		return nil, fmt.Errorf("current KEK version %d is not configured", current)
+	}
+	return &Vault{keks: keks, current: current}, nil
+}
+
+// ParseKeys parses a "<version>:<key>,..." list of KEKs, each key 32 bytes
+// in hex or base64
+func ParseKeys(spec string) (map[uint32][]byte, error) {
+	keks := make(map[uint32][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		versionStr, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("KEK entry must be <version>:<key>")
+		}
+		version, err := strconv.ParseUint(versionStr, 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid KEK version %q", versionStr)
+		}
+		if _, exists := keks[uint32(version)]; exists {
+			return nil, fmt.Errorf("duplicate KEK version %d", version)
+		}
+
+		kek, err := DecodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("KEK version %d: %v", version, err)
+		}
+		keks[uint32(version)] = kek
+	}
+	return keks, nil
+}
+
+// DecodeKey decodes a 32 byte key given in hex or base64
+func DecodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimPrefix(strings.TrimSpace(encoded), "0x")
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == keySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes, hex or base64 encoded", keySize)
+}
+
+// CurrentVersion returns the KEK version new references are sealed with
+func (v *Vault) CurrentVersion() uint32 {
+	return v.current
+}
+
+// Seal encrypts plaintext under a fresh data key wrapped with the current KEK
+func (v *Vault) Seal(plaintext []byte, associatedData string) (string, error) {
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %v", err)
+	}
+
+	ciphertext, err := seal(dek, plaintext, []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+	return v.wrap(v.current, dek, associatedData, ciphertext)
+}
+
+// Open decrypts a reference sealed with any configured KEK version
+func (v *Vault) Open(ref, associatedData string) ([]byte, error) {
+	dek, ciphertext, err := v.unwrap(ref, associatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := open(dek, ciphertext, []byte(associatedData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %v", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap re-wraps the data key of a reference with the current KEK. It
+// returns the reference unchanged and false when it already uses it.
+func (v *Vault) Rewrap(ref, associatedData string) (string, bool, error) {
+	version, err := RefVersion(ref)
+	if err != nil {
+		return "", false, err
+	}
+	if version == v.current {
+		return ref, false, nil
+	}
+
+	dek, ciphertext, err := v.unwrap(ref, associatedData)
+	if err != nil {
+		return "", false, err
+	}
+	// Make sure the secret still opens before the old wrapping is dropped
+	if _, err := open(dek, ciphertext, []byte(associatedData)); err != nil {
+		return "", false, fmt.Errorf("failed to decrypt secret: %v", err)
+	}
+
+	rewrapped, err := v.wrap(v.current, dek, associatedData, ciphertext)
+	if err != nil {
+		return "", false, err
+	}
+	return rewrapped, true, nil
+}
+
+// RefVersion returns the KEK version of a sealed reference
+func RefVersion(ref string) (uint32, error) {
+	versionPart, _, ok := strings.Cut(ref, ":")
+	if !ok || !strings.HasPrefix(versionPart, refPrefix) {
+		return 0, fmt.Errorf("not a sealed key reference")
+	}
+	version, err := strconv.ParseUint(strings.TrimPrefix(versionPart, refPrefix), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid KEK version in key reference")
+	}
+	return uint32(version), nil
+}
+
+// RefPrefix returns the prefix of references sealed with a KEK version
+func RefPrefix(version uint32) string {
+	return refPrefix + strconv.FormatUint(uint64(version), 10) + ":"
+}
+
+func (v *Vault) wrap(version uint32, dek []byte, associatedData string, ciphertext []byte) (string, error) {
+	wrapped, err := seal(v.keks[version], dek, dekAssociatedData(associatedData))
+	if err != nil {
+		return "", err
+	}
+	return RefPrefix(version) +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+func (v *Vault) unwrap(ref, associatedData string) ([]byte, []byte, error) {
+	version, err := RefVersion(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	kek, ok := v.keks[version]
+	if !ok {
+		return nil, nil, fmt.Errorf("KEK version %d is not configured", version)
+	}
+
+	parts := strings.Split(ref, ":")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("malformed key reference")
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed wrapped data key: %v", err)
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed ciphertext: %v", err)
+	}
+
+	dek, err := open(kek, wrapped, dekAssociatedData(associatedData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+	return dek, ciphertext, nil
+}
+
+// dekAssociatedData separates the data key layer from the secret layer
+func dekAssociatedData(associatedData string) []byte {
+	return []byte("dek:" + associatedData)
+}
+
+// seal returns nonce || AES-GCM(key, plaintext)
+func seal(key, plaintext, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func open(key, sealed, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, associatedData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,129 @@
+package keyvault
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func testKeys(versions ...uint32) map[uint32][]byte {
+	keks := make(map[uint32][]byte, len(versions))
+	for _, version := range versions {
+		keks[version] = bytes.Repeat([]byte{byte(version)}, keySize)
+	}
+	return keks
+}
+
+func newTestVault(t *testing.T, current uint32, versions ...uint32) *Vault {
+	t.Helper()
+
+	vault, err := NewVault(testKeys(versions...), current)
+	if err != nil {
+		t.Fatalf("failed to create vault: %v", err)
+	}
+	return vault
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	vault := newTestVault(t, 1, 1)
+	secret := []byte("deposit private key")
+
+	ref, err := vault.Seal(secret, "deposit:0xabc")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	if !strings.HasPrefix(ref, RefPrefix(1)) || strings.Contains(ref, string(secret)) {
+		t.Fatalf("unexpected reference %s", ref)
+	}
+
+	opened, err := vault.Open(ref, "deposit:0xabc")
+	if err != nil || !bytes.Equal(opened, secret) {
+		t.Fatalf("got %q (%v), want %q", opened, err, secret)
+	}
+
+	// Each seal uses a fresh data key and nonce
+	again, err := vault.Seal(secret, "deposit:0xabc")
+	if err != nil || again == ref {
+		t.Fatalf("sealing twice gave the same reference (%v)", err)
+	}
+}
+
+func TestOpenRejectsOtherAssociatedData(t *testing.T) {
+	vault := newTestVault(t, 1, 1)
+	ref, err := vault.Seal([]byte("key of 0xabc"), "deposit:0xabc")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	// A reference copied onto another address does not open there
+	if _, err := vault.Open(ref, "deposit:0xdef"); err == nil {
+		t.Fatalf("reference opened under another address")
+	}
+
+	// Neither does the ciphertext of one reference behind the data key of another
+	other, err := vault.Seal([]byte("key of 0xdef"), "deposit:0xdef")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+	parts, otherParts := strings.Split(ref, ":"), strings.Split(other, ":")
+	spliced := strings.Join([]string{parts[0], otherParts[1], parts[2]}, ":")
+	if _, err := vault.Open(spliced, "deposit:0xabc"); err == nil {
+		t.Fatalf("spliced reference opened")
+	}
+	if _, err := vault.Open(spliced, "deposit:0xdef"); err == nil {
+		t.Fatalf("spliced reference opened under the data key's address")
+	}
+}
+
+func TestRewrapChangesVersionNotSecret(t *testing.T) {
+	secret := []byte("deposit private key")
+	old := newTestVault(t, 1, 1)
+	ref, err := old.Seal(secret, "deposit:0xabc")
+	if err != nil {
+		t.Fatalf("failed to seal: %v", err)
+	}
+
+	rotated := newTestVault(t, 2, 1, 2)
+	rewrapped, changed, err := rotated.Rewrap(ref, "deposit:0xabc")
+	if err != nil || !changed {
+		t.Fatalf("got changed %v (%v), want the reference re-wrapped", changed, err)
+	}
+	if version, _ := RefVersion(rewrapped); version != 2 {
+		t.Fatalf("got KEK version %d, want 2", version)
+	}
+	// Only the data key wrapping changes; the secret ciphertext is kept
+	if strings.Split(rewrapped, ":")[2] != strings.Split(ref, ":")[2] {
+		t.Fatalf("secret ciphertext changed on re-wrap")
+	}
+	opened, err := rotated.Open(rewrapped, "deposit:0xabc")
+	if err != nil || !bytes.Equal(opened, secret) {
+		t.Fatalf("got %q (%v), want %q", opened, err, secret)
+	}
+	if _, err := old.Open(rewrapped, "deposit:0xabc"); err == nil {
+		t.Fatalf("reference re-wrapped with KEK 2 opened without it")
+	}
+
+	// A reference already on the current version is left alone
+	again, changed, err := rotated.Rewrap(rewrapped, "deposit:0xabc")
+	if err != nil || changed || again != rewrapped {
+		t.Fatalf("re-wrapping a current reference changed it (%v)", err)
+	}
+
+	if _, _, err := rotated.Rewrap(ref, "deposit:0xdef"); err == nil {
+		t.Fatalf("reference re-wrapped under another address")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	hexKey := strings.Repeat("ab", keySize)
+	keks, err := ParseKeys("1:" + hexKey + ", 2:0x" + hexKey)
+	if err != nil || len(keks) != 2 || !bytes.Equal(keks[1], keks[2]) {
+		t.Fatalf("got %d keys (%v)", len(keks), err)
+	}
+
+	for _, spec := range []string{"0:" + hexKey, "1:short", hexKey, "1:" + hexKey + ",1:" + hexKey} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Fatalf("invalid key list %q accepted", spec)
+		}
+	}
+}
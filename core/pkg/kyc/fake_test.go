@@ -0,0 +1,85 @@
+package kyc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeProviderParseWebhook(t *testing.T) {
+	provider := NewFakeProvider("webhook-secret")
+	body := []byte(`{"eventId":"evt-1","applicantId":"fake-1-00","status":"approved","level":1}`)
+
+	event, err := provider.ParseWebhook(body, provider.SignedHeader(body, time.Now()))
+	if err != nil {
+		t.Fatalf("valid delivery rejected: %v", err)
+	}
+	if event.EventID != "evt-1" || event.ApplicantID != "fake-1-00" || event.Status != StatusApproved || event.Level != LevelBasic {
+		t.Fatalf("got %+v", event)
+	}
+
+	tampered := provider.SignedHeader(body, time.Now())
+	tampered.Set(TimestampHeader, "1")
+
+	missingID := []byte(`{"applicantId":"fake-1-00","status":"approved","level":1}`)
+
+	tests := []struct {
+		name    string
+		body    []byte
+		header  func() http.Header
+		wantErr string
+	}{
+		{
+			name:    "tampered body",
+			body:    []byte(strings.Replace(string(body), `"level":1`, `"level":2`, 1)),
+			header:  func() http.Header { return provider.SignedHeader(body, time.Now()) },
+			wantErr: "invalid webhook signature",
+		},
+		{
+			name:    "other secret",
+			body:    body,
+			header:  func() http.Header { return NewFakeProvider("other").SignedHeader(body, time.Now()) },
+			wantErr: "invalid webhook signature",
+		},
+		{
+			name:    "timestamp changed after signing",
+			body:    body,
+			header:  func() http.Header { return tampered },
+			wantErr: "invalid webhook signature",
+		},
+		{
+			name:    "unsigned",
+			body:    body,
+			header:  func() http.Header { return http.Header{} },
+			wantErr: "invalid webhook signature",
+		},
+		{
+			name:    "stale",
+			body:    body,
+			header:  func() http.Header { return provider.SignedHeader(body, time.Now().Add(-WebhookTolerance-time.Minute)) },
+			wantErr: "stale webhook delivery",
+		},
+		{
+			name:    "from the future",
+			body:    body,
+			header:  func() http.Header { return provider.SignedHeader(body, time.Now().Add(WebhookTolerance+time.Minute)) },
+			wantErr: "stale webhook delivery",
+		},
+		{
+			name:    "no event id",
+			body:    missingID,
+			header:  func() http.Header { return provider.SignedHeader(missingID, time.Now()) },
+			wantErr: "webhook event id is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.ParseWebhook(tt.body, tt.header())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
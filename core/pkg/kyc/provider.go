@@ -0,0 +1,69 @@
+package kyc
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// KYC levels, a higher level unlocks higher withdrawal limits
+const (
+	LevelNone  = 0
+	LevelBasic = 1 // identity document
+	LevelFull  = 2 // identity document and proof of address
+)
+
+// Verification statuses reported by providers
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// WebhookTolerance is how far a webhook's signed timestamp may be from now
+// before the delivery is rejected as stale
+const WebhookTolerance = 5 * time.Minute
+
+// Session is a verification started with a provider
+type Session struct {
+	ApplicantID     string `json:"applicantId"`
+	VerificationURL string `json:"verificationUrl"`
+}
+
+// WebhookEvent is a provider callback reporting a verification result
+type WebhookEvent struct {
+	EventID      string `json:"eventId"` // unique per event, a repeated id is a replay
+	ApplicantID  string `json:"applicantId"`
+	Status       string `json:"status"`
+	Level        int    `json:"level"`
+	RejectReason string `json:"rejectReason,omitempty"`
+}
+
+// Provider is an external identity verification service
+type Provider interface {
+	Name() string
+	// StartVerification creates an applicant for the user at the requested level
+	StartVerification(userID uint64, level int) (*Session, error)
+	// ParseWebhook authenticates and decodes a callback request body. It
+	// rejects deliveries signed more than WebhookTolerance away from now and
+	// events without an id; the caller drops ids it has already applied.
+	ParseWebhook(body []byte, header http.Header) (*WebhookEvent, error)
+}
+
+// NewProvider returns the provider configured by name
+func NewProvider(name, webhookSecret string) (Provider, error) {
+	switch name {
+	case "fake":
+		if webhookSecret == "" {
+			return nil, fmt.Errorf("webhook secret is required")
+		}
+		return NewFakeProvider(webhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unsupported KYC provider: %s", name)
+	}
+}
+
+// IsValidLevel reports whether level is a known KYC level
+func IsValidLevel(level int) bool {
+	return level >= LevelNone && level <= LevelFull
+}
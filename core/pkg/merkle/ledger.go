@@ -0,0 +1,179 @@
+package merkle
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"usdk-backend/internal/model"
+)
+
+// ledgerAmountDecimals is the fixed point scale amounts are encoded with,
+// matching the decimal(38,18) ledger columns
+const ledgerAmountDecimals = 18
+
+// leafFieldSpec is the name and ABI type of one leaf field
+type leafFieldSpec struct {
+	Name string
+	Type string
+}
+
+// ledgerLeafFields names the fields of a ledger leaf in encoding order
+var ledgerLeafFields = []leafFieldSpec{
+	{"id", "uint256"},
+	{"userId", "uint256"},
+	{"entryType", "string"},
+	{"chainId", "uint256"},   // 0 if none
+	{"assetId", "uint256"},   // 0 if none
+	{"amount", "int256"},     // 18 decimals
+	{"kusdDelta", "int256"},  // 18 decimals
+	{"refTxHash", "string"},  // empty if none
+	{"createdAt", "uint256"}, // unix seconds
+}
+
+var ledgerLeafArgs = mustArguments(ledgerLeafFields)
+
+// LeafField is one ABI-encoded field of a ledger leaf
+type LeafField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// LedgerLeafFields returns the values a ledger entry leaf is encoded from, so
+// clients can rebuild the leaf themselves
+func LedgerLeafFields(entry *model.LedgerEntry) []LeafField {
+	values := ledgerLeafValues(entry)
+	fields := make([]LeafField, len(ledgerLeafFields))
+	for i, f := range ledgerLeafFields {
+		fields[i] = LeafField{Name: f.Name, Type: f.Type, Value: fmt.Sprint(values[i])}
+	}
+	return fields
+}
+
+// LedgerLeafScheme describes the ledger leaf encoding, e.g. for archive documents
+func LedgerLeafScheme() string {
+	params := make([]string, len(ledgerLeafFields))
+	for i, f := range ledgerLeafFields {
+		params[i] = f.Type + " " + f.Name
+	}
+	return "keccak256(keccak256(abi.encode(" + strings.Join(params, ", ") + ")))"
+}
+
+// EncodeLedgerEntry returns abi.encode(id, userId, entryType, chainId,
+// assetId, amount, kusdDelta, refTxHash, createdAt) of a ledger entry
+func EncodeLedgerEntry(entry *model.LedgerEntry) ([]byte, error) {
+	encoded, err := ledgerLeafArgs.Pack(ledgerLeafValues(entry)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ledger entry %d: %v", entry.ID, err)
+	}
+	return encoded, nil
+}
+
+// LedgerEntryLeaf returns the canonical leaf of a ledger entry:
+//
+//	keccak256(keccak256(abi.encode(id, userId, entryType, chainId, assetId,
+//	    amount, kusdDelta, refTxHash, createdAt)))
+//
+// The leaf is hashed twice so it can never collide with an inner node.
+func LedgerEntryLeaf(entry *model.LedgerEntry) (common.Hash, error) {
+	encoded, err := EncodeLedgerEntry(entry)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	inner := crypto.Keccak256(encoded)
+	return crypto.Keccak256Hash(inner), nil
+}
+
+func ledgerLeafValues(entry *model.LedgerEntry) []interface{} {
+	var chainID, assetID uint64
+	if entry.ChainID != nil {
+		chainID = *entry.ChainID
+	}
+	if entry.AssetID != nil {
+		assetID = *entry.AssetID
+	}
+	refTxHash := ""
+	if entry.RefTxHash != nil {
+		refTxHash = *entry.RefTxHash
+	}
+
+	return []interface{}{
+		new(big.Int).SetUint64(entry.ID),
+		new(big.Int).SetUint64(entry.UserID),
+		entry.EntryType,
+		new(big.Int).SetUint64(chainID),
+		new(big.Int).SetUint64(assetID),
+		entry.Amount.Shift(ledgerAmountDecimals).BigInt(),
+		entry.KusdDelta.Shift(ledgerAmountDecimals).BigInt(),
+		refTxHash,
+		big.NewInt(entry.CreatedAt.Unix()),
+	}
+}
+
+// LedgerTree is a merkle tree over ledger entries in ascending ID order
+type LedgerTree struct {
+	*Tree
+	index map[uint64]int // ledger entry ID -> leaf index
+}
+
+// BuildLedgerTree builds the tree of a batch of ledger entries. Entries are
+// ordered by ID so the same set always yields the same root.
+func BuildLedgerTree(entries []model.LedgerEntry) (*LedgerTree, error) {
+	sorted := make([]*model.LedgerEntry, len(entries))
+	for i := range entries {
+		sorted[i] = &entries[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	leaves := make([]common.Hash, len(sorted))
+	index := make(map[uint64]int, len(sorted))
+	for i, entry := range sorted {
+		if _, dup := index[entry.ID]; dup {
+			return nil, fmt.Errorf("duplicate ledger entry %d", entry.ID)
+		}
+		leaf, err := LedgerEntryLeaf(entry)
+		if err != nil {
+			return nil, err
+		}
+		leaves[i] = leaf
+		index[entry.ID] = i
+	}
+
+	tree, err := NewTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+	return &LedgerTree{Tree: tree, index: index}, nil
+}
+
+// ProofForEntry returns the leaf and proof of a ledger entry in the tree
+func (t *LedgerTree) ProofForEntry(entryID uint64) (common.Hash, []common.Hash, error) {
+	i, ok := t.index[entryID]
+	if !ok {
+		return common.Hash{}, nil, fmt.Errorf("ledger entry %d is not in the tree", entryID)
+	}
+	proof, err := t.Proof(i)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	return t.Leaves()[i], proof, nil
+}
+
+func mustArguments(fields []leafFieldSpec) abi.Arguments {
+	args := make(abi.Arguments, len(fields))
+	for i, f := range fields {
+		typ, err := abi.NewType(f.Type, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		args[i] = abi.Argument{Name: f.Name, Type: typ}
+	}
+	return args
+}
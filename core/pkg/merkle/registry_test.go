@@ -0,0 +1,184 @@
+package merkle
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"usdk-backend/pkg/contracts"
+)
+
+// proofRegistryArtifact is the compiled contract from the hardhat project
+var proofRegistryArtifact = filepath.Join("..", "..", "..", "contracts", "ProofRegistry.json")
+
+// registry is a ProofRegistry deployed on a simulated chain
+type registry struct {
+	sim    *backends.SimulatedBackend
+	auth   *bind.TransactOpts
+	caller *contracts.ProofRegistryContractCaller
+	tx     *contracts.ProofRegistryContractTransactor
+	next   int64
+}
+
+func deployProofRegistry(t *testing.T) *registry {
+	t.Helper()
+
+	raw, err := os.ReadFile(proofRegistryArtifact)
+	if err != nil {
+		t.Fatalf("failed to read ProofRegistry artifact: %v", err)
+	}
+	var artifact struct {
+		Bytecode string `json:"bytecode"`
+	}
+	if err := json.Unmarshal(raw, &artifact); err != nil {
+		t.Fatalf("invalid ProofRegistry artifact: %v", err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(contracts.ProofRegistryContractMetaData.ABI))
+	if err != nil {
+		t.Fatalf("invalid ProofRegistry ABI: %v", err)
+	}
+
+	key, _ := crypto.GenerateKey()
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{owner: {Balance: balance}}, 30_000_000)
+	t.Cleanup(func() { sim.Close() })
+
+	auth, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatalf("failed to create transactor: %v", err)
+	}
+	// The owner is both admin and oracle
+	address, _, _, err := bind.DeployContract(auth, parsed, hexutil.MustDecode(artifact.Bytecode), sim, owner, owner)
+	if err != nil {
+		t.Fatalf("failed to deploy ProofRegistry: %v", err)
+	}
+	sim.Commit()
+
+	caller, err := contracts.NewProofRegistryContractCaller(address, sim)
+	if err != nil {
+		t.Fatalf("failed to bind caller: %v", err)
+	}
+	transactor, err := contracts.NewProofRegistryContractTransactor(address, sim)
+	if err != nil {
+		t.Fatalf("failed to bind transactor: %v", err)
+	}
+	return &registry{sim: sim, auth: auth, caller: caller, tx: transactor, next: 1}
+}
+
+// publish stores a batch with root and returns its id
+func (r *registry) publish(t *testing.T, root common.Hash, entryCount int) *big.Int {
+	t.Helper()
+
+	tx, err := r.tx.PublishBatch(r.auth, root, 0, 0, 1, "ipfs://batch", uint32(entryCount))
+	if err != nil {
+		t.Fatalf("failed to publish batch: %v", err)
+	}
+	r.sim.Commit()
+	receipt, err := r.sim.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil || receipt.Status != 1 {
+		t.Fatalf("publish batch failed: %v", err)
+	}
+
+	id := big.NewInt(r.next)
+	r.next++
+	return id
+}
+
+// verify checks a proof with the deployed contract
+func (r *registry) verify(t *testing.T, batchID *big.Int, leaf common.Hash, proof []common.Hash) bool {
+	t.Helper()
+
+	ok, err := r.caller.VerifyProof(&bind.CallOpts{}, batchID, leaf, ToBytes32(proof))
+	if err != nil {
+		t.Fatalf("verifyProof call failed: %v", err)
+	}
+	return ok
+}
+
+func testLeaves(n int) []common.Hash {
+	leaves := make([]common.Hash, n)
+	for i := range leaves {
+		leaves[i] = crypto.Keccak256Hash(big.NewInt(int64(i)).Bytes(), []byte("leaf"))
+	}
+	return leaves
+}
+
+func TestProofsVerifyOnProofRegistry(t *testing.T) {
+	registry := deployProofRegistry(t)
+
+	tests := []struct {
+		name    string
+		leaves  int
+		indices []int
+	}{
+		{name: "single leaf", leaves: 1, indices: []int{0}},
+		{name: "odd leaf count", leaves: 7, indices: []int{0, 1, 2, 3, 4, 5, 6}},
+		{name: "large tree", leaves: 1025, indices: []int{0, 1, 511, 512, 1000, 1023, 1024}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := NewTree(testLeaves(tt.leaves))
+			if err != nil {
+				t.Fatalf("failed to build tree: %v", err)
+			}
+			batchID := registry.publish(t, tree.Root(), tt.leaves)
+
+			for _, index := range tt.indices {
+				leaf := tree.Leaves()[index]
+				proof, err := tree.Proof(index)
+				if err != nil {
+					t.Fatalf("failed to build proof for leaf %d: %v", index, err)
+				}
+				if !Verify(tree.Root(), leaf, proof) {
+					t.Fatalf("leaf %d does not verify locally", index)
+				}
+				if !registry.verify(t, batchID, leaf, proof) {
+					t.Fatalf("leaf %d rejected by ProofRegistry", index)
+				}
+			}
+		})
+	}
+}
+
+func TestProofRegistryRejectsTamperedLeaf(t *testing.T) {
+	registry := deployProofRegistry(t)
+
+	tree, err := NewTree(testLeaves(5))
+	if err != nil {
+		t.Fatalf("failed to build tree: %v", err)
+	}
+	batchID := registry.publish(t, tree.Root(), 5)
+
+	proof, err := tree.Proof(2)
+	if err != nil {
+		t.Fatalf("failed to build proof: %v", err)
+	}
+	leaf := tree.Leaves()[2]
+	leaf[31] ^= 1
+
+	if Verify(tree.Root(), leaf, proof) {
+		t.Fatalf("tampered leaf verifies locally")
+	}
+	if registry.verify(t, batchID, leaf, proof) {
+		t.Fatalf("tampered leaf accepted by ProofRegistry")
+	}
+	// The untouched leaf still verifies with the same proof
+	if !registry.verify(t, batchID, tree.Leaves()[2], proof) {
+		t.Fatalf("original leaf rejected by ProofRegistry")
+	}
+}
@@ -0,0 +1,37 @@
+package merkle
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var reserveLeafArgs = mustArguments([]leafFieldSpec{
+	{"chainId", "uint256"},
+	{"token", "address"}, // zero address for the native asset
+	{"balance", "uint256"},
+	{"blockNumber", "uint256"},
+	{"takenAt", "uint256"}, // unix seconds
+})
+
+// ReserveLeaf returns the leaf of one reserve holding line, the total raw
+// balance of a token held by the platform on a chain at a block:
+//
+//	keccak256(keccak256(abi.encode(chainId, token, balance, blockNumber, takenAt)))
+func ReserveLeaf(chainID uint64, token common.Address, balance *big.Int, blockNumber uint64, takenAt int64) (common.Hash, error) {
+	encoded, err := reserveLeafArgs.Pack(
+		new(big.Int).SetUint64(chainID),
+		token,
+		balance,
+		new(big.Int).SetUint64(blockNumber),
+		big.NewInt(takenAt),
+	)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("failed to encode reserve holding: %v", err)
+	}
+
+	inner := crypto.Keccak256(encoded)
+	return crypto.Keccak256Hash(inner), nil
+}
@@ -0,0 +1,96 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tree is a binary merkle tree hashed with sorted pairs, the scheme
+// ProofRegistry._verifyMerkleProof checks: each parent is
+// keccak256(min(a, b) ++ max(a, b)). A node without a sibling is carried up
+// to the next level unchanged.
+type Tree struct {
+	layers [][]common.Hash // layers[0] are the leaves, the last layer is the root
+}
+
+// NewTree builds a tree over the leaves in the given order
+func NewTree(leaves []common.Hash) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("merkle tree needs at least one leaf")
+	}
+
+	layer := make([]common.Hash, len(leaves))
+	copy(layer, leaves)
+	layers := [][]common.Hash{layer}
+
+	for len(layer) > 1 {
+		next := make([]common.Hash, 0, (len(layer)+1)/2)
+		for i := 0; i < len(layer); i += 2 {
+			if i+1 == len(layer) {
+				next = append(next, layer[i])
+				continue
+			}
+			next = append(next, HashPair(layer[i], layer[i+1]))
+		}
+		layers = append(layers, next)
+		layer = next
+	}
+
+	return &Tree{layers: layers}, nil
+}
+
+// Root returns the merkle root
+func (t *Tree) Root() common.Hash {
+	return t.layers[len(t.layers)-1][0]
+}
+
+// Leaves returns the leaves in tree order
+func (t *Tree) Leaves() []common.Hash {
+	return t.layers[0]
+}
+
+// Proof returns the sibling hashes from the leaf at index up to the root
+func (t *Tree) Proof(index int) ([]common.Hash, error) {
+	if index < 0 || index >= len(t.layers[0]) {
+		return nil, fmt.Errorf("leaf index %d out of range", index)
+	}
+
+	proof := make([]common.Hash, 0, len(t.layers)-1)
+	for _, layer := range t.layers[:len(t.layers)-1] {
+		sibling := index ^ 1
+		if sibling < len(layer) {
+			proof = append(proof, layer[sibling])
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// HashPair hashes two nodes in sorted order
+func HashPair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) <= 0 {
+		return crypto.Keccak256Hash(a[:], b[:])
+	}
+	return crypto.Keccak256Hash(b[:], a[:])
+}
+
+// Verify recomputes the root from a leaf and its proof, like the contract does
+func Verify(root, leaf common.Hash, proof []common.Hash) bool {
+	computed := leaf
+	for _, element := range proof {
+		computed = HashPair(computed, element)
+	}
+	return computed == root
+}
+
+// ToBytes32 converts a proof to the [][32]byte form taken by the contract bindings
+func ToBytes32(proof []common.Hash) [][32]byte {
+	out := make([][32]byte, len(proof))
+	for i, h := range proof {
+		out[i] = h
+	}
+	return out
+}
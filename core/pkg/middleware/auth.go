@@ -100,4 +100,80 @@ func OptionalJWTAuthMiddleware() gin.HandlerFunc {
 
 		c.Next()
 	})
-}
\ No newline at end of file
+}
+
+// AdminRoleLoader returns an admin's current role, or an error if the account
+// no longer exists or is disabled
+type AdminRoleLoader func(adminID uint64) (string, error)
+
+// AdminJWTAuthMiddleware accepts only admin tokens issued by GenerateAdminJWT
+// and exposes admin_id, admin_role and admin_permissions on the context. The
+// role is loaded with currentRole on every request rather than taken from the
+// token, so disabling or re-roling an admin applies to tokens already issued.
+func AdminJWTAuthMiddleware(currentRole AdminRoleLoader) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Authorization header is required"))
+			c.Abort()
+			return
+		}
+
+		bearerToken := strings.Split(authHeader, " ")
+		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Invalid authorization header format"))
+			c.Abort()
+			return
+		}
+
+		claims, err := utils.ParseJWT(bearerToken[1])
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Invalid token"))
+			c.Abort()
+			return
+		}
+
+		isAdminToken := false
+		for _, aud := range claims.Audience {
+			if aud == utils.AdminAudience {
+				isAdminToken = true
+				break
+			}
+		}
+		if !isAdminToken || claims.AdminID == 0 {
+			c.JSON(http.StatusForbidden, utils.ErrorResponse("Admin access required"))
+			c.Abort()
+			return
+		}
+
+		role, err := currentRole(claims.AdminID)
+		if err != nil || !utils.IsValidRole(role) {
+			c.JSON(http.StatusForbidden, utils.ErrorResponse("Admin access required"))
+			c.Abort()
+			return
+		}
+
+		c.Set("admin_id", claims.AdminID)
+		c.Set("admin_role", role)
+		c.Set("admin_permissions", utils.RolePermissions(role))
+		c.Next()
+	})
+}
+
+// RequirePermission aborts unless the admin's current role grants permission.
+// It must run after AdminJWTAuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		permissions, _ := c.Get("admin_permissions")
+		granted, _ := permissions.([]string)
+		for _, p := range granted {
+			if p == permission {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, utils.ErrorResponse("Permission denied: "+permission))
+		c.Abort()
+	})
+}
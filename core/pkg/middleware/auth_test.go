@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/pkg/utils"
+)
+
+func TestAdminJWTAuthMiddlewareUsesCurrentRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test-secret", ExpireHours: 1, AdminExpireHours: 1}}
+
+	// The admin logs in as a custodian
+	token, err := utils.GenerateAdminJWT(7, utils.RoleCustodian)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	userToken, err := utils.GenerateJWT(7)
+	if err != nil {
+		t.Fatalf("failed to issue user token: %v", err)
+	}
+
+	role, disabled := utils.RoleCustodian, false
+	currentRole := func(adminID uint64) (string, error) {
+		if adminID != 7 || disabled {
+			return "", errors.New("admin disabled")
+		}
+		return role, nil
+	}
+
+	router := gin.New()
+	router.POST("/key", AdminJWTAuthMiddleware(currentRole), RequirePermission(utils.PermKeyDecrypt), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	decrypt := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/key", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := decrypt(token); code != http.StatusOK {
+		t.Fatalf("custodian got %d, want 200", code)
+	}
+	if code := decrypt(userToken); code != http.StatusForbidden {
+		t.Fatalf("user token got %d, want 403", code)
+	}
+
+	// Downgrading the role revokes key:decrypt from the same token
+	role = utils.RoleOperator
+	if code := decrypt(token); code != http.StatusForbidden {
+		t.Fatalf("downgraded admin got %d, want 403", code)
+	}
+
+	// Disabling the admin locks the token out entirely
+	role, disabled = utils.RoleCustodian, true
+	if code := decrypt(token); code != http.StatusForbidden {
+		t.Fatalf("disabled admin got %d, want 403", code)
+	}
+}
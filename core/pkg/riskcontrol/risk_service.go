@@ -1,9 +1,11 @@
 package riskcontrol
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 
@@ -11,13 +13,22 @@ import (
 	"usdk-backend/internal/repository"
 )
 
+// AssetValuer converts an asset amount into its KUSD value
+type AssetValuer interface {
+	GetUSDValue(symbol string, amount decimal.Decimal) (decimal.Decimal, error)
+}
+
 type RiskService struct {
-	userRepo           *repository.UserRepository
+	userRepo            *repository.UserRepository
 	withdrawRequestRepo *repository.WithdrawRequestRepository
-	ledgerRepo         *repository.LedgerRepository
-	riskConfigRepo     *repository.RiskConfigRepository
-	blacklistRepo      *repository.BlacklistRepository
-	logger             *logrus.Logger
+	ledgerRepo          *repository.LedgerRepository
+	riskConfigRepo      *repository.RiskConfigRepository
+	blacklistRepo       *repository.BlacklistRepository
+	whitelistRepo       *repository.WithdrawalWhitelistRepository
+	kycRepo             *repository.UserKycRepository
+	valuer              AssetValuer // limits are in KUSD, withdrawals in asset units
+	logger              *logrus.Logger
+	rules               []Rule
 }
 
 func NewRiskService(
@@ -26,275 +37,255 @@ func NewRiskService(
 	ledgerRepo *repository.LedgerRepository,
 	riskConfigRepo *repository.RiskConfigRepository,
 	blacklistRepo *repository.BlacklistRepository,
+	whitelistRepo *repository.WithdrawalWhitelistRepository,
+	kycRepo *repository.UserKycRepository,
+	valuer AssetValuer,
 	logger *logrus.Logger,
 ) *RiskService {
 	return &RiskService{
-		userRepo:           userRepo,
+		userRepo:            userRepo,
 		withdrawRequestRepo: withdrawRequestRepo,
-		ledgerRepo:         ledgerRepo,
-		riskConfigRepo:     riskConfigRepo,
-		blacklistRepo:      blacklistRepo,
-		logger:             logger,
+		ledgerRepo:          ledgerRepo,
+		riskConfigRepo:      riskConfigRepo,
+		blacklistRepo:       blacklistRepo,
+		whitelistRepo:       whitelistRepo,
+		kycRepo:             kycRepo,
+		valuer:              valuer,
+		logger:              logger,
+		rules:               DefaultRules(),
 	}
 }
 
+// RegisterRule adds a rule, replacing any registered rule with the same name
+func (r *RiskService) RegisterRule(rule Rule) {
+	for i := range r.rules {
+		if r.rules[i].Name == rule.Name {
+			r.rules[i] = rule
+			return
+		}
+	}
+	r.rules = append(r.rules, rule)
+}
+
 type RiskCheckResult struct {
-	Approved     bool     `json:"approved"`
-	RiskScore    float64  `json:"riskScore"`
-	Reasons      []string `json:"reasons"`
-	RequiresKYC  bool     `json:"requiresKyc"`
-	MaxAmount    decimal.Decimal `json:"maxAmount"`
-	WaitingTime  int      `json:"waitingTimeHours"` // Hours to wait before approval
+	Approved    bool            `json:"approved"`
+	RiskScore   float64         `json:"riskScore"`
+	Reasons     []string        `json:"reasons"`
+	RequiresKYC bool            `json:"requiresKyc"`
+	MaxAmount   decimal.Decimal `json:"maxAmount"`
+	WaitingTime int             `json:"waitingTimeHours"` // Hours to wait before approval
+	Breakdown   []RuleResult    `json:"breakdown"`
 }
 
-// CheckWithdrawRisk performs comprehensive risk checks for withdrawal requests
+// CheckWithdrawRisk performs comprehensive risk checks for withdrawal requests.
+// amount is the KUSD value of the withdrawal, the unit of every rule limit.
 func (r *RiskService) CheckWithdrawRisk(userID uint64, amount decimal.Decimal, toAddress string, chainID uint64) (*RiskCheckResult, error) {
-	result := &RiskCheckResult{
-		Approved:    true,
-		RiskScore:   0.0,
-		Reasons:     make([]string, 0),
-		RequiresKYC: false,
-		MaxAmount:   decimal.Zero,
-		WaitingTime: 0,
-	}
-
-	// Check if address is blacklisted
-	if blacklisted, err := r.isAddressBlacklisted(toAddress, chainID); err != nil {
-		return nil, fmt.Errorf("failed to check blacklist: %v", err)
-	} else if blacklisted {
-		result.Approved = false
-		result.RiskScore += 100.0
-		result.Reasons = append(result.Reasons, "Destination address is blacklisted")
-		return result, nil
-	}
-
-	// Check daily withdrawal limits
-	if err := r.checkDailyLimits(userID, amount, result); err != nil {
-		return nil, fmt.Errorf("failed to check daily limits: %v", err)
-	}
-
-	// Check KYC requirements
-	if err := r.checkKYCRequirements(userID, amount, result); err != nil {
-		return nil, fmt.Errorf("failed to check KYC requirements: %v", err)
-	}
-
-	// Check suspicious patterns
-	if err := r.checkSuspiciousPatterns(userID, amount, result); err != nil {
-		return nil, fmt.Errorf("failed to check suspicious patterns: %v", err)
-	}
-
-	// Check velocity limits
-	if err := r.checkVelocityLimits(userID, amount, result); err != nil {
-		return nil, fmt.Errorf("failed to check velocity limits: %v", err)
+	input := RiskInput{UserID: userID, Amount: amount, Address: toAddress, ChainID: chainID}
+	result, decision, err := r.evaluate(RuleKindWithdraw, input)
+	if err != nil {
+		return nil, err
 	}
 
 	// Determine final approval based on risk score
-	if result.RiskScore >= 80.0 {
+	if result.RiskScore >= decision.RejectScore {
 		result.Approved = false
-	} else if result.RiskScore >= 50.0 {
-		result.Approved = false
-		result.WaitingTime = 24 // Require 24-hour hold
+	} else if result.RiskScore >= decision.ReviewScore {
+		// Still created, but held as pending_review until an admin decides
+		result.WaitingTime = decision.ReviewHoldHours
 		result.Reasons = append(result.Reasons, "High risk score requires manual review")
-	} else if result.RiskScore >= 30.0 {
-		result.WaitingTime = 1 // 1-hour delay for moderate risk
+	} else if result.RiskScore >= decision.DelayScore {
+		result.WaitingTime = decision.DelayHours
 	}
 
 	r.logger.WithFields(logrus.Fields{
-		"user_id":     userID,
-		"amount":      amount.String(),
-		"to_address":  toAddress,
-		"risk_score":  result.RiskScore,
-		"approved":    result.Approved,
-		"reasons":     result.Reasons,
+		"user_id":    userID,
+		"amount":     amount.String(),
+		"to_address": toAddress,
+		"risk_score": result.RiskScore,
+		"approved":   result.Approved,
+		"reasons":    result.Reasons,
 	}).Info("Withdrawal risk assessment completed")
 
 	return result, nil
 }
 
-// CheckDepositRisk performs risk checks for deposits
+// CheckDepositRisk performs risk checks for deposits, amount in KUSD
 func (r *RiskService) CheckDepositRisk(userID uint64, amount decimal.Decimal, fromAddress string, chainID uint64) (*RiskCheckResult, error) {
-	result := &RiskCheckResult{
-		Approved:  true,
-		RiskScore: 0.0,
-		Reasons:   make([]string, 0),
-	}
-
-	// Check if source address is blacklisted
-	if blacklisted, err := r.isAddressBlacklisted(fromAddress, chainID); err != nil {
-		return nil, fmt.Errorf("failed to check blacklist: %v", err)
-	} else if blacklisted {
-		result.Approved = false
-		result.RiskScore += 100.0
-		result.Reasons = append(result.Reasons, "Source address is blacklisted")
-		return result, nil
-	}
-
-	// Check daily deposit limits
-	if err := r.checkDailyDepositLimits(userID, amount, result); err != nil {
-		return nil, fmt.Errorf("failed to check daily deposit limits: %v", err)
+	input := RiskInput{UserID: userID, Amount: amount, Address: fromAddress, ChainID: chainID}
+	result, _, err := r.evaluate(RuleKindDeposit, input)
+	if err != nil {
+		return nil, err
 	}
-
 	return result, nil
 }
 
-func (r *RiskService) isAddressBlacklisted(address string, chainID uint64) (bool, error) {
-	blacklistAddr, err := r.blacklistRepo.FindByAddress(address, chainID)
-	if err != nil {
-		// If not found, it's not blacklisted
-		return false, nil
+// evaluate runs every registered rule of the given kind with its current
+// configuration and sums up their scores into a result with a per-rule breakdown.
+func (r *RiskService) evaluate(kind string, input RiskInput) (*RiskCheckResult, DecisionConfig, error) {
+	result := &RiskCheckResult{
+		Approved:    true,
+		RiskScore:   0.0,
+		Reasons:     make([]string, 0),
+		RequiresKYC: false,
+		MaxAmount:   decimal.Zero,
+		WaitingTime: 0,
+		Breakdown:   make([]RuleResult, 0),
 	}
-	
-	return blacklistAddr != nil && blacklistAddr.IsActive, nil
-}
 
-func (r *RiskService) checkDailyLimits(userID uint64, amount decimal.Decimal, result *RiskCheckResult) error {
-	// Get max daily withdrawal limit from config
-	maxDailyLimit, err := r.getRiskConfigDecimal("max_daily_withdrawal", decimal.NewFromInt(50000))
+	configs, err := r.loadRiskConfigs()
 	if err != nil {
-		return err
+		return nil, DecisionConfig{}, fmt.Errorf("failed to load risk configs: %v", err)
 	}
 
-	// Get user's withdrawals in the last 24 hours
-	since := time.Now().Add(-24 * time.Hour)
-	dailyWithdrawn, err := r.getDailyWithdrawnAmount(userID, since)
-	if err != nil {
-		return err
-	}
+	for _, rule := range r.rules {
+		if rule.Kind != kind {
+			continue
+		}
 
-	totalWithToday := dailyWithdrawn.Add(amount)
-	
-	if totalWithToday.GreaterThan(maxDailyLimit) {
-		result.Approved = false
-		result.RiskScore += 50.0
-		result.MaxAmount = maxDailyLimit.Sub(dailyWithdrawn)
-		result.Reasons = append(result.Reasons, 
-			fmt.Sprintf("Daily withdrawal limit exceeded. Limit: %s, Already withdrawn: %s", 
-				maxDailyLimit.String(), dailyWithdrawn.String()))
-	} else if totalWithToday.GreaterThan(maxDailyLimit.Mul(decimal.NewFromFloat(0.8))) {
-		// Warning when approaching 80% of limit
-		result.RiskScore += 20.0
-		result.Reasons = append(result.Reasons, "Approaching daily withdrawal limit")
-	}
+		cfg, err := ruleConfig(rule, configs)
+		if err != nil {
+			return nil, DecisionConfig{}, err
+		}
 
-	return nil
-}
+		ruleResult := RuleResult{Rule: rule.Name, Enabled: cfg.Enabled}
+		if !cfg.Enabled {
+			result.Breakdown = append(result.Breakdown, ruleResult)
+			continue
+		}
 
-func (r *RiskService) checkKYCRequirements(userID uint64, amount decimal.Decimal, result *RiskCheckResult) error {
-	kycLimit, err := r.getRiskConfigDecimal("kyc_withdrawal_limit", decimal.NewFromInt(1000))
-	if err != nil {
-		return err
-	}
+		outcome, err := rule.Evaluate(r, input, cfg)
+		if err != nil {
+			return nil, DecisionConfig{}, fmt.Errorf("risk rule %s failed: %v", rule.Name, err)
+		}
 
-	if amount.GreaterThan(kycLimit) {
-		// Check if user has completed KYC (this would need to be implemented)
-		hasKYC := false // Placeholder - implement actual KYC check
-		
-		if !hasKYC {
+		ruleResult.Triggered = outcome.Triggered
+		ruleResult.Score = outcome.Score
+		ruleResult.Blocking = outcome.Triggered && cfg.Block
+		ruleResult.Reason = outcome.Reason
+		result.Breakdown = append(result.Breakdown, ruleResult)
+
+		result.RiskScore += outcome.Score
+		if outcome.Reason != "" {
+			result.Reasons = append(result.Reasons, outcome.Reason)
+		}
+		if ruleResult.Blocking {
 			result.Approved = false
+		}
+		if outcome.Triggered && outcome.MaxAmount != nil {
+			result.MaxAmount = *outcome.MaxAmount
+		}
+		if outcome.RequiresKYC {
 			result.RequiresKYC = true
-			result.RiskScore += 70.0
-			result.Reasons = append(result.Reasons, 
-				fmt.Sprintf("KYC required for withdrawals over %s", kycLimit.String()))
 		}
 	}
 
-	return nil
+	decision := defaultDecisionConfig
+	if raw, ok := configs["risk_decision"]; ok {
+		if err := json.Unmarshal([]byte(raw), &decision); err != nil {
+			return nil, DecisionConfig{}, fmt.Errorf("invalid risk_decision config: %v", err)
+		}
+	}
+
+	return result, decision, nil
 }
 
-func (r *RiskService) checkSuspiciousPatterns(userID uint64, amount decimal.Decimal, result *RiskCheckResult) error {
-	threshold, err := r.getRiskConfigDecimal("suspicious_pattern_threshold", decimal.NewFromInt(10000))
+// loadRiskConfigs reads all risk_configs rows so rule changes apply without a restart
+func (r *RiskService) loadRiskConfigs() (map[string]string, error) {
+	rows, err := r.riskConfigRepo.FindAll()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Check for rapid consecutive withdrawals
-	recentWithdrawals, err := r.getRecentWithdrawals(userID, time.Hour)
-	if err != nil {
-		return err
+	configs := make(map[string]string, len(rows))
+	for _, row := range rows {
+		configs[row.ConfigKey] = row.ConfigValue
 	}
+	return configs, nil
+}
 
-	if len(recentWithdrawals) >= 3 {
-		result.RiskScore += 30.0
-		result.Reasons = append(result.Reasons, "Multiple withdrawals in short time period")
+// ruleConfig overlays the rule's "risk_rule.<name>" row on its defaults. Without
+// such a row the threshold still honours the rule's legacy flat key.
+func ruleConfig(rule Rule, configs map[string]string) (RuleConfig, error) {
+	cfg := rule.Defaults
+	cfg.Params = make(map[string]interface{}, len(rule.Defaults.Params))
+	for k, v := range rule.Defaults.Params {
+		cfg.Params[k] = v
 	}
 
-	// Check for large amount relative to user's typical activity
-	if amount.GreaterThan(threshold) {
-		avgAmount, err := r.getUserAverageWithdrawal(userID)
-		if err == nil && avgAmount.GreaterThan(decimal.Zero) {
-			ratio := amount.Div(avgAmount)
-			if ratio.GreaterThan(decimal.NewFromInt(10)) {
-				result.RiskScore += 40.0
-				result.Reasons = append(result.Reasons, "Withdrawal amount significantly higher than usual")
+	raw, ok := configs["risk_rule."+rule.Name]
+	if !ok {
+		if rule.LegacyThresholdKey != "" {
+			if value, ok := configs[rule.LegacyThresholdKey]; ok {
+				if threshold, err := decimal.NewFromString(value); err == nil {
+					cfg.Threshold = threshold
+				}
 			}
 		}
+		return cfg, nil
 	}
 
-	return nil
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return RuleConfig{}, fmt.Errorf("invalid config for risk rule %s: %v", rule.Name, err)
+	}
+	return cfg, nil
 }
 
-func (r *RiskService) checkVelocityLimits(userID uint64, amount decimal.Decimal, result *RiskCheckResult) error {
-	// Check withdrawal velocity (frequency and amounts)
-	weeklyLimit := decimal.NewFromInt(100000) // $100K per week
-	weeklyWithdrawn, err := r.getWeeklyWithdrawnAmount(userID)
+func (r *RiskService) isAddressBlacklisted(address string, chainID uint64) (bool, error) {
+	blacklistAddr, err := r.blacklistRepo.FindByAddress(address, chainID)
 	if err != nil {
-		return err
-	}
-
-	if weeklyWithdrawn.Add(amount).GreaterThan(weeklyLimit) {
-		result.RiskScore += 35.0
-		result.Reasons = append(result.Reasons, "Weekly withdrawal limit approached")
+		// If not found, it's not blacklisted
+		return false, nil
 	}
 
-	return nil
+	return blacklistAddr != nil && blacklistAddr.IsActive, nil
 }
 
-func (r *RiskService) checkDailyDepositLimits(userID uint64, amount decimal.Decimal, result *RiskCheckResult) error {
-	maxDailyDeposit, err := r.getRiskConfigDecimal("max_daily_deposit", decimal.NewFromInt(100000))
-	if err != nil {
-		return err
-	}
+// Helper functions
 
-	since := time.Now().Add(-24 * time.Hour)
-	dailyDeposited, err := r.getDailyDepositedAmount(userID, since)
-	if err != nil {
-		return err
+func (r *RiskService) isAddressWhitelisted(userID, chainID uint64, address string) (bool, error) {
+	if common.IsHexAddress(address) {
+		address = common.HexToAddress(address).Hex()
 	}
+	return r.whitelistRepo.IsWhitelisted(userID, chainID, address, time.Now())
+}
 
-	if dailyDeposited.Add(amount).GreaterThan(maxDailyDeposit) {
-		result.Approved = false
-		result.RiskScore += 30.0
-		result.Reasons = append(result.Reasons, "Daily deposit limit exceeded")
-	}
+// activeWithdrawStatuses are the statuses of requests that have moved, or may
+// still move, funds off the platform. Rejected and failed requests are excluded.
+var activeWithdrawStatuses = []string{"pending", "pending_review", "approved", "processing", "completed"}
 
-	return nil
+func (r *RiskService) getDailyWithdrawnAmount(userID uint64, since time.Time) (decimal.Decimal, error) {
+	amount, _, err := r.withdrawnKusdSince(userID, since, activeWithdrawStatuses)
+	return amount, err
 }
 
-// Helper functions
-
-func (r *RiskService) getRiskConfigDecimal(key string, defaultValue decimal.Decimal) (decimal.Decimal, error) {
-	config, err := r.riskConfigRepo.FindByKey(key)
+// withdrawnKusdSince returns the KUSD value and number of the user's
+// withdrawals created since the given time whose status is one of statuses.
+// Each asset's total is valued on its own before they are added up.
+func (r *RiskService) withdrawnKusdSince(userID uint64, since time.Time, statuses []string) (decimal.Decimal, int64, error) {
+	sums, err := r.withdrawRequestRepo.SumAmountByAssetSince(userID, since, statuses)
 	if err != nil {
-		return defaultValue, nil // Use default if not found
+		return decimal.Zero, 0, fmt.Errorf("failed to sum withdrawals: %v", err)
 	}
-	
-	value, err := decimal.NewFromString(config.ConfigValue)
-	if err != nil {
-		return defaultValue, nil
-	}
-	
-	return value, nil
-}
 
-func (r *RiskService) getDailyWithdrawnAmount(userID uint64, since time.Time) (decimal.Decimal, error) {
-	// This would query completed withdrawals in the timeframe
-	// For now, return zero - implement actual query logic
-	return decimal.Zero, nil
+	total, count := decimal.Zero, int64(0)
+	for _, sum := range sums {
+		value, err := r.valuer.GetUSDValue(sum.Symbol, sum.Total)
+		if err != nil {
+			return decimal.Zero, 0, fmt.Errorf("failed to value %s withdrawals: %v", sum.Symbol, err)
+		}
+		total = total.Add(value)
+		count += sum.Count
+	}
+	return total, count, nil
 }
 
 func (r *RiskService) getDailyDepositedAmount(userID uint64, since time.Time) (decimal.Decimal, error) {
-	// This would query completed deposits in the timeframe
-	return decimal.Zero, nil
+	// Only confirmed deposits reach the ledger
+	amount, err := r.ledgerRepo.SumKusdDeltaSince(userID, "deposit", since)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum deposits: %v", err)
+	}
+	return amount, nil
 }
 
 func (r *RiskService) getWeeklyWithdrawnAmount(userID uint64) (decimal.Decimal, error) {
@@ -303,11 +294,19 @@ func (r *RiskService) getWeeklyWithdrawnAmount(userID uint64) (decimal.Decimal,
 }
 
 func (r *RiskService) getRecentWithdrawals(userID uint64, duration time.Duration) ([]model.WithdrawRequest, error) {
-	// Query recent withdrawal requests
-	return []model.WithdrawRequest{}, nil
+	requests, err := r.withdrawRequestRepo.FindByUserSince(userID, time.Now().Add(-duration), activeWithdrawStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent withdrawals: %v", err)
+	}
+	return requests, nil
 }
 
 func (r *RiskService) getUserAverageWithdrawal(userID uint64) (decimal.Decimal, error) {
-	// Calculate user's average withdrawal amount over last 30 days
-	return decimal.NewFromInt(1000), nil // Placeholder
-}
\ No newline at end of file
+	// Average of completed withdrawals over the last 30 days
+	since := time.Now().Add(-30 * 24 * time.Hour)
+	total, count, err := r.withdrawnKusdSince(userID, since, []string{"completed"})
+	if err != nil || count == 0 {
+		return decimal.Zero, err
+	}
+	return total.Div(decimal.NewFromInt(count)), nil
+}
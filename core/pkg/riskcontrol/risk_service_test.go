@@ -0,0 +1,291 @@
+package riskcontrol
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"usdk-backend/internal/model"
+	"usdk-backend/internal/repository"
+	"usdk-backend/pkg/database"
+)
+
+// fixedPriceValuer values assets at a fixed USD price per symbol
+type fixedPriceValuer map[string]decimal.Decimal
+
+func (v fixedPriceValuer) GetUSDValue(symbol string, amount decimal.Decimal) (decimal.Decimal, error) {
+	return amount.Mul(v[symbol]), nil
+}
+
+func newTestRiskService(t *testing.T) (*RiskService, *gorm.DB) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	service := NewRiskService(
+		repository.NewUserRepository(db),
+		repository.NewWithdrawRequestRepository(db),
+		repository.NewLedgerRepository(db),
+		repository.NewRiskConfigRepository(db),
+		repository.NewBlacklistRepository(db),
+		repository.NewWithdrawalWhitelistRepository(db),
+		repository.NewUserKycRepository(db),
+		fixedPriceValuer{"ETH": decimal.NewFromInt(2000), "USDT": decimal.NewFromInt(1)},
+		log,
+	)
+	return service, db
+}
+
+func TestWithdrawLimitsValueEachAssetInKusd(t *testing.T) {
+	r, db := newTestRiskService(t)
+
+	user := &model.User{}
+	eth := &model.Asset{Symbol: "ETH", Name: "Ether", Decimals: 18, AssetType: "eth"}
+	usdt := &model.Asset{Symbol: "USDT", Name: "Tether", Decimals: 6, AssetType: "stable"}
+	for _, record := range []interface{}{user, eth, usdt} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatalf("failed to seed %T: %v", record, err)
+		}
+	}
+
+	// 20 ETH and 5000 USDT are 45000 KUSD, though only 5020 in raw units
+	for _, req := range []struct {
+		asset  *model.Asset
+		amount int64
+	}{{eth, 20}, {usdt, 5000}} {
+		err := db.Create(&model.WithdrawRequest{
+			UserID:    user.ID,
+			ChainID:   1,
+			AssetID:   req.asset.ID,
+			Amount:    decimal.NewFromInt(req.amount),
+			ToAddress: "0x00000000000000000000000000000000000000aa",
+			Status:    "completed",
+			CreatedAt: time.Now().Add(-time.Hour),
+		}).Error
+		if err != nil {
+			t.Fatalf("failed to seed request: %v", err)
+		}
+	}
+
+	daily, err := r.getDailyWithdrawnAmount(user.ID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to sum withdrawals: %v", err)
+	}
+	if !daily.Equal(decimal.NewFromInt(45000)) {
+		t.Fatalf("got %s KUSD withdrawn today, want 45000", daily)
+	}
+
+	average, err := r.getUserAverageWithdrawal(user.ID)
+	if err != nil {
+		t.Fatalf("failed to average withdrawals: %v", err)
+	}
+	if !average.Equal(decimal.NewFromInt(22500)) {
+		t.Fatalf("got %s KUSD average withdrawal, want 22500", average)
+	}
+
+	// Another 6000 KUSD crosses the default 50000 KUSD daily limit
+	result, err := r.CheckWithdrawRisk(user.ID, decimal.NewFromInt(6000), "0x00000000000000000000000000000000000000bb", 1)
+	if err != nil {
+		t.Fatalf("risk check failed: %v", err)
+	}
+	if result.Approved {
+		t.Fatalf("withdrawal past the daily limit was approved: %+v", result.Breakdown)
+	}
+	for _, rule := range result.Breakdown {
+		if rule.Rule != "daily_withdraw_limit" {
+			continue
+		}
+		if !rule.Blocking || !result.MaxAmount.Equal(decimal.NewFromInt(5000)) {
+			t.Fatalf("got %+v with max amount %s, want a block leaving 5000 KUSD", rule, result.MaxAmount)
+		}
+		return
+	}
+	t.Fatalf("daily_withdraw_limit missing from %+v", result.Breakdown)
+}
+
+func TestKYCRequiredFollowsTierLimits(t *testing.T) {
+	r, db := newTestRiskService(t)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	record := &model.UserKyc{UserID: user.ID}
+	if err := db.Create(record).Error; err != nil {
+		t.Fatalf("failed to seed KYC record: %v", err)
+	}
+
+	// Defaults: 1000 KUSD without KYC, 10000 at basic, unlimited at full
+	var cfg RuleConfig
+	for _, rule := range DefaultRules() {
+		if rule.Name == "kyc_required" {
+			cfg = rule.Defaults
+		}
+	}
+	tests := []struct {
+		level        int
+		amount       int64
+		wantRequired bool
+	}{
+		{0, 1000, false},
+		{0, 1001, true},
+		{1, 1001, false},
+		{1, 10000, false},
+		{1, 10001, true},
+		{2, 10001, false},
+		{2, 1000000, false},
+	}
+
+	for _, tt := range tests {
+		if err := db.Model(record).Update("level", tt.level).Error; err != nil {
+			t.Fatalf("failed to set KYC level: %v", err)
+		}
+		outcome, err := evaluateKYCRequired(r, RiskInput{UserID: user.ID, Amount: decimal.NewFromInt(tt.amount)}, cfg)
+		if err != nil {
+			t.Fatalf("level %d, %d KUSD: %v", tt.level, tt.amount, err)
+		}
+		if outcome.Triggered != tt.wantRequired || outcome.RequiresKYC != tt.wantRequired {
+			t.Fatalf("level %d, %d KUSD: got %+v, want KYC required %v", tt.level, tt.amount, outcome, tt.wantRequired)
+		}
+	}
+}
+
+func TestRuleConfigOverridesAndBreakdown(t *testing.T) {
+	r, db := newTestRiskService(t)
+
+	user := &model.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	if err := db.Create(&model.UserKyc{UserID: user.ID}).Error; err != nil {
+		t.Fatalf("failed to seed KYC record: %v", err)
+	}
+
+	var withdrawRules []string
+	for _, rule := range DefaultRules() {
+		if rule.Kind == RuleKindWithdraw {
+			withdrawRules = append(withdrawRules, rule.Name)
+		}
+	}
+
+	// setConfigs replaces every risk_configs row
+	setConfigs := func(configs map[string]string) {
+		t.Helper()
+		if err := db.Where("1 = 1").Delete(&model.RiskConfig{}).Error; err != nil {
+			t.Fatalf("failed to clear risk configs: %v", err)
+		}
+		for key, value := range configs {
+			if err := db.Create(&model.RiskConfig{ConfigKey: key, ConfigValue: value}).Error; err != nil {
+				t.Fatalf("failed to set %s: %v", key, err)
+			}
+		}
+	}
+	check := func() (*RiskCheckResult, map[string]RuleResult) {
+		t.Helper()
+		result, err := r.CheckWithdrawRisk(user.ID, decimal.NewFromInt(500), "0x00000000000000000000000000000000000000bb", 1)
+		if err != nil {
+			t.Fatalf("risk check failed: %v", err)
+		}
+		if len(result.Breakdown) != len(withdrawRules) {
+			t.Fatalf("got breakdown %+v, want a line for each of %v", result.Breakdown, withdrawRules)
+		}
+		lines := make(map[string]RuleResult, len(result.Breakdown))
+		for i, line := range result.Breakdown {
+			if line.Rule != withdrawRules[i] {
+				t.Fatalf("breakdown line %d is %s, want %s", i, line.Rule, withdrawRules[i])
+			}
+			lines[line.Rule] = line
+		}
+		return result, lines
+	}
+
+	// With the defaults nothing fires on a small withdrawal
+	result, lines := check()
+	if !result.Approved || result.RiskScore != 0 || result.WaitingTime != 0 {
+		t.Fatalf("got %+v with the defaults, want approved without a score", result)
+	}
+	for name, line := range lines {
+		if !line.Enabled || line.Triggered || line.Score != 0 {
+			t.Fatalf("rule %s: got %+v with the defaults", name, line)
+		}
+	}
+
+	// A rule row overrides weight and threshold; one scoring 55 is over the
+	// default review score but does not block
+	setConfigs(map[string]string{"risk_rule.weekly_velocity": `{"enabled": true, "weight": 55, "threshold": "100"}`})
+	result, lines = check()
+	if line := lines["weekly_velocity"]; !line.Triggered || line.Score != 55 || line.Blocking {
+		t.Fatalf("got weekly_velocity %+v, want triggered with score 55", line)
+	}
+	if !result.Approved || result.RiskScore != 55 || result.WaitingTime != defaultDecisionConfig.ReviewHoldHours {
+		t.Fatalf("got %+v, want approved after a %d hour review hold", result, defaultDecisionConfig.ReviewHoldHours)
+	}
+
+	// The decision thresholds are tunable too
+	setConfigs(map[string]string{
+		"risk_rule.weekly_velocity": `{"enabled": true, "weight": 55, "threshold": "100"}`,
+		"risk_decision":             `{"rejectScore": 50, "reviewScore": 40}`,
+	})
+	if result, _ = check(); result.Approved {
+		t.Fatalf("got %+v, want rejected at the lowered reject score", result)
+	}
+
+	// Without a rule row the legacy flat key still sets the threshold, and a
+	// blocking rule rejects whatever the score
+	setConfigs(map[string]string{"kyc_withdrawal_limit": "400"})
+	result, lines = check()
+	if line := lines["kyc_required"]; !line.Triggered || !line.Blocking {
+		t.Fatalf("got kyc_required %+v, want it blocking past the legacy 400 KUSD limit", line)
+	}
+	if result.Approved || !result.RequiresKYC {
+		t.Fatalf("got %+v, want rejected pending KYC", result)
+	}
+
+	// A disabled rule is listed but not evaluated, and its row wins over the
+	// legacy key
+	setConfigs(map[string]string{
+		"kyc_withdrawal_limit":   "400",
+		"risk_rule.kyc_required": `{"enabled": false}`,
+	})
+	result, lines = check()
+	if line := lines["kyc_required"]; line.Enabled || line.Triggered {
+		t.Fatalf("got kyc_required %+v, want it disabled", line)
+	}
+	if !result.Approved || result.RequiresKYC {
+		t.Fatalf("got %+v with kyc_required disabled, want approved", result)
+	}
+
+	// Fields and parameters missing from a row keep their defaults: only the
+	// warning ratio changes, the 20 point warning weight stays
+	setConfigs(map[string]string{"risk_rule.daily_withdraw_limit": `{"enabled": true, "params": {"warnRatio": 0.001}}`})
+	_, lines = check()
+	if line := lines["daily_withdraw_limit"]; line.Triggered || line.Score != 20 || line.Reason == "" {
+		t.Fatalf("got daily_withdraw_limit %+v, want a 20 point warning", line)
+	}
+
+	// A malformed row fails the check rather than silently using defaults
+	setConfigs(map[string]string{"risk_rule.rapid_withdrawals": `{"weight": "high"}`})
+	if _, err := r.CheckWithdrawRisk(user.ID, decimal.NewFromInt(500), "0x00000000000000000000000000000000000000bb", 1); err == nil {
+		t.Fatalf("risk check passed with a malformed rule config")
+	}
+}
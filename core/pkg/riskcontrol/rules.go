@@ -0,0 +1,305 @@
+package riskcontrol
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rule kinds, a rule only runs for the checks of its kind
+const (
+	RuleKindWithdraw = "withdraw"
+	RuleKindDeposit  = "deposit"
+)
+
+// RuleConfig is the tunable part of a rule. It is stored as JSON in the
+// risk_configs row "risk_rule.<name>"; fields missing there keep their default.
+type RuleConfig struct {
+	Enabled   bool                   `json:"enabled"`
+	Weight    float64                `json:"weight"`    // score added when the rule fires
+	Threshold decimal.Decimal        `json:"threshold"` // rule-specific trigger value
+	Block     bool                   `json:"block"`     // a hit rejects the request regardless of score
+	Params    map[string]interface{} `json:"params"`
+}
+
+// RiskInput is what a rule is evaluated against
+type RiskInput struct {
+	UserID  uint64
+	Amount  decimal.Decimal
+	Address string
+	ChainID uint64
+}
+
+// RuleOutcome is what a rule reports when evaluated
+type RuleOutcome struct {
+	Triggered   bool
+	Score       float64
+	Reason      string
+	MaxAmount   *decimal.Decimal // largest amount that would not trigger the rule
+	RequiresKYC bool
+}
+
+// RuleResult is one line of the per-rule breakdown of an evaluation
+type RuleResult struct {
+	Rule      string  `json:"rule"`
+	Enabled   bool    `json:"enabled"`
+	Triggered bool    `json:"triggered"`
+	Score     float64 `json:"score"`
+	Blocking  bool    `json:"blocking"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// Rule is a single registered risk check
+type Rule struct {
+	Name     string
+	Kind     string
+	Defaults RuleConfig
+	// LegacyThresholdKey is the older flat risk_configs key whose value seeds
+	// the threshold when no rule row exists yet
+	LegacyThresholdKey string
+	Evaluate           func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error)
+}
+
+// DecisionConfig maps the total score to a decision. It is stored as JSON in
+// the risk_configs row "risk_decision".
+type DecisionConfig struct {
+	RejectScore     float64 `json:"rejectScore"`
+	ReviewScore     float64 `json:"reviewScore"`
+	ReviewHoldHours int     `json:"reviewHoldHours"`
+	DelayScore      float64 `json:"delayScore"`
+	DelayHours      int     `json:"delayHours"`
+}
+
+var defaultDecisionConfig = DecisionConfig{
+	RejectScore:     80,
+	ReviewScore:     50,
+	ReviewHoldHours: 24,
+	DelayScore:      30,
+	DelayHours:      1,
+}
+
+// paramFloat reads a numeric rule parameter, falling back to defaultVal
+func (c RuleConfig) paramFloat(key string, defaultVal float64) float64 {
+	switch v := c.Params[key].(type) {
+	case float64:
+		return v
+	case string:
+		if d, err := decimal.NewFromString(v); err == nil {
+			f, _ := d.Float64()
+			return f
+		}
+	}
+	return defaultVal
+}
+
+// DefaultRules returns the built-in rules registered by NewRiskService
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:     "withdraw_blacklist",
+			Kind:     RuleKindWithdraw,
+			Defaults: RuleConfig{Enabled: true, Weight: 100, Block: true},
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				blacklisted, err := r.isAddressBlacklisted(in.Address, in.ChainID)
+				if err != nil || !blacklisted {
+					return RuleOutcome{}, err
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Destination address is blacklisted"}, nil
+			},
+		},
+		{
+			Name:     "whitelist_only",
+			Kind:     RuleKindWithdraw,
+			Defaults: RuleConfig{Enabled: true, Weight: 100, Block: true},
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				user, err := r.userRepo.FindByID(in.UserID)
+				if err != nil || !user.WhitelistOnlyAt(time.Now()) {
+					return RuleOutcome{}, err
+				}
+				whitelisted, err := r.isAddressWhitelisted(in.UserID, in.ChainID, in.Address)
+				if err != nil || whitelisted {
+					return RuleOutcome{}, err
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Whitelist-only mode: destination address is not whitelisted or still cooling off"}, nil
+			},
+		},
+		{
+			// A negative weight lowers the score for trusted destinations
+			Name:     "whitelisted_destination",
+			Kind:     RuleKindWithdraw,
+			Defaults: RuleConfig{Enabled: true, Weight: -20},
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				whitelisted, err := r.isAddressWhitelisted(in.UserID, in.ChainID, in.Address)
+				if err != nil || !whitelisted {
+					return RuleOutcome{}, err
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight}, nil
+			},
+		},
+		{
+			Name:               "daily_withdraw_limit",
+			Kind:               RuleKindWithdraw,
+			Defaults:           RuleConfig{Enabled: true, Weight: 50, Threshold: decimal.NewFromInt(50000), Block: true, Params: map[string]interface{}{"warnRatio": 0.8, "warnWeight": 20.0}},
+			LegacyThresholdKey: "max_daily_withdrawal",
+			Evaluate:           evaluateDailyWithdrawLimit,
+		},
+		{
+			// Threshold is the limit without KYC, params hold the per-level limits (0 = unlimited)
+			Name:               "kyc_required",
+			Kind:               RuleKindWithdraw,
+			Defaults:           RuleConfig{Enabled: true, Weight: 70, Threshold: decimal.NewFromInt(1000), Block: true, Params: map[string]interface{}{"level1Limit": 10000.0, "level2Limit": 0.0}},
+			LegacyThresholdKey: "kyc_withdrawal_limit",
+			Evaluate:           evaluateKYCRequired,
+		},
+		{
+			Name:     "rapid_withdrawals",
+			Kind:     RuleKindWithdraw,
+			Defaults: RuleConfig{Enabled: true, Weight: 30, Threshold: decimal.NewFromInt(3), Params: map[string]interface{}{"windowMinutes": 60.0}},
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				window := time.Duration(cfg.paramFloat("windowMinutes", 60)) * time.Minute
+				recent, err := r.getRecentWithdrawals(in.UserID, window)
+				if err != nil {
+					return RuleOutcome{}, err
+				}
+				if decimal.NewFromInt(int64(len(recent))).LessThan(cfg.Threshold) {
+					return RuleOutcome{}, nil
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Multiple withdrawals in short time period"}, nil
+			},
+		},
+		{
+			Name:               "unusual_amount",
+			Kind:               RuleKindWithdraw,
+			Defaults:           RuleConfig{Enabled: true, Weight: 40, Threshold: decimal.NewFromInt(10000), Params: map[string]interface{}{"averageMultiple": 10.0}},
+			LegacyThresholdKey: "suspicious_pattern_threshold",
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				if !in.Amount.GreaterThan(cfg.Threshold) {
+					return RuleOutcome{}, nil
+				}
+				avgAmount, err := r.getUserAverageWithdrawal(in.UserID)
+				if err != nil || !avgAmount.GreaterThan(decimal.Zero) {
+					return RuleOutcome{}, err
+				}
+				multiple := decimal.NewFromFloat(cfg.paramFloat("averageMultiple", 10))
+				if !in.Amount.Div(avgAmount).GreaterThan(multiple) {
+					return RuleOutcome{}, nil
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Withdrawal amount significantly higher than usual"}, nil
+			},
+		},
+		{
+			Name:     "weekly_velocity",
+			Kind:     RuleKindWithdraw,
+			Defaults: RuleConfig{Enabled: true, Weight: 35, Threshold: decimal.NewFromInt(100000)},
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				weeklyWithdrawn, err := r.getWeeklyWithdrawnAmount(in.UserID)
+				if err != nil {
+					return RuleOutcome{}, err
+				}
+				if !weeklyWithdrawn.Add(in.Amount).GreaterThan(cfg.Threshold) {
+					return RuleOutcome{}, nil
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Weekly withdrawal limit approached"}, nil
+			},
+		},
+		{
+			Name:     "deposit_blacklist",
+			Kind:     RuleKindDeposit,
+			Defaults: RuleConfig{Enabled: true, Weight: 100, Block: true},
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				blacklisted, err := r.isAddressBlacklisted(in.Address, in.ChainID)
+				if err != nil || !blacklisted {
+					return RuleOutcome{}, err
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Source address is blacklisted"}, nil
+			},
+		},
+		{
+			Name:               "daily_deposit_limit",
+			Kind:               RuleKindDeposit,
+			Defaults:           RuleConfig{Enabled: true, Weight: 30, Threshold: decimal.NewFromInt(100000), Block: true},
+			LegacyThresholdKey: "max_daily_deposit",
+			Evaluate: func(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+				dailyDeposited, err := r.getDailyDepositedAmount(in.UserID, time.Now().Add(-24*time.Hour))
+				if err != nil {
+					return RuleOutcome{}, err
+				}
+				if !dailyDeposited.Add(in.Amount).GreaterThan(cfg.Threshold) {
+					return RuleOutcome{}, nil
+				}
+				return RuleOutcome{Triggered: true, Score: cfg.Weight, Reason: "Daily deposit limit exceeded"}, nil
+			},
+		},
+	}
+}
+
+func evaluateDailyWithdrawLimit(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+	dailyWithdrawn, err := r.getDailyWithdrawnAmount(in.UserID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return RuleOutcome{}, err
+	}
+
+	totalWithToday := dailyWithdrawn.Add(in.Amount)
+	if totalWithToday.GreaterThan(cfg.Threshold) {
+		maxAmount := cfg.Threshold.Sub(dailyWithdrawn)
+		return RuleOutcome{
+			Triggered: true,
+			Score:     cfg.Weight,
+			MaxAmount: &maxAmount,
+			Reason: fmt.Sprintf("Daily withdrawal limit exceeded. Limit: %s, Already withdrawn: %s",
+				cfg.Threshold.String(), dailyWithdrawn.String()),
+		}, nil
+	}
+
+	// Warning when approaching the limit, this alone never blocks
+	warnRatio := decimal.NewFromFloat(cfg.paramFloat("warnRatio", 0.8))
+	if totalWithToday.GreaterThan(cfg.Threshold.Mul(warnRatio)) {
+		return RuleOutcome{Score: cfg.paramFloat("warnWeight", 20), Reason: "Approaching daily withdrawal limit"}, nil
+	}
+
+	return RuleOutcome{}, nil
+}
+
+func evaluateKYCRequired(r *RiskService, in RiskInput, cfg RuleConfig) (RuleOutcome, error) {
+	// Limit per KYC level, zero means unlimited
+	limits := []decimal.Decimal{
+		cfg.Threshold,
+		decimal.NewFromFloat(cfg.paramFloat("level1Limit", 10000)),
+		decimal.NewFromFloat(cfg.paramFloat("level2Limit", 0)),
+	}
+
+	requiredLevel := -1
+	for level, limit := range limits {
+		if limit.IsZero() || !in.Amount.GreaterThan(limit) {
+			requiredLevel = level
+			break
+		}
+	}
+
+	if requiredLevel == 0 {
+		return RuleOutcome{}, nil
+	}
+	if requiredLevel < 0 {
+		return RuleOutcome{
+			Triggered: true,
+			Score:     cfg.Weight,
+			Reason:    fmt.Sprintf("Withdrawal exceeds the maximum KYC limit of %s", limits[len(limits)-1].String()),
+		}, nil
+	}
+
+	level, err := r.kycRepo.GetApprovedLevel(in.UserID)
+	if err != nil {
+		return RuleOutcome{}, err
+	}
+	if level >= requiredLevel {
+		return RuleOutcome{}, nil
+	}
+
+	return RuleOutcome{
+		Triggered:   true,
+		Score:       cfg.Weight,
+		Reason:      fmt.Sprintf("KYC level %d required for withdrawals over %s", requiredLevel, limits[requiredLevel-1].String()),
+		RequiresKYC: true,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"usdk-backend/pkg/wallet"
+)
+
+// KeySigner signs with a private key held in memory
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{
+		key:     key,
+		address: crypto.PubkeyToAddress(key.PublicKey),
+	}
+}
+
+// NewKeySignerFromHex loads a raw hex private key
+func NewKeySignerFromHex(privateKeyHex string) (*KeySigner, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key: %v", err)
+	}
+	return NewKeySigner(key), nil
+}
+
+// NewKeystoreSigner decrypts a go-ethereum (Web3 Secret Storage) keystore file
+func NewKeystoreSigner(path, password string) (*KeySigner, error) {
+	if path == "" {
+		return nil, fmt.Errorf("keystore path is required")
+	}
+
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %v", err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore %s: %v", path, err)
+	}
+	return NewKeySigner(key.PrivateKey), nil
+}
+
+// NewHDSigner signs with the HD wallet key at derivationPath
+func NewHDSigner(hd *wallet.HDWalletService, derivationPath string) (*KeySigner, error) {
+	if derivationPath == "" {
+		return nil, fmt.Errorf("derivation path is required")
+	}
+
+	key, err := hd.DeriveECDSAKey(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySigner(key), nil
+}
+
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *KeySigner) SignTx(_ context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %v", err)
+	}
+	return signed, nil
+}
@@ -0,0 +1,126 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const remoteSignTimeout = 10 * time.Second
+
+// RemoteSigner asks an external signer holding the key to sign, over the
+// eth_signTransaction JSON-RPC method of web3signer and compatible services.
+// The returned transaction is checked against the request and the account
+// before it is used.
+type RemoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+// remoteTxArgs is the eth_signTransaction request of an EIP-1559 transaction
+type remoteTxArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to,omitempty"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// NewRemoteSigner connects to the signer at url and checks it serves address
+func NewRemoteSigner(ctx context.Context, url, authToken, address string) (*RemoteSigner, error) {
+	if url == "" {
+		return nil, fmt.Errorf("remote signer URL is required")
+	}
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("remote signer requires a valid account address")
+	}
+
+	var options []rpc.ClientOption
+	if authToken != "" {
+		options = append(options, rpc.WithHeader("Authorization", "Bearer "+authToken))
+	}
+	client, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to remote signer: %v", err)
+	}
+
+	s := &RemoteSigner{client: client, address: common.HexToAddress(address)}
+
+	callCtx, cancel := context.WithTimeout(ctx, remoteSignTimeout)
+	defer cancel()
+	var accounts []common.Address
+	if err := client.CallContext(callCtx, &accounts, "eth_accounts"); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to list remote signer accounts: %v", err)
+	}
+	for _, account := range accounts {
+		if account == s.address {
+			return s, nil
+		}
+	}
+	client.Close()
+	return nil, fmt.Errorf("remote signer does not hold account %s", s.address.Hex())
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if tx.Type() != types.DynamicFeeTxType {
+		return nil, fmt.Errorf("remote signer only signs EIP-1559 transactions")
+	}
+
+	args := remoteTxArgs{
+		From:                 s.address,
+		To:                   tx.To(),
+		Gas:                  hexutil.Uint64(tx.Gas()),
+		MaxFeePerGas:         (*hexutil.Big)(tx.GasFeeCap()),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tx.GasTipCap()),
+		Value:                (*hexutil.Big)(tx.Value()),
+		Nonce:                hexutil.Uint64(tx.Nonce()),
+		Data:                 tx.Data(),
+		ChainID:              (*hexutil.Big)(chainID),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteSignTimeout)
+	defer cancel()
+
+	var raw hexutil.Bytes
+	if err := s.client.CallContext(ctx, &raw, "eth_signTransaction", args); err != nil {
+		return nil, fmt.Errorf("remote signer failed: %v", err)
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("invalid transaction from remote signer: %v", err)
+	}
+
+	txSigner := types.LatestSignerForChainID(chainID)
+	if txSigner.Hash(signed) != txSigner.Hash(tx) {
+		return nil, fmt.Errorf("remote signer returned a different transaction")
+	}
+	sender, err := types.Sender(txSigner, signed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from remote signer: %v", err)
+	}
+	if sender != s.address {
+		return nil, fmt.Errorf("remote signer signed with %s instead of %s", sender.Hex(), s.address.Hex())
+	}
+	return signed, nil
+}
+
+// Close disconnects from the remote signer
+func (s *RemoteSigner) Close() {
+	s.client.Close()
+}
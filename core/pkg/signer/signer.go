@@ -0,0 +1,90 @@
+package signer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/pkg/wallet"
+)
+
+// Platform roles that sign transactions. Each role must use its own account.
+const (
+	RoleMinter   = "minter"   // holds MINTER_ROLE on USDK
+	RoleOracle   = "oracle"   // holds ORACLE_ROLE on ProofRegistry
+	RoleTreasury = "treasury" // hot wallet paying out withdrawals and holding USDK
+
+	RoleGasStation = "gas_station" // tops up deposit addresses with gas for sweeping
+)
+
+// Roles returns every signing role
+func Roles() []string {
+	return []string{RoleMinter, RoleOracle, RoleTreasury, RoleGasStation}
+}
+
+// Signer signs transactions for a single account
+type Signer interface {
+	Address() common.Address
+	// SignTx returns tx signed for chainID by the signer's account
+	SignTx(ctx context.Context, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// New creates the signer described by cfg. hd is only needed by the hd type.
+// It returns nil, nil when cfg.Type is empty. When cfg.Address is set, the
+// signer must sign with that account.
+func New(ctx context.Context, cfg config.SignerConfig, hd *wallet.HDWalletService) (Signer, error) {
+	var (
+		s   Signer
+		err error
+	)
+
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "keystore":
+		s, err = NewKeystoreSigner(cfg.KeystorePath, cfg.KeystorePassword)
+	case "hd":
+		if hd == nil {
+			return nil, fmt.Errorf("hd signer requires HD_MNEMONIC")
+		}
+		s, err = NewHDSigner(hd, cfg.HDPath)
+	case "remote":
+		s, err = NewRemoteSigner(ctx, cfg.RemoteURL, cfg.RemoteAuthToken, cfg.Address)
+	case "key":
+		s, err = NewKeySignerFromHex(cfg.PrivateKey)
+	default:
+		return nil, fmt.Errorf("unsupported signer type: %s", cfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Address != "" && common.HexToAddress(cfg.Address) != s.Address() {
+		return nil, fmt.Errorf("%s signer account %s does not match configured %s", cfg.Type, s.Address().Hex(), cfg.Address)
+	}
+	return s, nil
+}
+
+// CheckDistinct fails when two roles sign with the same account
+func CheckDistinct(signers map[string]Signer) error {
+	roles := make([]string, 0, len(signers))
+	for role := range signers {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	seen := make(map[common.Address]string, len(signers))
+	for _, role := range roles {
+		address := signers[role].Address()
+		if other, ok := seen[address]; ok {
+			return fmt.Errorf("%s and %s signers share account %s", other, role, address.Hex())
+		}
+		seen[address] = role
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"usdk-backend/internal/config"
+	"usdk-backend/pkg/wallet"
+)
+
+// hardhatMnemonic is the well-known development mnemonic, whose first
+// Ethereum account is hardhatAccount
+const (
+	hardhatMnemonic = "test test test test test test test test test test test junk"
+	hardhatAccount  = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+)
+
+var testChainID = big.NewInt(1337)
+
+// testTx is an EIP-1559 transaction to sign
+func testTx(nonce uint64) *types.Transaction {
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   testChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(2_000_000_000),
+		GasFeeCap: big.NewInt(100_000_000_000),
+		Gas:       21_000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+}
+
+// writeKeystore encrypts key into a keystore file and returns its path
+func writeKeystore(t *testing.T, key *ecdsa.PrivateKey, password string) string {
+	t.Helper()
+
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(key, password)
+	if err != nil {
+		t.Fatalf("failed to write keystore: %v", err)
+	}
+	return account.URL.Path
+}
+
+func TestNewChecksConfiguredAccount(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	keystorePath := writeKeystore(t, key, "secret")
+	hd, err := wallet.NewHDWalletService(hardhatMnemonic)
+	if err != nil {
+		t.Fatalf("failed to create HD wallet: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.SignerConfig
+		hd      *wallet.HDWalletService
+		address string
+		err     string
+	}{
+		{name: "keystore", cfg: config.SignerConfig{Type: "keystore", KeystorePath: keystorePath, KeystorePassword: "secret"}, address: address},
+		{name: "keystore with its account", cfg: config.SignerConfig{Type: "keystore", KeystorePath: keystorePath, KeystorePassword: "secret", Address: address}, address: address},
+		{name: "keystore with another account", cfg: config.SignerConfig{Type: "keystore", KeystorePath: keystorePath, KeystorePassword: "secret", Address: hardhatAccount}, err: "does not match configured"},
+		{name: "keystore with wrong password", cfg: config.SignerConfig{Type: "keystore", KeystorePath: keystorePath, KeystorePassword: "guess"}, err: "failed to decrypt keystore"},
+		{name: "keystore without path", cfg: config.SignerConfig{Type: "keystore"}, err: "keystore path is required"},
+		{name: "hd", cfg: config.SignerConfig{Type: "hd", HDPath: "m/44'/60'/0'/0/0", Address: hardhatAccount}, hd: hd, address: hardhatAccount},
+		{name: "hd without mnemonic", cfg: config.SignerConfig{Type: "hd", HDPath: "m/44'/60'/0'/0/0"}, err: "requires HD_MNEMONIC"},
+		{name: "hd without path", cfg: config.SignerConfig{Type: "hd"}, hd: hd, err: "derivation path is required"},
+		{name: "key", cfg: config.SignerConfig{Type: "key", PrivateKey: hexutil.Encode(crypto.FromECDSA(key))}, address: address},
+		{name: "remote without account", cfg: config.SignerConfig{Type: "remote", RemoteURL: "http://127.0.0.1:1"}, err: "requires a valid account address"},
+		{name: "unknown type", cfg: config.SignerConfig{Type: "ledger"}, err: "unsupported signer type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(ctx, tt.cfg, tt.hd)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create signer: %v", err)
+			}
+			if s.Address() != common.HexToAddress(tt.address) {
+				t.Fatalf("got account %s, want %s", s.Address().Hex(), tt.address)
+			}
+
+			signed, err := s.SignTx(ctx, testTx(0), testChainID)
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+			if sender, err := types.Sender(types.LatestSignerForChainID(testChainID), signed); err != nil || sender != s.Address() {
+				t.Fatalf("got sender %s (%v), want %s", sender.Hex(), err, s.Address().Hex())
+			}
+		})
+	}
+
+	// An empty type disables the role
+	if s, err := New(ctx, config.SignerConfig{}, nil); s != nil || err != nil {
+		t.Fatalf("got %v, %v for an empty type, want nil, nil", s, err)
+	}
+}
+
+// remoteSignerService is the eth namespace of a web3signer stand-in. It holds
+// key, and tamper changes what it returns from eth_signTransaction.
+type remoteSignerService struct {
+	key    *ecdsa.PrivateKey
+	tamper func(*types.DynamicFeeTx) *ecdsa.PrivateKey
+}
+
+func (s *remoteSignerService) Accounts() []common.Address {
+	return []common.Address{crypto.PubkeyToAddress(s.key.PublicKey)}
+}
+
+func (s *remoteSignerService) SignTransaction(args remoteTxArgs) (hexutil.Bytes, error) {
+	tx := &types.DynamicFeeTx{
+		ChainID:   args.ChainID.ToInt(),
+		Nonce:     uint64(args.Nonce),
+		GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+		GasFeeCap: args.MaxFeePerGas.ToInt(),
+		Gas:       uint64(args.Gas),
+		To:        args.To,
+		Value:     args.Value.ToInt(),
+		Data:      args.Data,
+	}
+	key := s.key
+	if s.tamper != nil {
+		key = s.tamper(tx)
+	}
+	signed, err := types.SignNewTx(key, types.LatestSignerForChainID(tx.ChainID), tx)
+	if err != nil {
+		return nil, err
+	}
+	return signed.MarshalBinary()
+}
+
+// startRemoteSigner serves service over HTTP, refusing requests without the
+// bearer token
+func startRemoteSigner(t *testing.T, service *remoteSignerService, token string) string {
+	t.Helper()
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("failed to register signer service: %v", err)
+	}
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Stop()
+	})
+	return httpServer.URL
+}
+
+func TestRemoteSignerChecksReturnedTransaction(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	// The signer must be reachable with the token and hold the account
+	url := startRemoteSigner(t, &remoteSignerService{key: key}, "token")
+	if _, err := NewRemoteSigner(ctx, url, "wrong", address); err == nil || !strings.Contains(err.Error(), "failed to list remote signer accounts") {
+		t.Fatalf("got %v with a wrong token, want the account listing refused", err)
+	}
+	if _, err := NewRemoteSigner(ctx, url, "token", crypto.PubkeyToAddress(other.PublicKey).Hex()); err == nil || !strings.Contains(err.Error(), "does not hold account") {
+		t.Fatalf("got %v for an account the signer does not hold", err)
+	}
+
+	s, err := New(ctx, config.SignerConfig{Type: "remote", RemoteURL: url, RemoteAuthToken: "token", Address: address}, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to remote signer: %v", err)
+	}
+	tx := testTx(7)
+	signed, err := s.SignTx(ctx, tx, testChainID)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	txSigner := types.LatestSignerForChainID(testChainID)
+	if sender, err := types.Sender(txSigner, signed); err != nil || sender != s.Address() || txSigner.Hash(signed) != txSigner.Hash(tx) {
+		t.Fatalf("got transaction from %s (%v), want the request signed by %s", sender.Hex(), err, address)
+	}
+	if _, err := s.SignTx(ctx, types.NewTx(&types.LegacyTx{Nonce: 7, Gas: 21_000, GasPrice: big.NewInt(1)}), testChainID); err == nil || !strings.Contains(err.Error(), "only signs EIP-1559") {
+		t.Fatalf("got %v for a legacy transaction", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(*types.DynamicFeeTx) *ecdsa.PrivateKey
+		err    string
+	}{
+		{
+			name: "different transaction",
+			tamper: func(tx *types.DynamicFeeTx) *ecdsa.PrivateKey {
+				tx.Value = big.NewInt(1_000_000)
+				return key
+			},
+			err: "returned a different transaction",
+		},
+		{
+			name:   "different account",
+			tamper: func(*types.DynamicFeeTx) *ecdsa.PrivateKey { return other },
+			err:    "signed with " + crypto.PubkeyToAddress(other.PublicKey).Hex(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startRemoteSigner(t, &remoteSignerService{key: key, tamper: tt.tamper}, "token")
+			s, err := NewRemoteSigner(ctx, url, "token", address)
+			if err != nil {
+				t.Fatalf("failed to connect to remote signer: %v", err)
+			}
+			defer s.Close()
+			if _, err := s.SignTx(ctx, testTx(7), testChainID); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckDistinct(t *testing.T) {
+	minterKey, _ := crypto.GenerateKey()
+	oracleKey, _ := crypto.GenerateKey()
+	signers := map[string]Signer{
+		RoleMinter: NewKeySigner(minterKey),
+		RoleOracle: NewKeySigner(oracleKey),
+	}
+	if err := CheckDistinct(signers); err != nil {
+		t.Fatalf("got %v for distinct accounts", err)
+	}
+
+	// The same key loaded twice is the same account
+	signers[RoleTreasury] = NewKeySigner(minterKey)
+	err := CheckDistinct(signers)
+	want := "minter and treasury signers share account " + crypto.PubkeyToAddress(minterKey.PublicKey).Hex()
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %q", err, want)
+	}
+}
@@ -8,8 +8,17 @@ import (
 	"usdk-backend/internal/config"
 )
 
+// AdminAudience marks admin tokens so they are never accepted as user tokens
+const AdminAudience = "usdk-admin"
+
 type Claims struct {
-	UserID uint64 `json:"user_id"`
+	UserID uint64 `json:"user_id,omitempty"`
+
+	// Admin tokens only
+	AdminID     uint64   `json:"admin_id,omitempty"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
@@ -30,6 +39,29 @@ func GenerateJWT(userID uint64) (string, error) {
 	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
 }
 
+// GenerateAdminJWT issues a token for an admin carrying its role and the
+// permissions granted to that role. The claims only describe the admin at
+// login; AdminJWTAuthMiddleware authorises with the role stored now.
+func GenerateAdminJWT(adminID uint64, role string) (string, error) {
+	expirationTime := time.Now().Add(time.Duration(config.AppConfig.JWT.AdminExpireHours) * time.Hour)
+
+	claims := &Claims{
+		AdminID:     adminID,
+		Role:        role,
+		Permissions: RolePermissions(role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "usdk-backend",
+			Audience:  jwt.ClaimStrings{AdminAudience},
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+}
+
 func ParseJWT(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
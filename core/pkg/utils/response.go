@@ -7,6 +7,7 @@ type Response struct {
 	Message   string      `json:"message,omitempty"`
 	Data      interface{} `json:"data,omitempty"`
 	Error     string      `json:"error,omitempty"`
+	Code      string      `json:"code,omitempty"`
 	Timestamp int64       `json:"timestamp"`
 }
 
@@ -44,6 +45,17 @@ func ErrorResponse(message string) Response {
 	}
 }
 
+// ErrorCodeResponse is an error response with a machine-readable code, for
+// errors the client handles by kind
+func ErrorCodeResponse(code, message string) Response {
+	return Response{
+		Success:   false,
+		Error:     message,
+		Code:      code,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
 func PaginatedSuccessResponse(data interface{}, total int64, page, pageSize int) PaginatedResponse {
 	return PaginatedResponse{
 		Success:   true,
@@ -0,0 +1,39 @@
+package utils
+
+// Admin roles
+const (
+	RoleOperator    = "operator"
+	RoleRiskOfficer = "risk_officer"
+	RoleTreasury    = "treasury"
+	RoleAuditor     = "auditor"
+	RoleCustodian   = "custodian" // may decrypt stored deposit keys
+)
+
+// Admin permissions checked by middleware.RequirePermission
+const (
+	PermWithdrawRead   = "withdraw:read"
+	PermWithdrawReview = "withdraw:review" // approve / reject
+	PermWithdrawNotes  = "withdraw:notes"
+	PermTokenWrite     = "token:write" // mint / burn / transfer
+	PermAuditRead      = "audit:read"
+	PermKeyDecrypt     = "key:decrypt" // read a deposit address private key, always audited
+)
+
+var rolePermissions = map[string][]string{
+	RoleOperator:    {PermWithdrawRead, PermWithdrawNotes},
+	RoleRiskOfficer: {PermWithdrawRead, PermWithdrawReview, PermWithdrawNotes},
+	RoleTreasury:    {PermTokenWrite},
+	RoleAuditor:     {PermWithdrawRead, PermAuditRead},
+	RoleCustodian:   {PermKeyDecrypt},
+}
+
+// IsValidRole reports whether role is a known admin role
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns the permissions granted to an admin role
+func RolePermissions(role string) []string {
+	return append([]string(nil), rolePermissions[role]...)
+}
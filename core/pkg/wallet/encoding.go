@@ -0,0 +1,260 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58CheckEncode encodes version || payload with a double SHA-256 checksum
+func base58CheckEncode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	checksum := doubleSHA256(data)
+	return base58Encode(append(data, checksum[:4]...))
+}
+
+// base58CheckDecode decodes a base58check string into its version and payload
+func base58CheckDecode(encoded string) (byte, []byte, error) {
+	data, err := base58Decode(encoded)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 5 {
+		return 0, nil, fmt.Errorf("base58check data too short")
+	}
+	body, checksum := data[:len(data)-4], data[len(data)-4:]
+	expected := doubleSHA256(body)
+	if !bytes.Equal(checksum, expected[:4]) {
+		return 0, nil, ErrInvalidChecksum
+	}
+	return body[0], body[1:], nil
+}
+
+func base58Encode(data []byte) string {
+	num := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for num.Sign() > 0 {
+		num.DivMod(num, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	// Every leading zero byte is written as the zero digit
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+func base58Decode(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("empty base58 string")
+	}
+
+	num := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range encoded {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		num.Mul(num, radix)
+		num.Add(num, big.NewInt(int64(digit)))
+	}
+
+	zeros := 0
+	for zeros < len(encoded) && encoded[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), num.Bytes()...), nil
+}
+
+func doubleSHA256(data []byte) [32]byte {
+	first := sha256.Sum256(data)
+	return sha256.Sum256(first[:])
+}
+
+// hash160 is RIPEMD-160(SHA-256(data)), the key hash of Bitcoin addresses
+func hash160(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(sum[:])
+	return hasher.Sum(nil)
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of BIP-173 (bech32) and BIP-350 (bech32m)
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// bech32Encode encodes 5-bit data with the checksum constant of bech32 or bech32m
+func bech32Encode(hrp string, data []byte, checksumConst uint32) string {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ checksumConst
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		sb.WriteByte(bech32Charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String()
+}
+
+// bech32Decode decodes a bech32 or bech32m string into its lower case HRP,
+// its 5-bit data and the checksum constant it was encoded with
+func bech32Decode(encoded string) (string, []byte, uint32, error) {
+	if len(encoded) > 90 {
+		return "", nil, 0, fmt.Errorf("bech32 string too long")
+	}
+	lower := strings.ToLower(encoded)
+	if encoded != lower && encoded != strings.ToUpper(encoded) {
+		return "", nil, 0, fmt.Errorf("bech32 string has mixed case")
+	}
+
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 || sep+7 > len(lower) {
+		return "", nil, 0, fmt.Errorf("invalid bech32 separator position")
+	}
+
+	hrp := lower[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 prefix character")
+		}
+	}
+
+	data := make([]byte, 0, len(lower)-sep-1)
+	for i := sep + 1; i < len(lower); i++ {
+		d := strings.IndexByte(bech32Charset, lower[i])
+		if d < 0 {
+			return "", nil, 0, fmt.Errorf("invalid bech32 character %q", lower[i])
+		}
+		data = append(data, byte(d))
+	}
+
+	checksumConst := bech32Polymod(append(bech32HRPExpand(hrp), data...))
+	if checksumConst != bech32Const && checksumConst != bech32mConst {
+		return "", nil, 0, ErrInvalidChecksum
+	}
+	return hrp, data[:len(data)-6], checksumConst, nil
+}
+
+// convertBits regroups data from fromBits-bit to toBits-bit groups
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+
+	var converted []byte
+	for _, value := range data {
+		if uint32(value)>>fromBits != 0 {
+			return nil, fmt.Errorf("invalid data value %d", value)
+		}
+		acc = acc<<fromBits | uint32(value)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return converted, nil
+}
+
+// segwitAddress encodes a witness program as a bech32 (v0) or bech32m (v1+) address
+func segwitAddress(hrp string, version byte, program []byte) (string, error) {
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	checksumConst := uint32(bech32Const)
+	if version > 0 {
+		checksumConst = bech32mConst
+	}
+	return bech32Encode(hrp, append([]byte{version}, data...), checksumConst), nil
+}
+
+// decodeSegwitAddress decodes a segwit address of hrp per BIP-173 and BIP-350
+func decodeSegwitAddress(hrp, address string) (byte, []byte, error) {
+	decodedHRP, data, checksumConst, err := bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if decodedHRP != hrp {
+		return 0, nil, fmt.Errorf("address prefix %s is not %s", decodedHRP, hrp)
+	}
+	if len(data) < 1 || data[0] > 16 {
+		return 0, nil, fmt.Errorf("invalid witness version")
+	}
+
+	version := data[0]
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid witness program: %v", err)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return 0, nil, fmt.Errorf("invalid witness program length %d", len(program))
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return 0, nil, fmt.Errorf("invalid witness v0 program length %d", len(program))
+	}
+	if (version == 0 && checksumConst != bech32Const) || (version > 0 && checksumConst != bech32mConst) {
+		return 0, nil, fmt.Errorf("witness version %d uses the wrong checksum", version)
+	}
+	return version, program, nil
+}
@@ -0,0 +1,199 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Chain families, the values of chains.family
+const (
+	FamilyEVM            = "evm"
+	FamilyBitcoin        = "bitcoin"
+	FamilyBitcoinTestnet = "bitcoin_testnet"
+	FamilyTron           = "tron"
+)
+
+// ErrInvalidChecksum is returned for an address that is well formed but whose
+// checksum does not match, usually a mistyped address
+var ErrInvalidChecksum = errors.New("address checksum mismatch")
+
+// ChainFamily derives, validates and formats the addresses of a family of
+// chains. Every family uses secp256k1 keys, so one mnemonic serves them all;
+// they differ in derivation purpose, coin type and address encoding.
+type ChainFamily interface {
+	// Name returns the family name stored in chains.family
+	Name() string
+	// Purpose returns the BIP-43 purpose of the family's derivation paths
+	Purpose() uint32
+	// CoinType returns the SLIP-44 coin type of the family's derivation paths
+	CoinType() uint32
+	// AddressFromPublicKey returns the address of a compressed public key
+	AddressFromPublicKey(publicKey []byte) (string, error)
+	// ValidateAddress reports whether address is a valid address of the family
+	ValidateAddress(address string) bool
+	// FormatAddress validates address and returns its canonical form. A
+	// checksum failure wraps ErrInvalidChecksum.
+	FormatAddress(address string) (string, error)
+}
+
+var (
+	EVM            ChainFamily = evmFamily{}
+	Bitcoin        ChainFamily = bitcoinFamily{name: FamilyBitcoin, hrp: "bc", coinType: 0, p2pkh: 0x00, p2sh: 0x05}
+	BitcoinTestnet ChainFamily = bitcoinFamily{name: FamilyBitcoinTestnet, hrp: "tb", coinType: 1, p2pkh: 0x6f, p2sh: 0xc4}
+	Tron           ChainFamily = tronFamily{}
+)
+
+// LookupFamily returns the chain family of a chains.family value. An empty
+// value is EVM, the family of every chain added before families existed.
+func LookupFamily(name string) (ChainFamily, error) {
+	switch name {
+	case FamilyEVM, "":
+		return EVM, nil
+	case FamilyBitcoin:
+		return Bitcoin, nil
+	case FamilyBitcoinTestnet:
+		return BitcoinTestnet, nil
+	case FamilyTron:
+		return Tron, nil
+	default:
+		return nil, fmt.Errorf("unsupported chain family %q", name)
+	}
+}
+
+// AccountPath returns the hardened path of an HD account of a family,
+// m/{purpose}'/{coin}'/{account}'
+func AccountPath(family ChainFamily, account uint32) string {
+	return fmt.Sprintf("m/%d'/%d'/%d'", family.Purpose(), family.CoinType(), account)
+}
+
+// evmFamily covers Ethereum and the EVM chains: BIP-44 coin type 60 and
+// EIP-55 checksummed hex addresses
+type evmFamily struct{}
+
+func (evmFamily) Name() string     { return FamilyEVM }
+func (evmFamily) Purpose() uint32  { return 44 }
+func (evmFamily) CoinType() uint32 { return 60 }
+
+func (evmFamily) AddressFromPublicKey(publicKey []byte) (string, error) {
+	pub, err := crypto.DecompressPubkey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress public key: %v", err)
+	}
+	return crypto.PubkeyToAddress(*pub).Hex(), nil
+}
+
+func (f evmFamily) ValidateAddress(address string) bool {
+	_, err := f.FormatAddress(address)
+	return err == nil
+}
+
+// FormatAddress enforces EIP-55 on mixed case addresses; all lower or all
+// upper case addresses carry no checksum and are accepted as they are
+func (evmFamily) FormatAddress(address string) (string, error) {
+	if !common.IsHexAddress(address) {
+		return "", fmt.Errorf("invalid EVM address: %s", address)
+	}
+
+	canonical := common.HexToAddress(address).Hex()
+	digits := address[len(address)-2*common.AddressLength:]
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && digits != canonical[2:] {
+		return "", fmt.Errorf("invalid EVM address %s: %w", address, ErrInvalidChecksum)
+	}
+	return canonical, nil
+}
+
+// bitcoinFamily derives native segwit P2WPKH addresses (BIP-84) and accepts
+// segwit, P2PKH and P2SH addresses as destinations
+type bitcoinFamily struct {
+	name     string
+	hrp      string
+	coinType uint32
+	p2pkh    byte
+	p2sh     byte
+}
+
+func (f bitcoinFamily) Name() string     { return f.name }
+func (f bitcoinFamily) Purpose() uint32  { return 84 }
+func (f bitcoinFamily) CoinType() uint32 { return f.coinType }
+
+func (f bitcoinFamily) AddressFromPublicKey(publicKey []byte) (string, error) {
+	if _, err := crypto.DecompressPubkey(publicKey); err != nil {
+		return "", fmt.Errorf("invalid compressed public key: %v", err)
+	}
+	return segwitAddress(f.hrp, 0, hash160(publicKey))
+}
+
+func (f bitcoinFamily) ValidateAddress(address string) bool {
+	_, err := f.FormatAddress(address)
+	return err == nil
+}
+
+// FormatAddress returns segwit addresses in lower case and base58 addresses
+// unchanged
+func (f bitcoinFamily) FormatAddress(address string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(address), f.hrp+"1") {
+		version, program, err := decodeSegwitAddress(f.hrp, address)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s address: %w", f.name, err)
+		}
+		return segwitAddress(f.hrp, version, program)
+	}
+
+	version, payload, err := base58CheckDecode(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s address: %w", f.name, err)
+	}
+	if (version != f.p2pkh && version != f.p2sh) || len(payload) != 20 {
+		return "", fmt.Errorf("invalid %s address: not a P2PKH or P2SH address of this network", f.name)
+	}
+	return address, nil
+}
+
+// tronAddressPrefix is the version byte in front of every Tron address
+const tronAddressPrefix = 0x41
+
+// tronFamily derives Tron addresses: the Ethereum address of a key behind
+// the 0x41 prefix, base58check encoded (T...)
+type tronFamily struct{}
+
+func (tronFamily) Name() string     { return FamilyTron }
+func (tronFamily) Purpose() uint32  { return 44 }
+func (tronFamily) CoinType() uint32 { return 195 }
+
+func (tronFamily) AddressFromPublicKey(publicKey []byte) (string, error) {
+	pub, err := crypto.DecompressPubkey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress public key: %v", err)
+	}
+	return base58CheckEncode(tronAddressPrefix, crypto.PubkeyToAddress(*pub).Bytes()), nil
+}
+
+func (f tronFamily) ValidateAddress(address string) bool {
+	_, err := f.FormatAddress(address)
+	return err == nil
+}
+
+// FormatAddress accepts the base58 form and the 41-prefixed hex form and
+// returns the base58 form
+func (tronFamily) FormatAddress(address string) (string, error) {
+	if len(address) == 2*(common.AddressLength+1) {
+		raw, err := hex.DecodeString(address)
+		if err == nil && raw[0] == tronAddressPrefix {
+			return base58CheckEncode(tronAddressPrefix, raw[1:]), nil
+		}
+	}
+
+	version, payload, err := base58CheckDecode(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid Tron address: %w", err)
+	}
+	if version != tronAddressPrefix || len(payload) != common.AddressLength {
+		return "", fmt.Errorf("invalid Tron address: %s", address)
+	}
+	return address, nil
+}
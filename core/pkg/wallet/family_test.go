@@ -0,0 +1,177 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// generatorPubKey is the compressed public key of private key 1
+const generatorPubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+func TestSegwitAddressVectors(t *testing.T) {
+	// Valid segwit addresses of BIP-173 and BIP-350
+	valid := []struct {
+		hrp     string
+		address string
+		version byte
+		program string
+	}{
+		{"bc", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", 0, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+		{"tb", "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", 0, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", 1, "751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bc", "BC1SW50QGDZ25J", 16, "751e"},
+		{"bc", "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", 2, "751e76e8199196d454941c45d1b3a323"},
+		{"tb", "tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", 1, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+	for _, tt := range valid {
+		t.Run(tt.address, func(t *testing.T) {
+			version, program, err := decodeSegwitAddress(tt.hrp, tt.address)
+			if err != nil {
+				t.Fatalf("valid address rejected: %v", err)
+			}
+			if version != tt.version || hex.EncodeToString(program) != tt.program {
+				t.Fatalf("got version %d program %x, want %d %s", version, program, tt.version, tt.program)
+			}
+			encoded, err := segwitAddress(tt.hrp, version, program)
+			if err != nil || encoded != strings.ToLower(tt.address) {
+				t.Fatalf("re-encoded as %s (%v), want %s", encoded, err, strings.ToLower(tt.address))
+			}
+		})
+	}
+
+	// Invalid segwit addresses of BIP-173 and BIP-350
+	invalid := []struct {
+		name    string
+		hrp     string
+		address string
+	}{
+		{"other prefix", "bc", "tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut"},
+		{"v1 with bech32 checksum", "bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd"},
+		{"v2 with bech32 checksum", "tb", "tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf"},
+		{"v16 with bech32 checksum", "bc", "BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL"},
+		{"v0 with bech32m checksum", "bc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh"},
+		{"v0 with bech32m checksum on testnet", "tb", "tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47"},
+		{"invalid character", "bc", "bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4"},
+		{"witness version 17", "bc", "BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R"},
+		{"program of 1 byte", "bc", "bc1pw5dgrnzv"},
+		{"program of 41 bytes", "bc", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav"},
+		{"v0 program of 16 bytes", "bc", "BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P"},
+		{"mixed case", "tb", "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq"},
+		{"zero padding of more than 4 bits", "bc", "bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du"},
+		{"non-zero padding", "tb", "tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j"},
+		{"empty data", "bc", "bc1gmk9yu"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeSegwitAddress(tt.hrp, tt.address); err == nil {
+				t.Fatalf("invalid address %s accepted", tt.address)
+			}
+		})
+	}
+}
+
+func TestFamilyAddressesFromPublicKey(t *testing.T) {
+	publicKey, _ := hex.DecodeString(generatorPubKey)
+
+	tests := []struct {
+		family ChainFamily
+		want   string
+	}{
+		{EVM, "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf"},
+		{Bitcoin, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{BitcoinTestnet, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.family.Name(), func(t *testing.T) {
+			address, err := tt.family.AddressFromPublicKey(publicKey)
+			if err != nil {
+				t.Fatalf("failed to derive address: %v", err)
+			}
+			if address != tt.want {
+				t.Fatalf("got %s, want %s", address, tt.want)
+			}
+		})
+	}
+
+	// A Tron address is the key's Ethereum address behind the 0x41 prefix
+	pub, _ := crypto.DecompressPubkey(publicKey)
+	tron, err := Tron.AddressFromPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("failed to derive Tron address: %v", err)
+	}
+	fromHex, err := Tron.FormatAddress("41" + hex.EncodeToString(crypto.PubkeyToAddress(*pub).Bytes()))
+	if err != nil || tron != fromHex || !strings.HasPrefix(tron, "T") {
+		t.Fatalf("got Tron address %s, hex form formats as %s (%v)", tron, fromHex, err)
+	}
+}
+
+func TestFormatAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		family   ChainFamily
+		address  string
+		want     string
+		checksum bool // fails with ErrInvalidChecksum
+	}{
+		// EIP-55
+		{name: "EIP-55 checksummed", family: EVM, address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", want: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{name: "EIP-55 checksummed 2", family: EVM, address: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", want: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"},
+		{name: "EIP-55 checksummed 3", family: EVM, address: "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", want: "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"},
+		{name: "EIP-55 checksummed 4", family: EVM, address: "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", want: "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb"},
+		{name: "EVM lower case", family: EVM, address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", want: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{name: "EVM upper case", family: EVM, address: "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", want: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{name: "EVM bad checksum", family: EVM, address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", checksum: true},
+		{name: "EVM too short", family: EVM, address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA"},
+
+		// Base58check and segwit Bitcoin addresses
+		{name: "P2PKH", family: Bitcoin, address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", want: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{name: "P2SH", family: Bitcoin, address: "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", want: "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"},
+		{name: "testnet P2PKH", family: BitcoinTestnet, address: "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn", want: "mipcBbFg9gMiCh81Kj8tqqdgoZub1ZJRfn"},
+		{name: "testnet P2SH", family: BitcoinTestnet, address: "2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc", want: "2MzQwSSnBHWHqSAqtTVQ6v47XtaisrJa1Vc"},
+		{name: "P2WPKH upper case", family: Bitcoin, address: "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", want: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{name: "P2TR", family: Bitcoin, address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", want: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{name: "P2PKH bad checksum", family: Bitcoin, address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", checksum: true},
+		{name: "P2WPKH bad checksum", family: Bitcoin, address: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", checksum: true},
+		{name: "mainnet P2PKH on testnet", family: BitcoinTestnet, address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{name: "testnet segwit on mainnet", family: Bitcoin, address: "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{name: "taproot with bech32 checksum", family: Bitcoin, address: "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd"},
+
+		// Tron
+		{name: "Tron base58", family: Tron, address: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeL", want: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeL"},
+		{name: "Tron hex", family: Tron, address: "418840E6C55B9ADA326D211D818C34A994AECED808", want: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeL"},
+		{name: "Tron bad checksum", family: Tron, address: "TNPeeaaFB7K9cmo4uQpcU32zGK8G1NYqeM", checksum: true},
+		{name: "Bitcoin address on Tron", family: Tron, address: "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{name: "EVM address on Tron", family: Tron, address: "0x8840E6C55B9ADA326D211D818C34A994AECED808"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.family.FormatAddress(tt.address)
+			if tt.want != "" {
+				if err != nil || got != tt.want {
+					t.Fatalf("got %s (%v), want %s", got, err, tt.want)
+				}
+				if !tt.family.ValidateAddress(tt.address) {
+					t.Fatalf("ValidateAddress rejects %s", tt.address)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("invalid address accepted as %s", got)
+			}
+			if errors.Is(err, ErrInvalidChecksum) != tt.checksum {
+				t.Fatalf("got error %v, checksum failure %v", err, tt.checksum)
+			}
+			if tt.family.ValidateAddress(tt.address) {
+				t.Fatalf("ValidateAddress accepts %s", tt.address)
+			}
+		})
+	}
+}
@@ -1,10 +1,10 @@
 package wallet
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/tyler-smith/go-bip32"
 	"github.com/tyler-smith/go-bip39"
@@ -57,33 +57,62 @@ func GenerateMnemonic() (string, error) {
 	return mnemonic, nil
 }
 
-// DeriveAddress derives an Ethereum address at the given derivation path
-func (h *HDWalletService) DeriveAddress(derivationPath string) (string, error) {
+// DeriveAddress derives the address of a chain family at the given derivation path
+func (h *HDWalletService) DeriveAddress(family ChainFamily, derivationPath string) (string, error) {
 	key, err := h.deriveKey(derivationPath)
 	if err != nil {
 		return "", err
 	}
 
-	// Convert to ECDSA private key
-	privateKey, err := crypto.ToECDSA(key.Key)
+	// Encode the compressed public key as an address of the family
+	return family.AddressFromPublicKey(key.PublicKey().Key)
+}
+
+// DepositAccountOffset is added to the chain ID to get the HD account of a
+// chain's deposit addresses. Lower accounts are reserved: account 0 holds the
+// legacy m/44'/60'/0'/0/{userID} deposit addresses and the others are left to
+// platform signers. Chain IDs here are chains table IDs, unique across families.
+const DepositAccountOffset = 100
+
+// DepositPath returns the derivation path of a deposit address:
+// m/{purpose}'/{coin}'/{DepositAccountOffset+chainID}'/{assetID}/{index}, with
+// the purpose and coin type of the chain family (m/44'/60' for EVM chains,
+// m/84'/0' for Bitcoin, m/44'/195' for Tron). Every chain has its own account
+// and every asset its own branch, so an address is never shared between chains
+// or assets.
+func DepositPath(family ChainFamily, chainID, assetID uint64, index uint32) (string, error) {
+	account, err := DepositAccount(chainID)
 	if err != nil {
-		return "", fmt.Errorf("failed to convert to ECDSA key: %v", err)
+		return "", err
+	}
+	if assetID >= uint64(bip32.FirstHardenedChild) {
+		return "", fmt.Errorf("asset ID %d is too large for a derivation branch", assetID)
+	}
+	if index >= bip32.FirstHardenedChild {
+		return "", fmt.Errorf("address index %d is out of range", index)
 	}
+	return fmt.Sprintf("%s/%d/%d", AccountPath(family, account), assetID, index), nil
+}
 
-	// Generate Ethereum address
-	address := crypto.PubkeyToAddress(privateKey.PublicKey)
-	return address.Hex(), nil
+// DepositAccount returns the HD account of a chain's deposit addresses
+func DepositAccount(chainID uint64) (uint32, error) {
+	account := DepositAccountOffset + chainID
+	if account >= uint64(bip32.FirstHardenedChild) {
+		return 0, fmt.Errorf("chain ID %d is too large for a derivation account", chainID)
+	}
+	return uint32(account), nil
 }
 
-// DeriveAddressForUser derives an address for a specific user ID
-func (h *HDWalletService) DeriveAddressForUser(userID uint64, chainKey, assetSymbol string) (string, string, error) {
-	// Standard Ethereum derivation path: m/44'/60'/0'/0/{userID}
-	// We can add chain and asset specific paths if needed
-	derivationPath := fmt.Sprintf("m/44'/60'/0'/0/%d", userID)
+// DeriveDepositAddress derives the deposit address at index of a chain and asset
+func (h *HDWalletService) DeriveDepositAddress(family ChainFamily, chainID, assetID uint64, index uint32) (string, string, error) {
+	derivationPath, err := DepositPath(family, chainID, assetID, index)
+	if err != nil {
+		return "", "", err
+	}
 
-	address, err := h.DeriveAddress(derivationPath)
+	address, err := h.DeriveAddress(family, derivationPath)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to derive address for user %d: %v", userID, err)
+		return "", "", fmt.Errorf("failed to derive deposit address %s: %v", derivationPath, err)
 	}
 
 	return address, derivationPath, nil
@@ -107,6 +136,20 @@ func (h *HDWalletService) DerivePrivateKey(derivationPath string) (string, error
 	return fmt.Sprintf("0x%x", crypto.FromECDSA(privateKey)), nil
 }
 
+// DeriveECDSAKey derives the signing key at the given derivation path
+func (h *HDWalletService) DeriveECDSAKey(derivationPath string) (*ecdsa.PrivateKey, error) {
+	key, err := h.deriveKey(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := crypto.ToECDSA(key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert to ECDSA key: %v", err)
+	}
+	return privateKey, nil
+}
+
 // GetPublicKey gets the public key for a derivation path
 func (h *HDWalletService) GetPublicKey(derivationPath string) (string, error) {
 	key, err := h.deriveKey(derivationPath)
@@ -118,9 +161,10 @@ func (h *HDWalletService) GetPublicKey(derivationPath string) (string, error) {
 	return fmt.Sprintf("0x%x", publicKey.Key), nil
 }
 
-// ValidateAddress validates an Ethereum address
+// ValidateAddress validates an Ethereum address. Addresses of other chains are
+// validated by their ChainFamily.
 func ValidateAddress(address string) bool {
-	return common.IsHexAddress(address)
+	return EVM.ValidateAddress(address)
 }
 
 // deriveKey derives a key at the given derivation path
@@ -142,9 +186,9 @@ func (h *HDWalletService) deriveKey(derivationPath string) (*bip32.Key, error) {
 	return key, nil
 }
 
-// GenerateRandomAddress generates a completely random Ethereum address (not HD)
+// GenerateRandomAddress generates a completely random address of a chain family (not HD)
 // This is useful for testing or when HD wallet is not desired
-func GenerateRandomAddress() (string, string, error) {
+func GenerateRandomAddress(family ChainFamily) (string, string, error) {
 	// Generate random private key
 	privateKey, err := crypto.GenerateKey()
 	if err != nil {
@@ -152,12 +196,29 @@ func GenerateRandomAddress() (string, string, error) {
 	}
 
 	// Get address
-	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+	address, err := family.AddressFromPublicKey(crypto.CompressPubkey(&privateKey.PublicKey))
+	if err != nil {
+		return "", "", err
+	}
 
 	// Get private key hex
 	privateKeyHex := fmt.Sprintf("0x%x", crypto.FromECDSA(privateKey))
 
-	return address.Hex(), privateKeyHex, nil
+	return address, privateKeyHex, nil
+}
+
+// AccountXpub returns the extended public key of an account of a chain family,
+// m/{purpose}'/{coin}'/{account}', for configuring a WatchWallet. The master
+// xpub in HDWalletInfo cannot be used for that, as accounts are hardened.
+func (h *HDWalletService) AccountXpub(family ChainFamily, account uint32) (string, error) {
+	if account >= bip32.FirstHardenedChild {
+		return "", fmt.Errorf("account %d is out of range", account)
+	}
+	key, err := h.deriveKey(AccountPath(family, account))
+	if err != nil {
+		return "", err
+	}
+	return key.PublicKey().B58Serialize(), nil
 }
 
 // HDWalletInfo contains information about the HD wallet
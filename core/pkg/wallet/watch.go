@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/tyler-smith/go-bip32"
+)
+
+// ErrAccountNotWatched is returned for paths under an account without an xpub
+var ErrAccountNotWatched = errors.New("no extended public key for account")
+
+// AddressDeriver derives deposit addresses. HDWalletService derives them from
+// the mnemonic, WatchWallet from account extended public keys only.
+type AddressDeriver interface {
+	DeriveAddress(family ChainFamily, derivationPath string) (string, error)
+	DeriveDepositAddress(family ChainFamily, chainID, assetID uint64, index uint32) (string, string, error)
+}
+
+// WatchWallet derives addresses from neutered account keys
+// (m/{purpose}'/{coin}'/{account}' of the chain's family), so the service
+// deriving deposit addresses holds no private key material. Keys for those
+// addresses are derived by the signing component that holds the mnemonic.
+type WatchWallet struct {
+	accounts map[uint32]*bip32.Key
+}
+
+// NewWatchWallet creates a watch-only wallet from account extended public
+// keys. Each key must be the xpub of the account in the family of the chain
+// the account belongs to, e.g. m/44'/60'/{account}' for an EVM chain.
+func NewWatchWallet(xpubs map[uint32]string) (*WatchWallet, error) {
+	if len(xpubs) == 0 {
+		return nil, fmt.Errorf("at least one account extended public key is required")
+	}
+
+	keys := make(map[uint32]*bip32.Key, len(xpubs))
+	for account, xpub := range xpubs {
+		key, err := bip32.B58Deserialize(xpub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extended public key for account %d: %v", account, err)
+		}
+		if key.IsPrivate {
+			return nil, fmt.Errorf("account %d: an extended private key was given, only xpubs are allowed", account)
+		}
+		// The key must sit at m/{purpose}'/{coin}'/{account}'
+		if key.Depth != 3 || binary.BigEndian.Uint32(key.ChildNumber) != bip32.FirstHardenedChild+account {
+			return nil, fmt.Errorf("extended public key is not the key of account %d' (m/{purpose}'/{coin}'/%d')", account, account)
+		}
+		keys[account] = key
+	}
+
+	return &WatchWallet{accounts: keys}, nil
+}
+
+// ParseAccountXpubs parses a "<account>:<xpub>,..." list of account keys
+func ParseAccountXpubs(spec string) (map[uint32]string, error) {
+	xpubs := make(map[uint32]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		accountStr, xpub, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("xpub entry must be <account>:<xpub>")
+		}
+		account, err := strconv.ParseUint(accountStr, 10, 32)
+		if err != nil || account >= uint64(bip32.FirstHardenedChild) {
+			return nil, fmt.Errorf("invalid account %q", accountStr)
+		}
+		if _, exists := xpubs[uint32(account)]; exists {
+			return nil, fmt.Errorf("duplicate xpub for account %d", account)
+		}
+		xpubs[uint32(account)] = strings.TrimSpace(xpub)
+	}
+	return xpubs, nil
+}
+
+// DeriveAddress derives the address of a chain family at a path below a
+// watched account, m/{purpose}'/{coin}'/{account}'/... with no hardened step
+// after the account
+func (w *WatchWallet) DeriveAddress(family ChainFamily, derivationPath string) (string, error) {
+	path, err := accounts.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid derivation path %s: %v", derivationPath, err)
+	}
+	if len(path) < 3 || path[0] != bip32.FirstHardenedChild+family.Purpose() ||
+		path[1] != bip32.FirstHardenedChild+family.CoinType() || path[2] < bip32.FirstHardenedChild {
+		return "", fmt.Errorf("derivation path %s is not below m/%d'/%d'/{account}'", derivationPath, family.Purpose(), family.CoinType())
+	}
+
+	account := path[2] - bip32.FirstHardenedChild
+	key, ok := w.accounts[account]
+	if !ok {
+		return "", fmt.Errorf("%w %d'", ErrAccountNotWatched, account)
+	}
+
+	for _, index := range path[3:] {
+		if index >= bip32.FirstHardenedChild {
+			return "", fmt.Errorf("derivation path %s has a hardened step below the account", derivationPath)
+		}
+		key, err = key.NewChildKey(index)
+		if err != nil {
+			return "", fmt.Errorf("failed to derive child key at index %d: %v", index, err)
+		}
+	}
+
+	return family.AddressFromPublicKey(key.Key)
+}
+
+// DeriveDepositAddress derives the deposit address at index of a chain and asset
+func (w *WatchWallet) DeriveDepositAddress(family ChainFamily, chainID, assetID uint64, index uint32) (string, string, error) {
+	derivationPath, err := DepositPath(family, chainID, assetID, index)
+	if err != nil {
+		return "", "", err
+	}
+
+	address, err := w.DeriveAddress(family, derivationPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to derive deposit address %s: %v", derivationPath, err)
+	}
+
+	return address, derivationPath, nil
+}
@@ -11,6 +11,8 @@ CREATE TABLE users (
   wallet_addr VARCHAR(128) UNIQUE,
   email VARCHAR(128),
   nonce VARCHAR(64) COMMENT 'for SIWE login',
+  whitelist_only BOOLEAN DEFAULT FALSE COMMENT 'restrict withdrawals to whitelisted addresses',
+  whitelist_off_at TIMESTAMP NULL COMMENT 'turning whitelist-only off takes effect at this time',
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   INDEX idx_wallet_addr (wallet_addr)
@@ -21,6 +23,7 @@ CREATE TABLE chains (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
   chain_key VARCHAR(32) UNIQUE NOT NULL COMMENT 'ethereum, arbitrum, optimism',
   chain_id BIGINT NOT NULL COMMENT '1, 42161, 10',
+  family VARCHAR(16) NOT NULL DEFAULT 'evm' COMMENT 'evm, bitcoin, bitcoin_testnet, tron',
   name VARCHAR(64) NOT NULL,
   rpc_url VARCHAR(256),
   explorer_base VARCHAR(128),
@@ -65,8 +68,8 @@ CREATE TABLE deposit_addresses (
   chain_id BIGINT NOT NULL,
   asset_id BIGINT NOT NULL,
   address VARCHAR(128) UNIQUE NOT NULL,
-  derivation_path VARCHAR(128) COMMENT 'HD/MPC metadata',
-  private_key_ref VARCHAR(256) COMMENT 'encrypted or KMS reference',
+  derivation_path VARCHAR(128) COMMENT 'm/{purpose}''/{coin}''/{100+chain}''/{asset}/{index} by chain family, legacy m/44''/60''/0''/0/{user}',
+  private_key_ref VARCHAR(256) COMMENT 'sealed by keyvault (kek<version>:...) or KMS reference',
   is_active BOOLEAN DEFAULT TRUE,
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   UNIQUE KEY uk_user_chain_asset (user_id, chain_id, asset_id),
@@ -76,24 +79,39 @@ CREATE TABLE deposit_addresses (
   FOREIGN KEY (asset_id) REFERENCES assets(id)
 ) COMMENT '用户专属充值地址';
 
+-- HD充值地址索引分配（每条链每个资产一个计数器）
+CREATE TABLE deposit_address_counters (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  chain_id BIGINT NOT NULL,
+  asset_id BIGINT NOT NULL,
+  next_index INT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'next unused address index',
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_counter_chain_asset (chain_id, asset_id),
+  FOREIGN KEY (chain_id) REFERENCES chains(id),
+  FOREIGN KEY (asset_id) REFERENCES assets(id)
+) COMMENT 'HD充值地址索引计数器';
+
 -- 链上充值与提现记录（原始交易）
 CREATE TABLE onchain_txs (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
-  direction VARCHAR(8) NOT NULL COMMENT 'in or out',
+  direction VARCHAR(8) NOT NULL COMMENT 'in, out or sweep',
   user_id BIGINT,
   chain_id BIGINT NOT NULL,
   asset_id BIGINT NOT NULL,
   from_addr VARCHAR(128),
   to_addr VARCHAR(128) NOT NULL,
-  tx_hash VARCHAR(128) UNIQUE NOT NULL,
+  tx_hash VARCHAR(128) NOT NULL,
+  log_index INT NOT NULL DEFAULT -1 COMMENT 'ERC-20 log index, -1 for native transfers',
   amount DECIMAL(38,18) NOT NULL,
   block_num BIGINT,
+  block_hash VARCHAR(66),
   gas_used BIGINT,
   gas_price DECIMAL(38,18),
   status VARCHAR(16) DEFAULT 'pending' COMMENT 'pending, confirmed, failed',
   confirmations INT DEFAULT 0,
   seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   confirmed_at TIMESTAMP NULL,
+  UNIQUE KEY uk_tx_log (tx_hash, log_index),
   INDEX idx_user_direction (user_id, direction),
   INDEX idx_chain_block (chain_id, block_num),
   INDEX idx_tx_hash (tx_hash),
@@ -103,6 +121,73 @@ CREATE TABLE onchain_txs (
   FOREIGN KEY (asset_id) REFERENCES assets(id)
 ) COMMENT '链上交易记录';
 
+-- 交易管理器发出的交易
+CREATE TABLE outgoing_txs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  chain_id BIGINT NOT NULL,
+  from_addr VARCHAR(42) NOT NULL,
+  nonce BIGINT NOT NULL,
+  to_addr VARCHAR(42) NOT NULL,
+  value DECIMAL(65,0) DEFAULT 0 COMMENT 'wei',
+  data BLOB,
+  gas_limit BIGINT NOT NULL,
+  max_fee_per_gas DECIMAL(65,0) NOT NULL COMMENT 'wei',
+  max_priority_fee_per_gas DECIMAL(65,0) NOT NULL COMMENT 'wei',
+  tx_hash VARCHAR(66) NOT NULL COMMENT '最新一次签名或已上链的哈希',
+  purpose VARCHAR(32) NOT NULL COMMENT 'withdrawal, proof_batch, mint, burn, transfer, sweep, sweep_gas',
+  ref_id BIGINT COMMENT '关联业务记录 ID',
+  status VARCHAR(16) DEFAULT 'pending' COMMENT 'pending, mined, confirmed, reverted, dropped',
+  attempts INT DEFAULT 0,
+  last_error VARCHAR(512),
+  block_num BIGINT,
+  gas_used BIGINT,
+  effective_gas_price DECIMAL(65,0),
+  broadcast_at TIMESTAMP NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  finalized_at TIMESTAMP NULL,
+  UNIQUE KEY idx_chain_from_nonce (chain_id, from_addr, nonce),
+  INDEX idx_tx_hash (tx_hash),
+  INDEX idx_purpose_ref (purpose, ref_id),
+  INDEX idx_status (status),
+  FOREIGN KEY (chain_id) REFERENCES chains(id)
+) COMMENT '交易管理器发出的交易';
+
+-- 同一 nonce 的每次签名（含加价替换）
+CREATE TABLE outgoing_tx_attempts (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  outgoing_tx_id BIGINT NOT NULL,
+  tx_hash VARCHAR(66) UNIQUE NOT NULL,
+  max_fee_per_gas DECIMAL(65,0) NOT NULL,
+  max_priority_fee_per_gas DECIMAL(65,0) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_outgoing_tx (outgoing_tx_id),
+  FOREIGN KEY (outgoing_tx_id) REFERENCES outgoing_txs(id) ON DELETE CASCADE
+) COMMENT '交易签名记录';
+
+-- 链上扫描进度
+CREATE TABLE chain_sync_states (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  chain_id BIGINT NOT NULL,
+  worker VARCHAR(32) NOT NULL COMMENT 'deposit, proof_indexer',
+  last_block BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_chain_worker (chain_id, worker),
+  FOREIGN KEY (chain_id) REFERENCES chains(id)
+) COMMENT '链上扫描进度';
+
+-- 已扫描区块哈希（链重组检测）
+CREATE TABLE chain_blocks (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  chain_id BIGINT NOT NULL,
+  block_num BIGINT NOT NULL,
+  block_hash VARCHAR(66) NOT NULL,
+  parent_hash VARCHAR(66) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_chain_block_num (chain_id, block_num),
+  FOREIGN KEY (chain_id) REFERENCES chains(id)
+) COMMENT '已扫描区块哈希';
+
 -- 估值快照（用户资产 -> KUSD）
 CREATE TABLE valuations (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -143,7 +228,7 @@ CREATE TABLE price_feeds (
 CREATE TABLE ledger_entries (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
   user_id BIGINT NOT NULL,
-  entry_type VARCHAR(16) NOT NULL COMMENT 'deposit, withdraw, yield, trade, fee',
+  entry_type VARCHAR(16) NOT NULL COMMENT 'deposit, withdraw, yield, trade, fee, reversal',
   chain_id BIGINT,
   asset_id BIGINT,
   amount DECIMAL(38,18) NOT NULL COMMENT '正负值',
@@ -153,11 +238,14 @@ CREATE TABLE ledger_entries (
   ref_onchain_tx_id BIGINT,
   proof_root VARCHAR(128) COMMENT '对应批次 Merkle 根',
   batch_id BIGINT COMMENT '关联 proof_batches',
+  reversal_of_id BIGINT COMMENT '冲正记录对应的原始记录',
   metadata JSON COMMENT '额外信息',
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   INDEX idx_user_type_time (user_id, entry_type, created_at),
   INDEX idx_proof_root (proof_root),
   INDEX idx_batch_id (batch_id),
+  INDEX idx_ref_onchain_tx (ref_onchain_tx_id),
+  INDEX idx_reversal_of (reversal_of_id),
   FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
   FOREIGN KEY (chain_id) REFERENCES chains(id),
   FOREIGN KEY (asset_id) REFERENCES assets(id),
@@ -167,24 +255,32 @@ CREATE TABLE ledger_entries (
 -- 批次证明（上链）
 CREATE TABLE proof_batches (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
-  batch_type VARCHAR(16) NOT NULL COMMENT 'deposit, yield, trade, withdraw',
+  batch_type VARCHAR(16) NOT NULL COMMENT 'deposit, yield, trade, withdraw, reserves',
   period_start TIMESTAMP NOT NULL,
   period_end TIMESTAMP NOT NULL,
   merkle_root VARCHAR(128) UNIQUE NOT NULL,
   oracle_sig VARCHAR(512) COMMENT '预言机签名',
   onchain_tx_hash VARCHAR(128) COMMENT '上链交易哈希',
+  publish_attempts INT DEFAULT 0 COMMENT '已发送的 publishBatch 交易次数',
+  onchain_batch_id BIGINT COMMENT 'ProofRegistry 合约内的 batchId',
+  onchain_root VARCHAR(128) COMMENT '链上根与 merkle_root 不一致时记录链上根',
+  divergent BOOLEAN DEFAULT FALSE COMMENT '链上根与本地不一致',
+  verified BOOLEAN DEFAULT FALSE COMMENT '链上已验证（BatchVerified）',
+  revoke_reason VARCHAR(512) COMMENT '链上撤销原因（BatchRevoked）',
   chain_id BIGINT,
   contract_addr VARCHAR(128) COMMENT 'ProofRegistry 合约地址',
   block_num BIGINT,
   gas_used BIGINT,
-  status VARCHAR(16) DEFAULT 'pending' COMMENT 'pending, confirmed, failed',
+  status VARCHAR(16) DEFAULT 'pending' COMMENT 'pending, confirmed, failed, revoked, recorded',
   ipfs_hash VARCHAR(64) COMMENT '详细数据的 IPFS 哈希',
   entry_count INT DEFAULT 0 COMMENT '本批次包含的记录数',
+  metadata JSON COMMENT '储备快照（reserves 批次）或其他预言机批次的发布者与 URI',
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   published_at TIMESTAMP NULL,
   INDEX idx_type_period (batch_type, period_start, period_end),
   INDEX idx_merkle_root (merkle_root),
   INDEX idx_chain_block (chain_id, block_num),
+  UNIQUE KEY idx_chain_onchain_batch (chain_id, onchain_batch_id),
   FOREIGN KEY (chain_id) REFERENCES chains(id)
 ) COMMENT '批次证明';
 
@@ -197,12 +293,14 @@ CREATE TABLE withdraw_requests (
   amount DECIMAL(38,18) NOT NULL,
   to_address VARCHAR(128) NOT NULL,
   fee DECIMAL(38,18) DEFAULT 0,
+  kusd_reserved DECIMAL(38,18) DEFAULT 0 COMMENT 'KUSD held from the balance while processing',
   status VARCHAR(16) DEFAULT 'pending' COMMENT 'pending, approved, rejected, processing, completed, failed',
   risk_score DECIMAL(4,2) COMMENT '风控评分',
   admin_notes TEXT,
   tx_hash VARCHAR(128) COMMENT '实际提现交易哈希',
   ledger_entry_id BIGINT,
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  claimed_at TIMESTAMP NULL COMMENT 'when the executor moved it to processing',
   processed_at TIMESTAMP NULL,
   INDEX idx_user_status (user_id, status),
   INDEX idx_status_created (status, created_at),
@@ -212,6 +310,32 @@ CREATE TABLE withdraw_requests (
   FOREIGN KEY (ledger_entry_id) REFERENCES ledger_entries(id)
 ) COMMENT '提现申请表';
 
+-- 用户KYC状态
+CREATE TABLE user_kyc (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNIQUE NOT NULL,
+  level INT NOT NULL DEFAULT 0 COMMENT 'approved level: 0 none, 1 basic, 2 full',
+  status VARCHAR(16) NOT NULL DEFAULT 'none' COMMENT 'none, pending, approved, rejected',
+  requested_level INT NOT NULL DEFAULT 0,
+  provider VARCHAR(32),
+  applicant_id VARCHAR(128),
+  reject_reason TEXT,
+  reviewed_at TIMESTAMP NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_applicant (applicant_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) COMMENT '用户KYC状态';
+
+-- 已处理的KYC回调事件（防重放）
+CREATE TABLE kyc_webhook_events (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  provider VARCHAR(32) NOT NULL,
+  event_id VARCHAR(128) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY idx_kyc_event (provider, event_id)
+) COMMENT '已处理的KYC回调事件';
+
 -- 用户白名单（提现地址）
 CREATE TABLE withdrawal_whitelist (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -220,6 +344,7 @@ CREATE TABLE withdrawal_whitelist (
   address VARCHAR(128) NOT NULL,
   label VARCHAR(64),
   is_active BOOLEAN DEFAULT TRUE,
+  activates_at TIMESTAMP NOT NULL COMMENT 'end of the cooling-off period',
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   UNIQUE KEY uk_user_chain_address (user_id, chain_id, address),
   FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
@@ -258,10 +383,23 @@ CREATE TABLE system_configs (
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 ) COMMENT '系统配置';
 
+-- 后台管理员
+CREATE TABLE admin_users (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  username VARCHAR(64) UNIQUE NOT NULL,
+  password_hash VARCHAR(128) NOT NULL COMMENT 'bcrypt',
+  role VARCHAR(32) NOT NULL COMMENT 'operator, risk_officer, treasury, auditor, custodian',
+  enabled BOOLEAN DEFAULT TRUE,
+  last_login_at TIMESTAMP NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) COMMENT '后台管理员';
+
 -- 审计日志
 CREATE TABLE audit_logs (
   id BIGINT AUTO_INCREMENT PRIMARY KEY,
   user_id BIGINT,
+  admin_id BIGINT COMMENT 'set for actions taken by an admin',
   action VARCHAR(64) NOT NULL,
   resource_type VARCHAR(32),
   resource_id VARCHAR(64),
@@ -272,7 +410,9 @@ CREATE TABLE audit_logs (
   created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   INDEX idx_user_created (user_id, created_at),
   INDEX idx_action_created (action, created_at),
-  FOREIGN KEY (user_id) REFERENCES users(id)
+  INDEX idx_admin_created (admin_id, created_at),
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (admin_id) REFERENCES admin_users(id)
 ) COMMENT '审计日志';
 
 -- 插入初始数据
@@ -283,6 +423,16 @@ INSERT INTO chains (chain_key, chain_id, name, explorer_base, enabled) VALUES
 ('polygon', 137, 'Polygon', 'https://polygonscan.com', TRUE),
 ('base', 8453, 'Base', 'https://basescan.org', TRUE);
 
+-- Sepolia 测试网部署（启用后通过 SEPOLIA_RPC_URL 连接）
+INSERT INTO chains (chain_key, chain_id, name, explorer_base, usdk_contract, proof_registry, enabled) VALUES
+('sepolia', 11155111, 'Sepolia Testnet', 'https://sepolia.etherscan.io',
+ '0xAeE3625b0E6a4FfAc196d4DCB51dCe7568dD6353', '0x4699ED32Ab75A7B7f8c74eAE88EF1EB02BFa55da', FALSE);
+
+-- 非EVM链（充值地址按链族派生，扫描与归集暂未接入）
+INSERT INTO chains (chain_key, chain_id, family, name, explorer_base, enabled) VALUES
+('bitcoin', 0, 'bitcoin', 'Bitcoin', 'https://mempool.space', FALSE),
+('tron', 728126428, 'tron', 'Tron', 'https://tronscan.org', FALSE);
+
 INSERT INTO assets (symbol, name, decimals, asset_type, min_deposit, enabled) VALUES
 ('USDC', 'USD Coin', 6, 'stable', 10, TRUE),
 ('USDT', 'Tether USD', 6, 'stable', 10, TRUE),
@@ -322,5 +472,8 @@ INSERT INTO system_configs (config_key, config_value, config_type, description)
 INSERT INTO risk_configs (config_key, config_value, description) VALUES
 ('max_daily_deposit', '100000', 'Maximum daily deposit per user in KUSD'),
 ('kyc_withdrawal_limit', '1000', 'Withdrawal limit without KYC in KUSD'),
+('risk_rule.kyc_required', '{"enabled":true,"weight":70,"threshold":"1000","block":true,"params":{"level1Limit":10000,"level2Limit":0}}', 'Per-withdrawal limits by KYC level: none / basic / full (0 = unlimited)'),
 ('suspicious_pattern_threshold', '10000', 'Threshold for suspicious pattern detection'),
-('aml_check_enabled', 'true', 'Enable AML checks for transactions');
\ No newline at end of file
+('aml_check_enabled', 'true', 'Enable AML checks for transactions'),
+('risk_decision', '{"rejectScore":80,"reviewScore":50,"reviewHoldHours":24,"delayScore":30,"delayHours":1}', 'Risk score thresholds for reject / manual review / delay'),
+('risk_rule.weekly_velocity', '{"enabled":true,"weight":35,"threshold":"100000"}', 'Weekly withdrawal velocity rule; other rules are tuned via risk_rule.<name>');
\ No newline at end of file